@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	log "github.com/sirupsen/logrus"
+)
+
+// hasAdminPolicy checks whether the token is allowed to use the admin /
+// diagnostic endpoints
+func hasAdminPolicy(tok string) (bool, error) {
+	if cfg.Admin.Policy == "" {
+		return false, nil
+	}
+
+	if cfg.Source == sourceFile {
+		// Without Vault there are no policies, enabling admin features
+		// enables them for everyone having access
+		return true, nil
+	}
+
+	policies, err := tokenPolicies(tok)
+	if err != nil {
+		return false, err
+	}
+
+	return hasAnyPolicy(policies, []string{cfg.Admin.Policy}), nil
+}
+
+// secretFingerprint calculates a salted hash of the secret which is
+// equal for equal secrets regardless of their notation (case, spaces,
+// padding). Without salt no fingerprint is calculated.
+func secretFingerprint(secret string) string {
+	if secret == "" || cfg.Admin.FingerprintSalt == "" {
+		return ""
+	}
+
+	secret = strings.ToUpper(strings.TrimRight(strings.Replace(secret, " ", "", -1), "="))
+
+	mac := hmac.New(sha256.New, []byte(cfg.Admin.FingerprintSalt))
+	mac.Write([]byte(secret))
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// getAdminVaultToken works like getVaultToken but additionally requires
+// the user to be an admin. In case of an error the response is already
+// written to the client.
+func getAdminVaultToken(res http.ResponseWriter, r *http.Request) (*sessions.Session, string, bool) {
+	sess, tok, ok := getVaultToken(res, r)
+	if !ok {
+		return nil, "", false
+	}
+
+	isAdmin, err := hasAdminPolicy(tok)
+	if err != nil {
+		log.WithFields(log.Fields{"token": hashSecret(tok)}).Errorf("Unable to check admin policy: %s", err)
+		http.Error(res, `{"error":"Unexpected error while checking permissions"}`, http.StatusInternalServerError)
+		return nil, "", false
+	}
+
+	if !isAdmin {
+		http.Error(res, `{"error":"Admin permissions required"}`, http.StatusForbidden)
+		return nil, "", false
+	}
+
+	return sess, tok, true
+}
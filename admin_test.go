@@ -0,0 +1,49 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSecretFingerprint(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	fingerprint := func(salt, secret string) string {
+		cfg.Admin.FingerprintSalt = salt
+		return secretFingerprint(secret)
+	}
+
+	base := fingerprint("salt", "JBSWY3DPEHPK3PXP")
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(base) {
+		t.Fatalf("Expected 64 hex characters, got %q", base)
+	}
+	if strings.Contains(strings.ToUpper(base), "JBSWY3DPEHPK3PXP") {
+		t.Errorf("Fingerprint %q contains the secret", base)
+	}
+
+	for _, c := range []struct {
+		name      string
+		salt      string
+		secret    string
+		wantEqual bool
+	}{
+		{name: "identical secret", salt: "salt", secret: "JBSWY3DPEHPK3PXP", wantEqual: true},
+		{name: "other notation", salt: "salt", secret: "jbsw y3dp ehpk 3pxp==", wantEqual: true},
+		{name: "other secret", salt: "salt", secret: "GEZDGNBVGY3TQOJQ"},
+		{name: "other salt", salt: "pepper", secret: "JBSWY3DPEHPK3PXP"},
+	} {
+		if equal := fingerprint(c.salt, c.secret) == base; equal != c.wantEqual {
+			t.Errorf("%s: Expected equal fingerprint %v, got %v", c.name, c.wantEqual, equal)
+		}
+	}
+
+	// Never fall back to an unsalted hash
+	if got := fingerprint("", "JBSWY3DPEHPK3PXP"); got != "" {
+		t.Errorf("Expected no fingerprint without salt, got %q", got)
+	}
+	if got := fingerprint("salt", ""); got != "" {
+		t.Errorf("Expected no fingerprint without secret, got %q", got)
+	}
+}
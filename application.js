@@ -9,10 +9,12 @@ const app = new Vue({
         return this.otpItems
       }
 
+      const filter = this.filter.toLowerCase()
       const items = []
 
       for (let i of this.otpItems) {
-        if (i.name.toLowerCase().match(this.filter.toLowerCase())) {
+        // Raw names are only set when the name was normalized
+        if (i.name.toLowerCase().match(filter) || (i.raw_name && i.raw_name.toLowerCase().match(filter))) {
           items.push(i)
         }
       }
@@ -33,7 +35,7 @@ const app = new Vue({
         min = 30
       }
 
-      return min
+      return Math.max(min, minRefresh)
     },
   },
 
@@ -41,16 +43,22 @@ const app = new Vue({
     authUrl,
     backoff: 500,
     currentTimeout: null,
+    failedSources: '',
+    fallback: false,
     fetchInProgress: false,
     filter: '',
+    groupFolders,
     inactivityTimeout: null,
     lastFetch: null,
     loading: true,
+    now: new Date(),
     preFetch: null,
     signedIn,
     otpItems: [],
     refreshTimerProgressTicker: null,
     timeLeftPerc: 0.0,
+    truncated: false,
+    warnings: '',
   },
 
   el: '#application',
@@ -62,6 +70,14 @@ const app = new Vue({
       this.createAlert(success ? 'success' : 'danger', 'Copy to clipboard...', 'Code copied to clipboard')
     },
 
+    // Code to copy and display: The next code once its period started
+    codeOf(item) {
+      if (item.next_code && item.valid_until && this.now >= new Date(item.valid_until)) {
+        return item.next_code
+      }
+      return item.code
+    },
+
     // Wrapper around toast creation
     createAlert(variant, title, text, autoHideDelay=2000) {
       this.$bvToast.toast(text, {
@@ -87,13 +103,16 @@ const app = new Vue({
       this.fetchInProgress=true
 
       let successFunc= iteration == iterationCurrent ? this.updateCodes : this.updatePreFetch
-      if (iteration == iterationCurrent && this.preFetch !== null) {
+      // Tokens not exposing their next code need the current codes to be fetched
+      if (iteration == iterationCurrent && this.preFetch !== null && !this.preFetch.tokens.some(t => t.no_next)) {
         successFunc(this.preFetch)
         this.fetchInProgress = false
         return
       }
 
-      axios.get(`codes.json?it=${iteration}`)
+      // The next codes are displayed before the current ones expire
+      const it = iteration == iterationCurrent && showNext > 0 ? 'both' : iteration
+      axios.get(`codes.json?it=${it}`)
         .then(resp => {
           successFunc(resp.data)
           this.backoff = 500 // Reset backoff to 500ms
@@ -109,6 +128,10 @@ const app = new Vue({
                 this.otpItems = []
                 break
 
+              case 422:
+                this.createAlert('danger', 'Too many tokens...', 'There are too many tokens to display, please ask your administrator to configure a narrower prefix.', this.backoff)
+                break
+
               case 500:
                 this.createAlert('danger', 'Oops.', `Something went wrong when fetching your codes, will try again in ${Math.round(this.backoff / 1000)}s...`, this.backoff)
                 break;
@@ -134,8 +157,29 @@ const app = new Vue({
         .replace(/^([0-9]{2})([0-9]{3})([0-9]{3})$/, '$1 $2 $3') // 8 digits
     },
 
+    // Next code to display as hint shortly before the current code expires
+    nextCodeOf(item) {
+      if (showNext <= 0 || !item.next_code || !item.valid_until) {
+        return ''
+      }
+
+      const left = (new Date(item.valid_until).getTime() - this.now.getTime()) / 1000
+      return left > 0 && left <= showNext ? item.next_code : ''
+    },
+
+    // Show a folder header in front of the first item of every folder
+    isFirstInFolder(idx) {
+      if (!this.groupFolders) {
+        return false
+      }
+
+      return idx === 0 || this.filteredItems[idx - 1].folder !== this.filteredItems[idx].folder
+    },
+
     // Update timer bar and trigger re-fetch of codes by time remaining
     refreshTimerProgress() {
+      this.now = new Date()
+
       const secondsLeft = this.timeLeft()
       this.timeLeftPerc = secondsLeft / this.minPeriod * 100
 
@@ -157,7 +201,30 @@ const app = new Vue({
 
     // Update displayed codes
     updateCodes(data) {
-      this.currentTimeout = new Date(data.next_wrap)
+      if (data.truncated && !this.truncated) {
+        this.createAlert('warning', 'Incomplete list...', 'Not all secrets could be scanned in time, the list of codes is truncated.', 10000)
+      }
+
+      const failedSources = (data.sources || []).filter(s => s.status === 'error').map(s => s.source).join(', ')
+      if (failedSources && failedSources !== this.failedSources) {
+        this.createAlert('warning', 'Incomplete list...', `The tokens of ${failedSources} could not be loaded.`, 10000)
+      }
+
+      const warnings = (data.warnings || []).map(w => w.name ? `${w.name}: ${w.warning}` : w.warning).join('\n')
+      if (warnings && warnings !== this.warnings) {
+        this.createAlert('warning', 'Token warnings...', warnings, 10000)
+      }
+
+      const fallback = data.tokens.some(t => t.fallback)
+      if (fallback && !this.fallback) {
+        this.createAlert('warning', 'Vault unavailable...', 'Vault could not be reached, only the emergency tokens are displayed.', 10000)
+      }
+
+      this.currentTimeout = new Date(data.next_refresh || data.next_wrap)
+      this.failedSources = failedSources
+      this.fallback = fallback
+      this.truncated = data.truncated === true
+      this.warnings = warnings
       this.otpItems = data.tokens
       this.loading = false
       this.preFetch = null
@@ -85,85 +85,101 @@ func (fi bindataFileInfo) Sys() interface{} {
 }
 
 var _bindataIndexhtml = []byte(
-	"\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\x59\x6b\x77\xd3\xb8\xd3\x7f\xcf\xa7\x98\x35\xbb\xe7\xec\xb2\x8f\xe2" +
-	"\x5c\xda\xd0\xa4\x49\x0f\x50\x4a\x2f\x1b\xa0\xd0\x96\x26\xbc\x42\xb6\xc7\xb6\x5a\x59\x32\x92\xec\x24\xf4\xf0\xdd" +
-	"\x9f\x23\x3b\xce\x3d\x50\xce\xff\xd0\x17\x89\x23\xcd\xed\x37\x33\x9a\x19\xab\xbd\x3f\x5e\xbf\x3f\xbe\x1e\x5d\x9e" +
-	"\x40\x6c\x12\x7e\xf4\xa4\x67\xbf\x80\x53\x11\xf5\x1d\x14\xce\xd1\x13\x80\x5e\x8c\x34\xb0\x0f\x00\xbd\x04\x0d\x05" +
-	"\x3f\xa6\x4a\xa3\xe9\x3b\x99\x09\xc9\x81\xb3\xbc\x15\x1b\x93\x12\xfc\x9a\xb1\xbc\xef\x0c\xc9\xcd\x4b\x72\x2c\x93" +
-	"\x94\x1a\xe6\x71\x74\xc0\x97\xc2\xa0\x30\x7d\xe7\xfc\xa4\x8f\x41\x84\x2b\x9c\x82\x26\xd8\x77\x72\x86\xe3\x54\x2a" +
-	"\xb3\x44\x3c\x66\x81\x89\xfb\x01\xe6\xcc\x47\x52\xfc\xf8\x3f\x60\x82\x19\x46\x39\xd1\x3e\xe5\xd8\x6f\x54\x82\xfe" +
-	"\x20\x04\xae\x63\x04\xea\xc9\x1c\xa1\x05\x85\x60\x43\x23\x0d\xcf\x92\x4c\x9b\x67\xe0\xcb\x04\x21\x64\x4a\x1b\x60" +
-	"\x02\x4c\x8c\x60\xb1\x1d\x02\x15\x53\x90\x26\x46\x55\xfc\xae\x74\x83\x65\x2a\x79\x9e\xd1\xd0\xa0\x7a\x66\x59\x34" +
-	"\x96\x22\x09\x99\x69\x35\xcc\x70\x3c\xfa\x44\x33\x6e\xe0\xfd\xf5\x25\xb9\x39\xef\xb9\xe5\xda\x93\x92\x80\x33\x71" +
-	"\x0f\x0a\x79\xdf\x49\xa8\x60\x21\x6a\xe3\x40\xac\x30\xec\x3b\xda\x50\xc3\x7c\xb7\x5a\xae\xdd\x69\x69\x7d\xbe\xce" +
-	"\xa6\xcd\x94\xa3\x8e\x11\xe7\x8c\xd6\xcf\xba\xeb\xba\x7e\x20\x6a\x77\x3a\x40\xce\x72\x55\x13\x68\x5c\x91\x26\xae" +
-	"\x27\xa5\xd1\x46\xd1\xf4\xc5\x5e\xad\x55\x6b\xb8\x01\xd3\xc6\xf5\xb5\x5e\x6c\xd4\x12\x26\x6a\xbe\xd6\x4e\xa1\xa9" +
-	"\xfc\x63\xc2\x60\xa4\x98\x99\xf6\x1d\x1d\xd3\xe6\x7e\x9b\x8c\x06\xa7\x38\xa4\x34\x3d\xaf\xbb\xfb\xe7\xd1\x67\x99" +
-	"\xe2\xf8\xe3\x85\xff\x66\x28\x93\xf8\xe3\x5b\x3e\x1a\xdd\x65\xd1\xe5\xe0\x6a\xfa\xee\xee\x7a\xd4\x77\xc0\x57\x52" +
-	"\x6b\xa9\x58\xc4\x44\xdf\xa1\x42\x8a\x69\x22\x33\x5d\x85\xe6\x7f\x03\x33\xa6\xc6\x8f\x97\xd1\x84\x9c\x1a\x3e\xfd" +
-	"\x55\x40\xf5\x24\xd6\xe3\xd4\xdf\x33\x37\xc9\x81\xf7\xef\xc9\x59\x72\x3b\xbd\x3f\x68\x3c\x7f\xc9\x4f\xcf\xff\x1d" +
-	"\xee\xbf\x4b\x3e\xe9\xff\xbc\x8b\xfb\x0f\xad\xbd\xa6\xff\x9b\x01\x59\x9b\x49\x9e\xe1\x8b\x66\xad\x5e\xab\x97\x98" +
-	"\x56\x36\x1e\x07\xa8\x73\x10\x8a\xe3\xe1\xe8\xe4\x7c\x10\xb5\xc7\xef\xc7\xf4\xcd\xed\xe5\x27\xbc\xbc\xf0\xd9\x37" +
-	"\x3d\xfa\x7c\xda\xbc\xf9\xf7\x5d\x67\xff\xf6\xea\x56\x9f\xb6\xa2\xdf\x07\x28\x94\xc2\x10\x3a\x46\x2d\x13\x7c\xb1" +
-	"\x57\x7b\x5e\xab\x17\xc9\xb6\xbc\xfc\x38\x34\xf8\x59\xa9\x0b\x7f\xfc\xda\x77\x5b\xd9\xeb\x58\x07\xa6\xdd\xd0\x83" +
-	"\xa6\x7c\xff\x6a\xd4\x6a\x37\xbf\xbe\x6d\x71\x29\x1a\xd1\xf4\x64\x72\x3f\xa8\xff\x08\x4d\x09\xa7\x00\x71\x34\xd3" +
-	"\xe7\xc9\x60\x0a\x0f\x50\x98\xa4\xd9\x37\xec\x42\xa3\x9d\x4e\x0e\x21\xa5\x41\xc0\x44\x44\x8c\x4c\xbb\xd0\xa9\xdb" +
-	"\xa5\xef\x33\x16\x06\x0f\x90\x50\x15\x31\x41\x14\x8b\x62\xd3\x85\x7a\x6d\x0f\x93\x05\x41\xcd\x16\xa1\x81\xa4\x01" +
-	"\xaa\x2d\xc4\x99\xd0\x68\x96\x88\x29\x47\x65\xe0\x01\x3c\xea\xdf\x47\x4a\x66\x22\x20\x2c\xa1\x11\x76\x41\x48\x81" +
-	"\x4b\x84\x1e\x0d\x22\x5c\x25\xf4\x25\x97\xaa\x0b\x4f\x9b\x9d\x83\xba\xd7\x39\x84\xea\x77\x10\x04\x87\x2b\x98\xf6" +
-	"\x2d\x80\x62\x61\x8c\xa5\x19\x9e\xe4\xc1\x61\x65\x5a\x81\xb2\xb5\x0c\xb2\xe6\xa3\x30\xa8\xe0\x01\x0c\x4e\x0c\xa1" +
-	"\x9c\x45\xa2\x0b\xe5\xe2\x12\x55\xc8\x26\x18\x58\x9b\xa4\x31\x32\xe9\x42\xfd\x10\x52\xa9\x99\x61\x52\x74\xa1\xd8" +
-	"\x3c\x84\xa2\x32\x77\xa1\x51\xaf\xff\x75\x08\xdf\x08\x13\x01\x4e\xba\xd0\xe9\x74\x96\xe4\xdc\x65\x89\x27\x8d\x92" +
-	"\x02\xe2\xe6\xcf\x74\x4a\x93\x12\x66\x30\x81\x07\xf0\x33\xa5\x2d\xe0\x54\xb2\x5d\x44\x36\x00\x95\x05\xb5\xc6\x4a" +
-	"\x98\x52\x8f\xaa\xed\xfe\x6c\x1c\xbc\x3a\xee\x1c\x1f\x42\x3c\x73\x56\x69\xfb\x82\xd1\xb6\x01\xca\x04\xee\x60\x3f" +
-	"\x79\xbe\x77\xdc\x3a\x3e\x04\x4f\xaa\x00\x15\xa9\xd4\xa7\x13\xa8\x97\x9f\xf3\xad\x8a\xa3\xd5\x6a\x2d\xb4\x15\x81" +
-	"\x58\xb8\x91\x7a\x5a\xf2\xcc\xe0\xe1\xb2\x97\x39\x86\xa6\x78\xf8\xa9\x77\x7b\xee\x2c\xe1\x6d\xc3\x76\xab\x8e\xdd" +
-	"\xb3\x89\x5f\x9d\x88\x80\xe5\xc0\x82\xbe\x43\xd3\x94\x33\x9f\x5a\xb5\xd5\x69\x01\xe8\x09\x9a\x83\xcf\xa9\xd6\x7d" +
-	"\x47\xd0\xdc\xfa\xac\x08\xac\x4d\x1a\x28\x17\x08\x4e\x52\x2a\x02\xc2\xa3\x6a\x21\xa0\xea\x1e\xbc\x88\xa4\x8a\x25" +
-	"\x54\x4d\x9d\xa3\xf9\xf1\x2e\x94\xcd\xc4\xcd\xdd\x48\x42\x9e\xb1\x60\x89\x0a\xa0\x47\x57\x95\x12\x4f\x51\x11\x40" +
-	"\xa2\xc8\x7e\x55\x7b\x9e\x3a\x6b\xbd\x95\xae\x08\xf0\x32\x63\xa4\x58\x93\x62\x64\x14\x71\x54\x0e\x98\x69\x8a\x7d" +
-	"\xa7\xa4\x71\x20\xa0\x86\xce\xf6\xac\x59\x9c\xd3\x54\x63\xb5\x4c\x55\x64\x67\x9a\xa7\xa5\x88\xab\x2c\xb5\x73\x08" +
-	"\x06\xc7\xe5\x2c\xe0\x00\x55\x8c\x12\x8b\x45\x49\x3e\xd7\xb4\x83\xac\xf4\x14\x06\x7d\x27\xa4\xdc\xaa\x28\x56\x39" +
-	"\xf5\x6c\x79\xbd\x2e\x0c\xb0\x3e\x64\x51\x15\x05\x58\xfa\xeb\xe9\x94\xee\x00\x44\x98\x6f\xc9\x7b\xae\x25\x59\x71" +
-	"\x83\x5b\x62\x9c\xc7\x73\x33\x08\x25\xda\x2a\x74\x0b\xf4\x36\x25\x76\x80\x59\xb3\x2b\x94\x2a\xa9\xe4\xd9\x67\xc2" +
-	"\x04\x67\x02\x6d\xb4\x68\x66\xe4\x1a\x39\x40\x8f\x89\x34\x33\xb3\x18\xd8\xc3\xee\xac\x70\xcf\x7c\xe9\x40\xca\xa9" +
-	"\x8f\xb1\xe4\x01\xaa\xbe\xf3\x86\x71\x63\x23\x97\x93\x44\x06\xd6\x5d\x61\xb9\xb0\x66\x8b\x6b\x45\xac\xad\x65\x7c" +
-	"\xcd\x6b\x36\xa7\x93\x29\x69\xda\x0f\x1e\x91\xfa\xa6\x85\x9c\x2d\xb1\x14\xa5\x64\x83\x66\x2d\x49\x89\xed\x93\xeb" +
-	"\x7d\x31\x62\x26\xce\xbc\x9a\x2f\x13\x77\x90\x7d\x63\x21\x2a\x37\xb7\x39\x4b\x6c\x81\xca\x98\x73\xd4\x9b\xeb\x09" +
-	"\x29\x84\x94\x94\x0c\xb3\xbc\x88\x59\x10\xa0\xe8\x3b\x46\x65\x68\x83\xcb\x8e\xe0\x4a\x66\xca\x47\x90\x02\x4e\x0b" +
-	"\xca\xb5\xac\x2f\x5d\xc0\xd9\xba\x53\x32\xbe\x9a\x14\x01\xcb\x8f\x8a\xa9\xd8\xad\xad\xc5\x7d\x3e\xbe\x6e\x10\xae" +
-	"\x1d\xd7\x82\x70\xeb\xb9\x5e\xd4\xc7\xd5\x23\xbd\x4c\xe2\x51\xe5\x40\xb7\x28\x4d\x7d\x67\x5e\xa2\xbf\xfc\xf9\x60" +
-	"\x58\x82\x03\x0c\xcd\x25\x2a\xff\xfb\x5f\x5f\xe0\x7b\x99\x88\x76\x59\x59\x1f\x58\x83\xd6\xec\xab\x2a\x95\x2b\x68" +
-	"\x7e\xf4\x64\x5e\xb8\xac\xba\x9c\x30\x3b\x4d\xb3\x48\x60\x70\xbe\x54\xd5\x76\x14\x22\x67\xe7\x31\x51\x72\x0c\x77" +
-	"\x99\x36\x2c\x9c\x92\xd9\x5b\x00\x49\x02\x52\x76\xa7\xf5\x1c\x5c\x3d\x5e\x64\xa2\x49\xa3\x6e\x9b\xb3\xe5\xd8\x9b" +
-	"\x75\x34\x58\x4c\x09\xce\xcc\x4c\x2e\xa9\x1d\x3a\xb6\x1c\x97\xd5\x1c\x51\x18\x2a\xd4\xb1\x7d\xd4\x29\x13\xf6\x7b" +
-	"\x7f\x52\xe6\x47\xcf\x53\xeb\xa1\x5f\x71\xd8\xba\x79\x63\xd2\xa8\xd7\x37\xdc\xba\x0b\x44\xb3\x00\xa1\x13\x72\x50" +
-	"\xa1\x69\x17\x0f\x3c\x22\xed\x4d\xab\x97\x04\x70\xa6\x0d\xb1\x5d\x32\x2d\xa3\x79\x8f\x53\xbb\xb4\xea\xee\x19\x1b" +
-	"\xdd\x58\x82\x4d\x31\x65\x73\x0f\x48\xc8\x71\xb2\x11\x17\x0f\xcd\x18\x51\x40\x31\x41\x14\x94\x7a\x16\x28\xa8\xe6" +
-	"\x02\x67\x8b\x92\x9c\x84\x52\xf5\x9d\x72\x6c\x10\x50\x96\x17\x0c\xce\x2d\xff\x36\xfa\xee\x3d\x4e\x4b\xf2\x9a\x7d" +
-	"\x47\xdd\x2e\xd2\xe7\x2c\xf5\x24\x55\x41\xd7\x97\x69\x45\xee\xcb\xe0\xa7\xe4\x3a\xf3\x7d\xb4\xa0\xff\xfe\x07\xfa" +
-	"\x47\x60\x59\x8e\x65\x3a\xfd\x88\x3a\xe3\xe6\x6f\x5b\x11\xfe\xf9\x99\x08\x54\xca\x02\xda\x2a\xa0\x68\x3f\x5b\x24" +
-	"\x6c\x16\xb9\x59\xdf\xd9\xb6\x51\xa4\x66\x77\x16\x9c\x2f\x65\x72\x86\x63\xfb\xf9\xe7\x43\x01\xd4\x76\xa5\xef\x5f" +
-	"\xca\xd4\xdc\xce\xbf\xdc\xd2\x8a\x77\x63\xe7\xe8\xe1\x01\xe6\x4e\x85\xef\xdf\x37\x7b\xda\x9c\x79\xf7\xce\xb2\xd8" +
-	"\x62\x70\x2e\xc4\xda\xd6\x40\xcd\xb1\x0c\xf0\xef\x79\x1c\xfe\xf9\x81\x8a\xa2\xb8\x6e\x54\xd7\xcd\xa3\xb2\xbe\xb4" +
-	"\xbd\x4a\x3d\xd9\xfa\xab\xac\x52\xc8\x35\x3e\xa2\x38\x2d\xa9\x58\xad\x4d\x8f\xa8\x40\x6b\x87\x57\x27\x44\x86\xa1" +
-	"\x46\x43\x9a\xab\x87\x39\x09\xaa\x8d\xd6\xfc\x70\x57\x0f\xd5\xc6\xe6\xc1\x5d\x29\xee\x54\x20\x87\xe2\x93\x04\x18" +
-	"\xda\x7e\xb7\xad\x79\xae\x73\x10\x3b\x9c\x16\xf5\xef\x92\x23\xd5\x08\xb6\x6c\x03\x13\x7f\x6c\x71\xf9\x76\x01\x76" +
-	"\xa8\xdd\xa2\x09\xa0\x97\x6e\x4f\xbf\x1b\x8d\xb3\x3e\x0a\x34\x33\x31\x0a\x33\x9b\x7f\xc1\xc8\x4a\xbd\x91\x30\x95" +
-	"\x99\x82\x72\xd6\x64\x42\x1b\x2a\x7c\x04\x3b\x8d\x46\x68\x80\x16\xc7\x14\x2a\x2a\x29\x90\xd8\x5e\x05\x29\xd5\x7a" +
-	"\x2c\x55\xa0\xbb\x5b\x33\x77\xab\x41\xbd\x74\x1e\xb4\x6d\x7d\x65\x4e\x46\xa1\x5b\x8e\x19\xd6\xe8\x1b\xc5\xe7\xe3" +
-	"\x93\x67\x04\x78\x46\x2c\xe6\xee\x5f\x1e\x2f\x8a\x8a\x29\x60\xcc\x4c\xbc\x7b\xc2\xd8\x09\x61\x6b\xa4\x56\x13\x7e" +
-	"\x07\xe1\x23\x8e\x4c\x79\x8d\xe7\x3e\xe5\x32\x62\x62\x31\x7d\xac\x6e\x2e\xbd\xc3\x2c\x91\x68\x5f\xb1\xd4\x80\x56" +
-	"\xfe\x4f\xae\x2c\xca\x9b\x97\x76\xad\x31\xbb\x7a\xa9\x2e\x5c\xee\xd6\x3a\xc0\xe6\x1d\x85\x1f\xf3\x77\x6f\xae\x3e" +
-	"\x4d\x5a\xd7\x81\xff\xa1\x39\xe4\xe3\xe7\x57\xb9\xf0\x06\x2f\x69\xfe\xf2\xc3\xe0\x7d\x7d\xe4\x0e\x5e\xb1\xdb\x61" +
-	"\x7d\x2f\x67\xa3\xfe\xaa\xac\x5d\xf7\x15\x3d\xb7\x34\xfb\xe8\x17\x31\xfc\xc2\x3d\xd2\xcf\x61\x9d\xe5\xed\x56\x9e" +
-	"\x0e\xdb\xe1\xc7\xb3\xfc\x6d\xfd\x66\xf4\x9f\xfb\xee\xe2\xad\xf7\xf2\xf3\x41\xc3\x6d\x9f\x9f\x7d\x08\xef\xef\xbf" +
-	"\xee\xbf\xba\x3a\x1b\x0d\x2f\x0f\x7e\x33\xac\x3c\xc3\x45\x6f\x6b\xbe\xa8\x2f\xee\xfc\x56\x76\x1e\x89\x6b\x98\x9f" +
-	"\x0d\x1a\x49\x9c\xbf\xbe\x19\x87\xa3\x41\xe7\x26\x1a\xd1\x93\xd3\xfa\xf5\xab\xff\x3e\x47\xad\x8b\x4e\x76\xe5\xdf" +
-	"\xdd\xbc\x3e\x6d\xcb\xe3\xab\xf6\xfd\x6f\xc6\x45\x27\x4c\xea\x17\xf5\x5a\xa3\x53\xc5\xa9\x58\x79\x24\x8e\xab\xc6" +
-	"\xc5\xde\xe9\xa7\xb3\xb3\xd7\x6f\x19\x53\x4c\x75\xbe\xea\xe1\xad\x7f\xf0\xf9\x76\xfc\x7c\xef\xf2\xec\x8c\x86\xa9" +
-	"\x3e\x4b\xf7\x2f\x87\xe6\xee\x5a\xff\x32\x8e\x4d\x20\x39\x55\xda\x1a\xf5\x23\xb0\x4b\x27\x70\x8d\xb4\xb8\x81\x28" +
-	"\x2f\x1e\x7a\x6e\xf9\x5f\x85\xff\x0f\x00\x00\xff\xff\x32\xf6\xd8\x90\x66\x18\x00\x00")
+	"\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xed\x1a\x69\x57\xdb\x3a\xf6\x7b\x7f\x85\xea\xbe\x77\x80\x76\x1c\x67\x21" +
+	"\x29\x09\x84\xd7\x96\x52\x96\xa6\x2d\x2d\x50\x12\xce\x9c\xf3\x50\x6c\xd9\x11\xd8\x96\x6b\xc9\x59\xca\xe3\xbf\xcf" +
+	"\x95\x6c\x27\xb6\xe3\xb0\x74\xa6\xdf\x86\x73\x0a\xb6\x74\xf7\x4d\xf7\xca\xdd\x79\xfe\xfe\xcb\xde\xd9\xe0\x64\x1f" +
+	"\x8d\x84\xe7\xee\x3e\xdb\x91\x7f\x90\x8b\x7d\xa7\xab\x11\x5f\xdb\x7d\x86\xd0\xce\x88\x60\x4b\x3e\xc0\xa3\x47\x04" +
+	"\x46\xe6\x08\x87\x9c\x88\xae\x16\x09\x5b\xdf\xd2\xb2\x5b\x23\x21\x02\x9d\xfc\x88\xe8\xb8\xab\xf5\xf5\xf3\xb7\xfa" +
+	"\x1e\xf3\x02\x2c\xe8\xd0\x25\x1a\x32\x99\x2f\x88\x0f\x78\x47\xfb\x5d\x62\x39\x24\x87\xe9\x63\x8f\x74\xb5\x31\x25" +
+	"\x93\x80\x85\x22\x03\x3c\xa1\x96\x18\x75\x2d\x32\xa6\x26\xd1\xd5\xcb\xbf\x10\xf5\xa9\xa0\xd8\xd5\xb9\x89\x5d\xd2" +
+	"\xad\xa5\x84\x9e\xeb\x3a\x3a\x1b\x11\x84\x87\x6c\x4c\x50\x03\x29\xc2\x02\x3b\x1c\xbd\xf4\x22\x2e\x5e\x02\x51\x8f" +
+	"\x20\x9b\x86\x5c\x00\x09\x24\x00\x54\xea\xb6\x8d\xb0\x3f\x43\x0c\x5e\x43\xf5\x9e\xf2\x46\x12\x29\xc6\x79\x89\x6d" +
+	"\x41\xc2\x97\x12\x85\x93\x98\xa4\xae\x27\x5c\x05\x15\x2e\xd9\xfd\x8e\x23\x57\xa0\x2f\x67\x27\xfa\xf9\xd1\x8e\x11" +
+	"\xaf\x3d\x8b\x01\x5c\xea\xdf\xa0\x90\xb8\x5d\xcd\xc3\x3e\xb5\x09\x07\xf5\x46\x21\xb1\xbb\x1a\x17\x60\x1b\xd3\x48" +
+	"\x97\x2b\xd7\x9c\x49\x9b\x17\xd1\xb8\x98\xb9\x84\x8f\x08\x99\x23\x4a\x3b\xf3\x8e\x61\x98\x96\x0f\x48\x16\x71\xe9" +
+	"\x38\xac\xf8\x44\x18\x7e\xe0\x19\x43\xc6\x04\x17\x21\x0e\xde\x6c\x56\x1a\x95\x9a\x61\x51\x2e\x0c\x93\xf3\xc5\x46" +
+	"\xc5\xa3\x7e\x05\x56\x34\xc5\x29\xfe\xa1\xa0\xb3\x13\x52\x31\x03\x7e\x23\x5c\x6f\xb6\xf4\x41\xef\x80\xf4\x31\x0e" +
+	"\x8e\xaa\x46\xf3\xc8\xb9\x64\x01\x99\x7c\x3b\x36\x3f\xf4\x99\x37\xfa\xf6\xc9\x1d\x0c\xae\x23\xe7\xa4\x77\x3a\xfb" +
+	"\x7c\x7d\x36\xe8\x82\xc3\x42\xc6\x39\x0b\xa9\x43\xfd\xae\x86\x7d\xe6\xcf\x3c\x16\xf1\xd4\x35\xff\x9d\x32\x13\x2c" +
+	"\xcc\x51\x56\x1b\xdb\xc5\xc2\x9d\x3d\x55\xa1\xaa\x37\xe2\x93\xc0\xdc\x14\xe7\xde\xd6\xf0\xd5\xfe\xa1\x77\x31\xbb" +
+	"\xd9\xaa\xbd\x7e\xeb\x1e\x1c\xbd\xea\x37\x3f\x7b\xdf\xf9\xc7\xe1\xf1\xcd\xd7\xc6\x66\xdd\xfc\xcd\x0a\x49\x99\xf5" +
+	"\x71\x44\xde\xd4\x2b\xd5\x4a\x35\xd6\x29\xb7\xf1\x38\x85\xda\x5b\xb6\xbf\xd7\x1f\xec\x1f\xf5\x9c\xd6\xe4\xcb\x04" +
+	"\x7f\xb8\x38\xf9\x4e\x4e\x8e\x4d\xfa\x93\x0f\x2e\x0f\xea\xe7\xaf\x3e\xb7\x9b\x17\xa7\x17\xfc\xa0\xe1\xfc\x3e\x85" +
+	"\x6c\xc8\x16\x1d\x4f\x08\x87\x44\x01\x1f\xbd\x06\x7d\x64\xb0\x65\x97\x1f\xa7\x0d\xb9\x0c\xc3\x63\x73\xf2\xde\x34" +
+	"\x1a\xd1\xfb\x11\xb7\x44\xab\xc6\x7b\x75\xf6\xe5\xdd\xa0\xd1\xaa\xff\xf8\xd4\x70\x99\x5f\x73\x66\xfb\xd3\x9b\x5e" +
+	"\xf5\x3e\x6d\x62\x75\x94\x12\xbb\x09\xbf\x21\xb3\x66\xe8\x16\x29\x91\x38\xfd\x49\x3a\xa8\xd6\x0a\xa6\xdb\x28\xc0" +
+	"\x96\x45\x7d\x47\x17\x2c\xe8\xa0\x76\x55\x2e\xdd\x25\x28\x14\xe0\x3d\x1c\x02\x75\x1d\x78\x8c\x44\x07\x55\x2b\x9b" +
+	"\xc4\x5b\x00\x54\x64\x11\xea\x31\x6c\x41\xd5\x58\x06\x8e\x7c\xa8\x90\x19\x60\xa8\x53\xa1\x00\xa8\x21\x36\x6f\x9c" +
+	"\x90\x45\xbe\xa5\x53\x0f\x3b\x20\x09\x48\x4e\x32\x80\x43\x0c\x95\x31\x0f\x68\x32\x97\x85\x1d\xf4\xa2\xde\xde\xaa" +
+	"\x0e\xdb\xdb\x28\x7d\xb7\x2c\x28\x5d\x59\x9d\x9a\x52\x01\xb5\x30\x21\xb1\x18\x43\xe6\x02\x4c\x22\x9a\xd2\xb2\x21" +
+	"\x61\x20\xca\x7c\x0e\x25\x94\xf9\x1d\xc4\x02\x6c\x82\x17\x40\xbd\x26\x2f\xca\x51\x21\xd3\x80\x86\x60\x21\x10\x28" +
+	"\x81\x93\x76\x68\x2e\xc1\xf9\x64\x2a\x40\x4e\x6b\x85\xe4\xed\x26\x6e\xe2\x56\x5e\xd8\xba\x14\xe4\x7e\x0b\x9b\x50" +
+	"\x84\xc1\xba\xb7\x48\x48\xf2\xd8\xa5\x0e\xc8\x1b\x2f\x66\xa0\x6c\x3a\x25\x96\xe4\xcb\x84\x60\x1e\x50\x01\xbf\xb2" +
+	"\x54\x3b\xb5\xb9\x8d\xd4\xb9\x01\x4c\xab\xd5\x3f\xb7\xd1\x4f\x9d\xfa\x16\x99\x82\xc7\xdb\xed\x0c\x9d\xeb\xc8\x03" +
+	"\x12\x21\xf3\xd1\xa8\xfe\x10\x4f\x06\xc7\x1c\x15\xc4\x03\x38\x33\x0a\xb9\x54\x32\x60\x74\x15\x90\x0c\x8f\x54\x82" +
+	"\x4a\x2d\xa7\x62\x30\xc4\x61\xb9\xcd\x6a\x5b\xef\xf6\xda\x7b\xdb\x70\x22\xc5\xc6\x89\x65\x5f\x20\xca\x43\x0a\x53" +
+	"\x9f\xac\x40\xdf\x7f\xbd\xb9\xd7\x00\xf4\x21\x0b\x21\x42\xf5\x94\x7d\x30\x45\xd5\xf8\xf7\x7c\x2b\xc5\x68\x34\x1a" +
+	"\x0b\x6e\x2a\x4c\x16\x66\xc4\x43\xce\xdc\x48\x90\xed\xac\x95\x5d\x62\x0b\xf5\xf0\x18\xeb\x0a\x76\x43\xfc\x38\xe6" +
+	"\x41\xe0\xb9\x52\xb1\x39\x4a\xc3\x60\x0c\x19\x43\xe1\x80\x4f\x9d\xa0\x57\x2b\xb5\xa6\xdc\x28\x33\xe5\x8e\x91\x24" +
+	"\xbc\x6c\x58\x8c\xb4\x63\xd9\x91\x89\x9f\x56\x04\x8b\x8e\x11\xb5\xa0\x56\x04\x81\x0b\x74\xa5\x62\x69\xb5\x80\x5d" +
+	"\x1f\x8f\x91\xe9\x62\xce\xbb\x1a\x3c\x4a\xaf\xa8\xd0\x91\x49\x83\xe2\x05\x1d\x92\x01\x83\x85\x5d\x27\x5d\xb0\x70" +
+	"\x78\x83\x86\x8e\x1e\x84\xa0\x57\x38\xd3\x76\xe7\xe5\x4d\x31\x4b\xc8\xcd\x1d\xa5\xdb\x6e\x44\xad\x0c\x14\xc0\xe1" +
+	"\x3c\x53\x7d\x08\x99\x69\x21\x2f\xd4\x9b\x69\xed\x7d\xa1\x15\x7a\x0b\x9c\x23\x30\x8c\xc0\x1f\x7e\x81\x8a\x60\x8e" +
+	"\x03\x05\x47\x43\x62\x16\x40\x57\x15\xc3\x68\xc8\xc2\x02\x27\x7b\x52\x2c\xd7\xc5\x01\x27\xe9\x32\xb8\x40\xf6\x74" +
+	"\x2f\x62\x12\xa7\x51\x20\xfb\x30\x62\xed\xc5\xbd\x90\x86\x70\x48\xb1\x2e\x75\x09\x99\x3b\xe7\xb4\x02\x2c\xb6\x14" +
+	"\x01\x63\xdb\xd8\x95\x2c\xd4\xaa\x8b\x87\xf2\x78\x39\x53\x02\x48\x1b\x52\x27\xf5\x02\xca\xfc\xec\x70\x40\x2e\x57" +
+	"\x48\xa7\xa6\x04\x07\x67\x03\x48\xce\x0c\x46\xac\xe3\xdc\x9f\xcb\x4e\x88\xb5\x4d\x5d\xb7\xd0\x5e\x86\xc4\x0a\x65" +
+	"\x0a\x72\xd9\x2c\xf4\x52\x7a\xf2\x19\x02\x1d\x0e\x4d\x22\xbd\x85\x23\xc1\x0a\xe0\x80\x40\xfd\x20\x12\x89\x0f\x64" +
+	"\x39\xd1\x72\xd8\x89\x2d\x35\x14\xb8\xd8\x24\x23\xa8\xd4\x24\xec\x6a\x1f\xa8\x2b\xa4\xe7\xc6\xba\x07\xe5\x14\xcc" +
+	"\x65\xc7\x0b\x05\x59\x0c\x49\xa2\xb0\x16\xb9\x05\xab\xc9\x98\xf6\x66\x7a\x5d\xfe\x72\x1d\xbd\xba\x2c\xa1\x4b\x33" +
+	"\x28\xaa\x58\x2d\xc1\x14\x82\x54\x97\x7d\x42\xb1\x2f\x70\xa8\x18\x45\xc3\x0a\xb4\xca\x46\x2f\xfa\x09\xbd\x6c\x68" +
+	"\x8c\x65\xcc\xea\xb2\x04\x46\x14\x3c\x36\xe7\x63\x63\x64\x63\x3d\x46\x48\xe2\x62\x44\x2d\x8b\xc0\x21\x2e\xc2\x88" +
+	"\x48\xe7\xd2\x5d\x74\xca\xa2\xd0\x24\x08\x02\xfb\x40\x41\x16\xa2\x3e\x36\x81\x4b\x8b\x46\x89\xdc\x7c\x50\x40\x00" +
+	"\xec\xaa\xa9\xc0\xa8\x14\xfc\x3e\x6f\xdf\x97\x00\x0b\xe9\xaa\x00\x4b\xf3\x7a\x51\x81\xf3\x29\x9d\x05\x01\x96\x1a" +
+	"\xea\xa8\xd2\xd4\xd5\xe6\x87\xc0\xd5\x1f\xb7\x82\x7a\xa4\x07\xf5\xf3\x84\x84\xe6\xdd\x9f\x57\xe8\x2e\x0e\x44\xb9" +
+	"\x1c\x4a\x1b\x48\x81\x0a\xf2\xa5\x95\xca\x00\x55\x40\xa4\x67\x19\x76\xe0\x3a\x39\x4d\x40\x99\x24\xd6\x51\xa6\xaa" +
+	"\xad\x28\x44\xda\xca\x34\x09\xd9\x04\x5d\xc3\xd4\x43\xed\x99\x9e\x4c\x41\xba\x07\x87\x8a\x3a\xff\x8a\x31\x98\x4f" +
+	"\x2f\x7d\xca\xf5\x5a\x55\x36\x27\x12\x63\x33\x39\x33\xd1\xa2\x4b\xd2\x12\x31\x5d\x78\x83\x96\xa2\x24\x5d\xf2\x31" +
+	"\x02\x11\x16\x42\x1b\x2a\x1f\x79\x00\xe3\x1a\xfc\x6d\x4e\xe3\xf8\xd8\x19\x86\x45\xd7\xe7\x0c\x56\x14\x6f\x02\x92" +
+	"\x55\x97\xcc\xba\x4a\x89\xba\x52\x82\x7b\xfa\x56\xaa\x4d\x4b\x3d\x40\x0a\xb5\x96\xa5\xce\x10\x70\xa1\x8d\xd7\xe5" +
+	"\x39\x1c\xc4\xde\xbc\x21\x33\xb9\x94\x37\x77\x82\x06\xa9\x06\x79\x2f\x08\x18\x05\x52\xb9\xab\xad\xcb\xe4\x83\xc9" +
+	"\xd6\x9a\x6e\xc8\xd9\x34\xce\x7a\xf0\x26\xac\xf2\x92\x9c\x8c\x19\x97\x2c\xa3\x65\x61\xe2\x26\xa4\xf0\xae\x73\x02" +
+	"\x0e\x86\x13\x6c\x06\x5d\x99\x2c\x3d\x5a\x29\xb1\xd8\x65\x94\x7f\x90\x43\xf3\x91\xff\x41\x81\xae\x4b\x29\xcb\xe1" +
+	"\x3b\xa0\x73\x57\xbb\x8a\x49\x76\xfe\xb8\x95\xac\x2a\xf1\xdb\xdd\x55\x19\xca\x6e\x29\x99\x62\x2c\xd8\x13\xf5\x5b" +
+	"\xd1\xd1\x61\x10\xf5\xe3\x38\x28\xc5\xbd\xbd\x45\x19\xae\xe8\x9f\x7f\xd0\x9a\xb1\x86\xee\xee\xca\x6c\xb8\x1c\x10" +
+	"\xbf\x66\x5c\x0b\x8a\x05\x99\x2e\xa5\xce\x90\x88\x09\x21\x3e\x52\x1d\x8c\x82\xe4\x49\x2e\xc5\xed\xa5\x07\x2d\x95" +
+	"\x75\xaf\xe1\xa5\x22\x70\x0c\x90\x95\x70\xb1\xc1\x15\x9c\xbc\x42\x79\x82\x89\x8b\x67\x68\xde\xfe\x9e\x93\xea\x9a" +
+	"\xe9\xdc\x64\x2d\x0b\xcd\x84\x5d\xb2\x92\x11\x34\x59\x79\x43\xc2\x50\x86\xf4\x62\x0d\x75\xd1\xda\x9a\xb6\x9a\x19" +
+	"\xea\x24\xbc\xae\xb2\xee\x4e\xa2\x47\x9e\xf9\x10\x3b\xc0\x88\x40\x2f\xb1\xda\xef\x85\xce\x41\x5d\xc1\x40\x98\x80" +
+	"\xf5\x76\xd3\x90\x90\x16\x82\x48\x00\xb7\xc3\xe2\x72\x17\x91\x0d\x8c\x7b\xf6\xb2\x5c\xd4\xf8\xa3\xed\x26\x2e\x5a" +
+	"\x8d\xb6\x3a\xd2\xf0\xef\x8d\xb3\x74\x08\x59\x15\x65\xd2\xa6\xbf\x1a\x58\x68\x7e\xb4\x29\x30\x35\x48\xa0\xbf\xd0" +
+	"\x55\x32\x58\xc4\xf3\xc1\x26\x0c\x1b\x30\x3c\xc0\x49\x9a\xf8\x53\x81\xdd\x5d\xa1\x8e\x8c\x89\x15\x52\x99\x2e\x0d" +
+	"\x86\x0c\x87\x56\xc7\x64\x41\x2a\x46\x08\x15\x0b\x86\x82\xd9\xdf\x72\xd0\xe4\xc0\xa8\x64\xb5\x72\x0d\x63\xd8\xfa" +
+	"\xda\xbf\xfd\xb5\x0d\x60\x20\x97\xbe\xd8\xaa\xb4\x6e\x3c\xcc\x8a\x47\xa6\x49\xa4\xd5\xd7\x37\x50\x77\x57\x21\xef" +
+	"\x01\xf7\x6f\x84\x43\x2b\xb3\x2e\xbb\x93\x47\x10\x49\x62\xbf\x94\x84\x6a\x86\x37\xfe\x9f\xa2\xf9\x14\x45\x1d\xf5" +
+	"\x37\x8d\x35\x26\x20\x9f\x96\x12\xf6\x7f\x96\xaa\x59\x63\xe4\x43\x67\xc1\xb5\x10\x52\x2e\xf1\x1d\x31\x02\x31\x50" +
+	"\xba\xa1\x1c\xcb\x1f\xe6\x1c\xdb\x43\x31\x7c\xae\x28\xcb\x1b\x69\x39\x6e\xfd\xcd\x7c\x77\xa6\x3d\xca\x48\xf1\x85" +
+	"\xd0\xfc\x82\x25\x55\x40\x2e\xec\x65\xc3\x1b\x25\x56\xfc\x0c\x1b\x4a\x3e\xa5\x8f\x1c\x13\xb0\x02\x5c\x2f\x62\x6c" +
+	"\x3c\x60\xd8\x72\xf3\xa5\x61\x70\x8b\xd2\xbb\xa1\x0e\x2a\x52\x86\x6e\xb6\xc0\xdb\x7c\x02\xdf\xfb\x8a\x28\x2e\x99" +
+	"\x4e\x8c\xb4\x95\x5a\x6a\xb3\xca\xfa\xbd\xe2\x52\x79\xab\xfd\xac\xf4\x2d\x6e\xb5\x55\x88\x3f\xdc\x61\xaf\x6c\xb0" +
+	"\xb5\x27\x77\xa0\xf0\xc0\x6c\x9b\x13\xa1\xd7\xf3\x1d\x29\x3c\x24\x1b\x8d\x79\x87\x9a\x3e\xa4\x1b\xcb\xdd\x67\x6e" +
+	"\x42\xc1\x3e\x71\x91\xfa\xad\x5b\xc4\x96\x43\x5b\xd9\x04\x58\xc4\xd0\xe5\x0d\x8b\x6a\xe2\x4f\x5c\x82\x61\x9c\x92" +
+	"\xb3\x07\xf4\xad\xcf\x57\x9c\x73\xcb\x04\xe4\xcd\x4c\x79\x5f\x1b\x94\x87\xc5\x39\x70\x89\x87\x41\x04\xd3\xf6\x08" +
+	"\x4e\xb7\xe4\x12\x07\x09\x96\xb2\x87\xa7\x19\x4c\x8e\x28\xbe\x30\xa1\x3e\x17\xd8\x87\x31\x52\x5e\xa9\x38\x44\x20" +
+	"\xac\xea\x3b\x4a\xa1\x98\x4f\x74\x39\x70\x81\xfa\x9c\x4f\xe0\xcc\xe2\x9d\xd2\xa0\x0b\xca\xc5\x9c\x3b\xad\x6c\x38" +
+	"\xca\xcc\xce\x9d\x78\x56\x96\x42\x9f\x87\xee\xfc\x0e\x60\x28\x7c\x04\xff\x16\x97\x47\x4f\x9e\x91\xd5\x59\xef\xc3" +
+	"\x50\x09\xc5\x69\xe5\x98\xbc\x52\x85\x52\x4f\xe5\x03\xfe\xd7\x53\x26\xfe\x16\x67\xbc\x70\x99\x03\x12\xce\x47\xe8" +
+	"\xfc\x66\xe6\x22\x2e\x03\xc2\xcd\x90\x06\x02\xa9\x43\xec\xde\xef\x0e\xf1\xe7\x93\x56\xa5\x96\x7c\x3f\x49\xbf\x9a" +
+	"\x5c\xf3\xfc\x09\xbb\xfc\xa1\xc1\x1c\xb9\x9f\x3f\x9c\x7e\x9f\x36\xce\x2c\xf3\x6b\xbd\xef\x4e\x5e\x9f\x8e\xfd\x61" +
+	"\xef\x2d\x1e\xbf\xfd\xda\xfb\x52\x1d\x18\xbd\x77\xf4\xa2\x5f\xdd\x1c\xd3\x41\x37\x4f\x6b\xd5\x47\x07\xa8\x59\x4a" +
+	"\xec\xdd\x27\xea\xf0\x84\x8f\x41\x0f\xab\x75\x38\x6e\x35\xc6\x41\xbf\x65\x7f\x3b\x1c\x7f\xaa\x9e\x0f\x3e\x1a\x9f" +
+	"\x8f\x3f\x0d\xdf\x5e\x6e\xd5\x8c\xd6\xd1\xe1\x57\xfb\xe6\xe6\x47\xf3\xdd\xe9\xe1\xa0\x7f\xb2\xf5\x9b\xd5\x02\x99" +
+	"\x17\x2d\x51\xfd\x4d\x75\xf1\xe1\x2e\xb7\xf3\x48\xbd\xfa\xe3\xc3\x5e\xcd\x1b\x8d\xdf\x9f\x4f\xec\x41\xaf\x7d\xee" +
+	"\x0c\xf0\xfe\x41\xf5\xec\xdd\xc7\x4b\xa7\x71\xdc\x8e\x4e\xcd\xeb\xf3\xf7\x07\x2d\xb6\x77\xda\xba\xf9\xcd\x7a\xe1" +
+	"\x29\x65\x1c\xd4\xa9\xb5\x53\x3f\xa9\x95\x47\xea\x71\x5a\x3b\xde\x3c\xf8\x7e\x78\xf8\xfe\x13\x85\x83\x33\x6c\xff" +
+	"\xe0\xfd\x0b\x73\xeb\xf2\x62\xf2\x7a\xf3\xe4\xf0\x10\xdb\x01\x3f\x0c\x9a\x27\x7d\x71\x7d\xc6\x9f\xac\xc7\xb2\x22" +
+	"\x63\x1c\x72\x29\xd4\x7d\xca\x66\x32\xb0\x00\xaa\xae\xd1\xe3\xdb\xf3\x1d\x23\xfe\xaf\x01\xff\x01\xc8\xcb\x48\x52" +
+	"\x2b\x20\x00\x00")
 
 func bindataIndexhtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -182,10 +198,10 @@ func bindataIndexhtml() (*asset, error) {
 
 	info := bindataFileInfo{
 		name: "index.html",
-		size: 6246,
+		size: 8235,
 		md5checksum: "",
 		mode: os.FileMode(436),
-		modTime: time.Unix(1568460315, 0),
+		modTime: time.Unix(1791999617, 0),
 	}
 
 	a := &asset{bytes: bytes, info: info}
@@ -194,75 +210,106 @@ func bindataIndexhtml() (*asset, error) {
 }
 
 var _bindataApplicationjs = []byte(
-	"\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\x58\xff\x8e\xdb\xb8\x11\xfe\xdf\x4f\x31\x41\x17\x27\xe9\xea\x95\x95" +
-	"\x6c\xd3\xf6\xdc\x53\x82\x36\x69\xd0\x05\xf6\xda\xc5\x25\xd7\xa2\x08\xd2\x86\x96\xc6\x36\xbb\x14\xa9\x92\x94\xbd" +
-	"\xee\x9e\xdf\xbd\x18\x52\xb2\x28\x59\x9b\x5c\x0e\xb8\x00\x49\x24\x72\x34\x9c\x1f\x1f\xbf\x99\x71\xa1\xa4\xb1\xc0" +
-	"\x2d\x6a\x66\xb9\x92\xaf\x1a\xad\x51\x5a\xc8\x21\x2a\xfc\x63\x34\x1b\x89\xfc\x15\xef\xdd\xbe\xc4\x7b\x1b\xcd\xda" +
-	"\x5d\x56\xd7\x90\x83\xc4\x3d\xfc\xbd\xc1\xf8\x61\x36\x03\x28\x54\x55\x37\x16\xcb\x25\x3c\xcc\x00\x00\xd6\x5c\x58" +
-	"\xd4\x58\x5e\x5b\xac\x4c\x9c\xb4\xab\x00\x7c\x0d\xb1\xdd\x72\x93\x7a\x01\xc8\xf3\x1c\xa2\xa8\xdf\x07\xd0\x68\x1b" +
-	"\x2d\xc1\x09\x29\x5b\x3b\x05\xed\xe6\x71\xd6\x3e\x9c\x8c\xac\x0c\xe4\xf0\xfe\x43\xb7\xbe\x56\x1a\x62\x81\x16\x38" +
-	"\xa8\xf5\x50\x45\x78\x04\x19\xc1\x53\xc9\x2a\x4c\xad\xba\x51\x7b\xd4\xaf\x98\xc1\x38\x49\x2b\x66\x8b\x6d\x68\xdf" +
-	"\x70\x3f\x09\x95\x80\x3f\x3f\xad\x1b\xb3\x8d\x79\x72\x5a\x3f\x8e\x8d\x6d\x1d\xe2\x27\x47\x8e\x73\xbf\x55\x71\x79" +
-	"\x8b\x9a\xab\x32\x88\x0f\x19\x5f\x71\x09\x39\x7c\x43\x7f\x7e\x96\x63\xb5\x53\x0a\x2f\x20\x83\xaf\xbe\x82\xd3\xfb" +
-	"\xb7\xa4\x78\xe8\x81\x3f\xa9\x93\x78\xdc\x05\xd2\xeb\x64\xf3\xd6\xae\x50\x8d\x57\x72\x95\x3d\xe2\x77\xc5\x65\xe7" +
-	"\x75\xe7\x7a\xc9\x2c\xeb\x80\xc2\x1a\xbb\xfd\x41\x8b\xb9\x7b\x59\xb1\xe2\x4e\xad\xd7\x4b\x78\x9e\x65\x7e\xa5\xc5" +
-	"\xe5\x3b\x5e\xa1\x6a\xec\x12\x64\x23\x5a\xd9\x35\xda\x62\x7b\x2d\x6f\xb5\xda\x68\x34\x66\x09\x6b\x26\x0c\xce\x03" +
-	"\xf4\x2d\x21\x8a\xfc\x3b\x97\xac\xb0\x7c\xc7\xed\x61\x42\x91\x60\xc6\xbe\x21\x65\x83\x45\xc5\x4a\x2e\x37\x4b\xb0" +
-	"\xba\x69\x95\xd6\x1a\xcf\xc4\x0c\xdf\x48\x2c\xaf\xa5\x7f\xeb\x52\xb2\x84\xf7\x1f\xfc\x8a\xc6\xb5\x46\xb3\xa5\x53" +
-	"\x75\x67\xe9\x3b\x5e\xdc\x91\x71\xbd\x16\xcb\x2b\xbc\xc1\xb5\xbd\x45\x5d\x2c\x21\x4b\xb3\x3e\x54\x28\x96\x10\xfd" +
-	"\x8a\xd5\xb5\xe0\x85\xbb\x90\x91\x5b\xae\xd0\x6e\x55\x69\x28\x88\x4e\xc3\x62\x01\x37\x68\xa1\x31\xa8\xe1\x4e\xaa" +
-	"\x3d\xec\xb7\x68\xb7\xa8\xc1\x6e\x11\x0a\x55\x1f\xe8\x82\x56\x4c\x96\xb0\x67\x06\x4c\x53\x14\x68\xcc\xba\x11\x3e" +
-	"\xc6\xaa\xc4\x57\xaa\x3e\x7c\x8f\xa6\x11\x36\x6e\x77\xfb\x14\x3b\xb8\x15\x1a\x99\xc5\x3f\x0a\xd4\x27\x09\x78\x09" +
-	"\x51\xfb\x18\xc1\x12\xa2\x92\xc9\x0d\xea\x68\x0e\x11\x69\x03\xab\xa0\x10\xbc\x5e\x29\xa6\xcb\x34\x4d\xfd\x7a\xe9" +
-	"\xcc\xe1\x58\x0e\xb6\xa3\x64\x70\x31\x16\x0b\xf8\x87\x66\x75\x8d\x1a\x98\x56\x8d\x24\x61\x66\x2c\x38\x1b\xb8\xf2" +
-	"\x78\x0a\x0d\xda\x31\xcd\x99\xb4\x73\xb0\xdc\x0a\x9c\x83\xc5\x7b\x3b\x27\x68\xa9\xbf\xf0\x12\x5f\xa3\x60\x87\xfc" +
-	"\x59\x96\x65\x23\x9f\x2e\x56\xbb\x77\xa4\x38\x75\xea\x63\xff\x55\x0f\xec\xc1\xf7\xf3\xd3\xb2\x3f\xa3\x7f\xa5\x6f" +
-	"\x1d\xd8\x56\x97\xed\xf3\xe5\x4a\x59\xab\xaa\xcb\x02\xa5\xa5\x80\x9c\x64\x3b\x3b\xbb\xab\x72\xe6\xf6\x77\x8c\x4b" +
-	"\x58\x37\xb2\x20\x37\x99\xe0\xf6\xb0\x04\x07\x3a\x97\x24\x33\x87\x2d\x93\xa5\x40\x40\xad\x95\x36\xc0\x65\x21\x1a" +
-	"\xc2\x69\x77\x73\xfa\x9b\x41\xa1\x36\xf1\x89\xc7\xf3\x31\xe9\x4f\x51\xf2\xf0\x46\xc1\x8f\x3f\xc2\x13\xb7\xd1\xa1" +
-	"\xfc\x9c\xa6\xa7\x88\xc2\x7d\x72\xba\x54\x44\x41\xc3\x95\x74\x83\xee\x3e\xc7\x09\xfc\xda\x6f\xb5\xc6\xc3\x0b\x57" +
-	"\x53\x5e\x33\x4b\x5c\x7c\x92\x0a\x4f\x5d\x2c\xc0\x08\xb5\x87\x52\xed\x25\x98\x9a\x55\xd5\x01\x34\xfe\xb7\x41\x63" +
-	"\xcd\x63\xa6\x05\x09\xef\xad\xca\x83\xa3\x66\xa1\xc8\x28\x08\x39\xdd\xff\x59\x40\xce\x2d\xe0\xdf\x34\xb2\xc8\xfb" +
-	"\x32\x09\x79\x7e\x5e\x56\x5f\x7a\x8d\x4d\x5d\x32\x8b\x2e\x1f\xb0\x0c\x97\x6e\x5b\x42\x09\x62\xf7\x69\x85\x5d\x28" +
-	"\x3b\x26\x82\x27\x79\xee\x68\x24\x0c\x51\x60\x60\x3c\x90\xee\x4b\xd4\x64\xb6\x73\x4f\xa0\x9f\x49\x30\xbb\xe7\xca" +
-	"\x50\x6e\xe2\x8f\x0e\x91\xe9\x7f\x8c\x92\x2f\xb9\xcd\x2f\x1e\x4e\xe6\x1e\x3f\xf6\x47\xa5\x76\x8b\x32\xd6\x68\x6a" +
-	"\xc8\x5f\x0c\x6a\x4f\x68\x27\xed\xa7\x54\x15\x92\x40\x60\x00\x8d\x9c\x4a\x02\xa5\xff\x7b\x34\x68\x3b\xb8\x13\x89" +
-	"\x3c\xcf\xb2\xaa\xcf\xfd\x31\x38\xba\x70\xd5\x1c\xb5\x1e\x1f\x3d\xd2\x3c\x78\xfd\x1a\x9e\xa6\xcf\xe1\x05\x5c\x65" +
-	"\x59\x96\xc1\xcb\xf6\xff\xe5\x84\xd0\x2c\x6c\x05\xd6\x40\x07\xa5\xe4\x88\x92\x06\x29\x55\xe1\x7b\x6a\x2c\xb3\x8d" +
-	"\x19\x56\x5f\x00\xb3\xe7\x94\xc6\xf8\x27\x88\x02\x14\xcc\x20\xfc\x26\x7b\xba\x1c\xad\x4f\x10\x74\x40\xc5\x37\x6a" +
-	"\xb3\xc1\x12\x54\x63\x5b\x0e\x7e\x8b\x7a\x87\x1a\xb6\xcc\x80\x54\xb0\x63\x82\x13\xbd\xde\xa1\x74\x4d\xc6\x41\x35" +
-	"\x4b\xf8\xa7\x6a\x40\xa2\xe7\x68\x8d\x97\x42\x6d\xb8\x4c\xa3\x64\xfa\xdc\x8e\x1c\xce\x00\x34\x90\xea\x4a\xa3\x6f" +
-	"\xd7\xc6\x22\x2b\x8d\xec\x6e\x36\xe5\xef\xf3\x2c\xfb\x32\x7f\xff\xa6\x6a\x43\x7e\x7e\x7c\xab\xa8\x48\x12\x37\xee" +
-	"\xe9\xee\xec\xb5\xa2\xc7\x2d\xf9\x49\xc8\xa7\x8d\x83\x6a\x74\x47\xac\x7b\x2e\x04\x58\x7d\x00\xb6\x21\x0e\xe6\x12" +
-	"\x2e\x1e\xbe\x63\x76\x9b\xba\x02\x14\x0f\xd2\xbf\x80\xa7\x54\x4c\x8e\x26\x4d\xd3\x8f\xf3\x01\x34\xce\x83\xe4\x7c" +
-	"\xfb\xc3\x60\xf9\x18\xbc\x1d\x01\x85\xc1\x51\xb2\xa9\xbf\x55\x02\x53\x47\xf3\x84\x8e\xa1\xda\x9f\xe4\xff\xbb\x2d" +
-	"\x76\xd4\x18\x44\xe0\x17\x71\xf4\x38\xbe\x0a\x21\x8d\x9d\xf3\xd8\x18\xda\x9f\x01\x88\x67\x6e\xdf\x8f\xd1\x6d\x25" +
-	"\x42\x9e\x8a\x65\x78\xf5\xd7\x5c\x32\x21\x0e\x71\x9c\xb8\xbb\x3f\x4d\xed\x0e\xae\x13\x45\xf8\x8d\xd2\x15\xb3\x0e" +
-	"\x18\xee\x4e\xac\xd0\xd2\xa8\xa2\x91\x95\x6c\xc5\x7d\x51\x26\x52\xa0\xbf\x4a\x43\xfb\x94\x65\xbe\x03\x5e\xbb\xaf" +
-	"\x89\xee\x63\xd2\xd0\x3b\xdb\xf6\xc3\xb4\xd8\x1b\xaa\xb1\x16\xac\xc0\x78\xf1\xaf\xf8\x7d\x76\xf9\xcd\x87\x87\xab" +
-	"\x63\xd2\x3f\x5d\x2c\xe6\x10\x5d\x3c\x85\x8b\x67\x51\x42\x96\xfd\x16\x4a\xbe\xe1\x41\xa9\x3b\xff\xfe\x59\xf8\x7d" +
-	"\xbf\xd6\x6b\x82\x8b\x2b\xaf\xec\x77\x3f\x4f\xd9\xd5\xa4\xb2\xdf\x87\xca\x82\x58\xfe\xe0\x2a\x9d\xeb\x70\x35\xac" +
-	"\x98\x06\xea\x41\xad\xe6\x9b\x8d\x8b\xe8\xa5\xcb\x09\xcd\x34\xee\x1a\xc2\xea\xe0\x44\x41\x63\xc5\xb8\xe4\x72\xf3" +
-	"\x68\x17\x1d\xcc\x4c\x7e\x1a\x34\x58\x28\x59\x1a\xea\xa3\x3b\x4e\xef\xfa\xea\x38\x09\x2b\x7c\xd8\x6d\x43\x3e\xf8" +
-	"\x6e\xe1\x25\x4e\x83\x19\x11\x7d\x96\x85\xcd\x4d\x28\xfd\x2d\x5c\x11\xcf\x3f\x19\xd6\xe4\xae\x48\x4f\x35\x4d\x8b" +
-	"\x05\xbc\x56\xc0\x68\x94\x68\x3d\xb7\x0a\x6a\xad\x76\xbc\x44\x60\x60\x90\x55\x82\x6a\x31\xde\xd3\x44\x86\xb2\xc0" +
-	"\x89\x9a\xed\x3b\xbb\xa1\x21\x54\xa9\xce\xda\x85\xe5\x70\x8c\x4f\x06\x1d\x51\x90\xa3\x57\x4c\x14\x8d\xa0\x34\x9d" +
-	"\xc2\xee\xb3\x40\xe0\x77\x03\x44\xab\x70\xc5\xc2\x64\x0d\x46\x97\xd1\x88\xef\x63\x32\x1c\xde\x26\x86\xfc\xb3\x99" +
-	"\xd1\xa7\x92\x06\x98\x7c\xb2\x17\x1c\x5e\xa5\x78\xe2\x94\xa0\xbb\xbc\x24\x45\x49\x4b\x62\x8f\x00\xb3\xe4\xa6\x16" +
-	"\xec\x80\x65\xe0\x53\xd0\xb0\xc5\xae\x29\x19\x4d\x42\x83\xf3\x42\x43\x49\x38\x95\x78\x6f\xff\xbd\xd7\xac\x1e\x80" +
-	"\x2e\xa0\x37\x27\xe5\x2a\xae\x99\x4d\x32\x5c\x58\x46\x87\xd8\xf2\xdd\x5e\x17\xac\x3d\x97\xa5\xda\xa7\x06\x3b\x5b" +
-	"\xe2\x11\x48\xe6\xe3\x6b\xf0\xb5\xa3\xf3\x71\x28\xde\x5a\xa5\x29\xf7\x05\xf2\x1d\x96\xce\x3e\x97\x7a\x82\x84\x86" +
-	"\xc6\xb0\x0d\x06\x71\xe9\xba\xd6\xa9\xd0\x04\x86\xd2\xee\xe0\x20\x6f\xed\x9f\x44\xa3\x63\xdc\x05\x60\x58\x2c\xe0" +
-	"\x56\x63\xcd\x34\x82\xd9\x36\xd6\x75\xf6\x6a\xed\x3a\xbc\x8d\x1f\xfe\xda\xeb\x0f\xcc\x80\xa0\x22\xce\x0c\xd4\x6c" +
-	"\x83\x5d\x0b\xb3\x56\x45\x33\x88\xe4\xd9\xb4\x0f\xf9\x44\xac\xda\xd2\x70\x82\x64\x2b\x51\x08\x64\xfa\x9a\xe6\xb6" +
-	"\x1d\x13\x3e\xa0\x8f\x0f\xf1\xa3\x96\xfa\x71\xc1\x2e\x73\x03\xf1\x29\x3b\x03\xa9\xe3\xdc\x37\x9f\xc9\x44\x18\xdf" +
-	"\x90\xcf\xc3\x38\x9e\x46\xaf\x33\xb5\x23\x06\xba\xa5\xd8\x71\x1a\x1e\x7d\xe8\x7c\x23\x30\x87\x52\x81\x54\x34\xe0" +
-	"\x98\x1a\x65\x39\x19\x97\x01\xce\xce\xcf\xf9\x32\xf7\x66\x67\x7c\xf1\x89\x40\x0f\x5d\xb8\x96\xbe\x3b\xb4\xae\xc7" +
-	"\xf1\xe8\xb0\x3e\xd0\xee\xf7\x0d\xab\xea\x1a\xcb\x39\x68\xdc\xf1\x1d\x02\xb7\x5f\x90\xa7\x1e\x29\x9f\x47\xc1\x9c" +
-	"\xfa\xd3\x29\x5e\xf5\xff\x3a\x4b\xb9\xe5\x4c\xf0\xff\x21\x04\x3f\xe2\xcc\x00\x2a\xd5\x48\x8b\xfd\x4f\x7f\xbf\x98" +
-	"\x61\xe3\xc2\x71\xd6\x8d\x85\xb8\x4a\x59\x59\xfe\x79\x87\xd2\xde\x70\x63\x51\xa2\x8e\xa3\x95\x68\xa8\xb1\xf4\x60" +
-	"\xcb\x5f\x78\x7d\xa3\xcb\x9c\x7c\x5a\x85\x83\xd9\x23\x3a\x7a\x24\x27\x6d\xdc\x8e\xc9\x6c\xf6\xff\x00\x00\x00\xff" +
-	"\xff\x2d\x5b\xdc\x64\xa9\x16\x00\x00")
+	"\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb5\x59\xff\x93\xd3\xb8\x15\xff\x3d\x7f\x85\x98\xee\x5c\x9c\x6b\xd6\x04" +
+	"\xe8\xb5\xbd\x94\x1c\xd3\x42\x99\x32\xc3\x71\x0c\x70\xd7\xe9\x50\xca\x3a\xb6\x92\xe8\x70\x2c\x57\x92\x37\xbb\xdd" +
+	"\xcb\xff\xde\xcf\x93\x64\x5b\x72\xbc\x7c\x69\xe7\x18\x20\xb6\xf4\xfc\xfc\xbe\xbf\x8f\x9e\x73\x59\x69\xc3\x84\xe1" +
+	"\x2a\x33\x42\x56\x8f\x1b\xa5\x78\x65\xd8\x8a\x4d\x73\x77\x39\x9d\xe4\x31\xc9\x0b\x7e\x65\xf7\x2b\xfc\x4e\x27\x7e" +
+	"\x37\xab\x6b\xac\x55\xfc\xc0\x7e\x6a\x78\x72\x33\x99\x30\x96\xcb\x7d\xdd\x18\x5e\x2c\xd9\x0d\xee\x18\xdb\x88\x12" +
+	"\x2c\x78\xf1\xcc\xf0\xbd\x4e\x66\x7e\x95\x31\xb1\x61\x89\xd9\x09\x9d\x3a\x02\xb6\x5a\x81\xf9\xb4\xdf\x67\x4c\x71" +
+	"\xd3\xa8\x8a\x59\x22\x69\x6a\xcb\xc0\x6f\x1e\x27\xfe\xc2\x89\xd1\xb2\x60\x01\xc3\xd4\xc8\xe7\xf2\xc0\xd5\xe3\x4c" +
+	"\xf3\x64\x16\x91\x0b\xe2\x04\xea\xb7\xef\x5a\x36\x1b\xa9\x58\x52\x72\x6c\x31\xb9\x89\xdf\x18\x4a\x74\xf7\x2e\x7b" +
+	"\x95\x1d\x58\x95\xed\xb9\x66\x99\xe2\x4c\x56\xe5\x35\xd3\x78\xee\xb0\xe3\x24\x29\xb7\x7b\xec\x90\x69\x56\x49\xb5" +
+	"\xcf\x4a\xf1\x1f\x5e\x74\x8f\x93\xca\x22\x25\x8a\x58\xb8\x74\x9f\x99\x7c\x97\x38\xb9\x67\xec\x97\x5f\x88\x4c\x65" +
+	"\x87\xf7\x96\xd9\x57\x5f\xb1\xfe\xee\x63\x0f\xce\x42\x51\x99\xd3\x32\xad\x1b\xbd\x4b\xc4\xac\x5b\x3f\x0e\x2d\xe8" +
+	"\xad\x2c\x3a\xeb\x1e\xe7\x6e\x6b\x2f\xaa\x97\x5c\x09\x59\x04\x4e\x23\x13\x61\x1d\xb6\xfb\x96\xfe\x7c\xa1\xf9\x9c" +
+	"\xfe\xb5\x65\xca\xbe\x63\x0b\xa7\x9a\xbf\x7f\x48\x8c\x63\x0d\xdc\x9b\x5a\x8a\xdb\x55\x20\xbe\x96\x76\xe5\xe5\x0a" +
+	"\xd9\x38\x26\x0f\x16\xb7\xe8\xfd\x7d\x66\x76\x30\xe3\x15\x31\x98\x13\xf1\x2b\xbe\x51\x5c\xef\x66\xad\x2d\x5a\x83" +
+	"\x14\x99\xc9\xda\x98\xce\x1a\xb3\xfb\x51\x95\x73\x7b\xb3\xce\xf2\x0f\x72\xb3\x59\xb2\x6f\x16\x0b\xb7\xe2\x53\xe8" +
+	"\x8d\xd8\x73\xd9\x98\x25\xab\x9a\xd2\xd3\x6e\x32\x51\xf2\xe2\xb5\x6c\x54\xce\xf5\x12\xf1\xde\x2e\x97\x25\xb1\x59" +
+	"\xd2\x95\xe6\x7e\x91\xc3\xb7\xcf\xaa\x97\x4a\x6e\x21\x90\x8e\xf7\xac\xc7\x7b\x06\x5b\x25\x9b\xfa\xa9\x2c\x0b\xae" +
+	"\xb4\x5b\x11\x55\x96\x1b\x71\x29\xcc\xf5\x88\x18\x65\xa6\xcd\x53\x62\x1f\x2d\xca\xac\x10\xd5\x76\xc9\x8c\x6a\xfc" +
+	"\x6b\x2a\x79\x58\xda\xec\x7e\x92\x19\x84\x9b\x5b\xac\x15\x3f\x79\x56\x8b\x6d\x85\x14\xaf\xdc\x5d\xeb\xfb\x25\x32" +
+	"\xcc\xad\x28\x67\x54\x12\x45\xb5\x0a\xbd\x11\xf9\x07\xd2\xa1\xe7\x62\xb0\xfd\x9c\x6f\x0c\xc2\x2e\x5f\xb2\x45\xea" +
+	"\xcd\x09\x79\xaa\x3c\xb3\x25\x25\x30\xc1\x21\x53\x15\xc4\xed\xac\xe8\xbc\xc4\x4b\xdc\xff\x06\x65\xa9\x14\xb9\x2d" +
+	"\x5b\x53\xbb\xbc\xe7\x66\x27\x0b\x4d\xfe\x9b\xf8\x44\x7e\x8e\x70\x6d\x34\xaa\xc6\x07\x68\x49\xf9\x8b\xec\x55\x36" +
+	"\x85\x73\x59\x5f\x53\x19\xdb\x67\x55\x61\x53\x59\x37\x39\xdc\xa5\x37\x4d\xe9\xdc\x2b\x0b\xfe\x18\x34\xaf\xb8\x6e" +
+	"\x4a\x93\xf8\xdd\x3e\xe6\x6c\xfc\xe7\x8a\x43\xe4\x3f\x97\x5c\x75\x14\xec\x11\x9b\xfa\xcb\x29\x83\x98\x45\x56\x6d" +
+	"\xb9\x9a\xce\xd9\x94\xb8\x31\x23\x59\x5e\x8a\x7a\x2d\x33\x55\xa4\x69\xea\xd6\x0b\x2b\x8e\xe0\x45\xb4\x3d\x9d\x45" +
+	"\x99\x0a\x6d\x2c\x25\x91\x10\x27\x92\xbb\x10\xba\x2e\xb3\xeb\x25\x7b\x43\x45\x89\x6a\x37\x89\x8d\x82\x95\x73\xe4" +
+	"\xba\x66\x3e\xeb\xb4\xc9\x94\xf1\x25\x8a\x08\x7e\xd8\x24\x54\x09\xe2\x32\x4d\x2b\x29\xf1\x78\x6f\x79\x50\xd6\xd2" +
+	"\xca\x25\xca\x5b\xf1\xbe\xa9\x8c\x28\x69\xcd\xaa\x4d\xb6\xfc\x6e\xd5\xc7\xcc\x90\x70\x36\x52\xe0\x63\xf6\x93\x38" +
+	"\xc9\x43\x9a\x6e\x3b\x50\xfc\xef\x0a\xce\x86\xe3\x32\x64\x40\x45\x56\x42\x68\x33\x6b\x7c\x38\xdf\xa9\x15\x78\xe2" +
+	"\x32\x53\x22\xab\xcc\x1c\xa1\x66\x4a\x8e\x1f\xbc\x75\x4e\xe9\x2c\xff\x26\x0a\xfe\x84\xc3\x62\xab\xfb\x8b\xc5\x62" +
+	"\xe0\xcc\xb3\xf5\xe5\x1b\x62\x9c\x5a\xf6\x89\x7b\xaa\xd7\x23\x7a\x7e\xde\x2d\xbb\x77\xf4\xb7\xf4\xac\x4d\xda\xf5" +
+	"\xb9\xbf\x3e\x5f\x4b\x63\xe4\xfe\x3c\x47\xb5\xa0\x48\xe8\x68\x5b\x39\x5b\x63\x9c\xf8\xfb\xfb\x0c\x65\x6d\x83\xbc" +
+	"\x20\x35\x61\x5e\x03\x4f\xdb\xac\xb4\x5e\xd4\x73\xb6\x43\x10\x94\x9c\x71\xa5\xa4\xd2\xa8\x06\x79\xd9\x50\x76\xb7" +
+	"\xd5\xaa\xaf\x30\x14\x39\x3a\xe9\xda\xfc\x6a\x88\x09\xc6\x3a\x76\x5c\x99\xa8\x61\xdd\xb1\x1b\x6d\x19\x38\x75\xf2" +
+	"\x58\xc9\xb6\x8f\x74\xa5\xa8\x0b\xa1\x6e\x25\xdd\x72\x5b\x43\xd1\x7f\x7e\xeb\xb6\xbc\xf0\xe8\x1d\x7d\x51\xea\xa9" +
+	"\x06\x9d\x5a\x97\x88\xc5\x42\x1e\x2a\xa6\xeb\x6c\xbf\xbf\x86\x24\xff\x6e\xb8\x36\xfa\x36\xd1\x02\x87\xf7\x52\x05" +
+	"\xb1\x3c\x9b\x84\x24\x03\x23\xac\xa8\x6a\x4e\x82\x36\xe9\x33\xfd\x29\x7c\xb4\xea\x51\x14\x7a\xd4\x29\xea\x7a\xe4" +
+	"\x38\x36\x35\x7a\x0c\xb7\xfe\x40\x79\x08\x96\x5e\xfa\x8a\x3b\xe9\x74\x7b\x23\x3f\xf0\x8a\x30\x86\x61\xfc\xaa\x96" +
+	"\x9a\x3c\x8b\xd2\x25\x54\x90\xea\x15\xa7\xaa\x41\xf5\xcc\xbf\xc6\x46\x06\x15\x89\x35\x77\xae\xef\xa0\x89\x4f\xf2" +
+	"\x8f\x88\xd8\x3a\xa7\x2d\xfe\xec\x0e\xa8\xa8\x72\xd3\xce\x9d\x68\x0b\x49\x42\xc2\xa5\x5a\xc2\x29\x80\x8c\xdf\x31" +
+	"\x83\xb2\xf0\x9e\x04\x8b\x5c\x14\x18\x28\x89\x18\xf4\x60\x65\x34\xda\x56\xae\x0b\x7c\x22\xc0\xc8\x48\x61\xe5\x73" +
+	"\x78\xcd\x57\x45\x18\x66\xcd\x01\x5a\x78\x64\x1f\x59\x81\x0a\xe6\x14\x8a\x0f\xe0\x22\xfb\x94\x07\x61\x04\xbd\x93" +
+	"\x07\x8b\x91\x09\xd8\xa0\xd2\x23\xb5\x77\x54\xe6\x3b\x5a\xcf\x33\xbb\x12\x52\x53\xd0\x26\x17\x56\xae\xf4\x67\x2d" +
+	"\xab\x47\xc2\xac\xce\x6e\x84\x39\x5e\xf4\xca\xa7\x90\xad\x4a\xa0\x72\x4d\x36\x0c\x71\x51\x68\x39\xda\x4f\x09\x9b" +
+	"\xcc\x02\x82\x28\x59\x56\x04\x4c\x2c\x74\xe5\x84\x54\xdb\x65\xc4\x01\xd6\xf7\x7d\x36\x1c\x83\x57\xe7\x16\x57\xa2" +
+	"\x78\x0c\x5f\x3d\xe0\x1c\xdd\x7e\xcd\xee\xa5\xdf\x40\xfd\x07\x28\xa0\x64\x02\xf7\xbb\x1c\x21\x9a\x84\x30\x15\xb1" +
+	"\x87\x17\xa5\xa4\x08\xcc\x6d\xdb\x4b\x78\x9f\xa2\x45\x99\x46\xc7\xc8\x10\x36\x38\x08\x0a\xc3\xe4\x33\x48\xe1\x47" +
+	"\xa0\x65\xf6\xbb\xc5\xbd\xe5\x60\x7d\xa4\x57\x07\x5d\xf9\xb9\xdc\x6e\x11\x29\xc0\x4e\xbe\x1d\xbf\xe6\xea\x12\xad" +
+	"\x66\x67\xd1\x3d\xb3\x5d\x8d\xd9\x60\xb7\x00\xf8\x5a\x36\x4b\xf6\x0f\xd9\xf8\xc4\x93\x08\xcc\xf3\x52\x6e\x45\x95" +
+	"\x4e\x67\xe3\xef\x6d\xcb\xe5\x49\x48\x47\x54\x2d\x9a\x72\x07\x96\x21\xc9\x1a\xb2\x7f\x98\x8c\xea\x7b\xff\xfe\x97" +
+	"\xe9\xfb\x46\x4a\x06\xcc\x73\xcd\x7c\x02\x3b\xa5\x91\x45\xc8\x13\x4a\x1e\x13\xef\x93\x8a\x3e\x9f\xe6\xac\x2e\x39" +
+	"\xbd\x33\xd3\x1f\xc8\x0e\x68\xc7\x05\xa0\xb4\xd0\x06\xa1\x0f\xd3\x58\x60\x52\x6d\xc4\xb6\x21\x56\x38\x23\xa1\x2b" +
+	"\xe1\x10\x43\x50\x72\x23\xae\xe8\x35\x61\x8c\xcc\xbe\x40\x49\x84\xf0\x97\x29\xf9\x83\xac\x35\xbd\xf0\xe2\xb5\x24" +
+	"\x50\x48\x85\xf3\x40\x19\x7c\x50\x92\x2e\xe9\x18\x67\x0b\x0e\x6d\x58\x4d\x7c\x3f\x3d\x08\xd4\x3a\xa3\x00\xae\xb6" +
+	"\xd4\x7a\xf1\xf7\xec\xc6\x9e\x1d\x2c\xee\x48\xa2\x18\xbf\xcb\xee\x11\x86\x38\x92\x09\x2f\x3e\x4b\xb7\x3f\x45\xcb" +
+	"\xc7\xe0\xee\x08\x48\x0b\x3d\xe3\x88\xa6\xba\x24\x4b\x9e\xda\xee\x4e\x29\x10\xb3\xfd\x2c\xfd\xa9\x3a\xfa\x8e\x18" +
+	"\x58\xe0\x57\x51\xf4\x38\xcc\xf7\xb0\x98\x9e\x56\xd3\x61\xfe\x7e\x22\x0b\x5c\xc3\x76\x87\x17\x2a\x49\xd4\x87\xc7" +
+	"\x6c\x19\xd6\xb7\x0d\x8e\x47\x65\x79\x9d\x00\x5a\x50\x81\x1b\xef\xe8\x36\x27\x47\xb0\xd7\x53\x3a\xd9\xfb\x26\x4b" +
+	"\x89\xbf\xe6\x86\xa6\x0f\xb0\x77\x91\xad\x85\xc3\x62\x54\xf9\xe8\x1f\xb6\xfd\x15\xfe\x4d\xfc\x59\x19\x4f\x53\x97" +
+	"\x4f\x88\x43\xaf\xac\x47\xba\x01\x06\x86\xa0\x8a\x23\xbf\x72\x9e\xdc\xfd\x57\xf2\x76\x71\xfe\xed\xbb\x9b\x07\xc7" +
+	"\x59\x7f\x75\x76\x17\x1e\x3d\xbb\xc7\xce\xee\x4f\x67\x24\xd9\xef\x91\x90\x5b\x11\x20\x9c\xd3\xe7\xef\x87\xcf\xf7" +
+	"\x6b\x3d\x27\x76\xf6\xc0\x31\xfb\xc3\xff\xc6\xec\xc1\x28\xb3\x3f\x86\xcc\x02\x5b\xbe\xe8\xe0\x4a\x5f\x4d\x50\x45" +
+	"\x18\xf2\xcf\x50\x4b\x55\xa6\xbc\x1e\xeb\xd5\xf6\x11\xd7\xab\x1d\x4f\x6a\xf4\x8f\x6f\x3b\xc0\x74\xbd\xf9\xe1\x0a" +
+	"\xcd\x99\xe0\xea\xe0\x48\xd3\x2d\x85\x47\x95\xd3\x93\xca\x74\x3a\x3e\x7f\x2a\x71\x68\x45\xe8\x25\xb7\x1f\x7b\x02" +
+	"\x2c\x7b\xde\x9d\x94\x42\xe8\xea\x52\x29\x0e\x05\xcb\xd6\xcf\x49\xec\x35\xc4\xef\x54\x79\x34\x38\x37\xd1\x89\x72" +
+	"\x3a\x34\xef\x6b\x50\xa3\xe0\x6e\xec\x64\x80\xed\x10\xa1\xf8\xa1\x93\x03\x72\xdd\xb8\x59\x0d\x62\x58\x28\x3f\x14" +
+	"\xa3\x15\x8e\x16\x77\xed\x9f\x70\x93\x04\xfd\x94\x08\x9e\x55\x6e\xbe\x90\x88\xe2\x2a\xb6\xae\x83\x7f\xe1\x08\x62" +
+	"\xc4\x74\x61\x83\x3b\x99\x3d\x15\x57\xb6\x10\x58\xd7\x04\x23\x3c\x3f\x34\x7c\x4b\xfb\xe7\xec\xde\xbb\xd4\xeb\x41" +
+	"\xd8\x73\x9c\xac\x25\x19\xda\xe1\x47\x8b\xa3\xed\x80\x01\xf9\x9a\x29\x7b\x44\x36\x4a\xa0\xc1\x53\xe2\x9e\xdb\xd4" +
+	"\x27\xed\x1d\x54\x5c\x5f\x5b\x52\xec\xec\x51\x03\x51\x57\x6e\x1d\x62\x24\x83\x93\x22\x9d\x7f\xc7\x8e\x0c\x2e\x4e" +
+	"\x34\xc7\x6f\xa1\x9f\xbb\x70\xb1\x0f\xb4\x33\x8f\x6e\x44\x19\xad\xd2\x24\x04\x94\xe1\x73\x77\x1d\x45\x37\x9d\x23" +
+	"\x44\x85\xc0\x09\xa3\x3d\xa0\x7e\xc8\x1e\x9c\x22\xf4\x0e\xcd\x8f\x9d\xd7\x60\xad\x27\x12\x31\x53\x77\x66\x41\x66" +
+	"\xd6\x4a\x5e\xe2\x80\x8b\x65\xcd\xb3\x7d\x49\x30\x1c\xc9\x87\xf7\xf3\x2a\xe7\x23\x70\xdd\x1d\x2a\x63\x41\x16\x2e" +
+	"\x64\x63\xe0\xbc\x8c\x07\xcc\xb3\xe8\x30\x16\xce\x37\xb2\x32\x6f\x4a\xf2\x61\xe7\x13\xe7\x22\x2a\xc0\x61\x61\x58" +
+	"\x67\xa1\x27\xa3\xb1\x52\x32\x16\xb6\xf1\xac\x6e\x24\x70\x17\xe3\x29\x3f\x74\x74\x9f\xcb\x71\x6c\x27\x23\x6f\x89" +
+	"\x8a\x01\x18\x45\xd9\x7f\x1a\xb5\xfd\xa1\xa5\xd7\x29\x38\x2b\x26\x16\xfd\x47\xaa\xd1\x4a\xda\x8d\xcd\xfa\x00\xe8" +
+	"\x96\x42\x3d\x4f\xd1\x82\x1f\xad\x11\x5c\x78\x56\xd1\x3c\x1f\xe7\x59\xce\x4a\xc0\x39\x8f\x09\x5f\xe0\xdc\x89\xfe" +
+	"\x49\x71\x09\x35\x35\xe4\x6a\x4a\x3a\x52\x31\x9d\x67\x15\x22\x8a\x4a\x0c\x59\x7d\x6e\x5d\x43\x0f\xf6\xb9\x25\x74" +
+	"\x3f\xd0\x23\x66\xa4\xf8\x62\x76\xcb\x5c\x3f\x1c\x97\x52\x81\xb5\x8a\x69\x7f\x8f\x62\xf1\xf6\xdd\xcc\x17\x81\x44" +
+	"\x53\x27\xd7\xfe\x08\xe0\xbe\x26\x58\x78\x34\xa5\x29\x79\xdd\x6d\xdb\x67\x67\xe9\xcf\x52\x54\x09\xa9\x32\x0b\xcc" +
+	"\x16\xbf\x0e\x56\x8b\x17\xfa\xb2\x13\x2e\xff\x7f\xa6\xb4\x30\xcc\xe3\x69\x98\xe8\xec\x26\xe2\x7d\xf4\x96\xa5\x73" +
+	"\x3e\xac\x4b\x20\x07\x46\xbb\xf8\xb8\xd1\xda\xc1\x68\x67\xaf\x6e\xc1\x1b\x8c\xcc\x71\x20\x73\x1c\xec\xb7\x08\xa4" +
+	"\xe5\xc5\xd9\x8d\xbb\x3e\x2e\x19\x5d\xfa\x27\x8e\x17\xc8\xcf\xee\xae\x35\xda\x3f\xab\xc8\x68\x1d\x77\xd8\xab\xbb" +
+	"\xee\x4c\xd5\xae\x7c\xb6\x95\xec\x64\xa3\x63\xe4\x8c\xd4\xde\x7d\x2a\x58\xdc\x10\x1d\x7a\xbb\xf8\x3f\x1d\x43\xb4" +
+	"\x24\xb1\xd3\xfd\x63\x5d\x96\x74\x54\x9f\x2b\xf3\x4f\x59\x53\x1a\xd6\x54\xd9\x25\x9c\x97\xad\x01\xcc\x5d\x9a\xb8" +
+	"\xf5\xc8\x85\xe0\x40\x73\x97\xb9\xfb\x72\x44\xd9\xc1\xd1\x4d\xb6\x28\xa3\xdd\xb1\x2a\x9a\x53\xdc\x9e\x21\x23\x75" +
+	"\x25\x2c\x48\xd6\x04\x16\x1c\xf8\xb6\x45\xde\xef\x17\x0f\x2a\xab\xa3\x8e\x33\x4c\xb5\xe8\x3e\x26\xec\xcc\xdc\x5e" +
+	"\x46\x9d\xab\xab\x3a\xad\x1b\xfa\x85\x55\x84\xce\xa3\xf8\x00\x75\x7b\x39\x19\x47\xfd\x81\x4f\x27\xa3\xc0\x3f\x44" +
+	"\x18\x71\xbb\x73\x93\xaa\xd6\x70\x07\x51\x15\xc0\x5e\x9a\xb7\x66\x4b\x06\x7d\x6b\x3e\xec\xcc\x5f\xdf\xeb\x1c\x10" +
+	"\x62\x2b\x43\xa0\x54\xf1\x9c\x8b\x4b\x68\x47\xf2\xd9\x6e\x44\x5d\x4a\xb1\x46\x67\x5b\x1e\x94\xea\x76\x86\x37\xa8" +
+	"\xd6\x43\x41\x69\x37\x7a\x91\x93\xf6\x2f\x65\x83\x73\xde\x65\xd0\x9f\x20\x00\x58\xd6\x14\x2c\x7a\xd7\x18\x3b\xe7" +
+	"\x44\x01\x21\x77\x6c\xdd\x28\xbc\xf5\x3b\xd0\x74\x49\x67\x5b\xfc\xd6\x90\xa9\x1d\x5f\x6c\x64\xde\x44\x96\x3c\xf9" +
+	"\x62\x44\x3e\x39\xb1\x95\x3f\x31\x75\x89\xe1\x29\x72\x9c\xff\xd5\x33\x9a\x62\x03\xf5\x3a\x83\xde\xfe\xcd\x67\x30" +
+	"\xe0\xbb\x9d\xb0\xf5\x5c\x44\x3e\x26\x67\x40\x75\x9c\xbb\xc1\xd3\x6c\xc4\x8c\x4f\x49\xe7\xd8\x8e\xdd\x20\xfa\x84" +
+	"\xed\x00\x14\xbd\x24\xdb\x09\x6d\xd1\x33\xb1\x71\xe7\xe3\x39\x2b\xa4\xcd\x6d\xdd\xe8\x9a\x57\xc5\xa8\x5d\xa2\x38" +
+	"\x3b\x7d\xcf\x97\xa9\x37\x39\x81\x30\x1f\x31\x74\xac\xc2\xb3\xca\x0d\x4d\x8c\x3d\xfa\xbb\xe8\x30\xce\xd0\xf6\x33" +
+	"\x97\x91\x75\x4d\xb5\x49\xf1\x4b\x84\x34\x90\xd9\x17\xf8\xa9\x8f\x94\x4f\x47\xc1\x9c\xc6\x36\x63\x50\xcf\xfd\x6f" +
+	"\x25\x15\x46\xd8\xaf\xe7\x2c\xf8\x96\x47\x1f\xf2\x10\xda\xa8\x25\x1d\x94\xfb\xd5\x04\x1b\x62\xd9\x93\x21\x45\x18" +
+	"\x57\x69\x56\x14\x7f\xbd\xc4\xea\x73\x34\x76\x5e\x01\x8d\x4c\xd7\xc8\x58\xd4\x6e\x17\x6c\xd4\x7c\x6c\xb5\x8b\x93" +
+	"\x79\xf6\x71\x16\x36\xcc\x6e\xe1\xd1\x47\xf2\xcc\xdb\xed\x08\x89\xfe\x0b\x3c\x70\x8b\xd2\xd6\x21\x00\x00")
 
 func bindataApplicationjsBytes() ([]byte, error) {
 	return bindataRead(
@@ -281,10 +328,10 @@ func bindataApplicationjs() (*asset, error) {
 
 	info := bindataFileInfo{
 		name: "application.js",
-		size: 5801,
+		size: 8662,
 		md5checksum: "",
 		mode: os.FileMode(436),
-		modTime: time.Unix(1568760800, 0),
+		modTime: time.Unix(1791999617, 0),
 	}
 
 	a := &asset{bytes: bytes, info: info}
@@ -293,15 +340,14 @@ func bindataApplicationjs() (*asset, error) {
 }
 
 var _bindataStaticManifestjson = []byte(
-	"\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x91\xb1\x6a\xc3\x30\x10\x86\x77\x3d\xc5\x71\x73\xda\x20\x21\x83\x9d" +
-	"\x37\xe8\xd4\x0e\x6d\x97\x52\xcc\x61\x8b\x54\x54\x91\x8c\x74\x01\xa5\x21\xef\x5e\x24\x0f\x9d\x54\x02\x5e\x6e\xf8" +
-	"\xbf\xff\xf8\x86\xff\x2a\x00\x70\xb6\x69\x71\x74\xc1\x03\x60\x62\xf2\x33\xb9\xe0\x0d\xee\x0a\xb2\x53\xf0\x09\x0f" +
-	"\xf0\x21\x00\x00\xae\xf5\x02\x60\xb2\x3f\xa6\xc4\x28\x55\x9f\xa5\xea\x6b\x79\x25\x71\x2a\xf9\x42\xb3\x0b\xd3\xf7" +
-	"\x28\x55\xff\xb8\xf8\xe3\x1f\xe7\xcb\x62\x4a\xc1\x9e\xe8\x68\xf6\x05\x55\x72\xdb\xb5\x04\x5a\x67\xa9\x75\x5b\xa0" +
-	"\xf5\x46\x41\xa7\xb2\xec\x54\x5b\xd0\xa9\x8d\x82\x41\x65\x39\xfc\x23\x18\xee\x14\x08\x80\xcf\x3a\x8a\xa7\x53\x2d" +
-	"\xbc\xd3\xd9\x31\x3c\xbf\xbe\x3c\xbc\x3d\xad\x73\x85\x68\x8d\x67\x62\x1b\x7c\x55\x84\xc8\x91\x2c\xaf\x30\x7d\x85" +
-	"\xc8\x63\xfb\x39\x31\x45\x1e\xcf\xd1\x15\xbc\x47\x71\x13\xbf\x01\x00\x00\xff\xff\x40\xd0\x36\x48\x1e\x02\x00\x00" +
-	"")
+	"\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa5\x91\xb1\x0a\x83\x30\x10\x86\x77\x9f\x22\x64\xb6\x96\x8a\x42\xed\x1b" +
+	"\x74\x6a\x87\xb6\x4b\x29\x72\x68\xb0\xa1\x31\x09\x49\x04\xad\xf8\xee\xbd\xc4\xa1\x93\xa5\xe0\x70\x07\xf7\x7f\x77" +
+	"\x7c\xc3\x8d\x11\x21\xb4\xe6\x56\x0b\x18\xe8\x81\x50\xeb\x40\xd6\x20\x94\x64\x34\xf6\x88\x57\x4a\x5a\x04\x77\x1c" +
+	"\x08\x19\x43\xc7\xd8\xf2\x37\xf3\x31\xdd\xa5\xfb\x1e\x2b\x2c\xcf\xc4\x54\x3e\xd7\x50\x0b\x55\xbd\x4a\x64\x89\x96" +
+	"\xcd\x97\xbb\x41\x33\xbf\xc0\x5b\x68\xd8\xd6\xa3\x40\xa6\x78\x49\x90\x65\x3d\xd6\xb2\x20\xcb\x56\x0a\xf2\xb4\xc7" +
+	"\x5a\x16\xe4\xe9\x4a\x41\x81\x82\xe2\x87\xa0\xf8\x53\x80\xfd\x11\x9e\x22\xa1\x0d\x0b\x37\xe8\x84\x23\xa7\xcb\x79" +
+	"\x73\x3d\xce\xef\x52\x86\x33\xe9\xc0\x71\x25\x83\x42\x19\x67\x80\xbb\x19\xda\x27\x8e\xe5\xf2\x31\xfe\x1e\x79\x67" +
+	"\x84\xc7\x49\xb2\xa5\xd1\x14\x7d\x00\xf2\x5d\xcd\x06\x20\x02\x00\x00")
 
 func bindataStaticManifestjsonBytes() ([]byte, error) {
 	return bindataRead(
@@ -320,10 +366,10 @@ func bindataStaticManifestjson() (*asset, error) {
 
 	info := bindataFileInfo{
 		name: "static/manifest.json",
-		size: 542,
+		size: 544,
 		md5checksum: "",
 		mode: os.FileMode(436),
-		modTime: time.Unix(1497477798, 0),
+		modTime: time.Unix(1791999617, 0),
 	}
 
 	a := &asset{bytes: bytes, info: info}
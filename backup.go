@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	backupVersion       = 1
+	backupMinPassphrase = 12
+)
+
+// backupEntry contains the fields stored in a key, as they are, to
+// recreate it in another Vault instance. The name is only informational.
+type backupEntry struct {
+	Path   string                 `json:"path"`
+	Name   string                 `json:"name"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// backupBundle is the encrypted form of the backup entries: The key for
+// the NaCl secretbox is derived from the passphrase using scrypt
+type backupBundle struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	KDF     string    `json:"kdf"`
+	Salt    []byte    `json:"salt"`
+	Nonce   []byte    `json:"nonce"`
+	Data    []byte    `json:"data"`
+}
+
+func backupKey(passphrase string, salt []byte) (*[32]byte, error) {
+	k, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to derive key")
+	}
+
+	var key [32]byte
+	copy(key[:], k)
+	return &key, nil
+}
+
+// sealBackup encrypts the entries using the passphrase. The plaintext is
+// only held in memory.
+func sealBackup(entries []backupEntry, passphrase string) (*backupBundle, error) {
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to marshal entries")
+	}
+
+	b := &backupBundle{
+		Version: backupVersion,
+		Created: time.Now().UTC(),
+		KDF:     "scrypt",
+		Salt:    make([]byte, 16),
+		Nonce:   make([]byte, 24),
+	}
+
+	if _, err = io.ReadFull(rand.Reader, b.Salt); err != nil {
+		return nil, errors.Wrap(err, "Unable to generate salt")
+	}
+
+	if _, err = io.ReadFull(rand.Reader, b.Nonce); err != nil {
+		return nil, errors.Wrap(err, "Unable to generate nonce")
+	}
+
+	key, err := backupKey(passphrase, b.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], b.Nonce)
+	b.Data = secretbox.Seal(nil, plain, &nonce, key)
+
+	return b, nil
+}
+
+// openBackup decrypts the bundle using the passphrase
+func openBackup(b *backupBundle, passphrase string) ([]backupEntry, error) {
+	if b.Version != backupVersion || b.KDF != "scrypt" {
+		return nil, errors.Errorf("Unsupported backup version %d (%s)", b.Version, b.KDF)
+	}
+
+	if len(b.Nonce) != 24 {
+		return nil, errors.New("Invalid nonce in backup")
+	}
+
+	key, err := backupKey(passphrase, b.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], b.Nonce)
+
+	plain, ok := secretbox.Open(nil, b.Data, &nonce, key)
+	if !ok {
+		return nil, errors.New("Unable to decrypt backup, wrong passphrase?")
+	}
+
+	// Numbers are kept as they were stored instead of turning into floats
+	dec := json.NewDecoder(bytes.NewReader(plain))
+	dec.UseNumber()
+
+	var entries []backupEntry
+	return entries, errors.Wrap(dec.Decode(&entries), "Unable to unmarshal entries")
+}
+
+// backupEntries collects the stored fields of the tokens, tokens without
+// them (i.e. fallback tokens) are nothing to restore. The raw fields scan
+// also returns the keys rejected while building their token.
+func backupEntries(tokens []*token) []backupEntry {
+	entries := []backupEntry{}
+
+	for _, t := range tokens {
+		if t.Fields == nil || t.Deleted {
+			continue
+		}
+
+		entries = append(entries, backupEntry{Path: t.Path, Name: t.Name, Fields: t.Fields})
+	}
+
+	return entries
+}
+
+// withRawFields marks the scan to keep the fields stored in the keys on
+// the tokens
+func withRawFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyRawFields, true)
+}
+
+func rawFieldsWanted(ctx context.Context) bool {
+	raw, _ := ctx.Value(ctxKeyRawFields).(bool)
+	return raw
+}
+
+func handleExport(res http.ResponseWriter, r *http.Request) {
+	if cfg.Source == sourceFile {
+		// The file source grants the admin features to everyone (see
+		// hasAdminPolicy), exporting all secrets requires the admin
+		// policy of an actual Vault token
+		http.Error(res, `{"error":"Export is not available for the file source"}`, http.StatusForbidden)
+		return
+	}
+
+	_, tok, ok := getAdminVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	passphrase := r.FormValue("passphrase")
+	if len(passphrase) < backupMinPassphrase {
+		http.Error(res, fmt.Sprintf(`{"error":"Parameter passphrase must have at least %d characters"}`, backupMinPassphrase), http.StatusBadRequest)
+		return
+	}
+
+	ctx := withRequestID(r.Context())
+	res.Header().Set("X-Request-Id", requestIDFromContext(ctx))
+
+	// Without codes keys whose code can't be generated are exported too
+	secrets, err := getSecrets(withoutCodes(withRawFields(ctx)), tok, false)
+	if err != nil {
+		logger(ctx).Errorf("Unable to fetch codes: %s", err)
+		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	entries := backupEntries(secrets.Tokens)
+	bundle, err := sealBackup(entries, passphrase)
+	if err != nil {
+		logger(ctx).WithError(err).Error("Unable to create backup")
+		http.Error(res, `{"error":"Unable to create backup"}`, http.StatusInternalServerError)
+		return
+	}
+
+	logger(ctx).WithField("tokens", len(entries)).Info("Exported backup bundle")
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"vault-otp-backup-%s.json\"", bundle.Created.Format("20060102-150405")))
+	res.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(res).Encode(bundle)
+}
+
+// runBackupDecrypt decrypts the backup bundle given as an argument and
+// prints the entries to stdout
+func runBackupDecrypt(file string) int {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read backup: %s\n", err)
+		return 1
+	}
+
+	var b backupBundle
+	if err = json.Unmarshal(raw, &b); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse backup: %s\n", err)
+		return 1
+	}
+
+	entries, err := openBackup(&b, cfg.CLI.BackupPassphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write entries: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const testPassphrase = "correct horse battery"
+
+func TestBackupRoundTrip(t *testing.T) {
+	entries := backupEntries([]*token{
+		{Path: "totp/mail", Name: "Mail", Fields: map[string]interface{}{
+			"name":     "Mail",
+			"issuer":   "Example",
+			"secret":   "jbsw y3dp ehpk 3pxp",
+			"color":    "#ff0000",
+			"note":     "Shared with the on-call team",
+			"digits":   json.Number("8"),
+			"period":   json.Number("60"),
+			"offset":   "-5s",
+			"no_next":  true,
+			"config":   "otpauth://totp/Mail?digits=8",
+			"tags":     []interface{}{"mail", "prod"},
+			"encoding": "base32",
+		}},
+		{Path: "totp/vpn", Name: "VPN", Fields: map[string]interface{}{
+			"secret_ref": "totp/shared",
+			"type":       "hotp",
+			"counter":    json.Number("12"),
+		}},
+		{Path: "totp/deleted", Name: "Deleted", Deleted: true, Fields: map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}},
+		{Path: "Break-Glass", Name: "Break-Glass", Secret: "JBSWY3DPEHPK3PXP", Fallback: true},
+	})
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries without deleted and fallback tokens, got %+v", entries)
+	}
+
+	bundle, err := sealBackup(entries, testPassphrase)
+	if err != nil {
+		t.Fatalf("Unable to seal backup: %s", err)
+	}
+
+	// The bundle is written and read as JSON
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("Unable to marshal bundle: %s", err)
+	}
+	var read backupBundle
+	if err = json.Unmarshal(raw, &read); err != nil {
+		t.Fatalf("Unable to unmarshal bundle: %s", err)
+	}
+
+	opened, err := openBackup(&read, testPassphrase)
+	if err != nil {
+		t.Fatalf("Unable to open backup: %s", err)
+	}
+
+	if !reflect.DeepEqual(opened, entries) {
+		t.Errorf("Opened entries differ:\n got %#v\nwant %#v", opened, entries)
+	}
+}
+
+func TestOpenBackupErrors(t *testing.T) {
+	entries := []backupEntry{{Path: "totp/mail", Name: "Mail", Fields: map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}}}
+
+	for _, c := range []struct {
+		name       string
+		passphrase string
+		tamper     func(*backupBundle)
+	}{
+		{name: "wrong passphrase", passphrase: "incorrect horse battery"},
+		{name: "tampered data", tamper: func(b *backupBundle) { b.Data[len(b.Data)-1] ^= 1 }},
+		{name: "tampered nonce", tamper: func(b *backupBundle) { b.Nonce[0] ^= 1 }},
+		{name: "tampered salt", tamper: func(b *backupBundle) { b.Salt[0] ^= 1 }},
+		{name: "truncated nonce", tamper: func(b *backupBundle) { b.Nonce = b.Nonce[:12] }},
+		{name: "truncated data", tamper: func(b *backupBundle) { b.Data = b.Data[:secretbox.Overhead-1] }},
+		{name: "unsupported version", tamper: func(b *backupBundle) { b.Version = backupVersion + 1 }},
+		{name: "unsupported KDF", tamper: func(b *backupBundle) { b.KDF = "pbkdf2" }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			bundle, err := sealBackup(entries, testPassphrase)
+			if err != nil {
+				t.Fatalf("Unable to seal backup: %s", err)
+			}
+
+			passphrase := testPassphrase
+			if c.passphrase != "" {
+				passphrase = c.passphrase
+			}
+			if c.tamper != nil {
+				c.tamper(bundle)
+			}
+
+			if opened, err := openBackup(bundle, passphrase); err == nil {
+				t.Errorf("Expected error, got %+v", opened)
+			}
+		})
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			res.Write([]byte(`{"data":{"policies":["admin"]}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","broken","rejected"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/broken":
+			// No code can be generated from the secret
+			res.Write([]byte(`{"data":{"name":"Broken","secret":"not base32!"}}`))
+		case r.URL.Path == "/v1/totp/rejected":
+			res.Write([]byte(`{"data":{"name":"<b>Chat</b>","secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	export := func(t *testing.T) *httptest.ResponseRecorder {
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		r := httptest.NewRequest(http.MethodPost, "/export", strings.NewReader(url.Values{"passphrase": {testPassphrase}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = "127.0.0.1:42424"
+		r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+		res := httptest.NewRecorder()
+
+		handleExport(res, r)
+		return res
+	}
+
+	t.Run("vault", func(t *testing.T) {
+		withArgs(t, []string{
+			"--vault-addr", vault.URL, "--vault-prefix", "totp",
+			"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+			"--admin-policy", "admin",
+		}, nil, func(err error) {
+			if err != nil {
+				t.Fatalf("loadConfig() returned error: %s", err)
+			}
+
+			res := export(t)
+			if res.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", res.Code, res.Body.String())
+			}
+
+			var bundle backupBundle
+			if err := json.NewDecoder(res.Body).Decode(&bundle); err != nil {
+				t.Fatalf("Unable to decode bundle: %s", err)
+			}
+			entries, err := openBackup(&bundle, testPassphrase)
+			if err != nil {
+				t.Fatalf("Unable to open backup: %s", err)
+			}
+
+			// Keys failing to generate a code or to build a token are
+			// backed up as well
+			paths := []string{}
+			for _, e := range entries {
+				paths = append(paths, e.Path)
+			}
+			sort.Strings(paths)
+			if want := []string{"totp/broken", "totp/mail", "totp/rejected"}; !reflect.DeepEqual(paths, want) {
+				t.Errorf("Expected entries %v, got %v", want, paths)
+			}
+			for _, e := range entries {
+				if e.Path == "totp/broken" && e.Fields["secret"] != "not base32!" {
+					t.Errorf("Expected the stored secret of the broken key, got %+v", e.Fields)
+				}
+			}
+		})
+	})
+
+	t.Run("file source", func(t *testing.T) {
+		withArgs(t, []string{"--source", "file", "--admin-policy", "admin"}, nil, func(err error) {
+			if err != nil {
+				t.Fatalf("loadConfig() returned error: %s", err)
+			}
+
+			if res := export(t); res.Code != http.StatusForbidden {
+				t.Errorf("Expected status 403, got %d: %s", res.Code, res.Body.String())
+			}
+		})
+	})
+}
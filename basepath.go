@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// normalizeBasePath brings the configured base path into the form
+// "/path" without trailing slash or an empty string for the root
+func normalizeBasePath(in string) string {
+	if p := strings.Trim(in, "/"); p != "" {
+		return "/" + p
+	}
+	return ""
+}
+
+// withBasePath mounts the handler below the base path: the base path is
+// stripped from requests before passing them to the handler and all
+// requests outside the base path are rejected. The health check is also
+// served at the root for probes not knowing about the base path.
+func withBasePath(base string, next http.Handler) http.Handler {
+	strip := http.StripPrefix(base, next)
+
+	return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/healthz":
+			next.ServeHTTP(res, r)
+		case r.URL.Path == base:
+			// Relative links in the page require the trailing slash
+			http.Redirect(res, r, base+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, base+"/"):
+			strip.ServeHTTP(res, r)
+		default:
+			http.NotFound(res, r)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithBasePath(t *testing.T) {
+	h := withBasePath("/otp", http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Write([]byte(r.URL.Path))
+	}))
+
+	for _, c := range []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/otp/", wantCode: http.StatusOK, wantBody: "/"},
+		{path: "/otp/codes.json", wantCode: http.StatusOK, wantBody: "/codes.json"},
+		{path: "/otp/healthz", wantCode: http.StatusOK, wantBody: "/healthz"},
+		{path: "/healthz", wantCode: http.StatusOK, wantBody: "/healthz"},
+		{path: "/otp", wantCode: http.StatusMovedPermanently},
+		{path: "/codes.json", wantCode: http.StatusNotFound},
+		{path: "/otpx/", wantCode: http.StatusNotFound},
+	} {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, c.path, nil))
+
+		if res.Code != c.wantCode {
+			t.Errorf("%s: Expected status %d, got %d", c.path, c.wantCode, res.Code)
+		}
+		if c.wantBody != "" && res.Body.String() != c.wantBody {
+			t.Errorf("%s: Expected the handler to get %q, got %q", c.path, c.wantBody, res.Body.String())
+		}
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"otp", "/otp"},
+		{"/otp/", "/otp"},
+		{"//tools/otp//", "/tools/otp"},
+	} {
+		if got := normalizeBasePath(c.in); got != c.want {
+			t.Errorf("normalizeBasePath(%q) = %q, expected %q", c.in, got, c.want)
+		}
+	}
+}
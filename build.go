@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// buildOptions contains what differs between the sources (Vault scan,
+// subkeys, source file, validation) when turning the data of a key into
+// a token
+type buildOptions struct {
+	// Created is the creation of the secret version (KV v2 only)
+	Created time.Time
+	// Folder is the folder to group the token into
+	Folder string
+	// ReadKey reads keys referenced by secret_ref, without it references
+	// are not followed
+	ReadKey keyReader
+	// Structure marks data only carrying the structure of the key but
+	// not the values of the secret fields (subkeys endpoint)
+	Structure bool
+	// Fields are the fields stored in the key to keep for the backup
+	Fields map[string]interface{}
+
+	// Codes enables generating codes, Next requests the codes of the
+	// next period
+	Codes bool
+	Next  bool
+}
+
+// buildToken parses the data of a key into a token and applies all checks
+// and post-processing shared by the sources. Keys not to be displayed are
+// returned as nil token without error, for rejected tokens the token is
+// returned along with the error to report the failure.
+func buildToken(ctx context.Context, key string, data map[string]interface{}, opts buildOptions) (*token, error) {
+	if !matchesRequiredFields(data) {
+		logger(ctx).WithField("key", key).Debug("Skipping key not matching the required fields")
+		return nil, nil
+	}
+
+	tok := tokenFromData(ctx, key, data)
+	tok.Created, tok.Fields = opts.Created, opts.Fields
+	if err := tok.sanitizeFields(); err != nil {
+		return tok, errors.Wrap(err, "Token rejected")
+	}
+
+	if cfg.UI.GroupFolders {
+		tok.Folder = opts.Folder
+	}
+
+	// No need to resolve references for tokens no code is generated for
+	tok.MetadataOnly = !generatesCodesFor(tok.Issuer)
+	ref := secretRef(data)
+
+	if !opts.Structure {
+		if tok.Secret == "" && ref != "" && !tok.MetadataOnly && opts.ReadKey != nil {
+			secret, err := resolveSecretRef(ctx, opts.ReadKey, key, ref)
+			if err != nil {
+				return tok, errors.Wrap(err, "Unable to resolve secret reference")
+			}
+			tok.Secret = transformSecret(ctx, key, secret)
+		}
+
+		// Tokens no code is generated for don't need the referenced secret
+		hasSource := tok.hasCodeSource() || (tok.MetadataOnly && ref != "")
+
+		if !hasSource && hasEmptySecretField(data) {
+			return tok, errEmptySecret
+		}
+
+		if !hasSource {
+			// Nothing to generate a code from, does not seem to be something for us
+			return tok, errNoOTPFields
+		}
+	}
+
+	if tok.MetadataOnly {
+		tok.Secret, tok.StoredCode, tok.RecoveryCodes = "", "", nil
+	}
+
+	if opts.Codes && !tok.MetadataOnly {
+		if err := tok.GenerateCode(opts.Next); err != nil {
+			return tok, errors.Wrap(err, "Unable to generate code")
+		}
+	} else if err := tok.resolveDefaults(); err != nil {
+		return tok, errors.Wrap(err, "Unable to resolve token options")
+	}
+
+	if err := checkExpectedDigits(ctx, tok); err != nil {
+		return tok, errors.Wrap(err, "Token rejected")
+	}
+	tok.Fingerprint = tok.ConfigFingerprint()
+
+	return tok, nil
+}
+
+// handleBuildError logs the error of building the token of the key and
+// reports whether it is to be recorded as a failure. Keys without (or
+// with an empty) secret are only reported when configured.
+func handleBuildError(ctx context.Context, key string, err error) bool {
+	switch errors.Cause(err) {
+	case errEmptySecret:
+		logger(ctx).WithField("key", key).Warn("Skipping key with empty secret field")
+		return cfg.Vault.ReportEmptySecret
+	case errNoOTPFields:
+		logger(ctx).WithField("key", key).Debug("Skipping key without secret")
+		return cfg.Vault.ReportNoFields
+	default:
+		logger(ctx).WithError(err).WithField("key", key).Error("Rejecting token")
+		return true
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+func TestBuildToken(t *testing.T) {
+	oldCfg, oldRequired := cfg, requiredFields
+	defer func() { cfg, requiredFields = oldCfg, oldRequired }()
+
+	cfg.UI.GroupFolders = true
+	cfg.Vault.HTMLFields = htmlFieldsReject
+	requiredFields = []fieldRequirement{{Field: "env", Value: "prod"}}
+
+	readRef := func(key string) (*api.Secret, error) {
+		if key != "totp/shared" {
+			return nil, errors.Errorf("unexpected read of %q", key)
+		}
+		return &api.Secret{Data: map[string]interface{}{"secret": rfc4226Secret}}, nil
+	}
+
+	for _, c := range []struct {
+		name      string
+		data      map[string]interface{}
+		opts      buildOptions
+		issuers   []string
+		wantNil   bool
+		wantErr   string
+		wantCode  bool
+		wantCheck func(*token) bool
+	}{
+		{
+			name:    "not matching required fields",
+			data:    map[string]interface{}{"env": "dev", "secret": rfc4226Secret},
+			wantNil: true,
+		},
+		{
+			name:     "complete token",
+			data:     map[string]interface{}{"env": "prod", "name": "Mail", "secret": rfc4226Secret},
+			opts:     buildOptions{Folder: "team", Codes: true},
+			wantCode: true,
+			wantCheck: func(tok *token) bool {
+				return tok.Name == "Mail" && tok.Folder == "team" && tok.Fingerprint != ""
+			},
+		},
+		{
+			name: "stored fields",
+			data: map[string]interface{}{"env": "prod", "secret": rfc4226Secret},
+			opts: buildOptions{Fields: map[string]interface{}{"env": "prod", "secret": rfc4226Secret}},
+			wantCheck: func(tok *token) bool {
+				return tok.Fields["secret"] == rfc4226Secret && len(tok.Fields) == 2
+			},
+		},
+		{
+			name:      "without codes",
+			data:      map[string]interface{}{"env": "prod", "secret": rfc4226Secret},
+			wantCheck: func(tok *token) bool { return tok.Code == "" && tok.Digits == 6 && tok.Period == 30 },
+		},
+		{
+			name:    "rejected HTML",
+			data:    map[string]interface{}{"env": "prod", "name": "<b>Mail</b>", "secret": rfc4226Secret},
+			wantErr: "Token rejected",
+		},
+		{
+			name:    "empty secret",
+			data:    map[string]interface{}{"env": "prod", "secret": ""},
+			wantErr: errEmptySecret.Error(),
+		},
+		{
+			name:    "whitespace secret",
+			data:    map[string]interface{}{"env": "prod", "secret": " \t"},
+			opts:    buildOptions{Codes: true},
+			wantErr: errEmptySecret.Error(),
+		},
+		{
+			name:    "no OTP fields",
+			data:    map[string]interface{}{"env": "prod", "username": "jdoe"},
+			wantErr: errNoOTPFields.Error(),
+		},
+		{
+			name:     "secret reference",
+			data:     map[string]interface{}{"env": "prod", "secret_ref": "totp/shared"},
+			opts:     buildOptions{ReadKey: readRef, Codes: true},
+			wantCode: true,
+		},
+		{
+			name:    "secret reference without reader",
+			data:    map[string]interface{}{"env": "prod", "secret_ref": "totp/shared"},
+			opts:    buildOptions{Codes: true},
+			wantErr: errNoOTPFields.Error(),
+		},
+		{
+			name:    "metadata only issuer",
+			data:    map[string]interface{}{"env": "prod", "issuer": "Other", "secret_ref": "totp/missing"},
+			opts:    buildOptions{ReadKey: readRef, Codes: true},
+			issuers: []string{"GitHub"},
+			wantCheck: func(tok *token) bool {
+				return tok.MetadataOnly && tok.Code == "" && tok.Secret == ""
+			},
+		},
+		{
+			name:      "structure only",
+			data:      map[string]interface{}{"env": "prod", "name": "Mail"},
+			opts:      buildOptions{Structure: true},
+			wantCheck: func(tok *token) bool { return tok.Name == "Mail" && tok.Code == "" },
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.CodeIssuers = c.issuers
+
+			tok, err := buildToken(context.Background(), "totp/key", c.data, c.opts)
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Fatalf("Unexpected error: %s", err)
+			case c.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), c.wantErr)):
+				t.Fatalf("Expected error %q, got %v", c.wantErr, err)
+			case err != nil:
+				return
+			}
+
+			if c.wantNil {
+				if tok != nil {
+					t.Fatalf("Expected the key to be skipped, got %+v", tok)
+				}
+				return
+			}
+
+			if tok == nil {
+				t.Fatal("Expected a token, got nil")
+			}
+			if c.wantCode && len(tok.Code) != 6 {
+				t.Errorf("Expected a code, got %q", tok.Code)
+			}
+			if c.wantCheck != nil && !c.wantCheck(tok) {
+				t.Errorf("Unexpected token: %+v", tok)
+			}
+		})
+	}
+}
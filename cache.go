@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cachedScan is the last scan result of a user served while a new scan
+// refreshes it in the background
+type cachedScan struct {
+	refreshing bool
+	result     *scanResult
+	scanned    time.Time
+}
+
+var (
+	scanCache     = map[string]*cachedScan{}
+	scanCacheLock sync.Mutex
+)
+
+// storeCachedSecrets remembers the result of a scan of the current
+// period for the user to be served by following requests
+func storeCachedSecrets(tok string, result *scanResult) {
+	if cfg.Vault.StaleRevalidate <= 0 || tok == "" {
+		return
+	}
+
+	scanCacheLock.Lock()
+	defer scanCacheLock.Unlock()
+
+	for k, c := range scanCache {
+		if time.Since(c.scanned) > cfg.Vault.StaleRevalidate {
+			delete(scanCache, k)
+		}
+	}
+
+	scanCache[hashSecret(tok)] = &cachedScan{result: result.copy(), scanned: time.Now()}
+}
+
+// cachedSecrets returns a copy of the cached scan of the user with fresh
+// codes and starts a scan to refresh the cache in the background. Cached
+// scans older than the configured age are not served.
+func cachedSecrets(ctx context.Context, tok string, next bool) *scanResult {
+	if cfg.Vault.StaleRevalidate <= 0 || tok == "" {
+		return nil
+	}
+
+	key := hashSecret(tok)
+
+	scanCacheLock.Lock()
+	c, ok := scanCache[key]
+	if !ok || time.Since(c.scanned) > cfg.Vault.StaleRevalidate {
+		scanCacheLock.Unlock()
+		return nil
+	}
+
+	result := c.result.copy()
+	refresh := !c.refreshing
+	c.refreshing = true
+	scanCacheLock.Unlock()
+
+	if refresh {
+		go refreshCachedSecrets(tok)
+	}
+
+	if codesWanted(ctx) {
+		// The secrets did not change, the codes of the cached tokens might
+		// have (and there might be codes of the next period requested)
+		generateAll(result.Tokens, func(t *token) {
+			if t.Deleted || t.MetadataOnly {
+				return
+			}
+			if err := t.GenerateCode(next); err != nil {
+				logger(ctx).WithError(err).WithField("name", t.Name).Error("Unable to generate code")
+			}
+		})
+		// Sorting by expiry depends on the fresh codes
+		sort.Sort(tokenList(result.Tokens))
+	}
+
+	logger(ctx).WithFields(log.Fields{
+		"token": key,
+		"age":   time.Since(c.scanned).Truncate(time.Millisecond),
+	}).Debug("Serving cached scan, refreshing in the background")
+	return result
+}
+
+func refreshCachedSecrets(tok string) {
+	ctx := withRequestID(context.Background())
+
+	result, err := getSecretsFromVault(ctx, tok, false)
+	if err != nil {
+		logger(ctx).WithError(err).Warn("Unable to refresh cached scan")
+
+		scanCacheLock.Lock()
+		defer scanCacheLock.Unlock()
+		if c, ok := scanCache[hashSecret(tok)]; ok {
+			c.refreshing = false
+		}
+		return
+	}
+
+	storeCachedSecrets(tok, result)
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedSecretsSortsFreshCodes(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.StaleRevalidate = time.Minute
+	cfg.UI.SortBy = sortByExpiry
+
+	defer func() {
+		scanCacheLock.Lock()
+		scanCache = map[string]*cachedScan{}
+		scanCacheLock.Unlock()
+	}()
+
+	// The slow token is in the middle of its period, the fast one expires
+	// within seconds: The order of the cached scan is outdated
+	c := &cachedScan{
+		// No background refresh against a Vault which does not exist
+		refreshing: true,
+		scanned:    time.Now(),
+		result: &scanResult{Tokens: []*token{
+			{Name: "Slow", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 86400, T0: time.Now().Unix() - 43200},
+			{Name: "Fast", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 10},
+		}},
+	}
+	scanCacheLock.Lock()
+	scanCache = map[string]*cachedScan{hashSecret("s.user"): c}
+	scanCacheLock.Unlock()
+
+	for _, ctx := range []context.Context{context.Background(), withoutCodes(context.Background())} {
+		res := cachedSecrets(ctx, "s.user", false)
+		if res == nil || len(res.Tokens) != 2 {
+			t.Fatalf("Expected the cached tokens, got %+v", res)
+		}
+
+		if !codesWanted(ctx) {
+			// Without codes the cached order is kept
+			if res.Tokens[0].Name != "Slow" || res.Tokens[0].Code != "" {
+				t.Errorf("Expected the cached tokens without codes, got %+v", res.Tokens)
+			}
+			continue
+		}
+
+		if res.Tokens[0].Name != "Fast" || res.Tokens[1].Name != "Slow" {
+			t.Errorf("Expected the tokens sorted by their fresh codes, got %s, %s", res.Tokens[0].Name, res.Tokens[1].Name)
+		}
+		for _, tok := range res.Tokens {
+			if tok.Code == "" || tok.RemainingSeconds == 0 {
+				t.Errorf("Expected a fresh code for %q, got %+v", tok.Name, tok)
+			}
+		}
+	}
+
+	// Serving the cache does not change the cached scan
+	if c.result.Tokens[0].Name != "Slow" || c.result.Tokens[0].Code != "" {
+		t.Errorf("Cached scan was modified: %+v", c.result.Tokens)
+	}
+}
+
+func TestCachedSecrets(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	defer func() {
+		scanCacheLock.Lock()
+		scanCache = map[string]*cachedScan{}
+		scanCacheLock.Unlock()
+	}()
+
+	for _, c := range []struct {
+		name    string
+		stale   time.Duration
+		tok     string
+		age     time.Duration
+		next    bool
+		wantHit bool
+	}{
+		{name: "disabled", tok: "s.user"},
+		{name: "without token", stale: time.Minute},
+		{name: "other user", stale: time.Minute, tok: "s.other"},
+		{name: "too old", stale: time.Minute, tok: "s.user", age: 2 * time.Minute},
+		{name: "fresh", stale: time.Minute, tok: "s.user", age: 30 * time.Second, wantHit: true},
+		{name: "next period", stale: time.Minute, tok: "s.user", next: true, wantHit: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.StaleRevalidate = c.stale
+
+			cached := &cachedScan{
+				// No background refresh against a Vault which does not exist
+				refreshing: true,
+				scanned:    time.Now().Add(-c.age),
+				result: &scanResult{Tokens: []*token{
+					{Name: "Mail", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 30},
+					{Name: "Deleted", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 30, Deleted: true},
+					{Name: "Metadata", Type: tokenTypeTOTP, Period: 30, MetadataOnly: true},
+				}},
+			}
+			scanCacheLock.Lock()
+			scanCache = map[string]*cachedScan{hashSecret("s.user"): cached}
+			scanCacheLock.Unlock()
+
+			res := cachedSecrets(context.Background(), c.tok, c.next)
+			if !c.wantHit {
+				if res != nil {
+					t.Fatalf("Expected no cached scan, got %+v", res)
+				}
+				return
+			}
+			if res == nil || len(res.Tokens) != 3 {
+				t.Fatalf("Expected the cached tokens, got %+v", res)
+			}
+
+			want := &token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 30}
+			if err := want.GenerateCode(c.next); err != nil {
+				t.Fatalf("Unable to generate code: %s", err)
+			}
+
+			for _, tok := range res.Tokens {
+				switch tok.Name {
+				case "Mail":
+					if tok.Code != want.Code {
+						t.Errorf("Expected code %q, got %q", want.Code, tok.Code)
+					}
+				default:
+					if tok.Code != "" {
+						t.Errorf("Expected no code for %q, got %q", tok.Name, tok.Code)
+					}
+				}
+			}
+
+			// The caller owns the returned tokens
+			res.Tokens[0].Name = "Changed"
+			for _, tok := range cached.result.Tokens {
+				if tok.Name == "Changed" || tok.Code != "" {
+					t.Errorf("Cached scan was modified: %+v", tok)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreCachedSecrets(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	defer func() {
+		scanCacheLock.Lock()
+		scanCache = map[string]*cachedScan{}
+		scanCacheLock.Unlock()
+	}()
+
+	for _, c := range []struct {
+		name     string
+		stale    time.Duration
+		tok      string
+		wantKeys []string
+	}{
+		{name: "disabled", tok: "s.user", wantKeys: []string{"s.old", "s.fresh"}},
+		{name: "without token", stale: time.Minute, wantKeys: []string{"s.old", "s.fresh"}},
+		{name: "evicts old scans", stale: time.Minute, tok: "s.user", wantKeys: []string{"s.fresh", "s.user"}},
+		{name: "replaces own scan", stale: time.Minute, tok: "s.old", wantKeys: []string{"s.fresh", "s.old"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.StaleRevalidate = c.stale
+
+			scanCacheLock.Lock()
+			scanCache = map[string]*cachedScan{
+				hashSecret("s.old"):   {result: &scanResult{}, scanned: time.Now().Add(-2 * time.Minute)},
+				hashSecret("s.fresh"): {result: &scanResult{}, scanned: time.Now()},
+			}
+			scanCacheLock.Unlock()
+
+			result := &scanResult{Tokens: []*token{{Name: "Mail"}}}
+			storeCachedSecrets(c.tok, result)
+
+			// Later changes of the result must not end up in the cache
+			result.Tokens[0].Name = "Changed"
+
+			scanCacheLock.Lock()
+			defer scanCacheLock.Unlock()
+
+			if len(scanCache) != len(c.wantKeys) {
+				t.Errorf("Expected %d cached scans, got %d", len(c.wantKeys), len(scanCache))
+			}
+			for _, k := range c.wantKeys {
+				if _, ok := scanCache[hashSecret(k)]; !ok {
+					t.Errorf("Expected a cached scan for %q", k)
+				}
+			}
+
+			if c.tok == "" {
+				return
+			}
+			if stored, ok := scanCache[hashSecret(c.tok)]; ok {
+				if stored.refreshing || time.Since(stored.scanned) > time.Second {
+					t.Errorf("Expected a fresh scan without refresh, got %+v", stored)
+				}
+				if len(stored.result.Tokens) != 1 || stored.result.Tokens[0].Name != "Mail" {
+					t.Errorf("Expected a copy of the stored result, got %+v", stored.result.Tokens)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSecretsStaleWhileRevalidate(t *testing.T) {
+	var (
+		lists   int32
+		failing int32
+		name    atomic.Value
+	)
+	name.Store("Mail")
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case atomic.LoadInt32(&failing) == 1:
+			res.WriteHeader(http.StatusForbidden)
+			res.Write([]byte(`{"errors":["permission denied"]}`))
+		case r.URL.Query().Get("list") == "true":
+			atomic.AddInt32(&lists, 1)
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			fmt.Fprintf(res, `{"data":{"name":%q,"secret":"JBSWY3DPEHPK3PXP"}}`, name.Load())
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.StaleRevalidate = time.Minute
+
+	defer func() {
+		scanCacheLock.Lock()
+		scanCache = map[string]*cachedScan{}
+		scanCacheLock.Unlock()
+	}()
+
+	get := func(ctx context.Context, next bool) string {
+		res, err := getSecrets(ctx, "s.user", next)
+		if err != nil {
+			t.Fatalf("getSecrets() returned error: %s", err)
+		}
+		if len(res.Tokens) != 1 {
+			t.Fatalf("Expected one token, got %+v", res.Tokens)
+		}
+		if codesWanted(ctx) && res.Tokens[0].Code == "" {
+			t.Errorf("Expected a code for %q", res.Tokens[0].Name)
+		}
+		return res.Tokens[0].Name
+	}
+
+	// waitRefreshed waits for the background refresh to finish and returns
+	// the name of the cached token
+	waitRefreshed := func() string {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			scanCacheLock.Lock()
+			c, ok := scanCache[hashSecret("s.user")]
+			if ok && !c.refreshing {
+				n := c.result.Tokens[0].Name
+				scanCacheLock.Unlock()
+				return n
+			}
+			scanCacheLock.Unlock()
+		}
+		t.Fatal("Background refresh did not finish")
+		return ""
+	}
+
+	// Without cached scan the request scans Vault and fills the cache
+	if n := get(context.Background(), false); n != "Mail" || atomic.LoadInt32(&lists) != 1 {
+		t.Fatalf("Expected a scan returning Mail, got %q after %d lists", n, lists)
+	}
+
+	// The changed secret is served after the background refresh only
+	name.Store("Renamed")
+	if n := get(context.Background(), false); n != "Mail" {
+		t.Errorf("Expected the cached Mail, got %q", n)
+	}
+	if n := waitRefreshed(); n != "Renamed" {
+		t.Errorf("Expected the refresh to cache Renamed, got %q", n)
+	}
+	if n := get(context.Background(), false); n != "Renamed" {
+		t.Errorf("Expected the refreshed Renamed, got %q", n)
+	}
+	waitRefreshed()
+	if l := atomic.LoadInt32(&lists); l != 3 {
+		t.Errorf("Expected one scan and two refreshes, got %d lists", l)
+	}
+
+	// A failing refresh keeps the cached scan and allows the next refresh
+	atomic.StoreInt32(&failing, 1)
+	if n := get(context.Background(), false); n != "Renamed" {
+		t.Errorf("Expected the cached Renamed, got %q", n)
+	}
+	if n := waitRefreshed(); n != "Renamed" {
+		t.Errorf("Expected the cached scan to be kept, got %q", n)
+	}
+	atomic.StoreInt32(&failing, 0)
+
+	// Scans for the next period or without codes are not cached
+	for _, c := range []struct {
+		name string
+		ctx  context.Context
+		next bool
+	}{
+		{name: "next period", ctx: context.Background(), next: true},
+		{name: "without codes", ctx: withoutCodes(context.Background())},
+	} {
+		scanCacheLock.Lock()
+		scanCache = map[string]*cachedScan{}
+		scanCacheLock.Unlock()
+
+		get(c.ctx, c.next)
+
+		scanCacheLock.Lock()
+		if len(scanCache) != 0 {
+			t.Errorf("%s: Expected no cached scan, got %d", c.name, len(scanCache))
+		}
+		scanCacheLock.Unlock()
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// runList prints the current codes as a table and returns the exit code
+// to use
+func runList() int {
+	var (
+		tok string
+		err error
+	)
+
+	if cfg.Source == sourceVault {
+		if tok, err = useOrRenewToken(cfg.CLI.VaultToken, cfg.CLI.GithubToken); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to authorize against vault: %s\n", err)
+			return 1
+		}
+	}
+
+	secrets, err := getSecrets(withRequestID(context.Background()), tok, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to fetch codes: %s\n", err)
+		return 1
+	}
+
+	if secrets.Truncated {
+		fmt.Fprintln(os.Stderr, "Scan was stopped early (operation budget or soft deadline), the list is truncated")
+	}
+
+	for _, w := range secrets.warningsFor(secrets.Tokens) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	if cfg.CLI.Watch {
+		// Runs until the command is interrupted
+		watchTokenTable(os.Stdout, secrets.Tokens)
+	}
+
+	printTokenTable(os.Stdout, secrets.Tokens, cfg.CLI.MaxNameWidth, false)
+	return 0
+}
+
+// watchTokenTable redraws the table every second. The codes are
+// generated locally from the secrets fetched once so Vault is not
+// scanned again for every redraw.
+func watchTokenTable(w io.Writer, tokens []*token) {
+	for {
+		now := time.Now()
+		for _, t := range tokens {
+			if t.Deleted || t.Type == tokenTypeHOTP {
+				continue
+			}
+
+			// Errors were already reported when fetching the tokens
+			t.GenerateCode(false)
+		}
+
+		// Move the cursor to the top left and clear the screen
+		fmt.Fprint(w, "\033[H\033[2J")
+		printTokenTable(w, tokens, cfg.CLI.MaxNameWidth, true)
+
+		time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(time.Now()))
+	}
+}
+
+// printTokenTable prints the tokens, with countdown each token gets the
+// seconds its code is still valid (of its own period) appended
+func printTokenTable(w io.Writer, tokens []*token, maxWidth int, countdown bool) {
+	width := tokenList(tokens).LongestName()
+	if maxWidth > 0 && width > maxWidth {
+		width = maxWidth
+	}
+
+	codes := make([]string, len(tokens))
+	codeWidth := 0
+	for i, t := range tokens {
+		codes[i] = t.Code
+		if t.Deleted {
+			codes[i] = "(deleted)"
+		}
+		if l := utf8.RuneCountInString(codes[i]); l > codeWidth {
+			codeWidth = l
+		}
+	}
+
+	for i, t := range tokens {
+		code := codes[i]
+		if countdown && t.RemainingSeconds > 0 {
+			fmt.Fprintf(w, "%-*s  %-*s  %3ds\n", width, truncateName(t.Name, width), codeWidth, code, t.RemainingSeconds)
+			continue
+		}
+		fmt.Fprintf(w, "%-*s  %s\n", width, truncateName(t.Name, width), code)
+	}
+}
+
+// truncateName shortens the name to the given width (in runes) and marks
+// the truncation with an ellipsis. The token itself is not modified so
+// structured output still contains the full name.
+func truncateName(name string, width int) string {
+	r := []rune(name)
+	if width <= 0 || len(r) <= width {
+		return name
+	}
+
+	if width == 1 {
+		return "…"
+	}
+
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTruncateName(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		width int
+		want  string
+	}{
+		{"GitHub", 10, "GitHub"},
+		{"GitHub", 6, "GitHub"},
+		{"GitHub", 4, "Git…"},
+		{"GitHub", 1, "…"},
+		{"GitHub", 0, "GitHub"},
+		// Width is counted in runes, multi-byte names are not cut in halves
+		{"Überweisung", 5, "Über…"},
+	} {
+		if got := truncateName(c.name, c.width); got != c.want {
+			t.Errorf("truncateName(%q, %d) = %q, expected %q", c.name, c.width, got, c.want)
+		}
+	}
+}
+
+func TestPrintTokenTableMaxWidth(t *testing.T) {
+	tokens := []*token{
+		{Name: "Mail", Code: "123456"},
+		{Name: "A very long account name", Code: "654321"},
+	}
+
+	for _, c := range []struct {
+		name     string
+		maxWidth int
+		want     string
+	}{
+		{
+			name: "unlimited",
+			want: "Mail                      123456\n" +
+				"A very long account name  654321\n",
+		},
+		{
+			name:     "truncated",
+			maxWidth: 8,
+			want: "Mail      123456\n" +
+				"A very …  654321\n",
+		},
+		{
+			name:     "limit above the longest name",
+			maxWidth: 40,
+			want: "Mail                      123456\n" +
+				"A very long account name  654321\n",
+		},
+	} {
+		buf := new(bytes.Buffer)
+		printTokenTable(buf, tokens, c.maxWidth, false)
+		if buf.String() != c.want {
+			t.Errorf("%s: Expected output\n%s\ngot\n%s", c.name, c.want, buf.String())
+		}
+		// Only the output is shortened
+		if tokens[1].Name != "A very long account name" {
+			t.Errorf("%s: Token name was modified to %q", c.name, tokens[1].Name)
+		}
+	}
+}
+
+func TestPrintTokenTableCountdown(t *testing.T) {
+	tokens := []*token{
+		{Name: "Mail", Code: "123456", RemainingSeconds: 7},
+		{Name: "Bank", Code: "12345678", RemainingSeconds: 37},
+		{Name: "Server", Code: "654321", RemainingSeconds: 120},
+		{Name: "Counter", Code: "111111", Type: tokenTypeHOTP},
+		{Name: "Old", Deleted: true},
+	}
+
+	for _, c := range []struct {
+		name      string
+		countdown bool
+		want      string
+	}{
+		{
+			name: "without countdown",
+			want: "Mail     123456\n" +
+				"Bank     12345678\n" +
+				"Server   654321\n" +
+				"Counter  111111\n" +
+				"Old      (deleted)\n",
+		},
+		{
+			// Every token counts down its own period, codes not
+			// expiring by time have no countdown
+			name:      "countdown",
+			countdown: true,
+			want: "Mail     123456       7s\n" +
+				"Bank     12345678    37s\n" +
+				"Server   654321     120s\n" +
+				"Counter  111111\n" +
+				"Old      (deleted)\n",
+		},
+	} {
+		buf := new(bytes.Buffer)
+		printTokenTable(buf, tokens, 0, c.countdown)
+		if buf.String() != c.want {
+			t.Errorf("%s: Expected output\n%s\ngot\n%s", c.name, c.want, buf.String())
+		}
+	}
+}
+
+func TestCountdownMixedPeriods(t *testing.T) {
+	for {
+		tokens := []*token{
+			{Name: "Fast", Secret: "JBSWY3DPEHPK3PXP", Period: 10},
+			{Name: "Default", Secret: "JBSWY3DPEHPK3PXP"},
+			{Name: "Slow", Secret: "JBSWY3DPEHPK3PXP", Period: 60},
+		}
+
+		start := time.Now().Unix()
+		for _, tok := range tokens {
+			if err := tok.GenerateCode(false); err != nil {
+				t.Fatalf("%s: Unable to generate code: %s", tok.Name, err)
+			}
+		}
+		if time.Now().Unix() != start {
+			// Second changed between the tokens, countdowns differ
+			continue
+		}
+
+		for _, tok := range tokens {
+			if want := tok.Period - int(start%int64(tok.Period)); tok.RemainingSeconds != want {
+				t.Errorf("%s: Expected %ds remaining of its %ds period, got %d", tok.Name, want, tok.Period, tok.RemainingSeconds)
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// clientCert holds the client certificate to authenticate against Vault
+// with when using mTLS, it is nil without a certificate configured
+var clientCert *clientCertReloader
+
+// clientCertReloader keeps the client certificate loaded from the files
+// and reloads it when the files change (i.e. rotated by cert-manager)
+type clientCertReloader struct {
+	certFile, keyFile string
+
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+	lock     sync.RWMutex
+}
+
+func newClientCertReloader(certFile, keyFile string) (*clientCertReloader, error) {
+	c := &clientCertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetClientCertificate returns the current certificate, it is used as
+// callback of the TLS config so every new connection uses the latest
+// certificate
+func (c *clientCertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.cert, nil
+}
+
+// reload loads the certificate again if one of the files changed since
+// the last load and reports whether it did
+func (c *clientCertReloader) reload() (bool, error) {
+	var modTimes [2]time.Time
+	for i, f := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, errors.Wrap(err, "Unable to stat client certificate")
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	c.lock.RLock()
+	unchanged := c.cert != nil && modTimes == c.modTimes
+	c.lock.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, errors.Wrap(err, "Unable to load client certificate")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cert, c.modTimes = &cert, modTimes
+	return true, nil
+}
+
+// watch checks the files for changes in the given interval. When the new
+// files can't be loaded (i.e. only one of them was written yet) the
+// previous certificate is kept and loading is retried on the next check.
+func (c *clientCertReloader) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		reloaded, err := c.reload()
+		switch {
+		case err != nil:
+			log.WithError(err).Error("Unable to reload client certificate, keeping the current one")
+		case reloaded:
+			log.WithField("cert", c.certFile).Info("Reloaded client certificate")
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed certificate for the common name
+// and its key as PEM, the modification time is set explicitly so changes
+// are detected regardless of the resolution of the file system
+func writeClientCert(t *testing.T, certFile, keyFile, cn string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %s", err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unable to create certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unable to marshal key: %s", err)
+	}
+
+	for f, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if f == "" {
+			continue
+		}
+		if err := ioutil.WriteFile(f, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("Unable to write %s: %s", f, err)
+		}
+		if err := os.Chtimes(f, modTime, modTime); err != nil {
+			t.Fatalf("Unable to set time of %s: %s", f, err)
+		}
+	}
+}
+
+func TestClientCertReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clientcert")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writeClientCert(t, certFile, keyFile, "first", start)
+
+	var (
+		lock   sync.Mutex
+		lastCN string
+	)
+	vault := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if len(r.TLS.PeerCertificates) > 0 {
+			lastCN = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"data":{}}`))
+	}))
+	vault.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	vault.StartTLS()
+	defer vault.Close()
+
+	oldCfg, oldCert, oldSkip := cfg, clientCert, os.Getenv("VAULT_SKIP_VERIFY")
+	defer func() {
+		cfg, clientCert = oldCfg, oldCert
+		os.Setenv("VAULT_SKIP_VERIFY", oldSkip)
+	}()
+	cfg.Vault.Address = vault.URL
+	os.Setenv("VAULT_SKIP_VERIFY", "true")
+
+	if clientCert, err = newClientCertReloader(certFile, keyFile); err != nil {
+		t.Fatalf("Unable to load client certificate: %s", err)
+	}
+
+	// One client for all steps: the rotated certificate has to be used for
+	// new connections of existing clients
+	client, err := newVaultClient()
+	if err != nil {
+		t.Fatalf("Unable to create client: %s", err)
+	}
+
+	for _, c := range []struct {
+		name         string
+		cn           string
+		certOnly     bool // Only the certificate is written, the key does not match
+		modTime      time.Time
+		wantReloaded bool
+		wantErr      bool
+		wantCN       string
+	}{
+		{name: "initial certificate", wantCN: "first"},
+		{name: "unchanged files", wantCN: "first"},
+		{name: "swapped files", cn: "second", modTime: start.Add(time.Minute), wantReloaded: true, wantCN: "second"},
+		{name: "half written rotation", cn: "third", certOnly: true, modTime: start.Add(2 * time.Minute), wantErr: true, wantCN: "second"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if c.cn != "" {
+				kf := keyFile
+				if c.certOnly {
+					kf = ""
+					os.Chtimes(keyFile, c.modTime, c.modTime)
+				}
+				writeClientCert(t, certFile, kf, c.cn, c.modTime)
+			}
+
+			reloaded, err := clientCert.reload()
+			if (err != nil) != c.wantErr || reloaded != c.wantReloaded {
+				t.Errorf("Expected reloaded=%v and error=%v, got %v / %v", c.wantReloaded, c.wantErr, reloaded, err)
+			}
+
+			// Force a new handshake for the request
+			vault.CloseClientConnections()
+			if _, err := client.Logical().Read("totp/mail"); err != nil {
+				t.Fatalf("Request failed: %s", err)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if lastCN != c.wantCN {
+				t.Errorf("Expected certificate %q to be used, got %q", c.wantCN, lastCN)
+			}
+		})
+	}
+}
+
+func TestNewClientCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clientcert")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeClientCert(t, certFile, "", "cert", time.Now())
+
+	for _, c := range []struct {
+		name              string
+		certFile, keyFile string
+	}{
+		{name: "missing key", certFile: certFile, keyFile: keyFile},
+		{name: "missing certificate", certFile: filepath.Join(dir, "missing.crt"), keyFile: certFile},
+		{name: "certificate as key", certFile: certFile, keyFile: certFile},
+	} {
+		if _, err := newClientCertReloader(c.certFile, c.keyFile); err == nil {
+			t.Errorf("%s: Expected an error", c.name)
+		}
+	}
+}
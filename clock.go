@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const clockCheckInterval = 15 * time.Minute
+
+// clockSkew holds the last measured difference between the local clock
+// and the clock of Vault in nanoseconds (positive when running ahead)
+var clockSkew int64
+
+// watchClockSkew checks the local clock against Vault at startup and in
+// the configured interval as a skewed clock produces wrong codes
+func watchClockSkew() {
+	for {
+		if err := checkClockSkew(); err != nil {
+			log.WithError(err).Error("Unable to check clock against Vault")
+		}
+		time.Sleep(clockCheckInterval)
+	}
+}
+
+func checkClockSkew() error {
+	client, err := newVaultClient()
+	if err != nil {
+		return errors.Wrap(err, "Unable to create client")
+	}
+
+	start := time.Now()
+	// Health returns non-2xx codes for standby or sealed nodes but the
+	// response still carries the Date header
+	resp, err := client.RawRequest(client.NewRequest(http.MethodGet, "/v1/sys/health"))
+	if resp == nil {
+		return errors.Wrap(err, "Unable to query Vault")
+	}
+	defer resp.Body.Close()
+
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return errors.Wrap(err, "Unable to parse Date header")
+	}
+
+	// Assume the Date header was set half way through the request, it
+	// has a resolution of one second only
+	local := start.Add(time.Since(start) / 2).Truncate(time.Second)
+	skew := local.Sub(remote)
+	atomic.StoreInt64(&clockSkew, int64(skew))
+
+	entry := log.WithFields(log.Fields{"skew": skew, "max_skew": cfg.Vault.MaxClockSkew})
+	if clockSkewed() {
+		entry.Warn("Local clock differs from Vault, generated codes are most likely wrong")
+		return nil
+	}
+
+	entry.Debug("Clock checked against Vault")
+	return nil
+}
+
+func clockSkewed() bool {
+	skew := time.Duration(atomic.LoadInt64(&clockSkew))
+	if skew < 0 {
+		skew = -skew
+	}
+
+	return cfg.Vault.MaxClockSkew > 0 && skew > cfg.Vault.MaxClockSkew
+}
+
+func handleHealthz(res http.ResponseWriter, r *http.Request) {
+	status := struct {
+		Status    string `json:"status"`
+		ClockSkew string `json:"clock_skew,omitempty"`
+	}{Status: "ok"}
+
+	code := http.StatusOK
+	if cfg.Vault.MaxClockSkew > 0 {
+		status.ClockSkew = time.Duration(atomic.LoadInt64(&clockSkew)).String()
+		if clockSkewed() {
+			status.Status = "clock_skew"
+			// Restarting the instance does not fix the clock, only
+			// report it unhealthy when asked to
+			if cfg.Vault.ClockSkewUnhealthy {
+				code = http.StatusServiceUnavailable
+			}
+		}
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(code)
+	json.NewEncoder(res).Encode(status)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandleHealthz(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	oldSkew := atomic.LoadInt64(&clockSkew)
+	defer atomic.StoreInt64(&clockSkew, oldSkew)
+
+	for _, c := range []struct {
+		name       string
+		maxSkew    time.Duration
+		skew       time.Duration
+		unhealthy  bool
+		wantCode   int
+		wantStatus string
+		wantSkew   string
+	}{
+		{name: "unchecked", skew: time.Minute, wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "within limit", maxSkew: 10 * time.Second, skew: -5 * time.Second, wantCode: http.StatusOK, wantStatus: "ok", wantSkew: "-5s"},
+		{name: "skewed", maxSkew: 10 * time.Second, skew: -time.Minute, wantCode: http.StatusOK, wantStatus: "clock_skew", wantSkew: "-1m0s"},
+		{name: "skewed unhealthy", maxSkew: 10 * time.Second, skew: time.Minute, unhealthy: true, wantCode: http.StatusServiceUnavailable, wantStatus: "clock_skew", wantSkew: "1m0s"},
+		{name: "within limit unhealthy", maxSkew: 10 * time.Second, skew: 5 * time.Second, unhealthy: true, wantCode: http.StatusOK, wantStatus: "ok", wantSkew: "5s"},
+	} {
+		cfg.Vault.MaxClockSkew, cfg.Vault.ClockSkewUnhealthy = c.maxSkew, c.unhealthy
+		atomic.StoreInt64(&clockSkew, int64(c.skew))
+
+		res := httptest.NewRecorder()
+		handleHealthz(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		if res.Code != c.wantCode {
+			t.Errorf("%s: Expected status %d, got %d", c.name, c.wantCode, res.Code)
+		}
+
+		var status struct {
+			Status    string `json:"status"`
+			ClockSkew string `json:"clock_skew"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
+			t.Fatalf("%s: Unable to decode response: %s", c.name, err)
+		}
+		if status.Status != c.wantStatus || status.ClockSkew != c.wantSkew {
+			t.Errorf("%s: Expected status %q with skew %q, got %+v", c.name, c.wantStatus, c.wantSkew, status)
+		}
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	var (
+		remoteOffset time.Duration
+		status       int
+		noDate       bool
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		if noDate {
+			// Suppresses the Date header set by the server
+			res.Header()["Date"] = nil
+		} else {
+			res.Header().Set("Date", time.Now().Add(remoteOffset).UTC().Format(http.TimeFormat))
+		}
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(status)
+		res.Write([]byte(`{"initialized":true,"sealed":false,"standby":true}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	oldSkew := atomic.LoadInt64(&clockSkew)
+	defer atomic.StoreInt64(&clockSkew, oldSkew)
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.MaxClockSkew = 10 * time.Second
+
+	for _, c := range []struct {
+		name       string
+		offset     time.Duration
+		status     int
+		noDate     bool
+		wantErr    bool
+		wantSkew   time.Duration // Expected within the one second resolution
+		wantSkewed bool
+	}{
+		{name: "in sync", status: http.StatusOK},
+		{name: "local clock behind", offset: time.Minute, status: http.StatusOK, wantSkew: -time.Minute, wantSkewed: true},
+		{name: "local clock ahead", offset: -30 * time.Second, status: http.StatusOK, wantSkew: 30 * time.Second, wantSkewed: true},
+		{name: "within limit", offset: 5 * time.Second, status: http.StatusOK, wantSkew: -5 * time.Second},
+		// Performance standby nodes answer the health check with 473
+		{name: "standby", offset: time.Minute, status: 473, wantSkew: -time.Minute, wantSkewed: true},
+		{name: "no date", status: http.StatusOK, noDate: true, wantErr: true},
+	} {
+		remoteOffset, status, noDate = c.offset, c.status, c.noDate
+		atomic.StoreInt64(&clockSkew, 0)
+
+		err := checkClockSkew()
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: checkClockSkew() error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+
+		if skew := time.Duration(atomic.LoadInt64(&clockSkew)); skew < c.wantSkew-time.Second || skew > c.wantSkew+time.Second {
+			t.Errorf("%s: Expected skew of %s, got %s", c.name, c.wantSkew, skew)
+		}
+		if clockSkewed() != c.wantSkewed {
+			t.Errorf("%s: Expected skewed %v, got %v", c.name, c.wantSkewed, clockSkewed())
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// colorPalette contains the colors to derive token colors from their
+// issuer, empty to disable the derivation
+var colorPalette []string
+
+// colorPattern only allows hex colors to prevent arbitrary CSS from
+// ending up in the page
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func parseColor(in string) (string, error) {
+	in = strings.TrimSpace(in)
+	if !colorPattern.MatchString(in) {
+		return "", errors.Errorf("Invalid color %q, expected #rgb or #rrggbb", in)
+	}
+
+	return strings.ToLower(in), nil
+}
+
+func parseColorPalette(in []string) ([]string, error) {
+	var palette []string
+	for _, e := range in {
+		if strings.TrimSpace(e) == "" {
+			continue
+		}
+
+		c, err := parseColor(e)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid color palette")
+		}
+		palette = append(palette, c)
+	}
+
+	return palette, nil
+}
+
+// issuerColor picks a color from the palette using a stable hash of the
+// issuer so all tokens of the same issuer share the same color
+func issuerColor(issuer string) string {
+	if issuer == "" || len(colorPalette) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(strings.ToLower(issuer)))
+
+	return colorPalette[binary.BigEndian.Uint32(sum[:4])%uint32(len(colorPalette))]
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// compactDefaults returns the values omitted from compact tokens, they
+// are sent once along with the tokens for clients to fill them in
+func compactDefaults() map[string]interface{} {
+	profile := activeProfile()
+
+	return map[string]interface{}{
+		"digits": profile.Digits,
+		"icon":   defaultIcon(tokenTypeTOTP),
+		"period": profile.Period,
+		"type":   tokenTypeTOTP,
+	}
+}
+
+// compactToken serializes the token omitting the fields set to the
+// values contained in compactDefaults
+type compactToken struct {
+	*token
+}
+
+func (c compactToken) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(c.token)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	for k, v := range compactDefaults() {
+		def, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(fields[k], def) {
+			delete(fields, k)
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+func compactTokens(in []*token) []compactToken {
+	out := make([]compactToken, len(in))
+	for i, t := range in {
+		out[i] = compactToken{t}
+	}
+
+	return out
+}
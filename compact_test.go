@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestCompactToken(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	oldIcons := typeIcons
+	defer func() { typeIcons = oldIcons }()
+	typeIcons = map[string]string{}
+
+	for _, c := range []struct {
+		name        string
+		profile     string
+		tok         *token
+		wantOmitted []string
+		wantKept    map[string]interface{}
+	}{
+		{
+			name:        "defaults",
+			profile:     "default",
+			tok:         &token{Name: "Mail", Code: "123456", Digits: 6, Period: 30, Type: tokenTypeTOTP, Icon: "key"},
+			wantOmitted: []string{"digits", "icon", "period", "type"},
+			wantKept:    map[string]interface{}{"name": "Mail", "code": "123456"},
+		},
+		{
+			name:        "non-default values",
+			profile:     "default",
+			tok:         &token{Name: "Bank", Digits: 8, Period: 60, Type: tokenTypeTOTP, Icon: "university"},
+			wantOmitted: []string{"type"},
+			wantKept:    map[string]interface{}{"digits": float64(8), "period": float64(60), "icon": "university"},
+		},
+		{
+			name:        "hotp",
+			profile:     "default",
+			tok:         &token{Name: "VPN", Digits: 6, Type: tokenTypeHOTP, Icon: "key"},
+			wantOmitted: []string{"digits", "icon"},
+			wantKept:    map[string]interface{}{"type": tokenTypeHOTP},
+		},
+		{
+			name:        "defaults of the profile",
+			profile:     "authy",
+			tok:         &token{Name: "Authy", Digits: 7, Period: 10, Type: tokenTypeTOTP, Icon: "key"},
+			wantOmitted: []string{"digits", "period"},
+		},
+		{
+			name:     "defaults of another profile",
+			profile:  "authy",
+			tok:      &token{Name: "Mail", Digits: 6, Period: 30, Type: tokenTypeTOTP, Icon: "key"},
+			wantKept: map[string]interface{}{"digits": float64(6), "period": float64(30)},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.OTP.Profile = c.profile
+
+			raw, err := json.Marshal(compactToken{c.tok})
+			if err != nil {
+				t.Fatalf("Unable to marshal token: %s", err)
+			}
+
+			var fields map[string]interface{}
+			if err = json.Unmarshal(raw, &fields); err != nil {
+				t.Fatalf("Unable to unmarshal token: %s", err)
+			}
+
+			for _, k := range c.wantOmitted {
+				if v, ok := fields[k]; ok {
+					t.Errorf("Expected %q to be omitted, got %v", k, v)
+				}
+			}
+			for k, want := range c.wantKept {
+				if fields[k] != want {
+					t.Errorf("Expected %q to be %v, got %v", k, want, fields[k])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCodesJSONCompact(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","bank"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/bank":
+			res.Write([]byte(`{"data":{"name":"Bank","secret":"JBSWY3DPEHPK3PXP","digits":"8"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			url          string
+			wantDefaults bool
+		}{
+			{url: "/codes.json"},
+			{url: "/codes.json?compact=false"},
+			{url: "/codes.json?compact=true", wantDefaults: true},
+		} {
+			t.Run(c.url, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodGet, c.url, nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleCodesJSON(res, r)
+
+				if res.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", res.Code, res.Body.String())
+				}
+
+				var result struct {
+					Tokens   []map[string]interface{} `json:"tokens"`
+					Defaults map[string]interface{}   `json:"defaults"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+
+				if (result.Defaults != nil) != c.wantDefaults {
+					t.Fatalf("Expected defaults %v, got %+v", c.wantDefaults, result.Defaults)
+				}
+				if len(result.Tokens) != 2 {
+					t.Fatalf("Expected two tokens, got %+v", result.Tokens)
+				}
+
+				// Filling in the defaults must restore the full tokens
+				var (
+					digits    []float64
+					withField int
+				)
+				for _, tok := range result.Tokens {
+					if _, ok := tok["digits"]; ok {
+						withField++
+					}
+					for k, v := range result.Defaults {
+						if _, ok := tok[k]; !ok {
+							tok[k] = v
+						}
+					}
+					if tok["type"] != tokenTypeTOTP || tok["period"] != float64(30) {
+						t.Errorf("Expected a TOTP token with a period of 30, got %+v", tok)
+					}
+					digits = append(digits, tok["digits"].(float64))
+				}
+				if want := map[bool]int{false: 2, true: 1}[c.wantDefaults]; withField != want {
+					t.Errorf("Expected %d tokens to carry their digits, got %d", want, withField)
+				}
+				sort.Float64s(digits)
+				if !reflect.DeepEqual(digits, []float64{6, 8}) {
+					t.Errorf("Expected tokens with 6 and 8 digits, got %v", digits)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const vaultIndexHeader = "X-Vault-Index"
+
+// vaultIndexes stores the last index returned by writes (i.e. logins)
+// per Vault address to be replayed on subsequent requests
+var vaultIndexes sync.Map
+
+// consistencyTransport implements client controlled consistency for
+// Vault clusters using performance standbys: The index returned by
+// writes is sent along with the following requests and requests
+// rejected as the node did not yet catch up with that index (412) are
+// retried.
+type consistencyTransport struct {
+	addr string
+	next http.RoundTripper
+}
+
+func (c *consistencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if idx, ok := vaultIndexes.Load(c.addr); ok && req.Header.Get(vaultIndexHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(vaultIndexHeader, idx.(string))
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	for attempt := 0; ; attempt++ {
+		if resp, err = c.next.RoundTrip(req); err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusPreconditionFailed || attempt >= cfg.Vault.ConsistencyRetries || !canReplay(req) {
+			break
+		}
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		log.WithFields(log.Fields{"path": req.URL.Path, "attempt": attempt + 1}).Debug("Vault node did not yet catch up, retrying")
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(consistencyBackoff(attempt)):
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if idx := resp.Header.Get(vaultIndexHeader); idx != "" && req.Method != http.MethodGet {
+		vaultIndexes.Store(c.addr, idx)
+	}
+
+	return resp, nil
+}
+
+// canReplay checks whether the request can be sent again which is not
+// possible for requests with a body which cannot be recreated
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// consistencyBackoff doubles the wait time with every attempt, starting
+// with 50ms and capped at one second
+func consistencyBackoff(attempt int) time.Duration {
+	d := 50 * time.Millisecond << uint(attempt)
+	if d > time.Second || d <= 0 {
+		return time.Second
+	}
+
+	return d
+}
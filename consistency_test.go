@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConsistencyTransport(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		reads      int
+		readIndex  []string
+		catchUpIn  int // Number of reads rejected before the node caught up
+		writeIndex = "AAAA"
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		res.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			res.Header().Set(vaultIndexHeader, writeIndex)
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+			return
+		}
+
+		reads++
+		readIndex = append(readIndex, r.Header.Get(vaultIndexHeader))
+		// Reads must not replace the index of the write
+		res.Header().Set(vaultIndexHeader, "read-index")
+
+		if reads <= catchUpIn {
+			http.Error(res, `{"errors":["required index state not present"]}`, http.StatusPreconditionFailed)
+			return
+		}
+		res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer vault.Close()
+	defer vaultIndexes.Delete(vault.URL)
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.ConsistencyRetries = 2
+
+	for _, c := range []struct {
+		name      string
+		catchUpIn int
+		wantReads int
+		wantErr   bool
+	}{
+		{name: "caught up", wantReads: 1},
+		{name: "412 then success", catchUpIn: 1, wantReads: 2},
+		{name: "412 until the last retry", catchUpIn: 2, wantReads: 3},
+		{name: "412 exceeding the retries", catchUpIn: 3, wantReads: 3, wantErr: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			lock.Lock()
+			reads, readIndex, catchUpIn = 0, nil, c.catchUpIn
+			lock.Unlock()
+			vaultIndexes.Delete(vault.URL)
+
+			client, err := newVaultClient()
+			if err != nil {
+				t.Fatalf("Unable to create client: %s", err)
+			}
+
+			if _, err = client.Logical().Write("auth/token/create/r", map[string]interface{}{}); err != nil {
+				t.Fatalf("Unable to log in: %s", err)
+			}
+
+			// Every read (including the retries) replays the index of the
+			// login, even using a new client
+			if client, err = newVaultClient(); err != nil {
+				t.Fatalf("Unable to create client: %s", err)
+			}
+			client.SetToken("s.user")
+			for i := 0; i < 2; i++ {
+				lock.Lock()
+				reads, readIndex = 0, nil
+				lock.Unlock()
+
+				_, err = logicalRequest(context.Background(), client, "totp/mail", false)
+				if (err != nil) != c.wantErr {
+					t.Fatalf("logicalRequest() error = %v, expected error %v", err, c.wantErr)
+				}
+
+				lock.Lock()
+				if reads != c.wantReads {
+					t.Errorf("Expected %d reads, got %d", c.wantReads, reads)
+				}
+				for _, idx := range readIndex {
+					if idx != writeIndex {
+						t.Errorf("Expected the reads to carry the index %q, got %q", writeIndex, idx)
+					}
+				}
+				lock.Unlock()
+			}
+		})
+	}
+}
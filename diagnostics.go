@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+func handleFailures(res http.ResponseWriter, r *http.Request) {
+	_, tok, ok := getAdminVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	ctx := withRequestID(r.Context())
+	res.Header().Set("X-Request-Id", requestIDFromContext(ctx))
+
+	secrets, err := getSecrets(ctx, tok, false)
+	if err != nil {
+		logger(ctx).Errorf("Unable to fetch codes: %s", err)
+		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	failures := secrets.Failures
+	if failures == nil {
+		failures = []scanFailure{}
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Path < failures[j].Path })
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(res).Encode(struct {
+		Failures []scanFailure `json:"failures"`
+	}{failures})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleFailures(t *testing.T) {
+	var keys string
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			res.Write([]byte(`{"data":{"policies":["admin"]}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(keys))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/chat":
+			res.Write([]byte(`{"data":{"name":"Chat","secret":"not base32!"}}`))
+		case r.URL.Path == "/v1/totp/bank":
+			res.Write([]byte(`{"data":{"name":"Bank","secret":"JBSWY3DP","algorithm":"whirlpool"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name       string
+		policy     string
+		keys       string
+		wantStatus int
+		wantPaths  []string
+	}{
+		{name: "failing keys sorted by path", policy: "admin", keys: `{"data":{"keys":["mail","chat","bank"]}}`, wantStatus: http.StatusOK, wantPaths: []string{"totp/bank", "totp/chat"}},
+		{name: "no failures", policy: "admin", keys: `{"data":{"keys":["mail"]}}`, wantStatus: http.StatusOK, wantPaths: []string{}},
+		{name: "without admin policy", policy: "root", keys: `{"data":{"keys":["chat"]}}`, wantStatus: http.StatusForbidden},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			keys = c.keys
+			withArgs(t, []string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+				"--admin-policy", c.policy,
+			}, nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				r := httptest.NewRequest(http.MethodGet, "/admin/failures", nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleFailures(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+				if c.wantStatus != http.StatusOK {
+					return
+				}
+
+				var result struct {
+					Failures []scanFailure `json:"failures"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Fatalf("Unable to decode failures: %s", err)
+				}
+				// An empty list instead of null for the client
+				if result.Failures == nil {
+					t.Fatalf("Expected a failures list, got %s", res.Body.String())
+				}
+
+				paths := []string{}
+				for _, f := range result.Failures {
+					paths = append(paths, f.Path)
+					if f.Error == "" {
+						t.Errorf("Expected an error for %q", f.Path)
+					}
+				}
+				if !reflect.DeepEqual(paths, c.wantPaths) {
+					t.Errorf("Expected failures %v, got %v", c.wantPaths, paths)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	digitsMismatchReject = "reject"
+	digitsMismatchWarn   = "warn"
+)
+
+// effectiveDigits returns the number of digits the codes of the token
+// have after applying the defaults of the profile
+func (t *token) effectiveDigits() (int, error) {
+	switch {
+	case t.usesStoredCode():
+		return len(strings.Replace(t.StoredCode, " ", "", -1)), nil
+
+	case t.Type == tokenTypeHOTP:
+		opts, err := t.hotpOpts()
+		return int(opts.Digits), err
+
+	default:
+		opts, err := t.totpOpts()
+		return int(opts.Digits), err
+	}
+}
+
+// checkExpectedDigits warns about tokens whose codes differ in length
+// from the expected number of digits and returns an error for them when
+// configured to reject them
+func checkExpectedDigits(ctx context.Context, t *token) error {
+	if cfg.OTP.ExpectedDigits <= 0 || t.Type == tokenTypeRecovery {
+		return nil
+	}
+
+	digits, err := t.effectiveDigits()
+	if err != nil || digits == cfg.OTP.ExpectedDigits {
+		// Invalid options are reported while generating the code
+		return nil
+	}
+
+	t.warn(logger(ctx).WithFields(log.Fields{
+		"key":      t.Path,
+		"digits":   digits,
+		"expected": cfg.OTP.ExpectedDigits,
+	}), fmt.Sprintf("Token has %d digits instead of the expected %d", digits, cfg.OTP.ExpectedDigits))
+
+	if cfg.OTP.DigitsMismatch != digitsMismatchReject {
+		return nil
+	}
+
+	return errors.Errorf("Token has %d digits instead of the expected %d", digits, cfg.OTP.ExpectedDigits)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckExpectedDigits(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	badSkew := -1
+
+	for _, c := range []struct {
+		name         string
+		expected     int
+		mismatch     string
+		profile      string
+		codeMode     string
+		tok          token
+		wantErr      bool
+		wantWarnings int
+	}{
+		{name: "disabled", tok: token{Type: tokenTypeTOTP, Digits: 8}},
+		{name: "profile default", expected: 6, tok: token{Type: tokenTypeTOTP}},
+		{name: "profile mismatch", expected: 6, profile: "sha1-8", tok: token{Type: tokenTypeTOTP}, wantWarnings: 1},
+		{name: "token digits", expected: 8, tok: token{Type: tokenTypeTOTP, Digits: 8}},
+		{name: "token mismatch warned", expected: 6, tok: token{Type: tokenTypeTOTP, Digits: 8}, wantWarnings: 1},
+		{name: "token mismatch rejected", expected: 6, mismatch: digitsMismatchReject, tok: token{Type: tokenTypeTOTP, Digits: 8}, wantErr: true, wantWarnings: 1},
+		{name: "hotp mismatch", expected: 6, mismatch: digitsMismatchReject, tok: token{Type: tokenTypeHOTP, Digits: 7}, wantErr: true, wantWarnings: 1},
+		{name: "static code", expected: 6, codeMode: codeModeStatic, tok: token{Type: tokenTypeTOTP, StoredCode: "123 456"}},
+		{name: "static code mismatch", expected: 6, mismatch: digitsMismatchReject, codeMode: codeModeStatic, tok: token{Type: tokenTypeTOTP, StoredCode: "1234 5678"}, wantErr: true, wantWarnings: 1},
+		{name: "stored code with secret", expected: 6, codeMode: codeModeStatic, tok: token{Type: tokenTypeTOTP, Secret: "JBSWY3DPEHPK3PXP", StoredCode: "1234 5678"}},
+		{name: "recovery codes", expected: 6, mismatch: digitsMismatchReject, tok: token{Type: tokenTypeRecovery, RecoveryCodes: []string{"abc-123"}}},
+		{name: "invalid options", expected: 6, mismatch: digitsMismatchReject, tok: token{Type: tokenTypeTOTP, Digits: 8, Skew: &badSkew}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.OTP.ExpectedDigits = c.expected
+			cfg.OTP.DigitsMismatch = digitsMismatchWarn
+			if c.mismatch != "" {
+				cfg.OTP.DigitsMismatch = c.mismatch
+			}
+			cfg.OTP.Profile = "default"
+			if c.profile != "" {
+				cfg.OTP.Profile = c.profile
+			}
+			cfg.Vault.CodeMode = c.codeMode
+
+			tok := c.tok
+			err := checkExpectedDigits(context.Background(), &tok)
+			if (err != nil) != c.wantErr {
+				t.Errorf("Expected error %v, got %v", c.wantErr, err)
+			}
+			if len(tok.Warnings) != c.wantWarnings {
+				t.Errorf("Expected %d warnings, got %v", c.wantWarnings, tok.Warnings)
+			}
+		})
+	}
+}
+
+func TestScanVaultExpectedDigits(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["six","eight","backup"]}}`))
+		case r.URL.Path == "/v1/totp/six":
+			res.Write([]byte(`{"data":{"name":"Six","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/eight":
+			res.Write([]byte(`{"data":{"name":"Eight","secret":"JBSWY3DPEHPK3PXP","digits":"8"}}`))
+		case r.URL.Path == "/v1/totp/backup":
+			res.Write([]byte(`{"data":{"name":"Backup","recovery_codes":"abc-123 def-456"}}`))
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name         string
+		expected     int
+		mismatch     string
+		wantNames    []string
+		wantFailures []string
+		wantWarned   []string
+	}{
+		{name: "disabled", mismatch: digitsMismatchReject, wantNames: []string{"Backup", "Eight", "Six"}, wantFailures: []string{}, wantWarned: []string{}},
+		{name: "warn", expected: 6, mismatch: digitsMismatchWarn, wantNames: []string{"Backup", "Eight", "Six"}, wantFailures: []string{}, wantWarned: []string{"Eight"}},
+		{name: "reject", expected: 6, mismatch: digitsMismatchReject, wantNames: []string{"Backup", "Six"}, wantFailures: []string{"totp/eight"}, wantWarned: []string{}},
+		{name: "reject others", expected: 8, mismatch: digitsMismatchReject, wantNames: []string{"Backup", "Eight"}, wantFailures: []string{"totp/six"}, wantWarned: []string{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, []string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp",
+				"--otp-expected-digits", strconv.Itoa(c.expected), "--otp-digits-mismatch", c.mismatch,
+			}, nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				res, err := scanVault(context.Background(), "s.user", false)
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+
+				names, warned := []string{}, []string{}
+				for _, tok := range res.Tokens {
+					names = append(names, tok.Name)
+					if len(tok.Warnings) > 0 {
+						warned = append(warned, tok.Name)
+					}
+				}
+				if !reflect.DeepEqual(names, c.wantNames) {
+					t.Errorf("Expected tokens %v, got %v", c.wantNames, names)
+				}
+				if !reflect.DeepEqual(warned, c.wantWarned) {
+					t.Errorf("Expected warnings for %v, got %v", c.wantWarned, warned)
+				}
+
+				failures := []string{}
+				for _, f := range res.Failures {
+					failures = append(failures, f.Path)
+					if !strings.Contains(f.Error, "instead of the expected") {
+						t.Errorf("Expected a digits error for %q, got %q", f.Path, f.Error)
+					}
+				}
+				if !reflect.DeepEqual(failures, c.wantFailures) {
+					t.Errorf("Expected failures %v, got %v", c.wantFailures, failures)
+				}
+			})
+		})
+	}
+}
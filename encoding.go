@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/base32"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+const (
+	encodingAlnum   = "alnum"
+	encodingDecimal = "decimal"
+
+	alnumAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// 36^6 is just above 2^31, a truncation yields six characters
+	alnumCharsPerTruncation = 6
+)
+
+// encodeCode generates the code for the given counter in the encoding
+// configured for the token
+func (t *token) encodeCode(counter uint64, opts hotp.ValidateOpts) (string, error) {
+	switch t.Encoding {
+	case "", encodingDecimal:
+		return hotp.GenerateCodeCustom(t.Secret, counter, opts)
+	case encodingAlnum:
+		return alnumCode(t.Secret, counter, opts)
+	default:
+		return "", errors.Errorf("Unsupported encoding %q", t.Encoding)
+	}
+}
+
+// alnumCode generates a HOTP code using the same dynamic truncation as
+// the decimal codes but maps the result to uppercase alphanumeric
+// characters instead of digits. The number of digits is used as the
+// length of the code.
+func alnumCode(secret string, counter uint64, opts hotp.ValidateOpts) (string, error) {
+	secret = strings.ToUpper(strings.TrimSpace(secret))
+	if n := len(secret) % 8; n != 0 {
+		secret = secret + strings.Repeat("=", 8-n)
+	}
+
+	secretBytes, err := base32.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", otp.ErrValidateSecretInvalidBase32
+	}
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, counter)
+
+	mac := hmac.New(opts.Algorithm.Hash, secretBytes)
+	mac.Write(buf)
+	sum := mac.Sum(nil)
+
+	// "Dynamic truncation" in RFC 4226
+	offset := int(sum[len(sum)-1] & 0xf)
+
+	// One truncation covers a little more than six characters, longer
+	// codes take the following bytes of the sum for every six characters
+	// so their tail is not constant
+	var value uint32
+	code := make([]byte, opts.Digits.Length())
+	for i := range code {
+		if i%alnumCharsPerTruncation == 0 {
+			value = truncate(sum, offset+4*(i/alnumCharsPerTruncation))
+		}
+		code[i] = alnumAlphabet[value%uint32(len(alnumAlphabet))]
+		value /= uint32(len(alnumAlphabet))
+	}
+
+	return string(code), nil
+}
+
+// truncate reads the 31 bit value of the four bytes of the sum starting
+// at the offset, wrapping around at the end of the sum
+func truncate(sum []byte, offset int) uint32 {
+	var value uint32
+	for i := 0; i < 4; i++ {
+		value = value<<8 | uint32(sum[(offset+i)%len(sum)])
+	}
+
+	return value & 0x7fffffff
+}
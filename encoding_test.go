@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+// Secrets of the test vectors in RFC 4226 (SHA1) and RFC 6238 (SHA256)
+const (
+	rfc4226Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"
+)
+
+func TestAlnumCode(t *testing.T) {
+	for _, c := range []struct {
+		secret  string
+		counter uint64
+		digits  otp.Digits
+		algo    otp.Algorithm
+		want    string
+	}{
+		// Truncated values from RFC 4226 appendix D: 0x4c93cf18, 0x41397eea,
+		// 0x082fef30 and 0x66ef7655 in base 36, least significant first
+		{rfc4226Secret, 0, 6, otp.AlgorithmSHA1, "4HRW8L"},
+		{rfc4226Secret, 1, 6, otp.AlgorithmSHA1, "EBDI3I"},
+		{rfc4226Secret, 2, 6, otp.AlgorithmSHA1, "003S92"},
+		{rfc4226Secret, 3, 6, otp.AlgorithmSHA1, "15Y6KS"},
+		{rfc4226Secret, 0, 4, otp.AlgorithmSHA1, "4HRW"},
+		{rfc4226Secret, 0, 8, otp.AlgorithmSHA1, "4HRW8LF1"},
+		{rfc4226Secret, 1, 8, otp.AlgorithmSHA1, "EBDI3IVL"},
+		{rfc4226Secret, 2, 10, otp.AlgorithmSHA1, "003S92FP4Z"},
+		{rfc4226Secret, 3, 10, otp.AlgorithmSHA1, "15Y6KSAIRM"},
+		{rfc6238Secret, 1, 8, otp.AlgorithmSHA256, "MAX7CC0Q"},
+		// Secrets are accepted without padding and in lowercase
+		{"gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 0, 6, otp.AlgorithmSHA1, "4HRW8L"},
+	} {
+		got, err := alnumCode(c.secret, c.counter, hotp.ValidateOpts{Digits: c.digits, Algorithm: c.algo})
+		if err != nil {
+			t.Errorf("alnumCode(%q, %d, %d) returned error: %s", c.secret, c.counter, c.digits, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("alnumCode(%q, %d, %d) = %q, expected %q", c.secret, c.counter, c.digits, got, c.want)
+		}
+	}
+}
+
+func TestAlnumCodeTail(t *testing.T) {
+	// The characters after the first truncation must not be constant
+	seen := map[string]bool{}
+	for counter := uint64(0); counter < 100; counter++ {
+		code, err := alnumCode(rfc4226Secret, counter, hotp.ValidateOpts{Digits: 10, Algorithm: otp.AlgorithmSHA1})
+		if err != nil {
+			t.Fatalf("alnumCode returned error: %s", err)
+		}
+		seen[code[6:]] = true
+	}
+
+	if len(seen) < 90 {
+		t.Errorf("Expected distinct tails for most counters, got %d distinct of 100", len(seen))
+	}
+}
+
+func TestAlnumCodeInvalidSecret(t *testing.T) {
+	if _, err := alnumCode("not base32!", 0, hotp.ValidateOpts{Digits: 6, Algorithm: otp.AlgorithmSHA1}); err != otp.ErrValidateSecretInvalidBase32 {
+		t.Errorf("Expected invalid base32 error, got %v", err)
+	}
+}
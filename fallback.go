@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// fallbackToken is a break-glass token provided through the config to be
+// served while Vault is unavailable
+type fallbackToken struct {
+	Name   string
+	Secret string
+}
+
+var fallbackTokens []fallbackToken
+
+// parseFallbackTokens parses the tokens given as "Name:Secret". As the
+// values are secrets errors only refer to the position.
+func parseFallbackTokens(in []string) ([]fallbackToken, error) {
+	var tokens []fallbackToken
+
+	for i, e := range in {
+		if strings.TrimSpace(e) == "" {
+			continue
+		}
+
+		// Base32 secrets never contain a colon but names might
+		idx := strings.LastIndex(e, ":")
+		if idx < 1 || idx == len(e)-1 {
+			return nil, errors.Errorf("Fallback token #%d is not in format Name:Secret", i+1)
+		}
+
+		tokens = append(tokens, fallbackToken{
+			Name:   strings.TrimSpace(e[:idx]),
+			Secret: strings.TrimSpace(e[idx+1:]),
+		})
+	}
+
+	return tokens, nil
+}
+
+// markVaultUnavailable records on the request the login of the user failed
+// as Vault is unavailable so the fallback tokens may be served instead
+func markVaultUnavailable(r *http.Request, user string) {
+	*r = *r.WithContext(context.WithValue(r.Context(), ctxKeyVaultUnavailable, user))
+}
+
+// vaultUnavailableUser returns the user whose login failed as Vault is
+// unavailable and whether the request was marked that way
+func vaultUnavailableUser(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(ctxKeyVaultUnavailable).(string)
+	return user, ok
+}
+
+// getFallbackSecrets generates the codes for the fallback tokens
+func getFallbackSecrets(ctx context.Context, next bool) (*scanResult, error) {
+	var (
+		resp     = []*token{}
+		warnings = []tokenWarning{{Warning: "Vault is unavailable, only the fallback tokens are displayed"}}
+	)
+
+	for _, f := range fallbackTokens {
+		tok := &token{
+			Fallback: true,
+			Icon:     "life-ring",
+			Name:     f.Name,
+			Path:     f.Name,
+			Secret:   f.Secret,
+			Type:     tokenTypeTOTP,
+		}
+
+		if err := tok.GenerateCode(next); err != nil {
+			logger(ctx).WithError(err).WithField("name", tok.Name).Error("Unable to generate code")
+			warnings = append(warnings, tokenWarning{Name: tok.Name, Warning: "Unable to generate code"})
+			continue
+		}
+		tok.Fingerprint = tok.ConfigFingerprint()
+
+		resp = append(resp, tok)
+	}
+
+	sort.Sort(tokenList(resp))
+
+	return &scanResult{Tokens: resp, Warnings: warnings}, nil
+}
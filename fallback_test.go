@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestGetSecretsFallback(t *testing.T) {
+	// Nothing listens on the address of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to allocate address: %s", err)
+	}
+	unavailable := "http://" + l.Addr().String()
+	l.Close()
+
+	forbidden := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+	}))
+	defer forbidden.Close()
+
+	sealed := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		http.Error(res, `{"errors":["Vault is sealed"]}`, http.StatusServiceUnavailable)
+	}))
+	defer sealed.Close()
+
+	available := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") == "true" {
+			res.Write([]byte(`{"data":{"keys":["github"]}}`))
+			return
+		}
+		res.Write([]byte(`{"data":{"name":"GitHub","secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer available.Close()
+
+	oldCfg, oldFallback := cfg, fallbackTokens
+	defer func() { cfg, fallbackTokens = oldCfg, oldFallback }()
+
+	cfg.Source = sourceVault
+	cfg.Vault.Prefix = "totp"
+	fallbackTokens = []fallbackToken{{Name: "Break-Glass", Secret: "JBSWY3DPEHPK3PXP"}}
+
+	for _, c := range []struct {
+		name        string
+		addr        string
+		tok         string
+		unavailable bool
+		wantErr     bool
+		wantToken   string
+	}{
+		{name: "forbidden", addr: forbidden.URL, tok: "s.user", wantErr: true},
+		{name: "unreachable", addr: unavailable, tok: "s.user", wantToken: "Break-Glass"},
+		{name: "sealed", addr: sealed.URL, tok: "s.user", wantToken: "Break-Glass"},
+		{name: "success", addr: available.URL, tok: "s.user", wantToken: "GitHub"},
+		{name: "no token", addr: available.URL, wantErr: true},
+		{name: "no token after unavailable login", addr: unavailable, unavailable: true, wantToken: "Break-Glass"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.Address = c.addr
+
+			ctx := context.Background()
+			if c.unavailable {
+				r := httptest.NewRequest(http.MethodGet, "/codes.json", nil)
+				markVaultUnavailable(r, "jdoe")
+				ctx = r.Context()
+			}
+
+			res, err := getSecrets(ctx, c.tok, false)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error, got %d tokens", len(res.Tokens))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if len(res.Tokens) != 1 || res.Tokens[0].Name != c.wantToken {
+				t.Fatalf("Expected only token %q, got %+v", c.wantToken, res.Tokens)
+			}
+			fb := c.wantToken == "Break-Glass"
+			if res.Tokens[0].Fallback != fb {
+				t.Errorf("Expected fallback %v, got %v", fb, res.Tokens[0].Fallback)
+			}
+			if warned := len(res.Warnings) == 1 && res.Warnings[0].Name == ""; warned != fb {
+				t.Errorf("Expected fallback warning %v, got %+v", fb, res.Warnings)
+			}
+		})
+	}
+}
+
+func TestIsVaultUnavailable(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errInvalidAccessToken, want: false},
+		{err: errTooManyTokens, want: false},
+		{err: context.DeadlineExceeded, want: false},
+		{err: &net.OpError{Op: "dial", Err: errNoOTPFields}, want: true},
+	} {
+		if got := isVaultUnavailable(c.err); got != c.want {
+			t.Errorf("isVaultUnavailable(%v) = %v, expected %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestGetGithubVaultTokenFallback(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to allocate address: %s", err)
+	}
+	unavailable := "http://" + l.Addr().String()
+	l.Close()
+
+	// The access token is the login of the user, jdoe is member of the
+	// org and the team, mallory of another org only
+	memberships := map[string][]string{
+		"jdoe":    {"/user/memberships/orgs/example", "/orgs/example/teams/ops/memberships/jdoe"},
+		"mallory": {"/user/memberships/orgs/other-org"},
+	}
+	github := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		login := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/user" {
+			fmt.Fprintf(res, `{"login":%q}`, login)
+			return
+		}
+		for _, p := range memberships[login] {
+			if r.URL.Path == p {
+				res.Write([]byte(`{"state":"active"}`))
+				return
+			}
+		}
+		http.Error(res, `{"message":"Not Found"}`, http.StatusNotFound)
+	}))
+	defer github.Close()
+
+	oldCfg, oldFallback, oldStore := cfg, fallbackTokens, cookieStore
+	defer func() { cfg, fallbackTokens, cookieStore = oldCfg, oldFallback, oldStore }()
+
+	cfg.Source = sourceVault
+	cfg.Vault.Address = unavailable
+	cfg.Github.APIURL = github.URL
+	fallbackTokens = []fallbackToken{{Name: "Break-Glass", Secret: "JBSWY3DPEHPK3PXP"}}
+	cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+	for _, c := range []struct {
+		name        string
+		allowed     []string
+		accessToken string
+		wantStatus  int
+	}{
+		{name: "org member", allowed: []string{"example"}, accessToken: "jdoe", wantStatus: http.StatusOK},
+		{name: "team member", allowed: []string{"example/ops"}, accessToken: "jdoe", wantStatus: http.StatusOK},
+		{name: "member of another org", allowed: []string{"example", "example/ops"}, accessToken: "mallory", wantStatus: http.StatusUnauthorized},
+		{name: "unknown user", allowed: []string{"example"}, accessToken: "nobody", wantStatus: http.StatusUnauthorized},
+		{name: "no memberships configured", accessToken: "jdoe", wantStatus: http.StatusInternalServerError},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Github.FallbackOrgs = c.allowed
+
+			r := httptest.NewRequest(http.MethodGet, "/codes.json", nil)
+			res := httptest.NewRecorder()
+			sess, _ := cookieStore.Get(r, sessionName)
+			sess.Values["access_token"] = c.accessToken
+
+			_, _, ok := getGithubVaultToken(res, r, sess)
+			if ok != (c.wantStatus == http.StatusOK) || res.Code != c.wantStatus {
+				t.Fatalf("Expected status %d, got %d (ok = %v): %s", c.wantStatus, res.Code, ok, res.Body.String())
+			}
+
+			if _, unavailable := vaultUnavailableUser(r.Context()); unavailable != ok {
+				t.Errorf("Expected the request to be marked for fallback tokens: %v", ok)
+			}
+		})
+	}
+}
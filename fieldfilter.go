@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type fieldRequirement struct {
+	Field string
+	Value string
+}
+
+// requiredFields contains the field values a secret must carry to be
+// displayed (i.e. env=prod to serve per-environment views)
+var requiredFields []fieldRequirement
+
+func parseRequiredFields(in []string) ([]fieldRequirement, error) {
+	var out []fieldRequirement
+	for _, e := range in {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.Errorf("Invalid required field %q, expected field=value", e)
+		}
+		out = append(out, fieldRequirement{Field: strings.TrimSpace(parts[0]), Value: parts[1]})
+	}
+
+	return out, nil
+}
+
+// matchesRequiredFields checks the secret data carries all required field
+// values, fields may be given as dotted paths into nested data
+func matchesRequiredFields(data map[string]interface{}) bool {
+	for _, r := range requiredFields {
+		v, ok := lookupField(data, r.Field)
+		if !ok || fmt.Sprint(v) != r.Value {
+			return false
+		}
+	}
+
+	return true
+}
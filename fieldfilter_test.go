@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRequiredFields(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		in      []string
+		want    []fieldRequirement
+		wantErr bool
+	}{
+		{name: "none"},
+		{
+			name: "multiple",
+			in:   []string{"env=prod", "team=ops"},
+			want: []fieldRequirement{{Field: "env", Value: "prod"}, {Field: "team", Value: "ops"}},
+		},
+		{
+			name: "whitespace around the field",
+			in:   []string{" env =prod"},
+			want: []fieldRequirement{{Field: "env", Value: "prod"}},
+		},
+		{
+			name: "value containing equal signs",
+			in:   []string{"labels.query=a=b"},
+			want: []fieldRequirement{{Field: "labels.query", Value: "a=b"}},
+		},
+		{
+			name: "empty value",
+			in:   []string{"env="},
+			want: []fieldRequirement{{Field: "env", Value: ""}},
+		},
+		{name: "missing value", in: []string{"env"}, wantErr: true},
+		{name: "missing field", in: []string{"=prod"}, wantErr: true},
+		{name: "blank field", in: []string{"env=prod", "  =prod"}, wantErr: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseRequiredFields(c.in)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("Expected error %v, got %v", c.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchesRequiredFields(t *testing.T) {
+	oldRequired := requiredFields
+	defer func() { requiredFields = oldRequired }()
+
+	data := map[string]interface{}{
+		"env":    "prod",
+		"digits": 8,
+		"empty":  "",
+		"labels": map[string]interface{}{"team": "ops"},
+	}
+
+	for _, c := range []struct {
+		name     string
+		required []fieldRequirement
+		want     bool
+	}{
+		{name: "no requirements", want: true},
+		{name: "matching value", required: []fieldRequirement{{"env", "prod"}}, want: true},
+		{name: "other value", required: []fieldRequirement{{"env", "dev"}}},
+		{name: "case sensitive", required: []fieldRequirement{{"env", "Prod"}}},
+		{name: "missing field", required: []fieldRequirement{{"team", "ops"}}},
+		{name: "missing field with empty value", required: []fieldRequirement{{"team", ""}}},
+		{name: "present empty value", required: []fieldRequirement{{"empty", ""}}, want: true},
+		{name: "numeric value", required: []fieldRequirement{{"digits", "8"}}, want: true},
+		{name: "nested field", required: []fieldRequirement{{"labels.team", "ops"}}, want: true},
+		{name: "nested map as value", required: []fieldRequirement{{"labels", "ops"}}},
+		{name: "all matching", required: []fieldRequirement{{"env", "prod"}, {"labels.team", "ops"}}, want: true},
+		{name: "one not matching", required: []fieldRequirement{{"env", "prod"}, {"labels.team", "dev"}}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			requiredFields = c.required
+			if got := matchesRequiredFields(data); got != c.want {
+				t.Errorf("Expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretsFromFileRequiredFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-otp-ui")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "tokens.yaml")
+	if err = ioutil.WriteFile(file, []byte(`
+prod:
+  name: Prod
+  secret: JBSWY3DPEHPK3PXP
+  env: prod
+dev:
+  name: Dev
+  secret: JBSWY3DPEHPK3PXP
+  env: dev
+unlabeled:
+  name: Unlabeled
+  secret: JBSWY3DPEHPK3PXP
+`), 0600); err != nil {
+		t.Fatalf("Unable to write source file: %s", err)
+	}
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.SourceFile = file
+
+	oldRequired := requiredFields
+	defer func() { requiredFields = oldRequired }()
+	requiredFields = []fieldRequirement{{Field: "env", Value: "prod"}}
+
+	res, err := getSecretsFromFile(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(res.Tokens) != 1 || res.Tokens[0].Name != "Prod" {
+		t.Errorf("Expected only the Prod token, got %+v", res.Tokens)
+	}
+	// Filtered secrets are intentional and must not show up as problems
+	if len(res.Warnings) != 0 || len(res.Failures) != 0 {
+		t.Errorf("Expected no warnings or failures, got %+v / %+v", res.Warnings, res.Failures)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	sourceFile  = "file"
+	sourceVault = "vault"
+)
+
+// getSecrets fetches the tokens from the configured source
+func getSecrets(ctx context.Context, tok string, next bool) (*scanResult, error) {
+	if cfg.Source == sourceFile {
+		return getSecretsFromFile(ctx, next)
+	}
+
+	if tok == "" {
+		// Only a failed login due to Vault being unavailable leaves the
+		// user without token and allows to serve the fallback tokens
+		user, unavailable := vaultUnavailableUser(ctx)
+		if !unavailable || len(fallbackTokens) == 0 {
+			return nil, errors.New("No Vault token available")
+		}
+
+		logger(ctx).WithField("user", user).Warn("Vault is unavailable, serving fallback tokens")
+		return getFallbackSecrets(ctx, next)
+	}
+
+	if rawFieldsWanted(ctx) {
+		// Neither pregenerated nor cached results carry the stored fields
+		// and the fallback tokens are nothing to back up
+		return getSecretsFromVault(ctx, tok, next)
+	}
+
+	if res := pregeneratedSecrets(ctx, tok, next); res != nil {
+		return res, nil
+	}
+
+	if res := cachedSecrets(ctx, tok, next); res != nil {
+		return res, nil
+	}
+
+	res, err := getSecretsFromVault(ctx, tok, next)
+	if err == nil && !next && codesWanted(ctx) {
+		storeCachedSecrets(tok, res)
+	}
+	if err != nil && len(fallbackTokens) > 0 && isVaultUnavailable(err) {
+		logger(ctx).WithError(err).WithField("token", hashSecret(tok)).Warn("Vault is unavailable, serving fallback tokens")
+		return getFallbackSecrets(ctx, next)
+	}
+
+	return res, err
+}
+
+// getSecretsFromFile reads token definitions from a local JSON / YAML
+// file instead of Vault. The file contains a map of keys to their fields
+// in the same schema used for secrets in Vault.
+func getSecretsFromFile(ctx context.Context, next bool) (*scanResult, error) {
+	raw, err := ioutil.ReadFile(cfg.SourceFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read source file")
+	}
+
+	var entries map[string]interface{}
+	if err = yaml.Unmarshal(raw, &entries); err != nil {
+		return nil, errors.Wrap(err, "Unable to parse source file")
+	}
+
+	var (
+		failures []scanFailure
+		warnings []tokenWarning
+		resp     = []*token{}
+	)
+
+	skipped := func(key string, err error) {
+		if w, ok := skipWarning(key, err); ok {
+			warnings = append(warnings, w)
+		}
+	}
+
+	for k, v := range entries {
+		data, ok := normalizeFileData(v).(map[string]interface{})
+		if !ok {
+			logger(ctx).WithField("key", k).Error("Entry in source file is not a map of fields")
+			if cfg.Vault.ReportMalformed {
+				failures = append(failures, scanFailure{Name: k, Path: k, Error: errMalformedSecret.Error()})
+			}
+			skipped(k, errMalformedSecret)
+			continue
+		}
+
+		folder := path.Dir(k)
+		if folder == "." {
+			folder = ""
+		}
+
+		var stored map[string]interface{}
+		if rawFieldsWanted(ctx) {
+			stored = data
+		}
+
+		tok, err := buildToken(ctx, k, data, buildOptions{
+			Folder: folder,
+			Fields: stored,
+			Codes:  codesWanted(ctx),
+			Next:   next,
+		})
+		if err != nil {
+			if handleBuildError(ctx, k, err) {
+				failures = append(failures, scanFailure{Name: tok.Name, Path: tok.Path, Error: err.Error()})
+			}
+			skipped(k, err)
+			continue
+		}
+
+		if tok == nil {
+			continue
+		}
+
+		resp = append(resp, tok)
+	}
+
+	sort.Sort(tokenList(resp))
+	sortWarnings(warnings)
+
+	return &scanResult{Tokens: resp, Failures: failures, Warnings: warnings}, nil
+}
+
+// normalizeFileData converts the values parsed from YAML into the shape
+// of Vault secret data: maps keyed by strings and scalars as strings
+func normalizeFileData(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for k, sv := range v {
+			out[fmt.Sprint(k)] = normalizeFileData(sv)
+		}
+		return out
+
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, sv := range v {
+			out[i] = normalizeFileData(sv)
+		}
+		return out
+
+	case nil:
+		return nil
+
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetSecretsFromFileWarnings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-otp-ui")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "tokens.yaml")
+	if err = ioutil.WriteFile(file, []byte(`
+mail:
+  name: Mail
+  secret: JBSWY3DPEHPK3PXP
+  digits: 12
+empty:
+  name: Empty
+  secret: ""
+malformed: just a string
+rejected:
+  name: <b>Chat</b>
+  secret: JBSWY3DPEHPK3PXP
+userdata:
+  username: jdoe
+`), 0600); err != nil {
+		t.Fatalf("Unable to write source file: %s", err)
+	}
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.SourceFile = file
+	cfg.Vault.HTMLFields = htmlFieldsReject
+
+	res, err := getSecretsFromFile(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(res.Tokens) != 1 || res.Tokens[0].Name != "Mail" {
+		t.Fatalf("Expected only the Mail token, got %+v", res.Tokens)
+	}
+
+	want := []tokenWarning{
+		{Name: "empty", Warning: "Skipped key with empty secret field"},
+		{Name: "malformed", Warning: "Skipped key with malformed data"},
+		{Name: "rejected", Warning: "Skipped key which was rejected"},
+		{Name: "Mail", Warning: "Digits 12 out of range (4-10), using 10"},
+	}
+	if got := res.warningsFor(res.Tokens); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected warnings:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestGetSecretsFromFileReportNoFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-otp-ui")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "tokens.yaml")
+	if err = ioutil.WriteFile(file, []byte(`
+mail:
+  name: Mail
+  secret: JBSWY3DPEHPK3PXP
+userdata:
+  username: jdoe
+`), 0600); err != nil {
+		t.Fatalf("Unable to write source file: %s", err)
+	}
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.SourceFile = file
+
+	for _, c := range []struct {
+		report       bool
+		wantFailures int
+	}{
+		{report: false},
+		{report: true, wantFailures: 1},
+	} {
+		cfg.Vault.ReportNoFields = c.report
+
+		res, err := getSecretsFromFile(context.Background(), false)
+		if err != nil {
+			t.Fatalf("report %v: Unexpected error: %s", c.report, err)
+		}
+
+		if len(res.Tokens) != 1 || res.Tokens[0].Name != "Mail" {
+			t.Errorf("report %v: Expected only the Mail token, got %+v", c.report, res.Tokens)
+		}
+		if len(res.Failures) != c.wantFailures {
+			t.Fatalf("report %v: Expected %d failures, got %+v", c.report, c.wantFailures, res.Failures)
+		}
+		if c.wantFailures > 0 && res.Failures[0].Error != errNoOTPFields.Error() {
+			t.Errorf("report %v: Expected failure %q, got %+v", c.report, errNoOTPFields, res.Failures[0])
+		}
+	}
+}
+
+func TestGetSecretsFromFileReportMalformed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-otp-ui")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "tokens.yaml")
+	if err = ioutil.WriteFile(file, []byte(`
+mail:
+  name: Mail
+  secret: JBSWY3DPEHPK3PXP
+list:
+  - JBSWY3DPEHPK3PXP
+`), 0600); err != nil {
+		t.Fatalf("Unable to write source file: %s", err)
+	}
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.SourceFile = file
+
+	for _, report := range []bool{false, true} {
+		cfg.Vault.ReportMalformed = report
+
+		res, err := getSecretsFromFile(context.Background(), false)
+		if err != nil {
+			t.Fatalf("report %v: Unexpected error: %s", report, err)
+		}
+
+		if len(res.Tokens) != 1 || res.Tokens[0].Name != "Mail" {
+			t.Errorf("report %v: Expected only the Mail token, got %+v", report, res.Tokens)
+		}
+
+		want := []scanFailure{}
+		if report {
+			want = []scanFailure{{Name: "list", Path: "list", Error: errMalformedSecret.Error()}}
+		}
+		if len(res.Failures) != len(want) || (report && !reflect.DeepEqual(res.Failures, want)) {
+			t.Errorf("report %v: Expected failures %+v, got %+v", report, want, res.Failures)
+		}
+	}
+}
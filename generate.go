@@ -0,0 +1,54 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// generateWorkers returns the number of tokens to generate codes for in
+// parallel, by default one per CPU
+func generateWorkers() int {
+	if cfg.OTP.Workers > 0 {
+		return cfg.OTP.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// generateAll executes fn (generating codes) for all tokens using a
+// bounded number of workers and returns when all tokens are done. The
+// tokens are modified in place so their order is kept.
+func generateAll(tokens []*token, fn func(*token)) {
+	workers := generateWorkers()
+	if workers > len(tokens) {
+		workers = len(tokens)
+	}
+
+	if workers <= 1 {
+		for _, t := range tokens {
+			fn(t)
+		}
+		return
+	}
+
+	var (
+		queue = make(chan *token)
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				fn(t)
+			}
+		}()
+	}
+
+	for _, t := range tokens {
+		queue <- t
+	}
+	close(queue)
+
+	wg.Wait()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGenerateAll(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, workers := range []int{1, 4, 100} {
+		cfg.OTP.Workers = workers
+
+		tokens := make([]*token, 50)
+		for i := range tokens {
+			tokens[i] = &token{Name: fmt.Sprintf("token-%02d", i)}
+		}
+
+		var calls int32
+		generateAll(tokens, func(tok *token) {
+			atomic.AddInt32(&calls, 1)
+			tok.Code = tok.Name
+		})
+
+		if calls != int32(len(tokens)) {
+			t.Errorf("%d workers: Expected %d calls, got %d", workers, len(tokens), calls)
+		}
+		for i, tok := range tokens {
+			if want := fmt.Sprintf("token-%02d", i); tok.Name != want || tok.Code != want {
+				t.Errorf("%d workers: Expected token %q at position %d, got %+v", workers, want, i, tok)
+			}
+		}
+	}
+
+	// Empty lists must not block
+	generateAll(nil, func(*token) { t.Error("Called for empty list") })
+}
+
+func TestScanGeneratesCodes(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","broken","other"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/other":
+			res.Write([]byte(`{"data":{"name":"Other","secret":"JBSWY3DPEHPK3PXP","digits":"8"}}`))
+		case r.URL.Path == "/v1/totp/broken":
+			res.Write([]byte(`{"data":{"name":"Broken","secret":"not base32!"}}`))
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.OTP.Workers = 2
+
+	for _, c := range []struct {
+		name      string
+		ctx       context.Context
+		wantCodes bool
+	}{
+		{name: "codes", ctx: context.Background(), wantCodes: true},
+		{name: "without codes", ctx: withoutCodes(context.Background())},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := scanVault(c.ctx, "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if !c.wantCodes {
+				// Without codes the secret is not decoded
+				if len(res.Tokens) != 3 || len(res.Failures) != 0 {
+					t.Fatalf("Expected all tokens without failures, got %+v / %+v", res.Tokens, res.Failures)
+				}
+				for _, tok := range res.Tokens {
+					if tok.Code != "" {
+						t.Errorf("Expected no code for %q, got %q", tok.Name, tok.Code)
+					}
+				}
+				return
+			}
+
+			if len(res.Tokens) != 2 || res.Tokens[0].Name != "Mail" || res.Tokens[1].Name != "Other" {
+				t.Fatalf("Expected the tokens Mail and Other, got %+v", res.Tokens)
+			}
+			for _, tok := range res.Tokens {
+				if len(tok.Code) != tok.Digits || tok.RemainingSeconds == 0 {
+					t.Errorf("Expected a %d digit code for %q, got %q (remaining %d)", tok.Digits, tok.Name, tok.Code, tok.RemainingSeconds)
+				}
+			}
+
+			if len(res.Failures) != 1 || res.Failures[0].Path != "totp/broken" {
+				t.Errorf("Expected a failure for the broken key, got %+v", res.Failures)
+			}
+			if len(res.Warnings) != 1 || res.Warnings[0].Name != "totp/broken" {
+				t.Errorf("Expected a warning for the broken key, got %+v", res.Warnings)
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateAll(b *testing.B) {
+	tokens := make([]*token, 5000)
+	for i := range tokens {
+		tokens[i] = &token{
+			Name:   fmt.Sprintf("token-%04d", i),
+			Secret: "JBSWY3DPEHPK3PXP",
+			Type:   tokenTypeTOTP,
+		}
+	}
+
+	for _, workers := range []int{1, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.OTP.Workers = workers
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				generateAll(tokens, func(t *token) {
+					if err := t.GenerateCode(false); err != nil {
+						b.Errorf("Unable to generate code: %s", err)
+					}
+				})
+			}
+		})
+	}
+}
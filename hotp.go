@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+func (t *token) hotpCode(counter uint64) (string, error) {
+	opts, err := t.hotpOpts()
+	if err != nil {
+		return "", err
+	}
+
+	return t.encodeCode(counter, opts)
+}
+
+// hotpOpts resolves the options to generate HOTP codes of the token with
+// from its fields and the active profile
+func (t *token) hotpOpts() (hotp.ValidateOpts, error) {
+	profile := activeProfile()
+	opts := hotp.ValidateOpts{
+		Digits:    otp.Digits(profile.Digits),
+		Algorithm: profile.Algorithm,
+	}
+
+	if t.Digits != 0 {
+		opts.Digits = otp.Digits(t.Digits)
+	}
+
+	if t.Algorithm != "" {
+		var err error
+		if opts.Algorithm, err = parseAlgorithm(t.Algorithm); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// maxResyncWindow limits the look-ahead window as every counter in it
+// costs one HMAC per resync request
+const maxResyncWindow = 1000
+
+// errCounterChanged is returned when the counter was updated by someone
+// else between reading and writing the key
+var errCounterChanged = errors.New("Counter was changed concurrently")
+
+// Resync searches the counters in the look-ahead window following the
+// current counter for the given code and returns the counter matching it
+func (t *token) Resync(code string, window uint64) (uint64, bool, error) {
+	code = strings.Replace(code, " ", "", -1)
+
+	for i := uint64(0); i <= window; i++ {
+		if t.Counter > math.MaxUint64-i {
+			// The window must not wrap around to the first counters
+			break
+		}
+
+		c := t.Counter + i
+		cc, err := t.hotpCode(c)
+		if err != nil {
+			return 0, false, err
+		}
+
+		if cc == code {
+			return c, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// hotpKey is the current state of the key of a HOTP token read right
+// before resyncing to not work on a stale counter of a (cached) scan
+type hotpKey struct {
+	client  *api.Client
+	data    map[string]interface{}
+	key     string
+	version int64
+}
+
+func readHOTPKey(tok, key string) (*hotpKey, error) {
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create client")
+	}
+
+	client.SetToken(tok)
+
+	s, err := client.Logical().Read(kvReadPath(key))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read from key %q", key)
+	}
+
+	data, deleted := kvData(key, s)
+	if data == nil || deleted {
+		return nil, errors.Errorf("There is no key %q", key)
+	}
+
+	return &hotpKey{client: client, data: data, key: key, version: kvVersion(key, s)}, nil
+}
+
+// Counter returns the counter currently stored in the key
+func (h *hotpKey) Counter() (uint64, error) {
+	v, ok := h.data["counter"]
+	if !ok {
+		return 0, nil
+	}
+
+	c, err := strconv.ParseUint(fieldString(v), 10, 64)
+	return c, errors.Wrapf(err, "Unable to parse counter of key %q", h.key)
+}
+
+// UpdateCounter writes the counter into the key. KV v2 keys are written
+// using check-and-set against the version read so a concurrent update of
+// the counter is not overwritten.
+func (h *hotpKey) UpdateCounter(counter uint64) error {
+	data := map[string]interface{}{}
+	for k, v := range h.data {
+		data[k] = v
+	}
+	data["counter"] = strconv.FormatUint(counter, 10)
+
+	if isKV2Key(h.key) {
+		// KV v2 expects the fields wrapped into a data object
+		data = map[string]interface{}{
+			"data":    data,
+			"options": map[string]interface{}{"cas": h.version},
+		}
+	}
+
+	_, err := h.client.Logical().Write(kvReadPath(h.key), data)
+	if rerr, ok := errors.Cause(err).(*api.ResponseError); ok && isKV2Key(h.key) && rerr.StatusCode == http.StatusBadRequest {
+		// Vault rejects writes not matching the check-and-set version
+		// with a bad request
+		return errors.Wrapf(errCounterChanged, "Unable to write to key %q: %s", h.key, err)
+	}
+	return errors.Wrapf(err, "Unable to write to key %q", h.key)
+}
+
+// findHOTPToken looks up the HOTP token to resync by its path or, when no
+// path is given, by its name which must be unique then
+func findHOTPToken(tokens []*token, key, name string) (*token, int) {
+	var (
+		found *token
+		count int
+	)
+
+	key = strings.Trim(key, "/")
+	for _, t := range tokens {
+		if t.Type != tokenTypeHOTP {
+			continue
+		}
+
+		if (key != "" && strings.Trim(t.Path, "/") == key) || (key == "" && t.Name == name) {
+			found = t
+			count++
+		}
+	}
+
+	return found, count
+}
+
+func handleHOTPResync(res http.ResponseWriter, r *http.Request) {
+	sess, tok, ok := getVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	var (
+		key  = r.FormValue("path")
+		name = r.FormValue("name")
+		code = r.FormValue("code")
+	)
+
+	if (key == "" && name == "") || code == "" {
+		http.Error(res, `{"error":"Parameters path (or name) and code are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx := withRequestID(r.Context())
+
+	secrets, err := getSecrets(ctx, tok, false)
+	if err != nil {
+		logger(ctx).Errorf("Unable to fetch codes: %s", err)
+		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	t, count := findHOTPToken(secrets.Tokens, key, name)
+	switch {
+	case t == nil:
+		http.Error(res, `{"error":"No HOTP token found"}`, http.StatusNotFound)
+		return
+	case count > 1:
+		http.Error(res, `{"error":"Multiple HOTP tokens with that name found, use the path"}`, http.StatusConflict)
+		return
+	}
+
+	var stored *hotpKey
+	if cfg.Source == sourceVault {
+		// The scan might be cached, resync from the counter stored now
+		if stored, err = readHOTPKey(tok, t.Path); err == nil {
+			t.Counter, err = stored.Counter()
+		}
+		if err != nil {
+			logger(ctx).WithError(err).WithField("path", t.Path).Error("Unable to read counter")
+			http.Error(res, `{"error":"Unable to read counter"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	counter, found, err := t.Resync(code, cfg.HOTP.ResyncWindow)
+	if err != nil {
+		logger(ctx).WithError(err).WithField("path", t.Path).Error("Unable to generate code")
+		http.Error(res, `{"error":"Unable to generate codes for token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if !found || counter == math.MaxUint64 {
+		http.Error(res, `{"error":"Code not found in look-ahead window"}`, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// The matched code has been used, the next code is expected at the following counter
+	counter++
+
+	updated := cfg.HOTP.UpdateCounter && stored != nil
+	if updated {
+		err := stored.UpdateCounter(counter)
+		if errors.Cause(err) == errCounterChanged {
+			logger(ctx).WithError(err).WithField("path", t.Path).Warn("Counter changed during resync")
+			http.Error(res, `{"error":"Counter was changed concurrently, please try again"}`, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logger(ctx).WithError(err).WithField("path", t.Path).Error("Unable to update counter")
+			http.Error(res, `{"error":"Unable to update counter"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	sess.Values["vault_token"] = tok
+	if err := sess.Save(r, res); err != nil {
+		logger(ctx).Errorf("Was not able to set the cookie: %s", err)
+		http.Error(res, "Something went wrong while fetching token. Sorry.", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(res).Encode(struct {
+		Counter uint64 `json:"counter"`
+		Updated bool   `json:"updated"`
+	}{counter, updated})
+}
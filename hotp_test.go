@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Codes of counters 0-9 from RFC 4226 appendix D
+var rfc4226Codes = []string{
+	"755224", "287082", "359152", "969429", "338314",
+	"254676", "287922", "162583", "399871", "520489",
+}
+
+func TestHOTPCode(t *testing.T) {
+	tok := &token{Secret: rfc4226Secret, Type: tokenTypeHOTP}
+	for counter, want := range rfc4226Codes {
+		got, err := tok.hotpCode(uint64(counter))
+		if err != nil {
+			t.Fatalf("hotpCode(%d) returned error: %s", counter, err)
+		}
+		if got != want {
+			t.Errorf("hotpCode(%d) = %q, expected %q", counter, got, want)
+		}
+	}
+}
+
+func TestResync(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		counter     uint64
+		code        string
+		window      uint64
+		wantCounter uint64
+		wantFound   bool
+	}{
+		{name: "current counter", counter: 0, code: "755224", window: 0, wantCounter: 0, wantFound: true},
+		{name: "within window", counter: 1, code: "969429", window: 5, wantCounter: 3, wantFound: true},
+		{name: "end of window", counter: 4, code: "520489", window: 5, wantCounter: 9, wantFound: true},
+		{name: "with spaces", counter: 0, code: "338 314", window: 5, wantCounter: 4, wantFound: true},
+		{name: "beyond window", counter: 0, code: "520489", window: 5},
+		{name: "before counter", counter: 5, code: "755224", window: 5},
+		{name: "window exceeding counter range", counter: math.MaxUint64 - 2, code: "755224", window: 10},
+	} {
+		tok := &token{Secret: rfc4226Secret, Type: tokenTypeHOTP, Counter: c.counter}
+
+		counter, found, err := tok.Resync(c.code, c.window)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+			continue
+		}
+		if found != c.wantFound || counter != c.wantCounter {
+			t.Errorf("%s: got counter=%d found=%v, expected counter=%d found=%v",
+				c.name, counter, found, c.wantCounter, c.wantFound)
+		}
+	}
+}
+
+func TestFindHOTPToken(t *testing.T) {
+	tokens := []*token{
+		{Name: "VPN", Path: "totp/team-a/vpn", Type: tokenTypeHOTP},
+		{Name: "VPN", Path: "totp/team-b/vpn", Type: tokenTypeHOTP},
+		{Name: "Mail", Path: "totp/mail", Type: tokenTypeHOTP},
+		{Name: "Chat", Path: "totp/chat", Type: tokenTypeTOTP},
+	}
+
+	for _, c := range []struct {
+		name, path, tokenName string
+		wantPath              string
+		wantCount             int
+	}{
+		{name: "by path", path: "totp/team-b/vpn", wantPath: "totp/team-b/vpn", wantCount: 1},
+		{name: "by path with slashes", path: "/totp/team-a/vpn/", wantPath: "totp/team-a/vpn", wantCount: 1},
+		{name: "path wins over name", path: "totp/mail", tokenName: "VPN", wantPath: "totp/mail", wantCount: 1},
+		{name: "unique name", tokenName: "Mail", wantPath: "totp/mail", wantCount: 1},
+		{name: "duplicate name", tokenName: "VPN", wantCount: 2},
+		{name: "not HOTP", tokenName: "Chat"},
+		{name: "unknown path", path: "totp/unknown"},
+	} {
+		tok, count := findHOTPToken(tokens, c.path, c.tokenName)
+		if count != c.wantCount {
+			t.Errorf("%s: found %d tokens, expected %d", c.name, count, c.wantCount)
+		}
+		if c.wantCount == 1 && (tok == nil || tok.Path != c.wantPath) {
+			t.Errorf("%s: found %+v, expected path %q", c.name, tok, c.wantPath)
+		}
+	}
+}
+
+func TestHOTPKeyUpdateCounter(t *testing.T) {
+	var written map[string]interface{}
+	current := json.Number("3")
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(res).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"data":     map[string]interface{}{"secret": rfc4226Secret, "type": "hotp", "counter": current},
+				"metadata": map[string]interface{}{"version": 4},
+			}})
+		case http.MethodPut, http.MethodPost:
+			written = nil
+			json.NewDecoder(r.Body).Decode(&written)
+			if opts, _ := written["options"].(map[string]interface{}); opts["cas"] != float64(4) {
+				http.Error(res, `{"errors":["check-and-set parameter did not match the current version"]}`, http.StatusBadRequest)
+				return
+			}
+			res.Write([]byte(`{"data":{"version":5}}`))
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.KV2Mount = "secret"
+
+	stored, err := readHOTPKey("s.user", "secret/totp/vpn")
+	if err != nil {
+		t.Fatalf("Unable to read key: %s", err)
+	}
+
+	if c, err := stored.Counter(); err != nil || c != 3 {
+		t.Fatalf("Counter() = %d, %v, expected 3", c, err)
+	}
+
+	if err := stored.UpdateCounter(8); err != nil {
+		t.Fatalf("Unable to update counter: %s", err)
+	}
+	data, _ := written["data"].(map[string]interface{})
+	if data["counter"] != "8" || data["secret"] != rfc4226Secret {
+		t.Errorf("Unexpected data written: %v", written)
+	}
+
+	// Another version was written in the meantime
+	stored.version = 3
+	if err := stored.UpdateCounter(9); errors.Cause(err) != errCounterChanged {
+		t.Errorf("Expected counter changed error, got %v", err)
+	}
+}
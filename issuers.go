@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type issuerCount struct {
+	Issuer string `json:"issuer"`
+	Count  int    `json:"count"`
+}
+
+// Issuers returns the distinct issuers of the tokens together with the
+// number of tokens of each issuer. Tokens without issuer are skipped.
+func (t tokenList) Issuers() []issuerCount {
+	counts := map[string]int{}
+	for _, tok := range t {
+		if tok.Issuer != "" {
+			counts[tok.Issuer]++
+		}
+	}
+
+	out := []issuerCount{}
+	for issuer, count := range counts {
+		out = append(out, issuerCount{Issuer: issuer, Count: count})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if ki, kj := sortKey(out[i].Issuer), sortKey(out[j].Issuer); ki != kj {
+			return ki < kj
+		}
+		return out[i].Issuer < out[j].Issuer
+	})
+
+	return out
+}
+
+// generatesCodesFor checks whether codes are generated for tokens of the
+// issuer, without issuers configured codes are generated for all tokens
+func generatesCodesFor(issuer string) bool {
+	if len(cfg.Vault.CodeIssuers) == 0 {
+		return true
+	}
+
+	for _, i := range cfg.Vault.CodeIssuers {
+		if strings.EqualFold(strings.TrimSpace(i), issuer) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func handleIssuers(res http.ResponseWriter, r *http.Request) {
+	sess, tok, ok := getVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	ctx := withRequestID(r.Context())
+	res.Header().Set("X-Request-Id", requestIDFromContext(ctx))
+
+	// Only the metadata is required, no need to generate any code
+	secrets, err := getSecrets(withoutCodes(ctx), tok, false)
+	if err != nil {
+		logger(ctx).Errorf("Unable to fetch tokens: %s", err)
+		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	sess.Values["vault_token"] = tok
+	if err := sess.Save(r, res); err != nil {
+		logger(ctx).Errorf("Was not able to set the cookie: %s", err)
+		http.Error(res, "Something went wrong while fetching token. Sorry.", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(res).Encode(struct {
+		Issuers []issuerCount `json:"issuers"`
+	}{tokenList(secrets.Tokens).Issuers()})
+}
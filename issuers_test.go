@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestTokenListIssuers(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		in   tokenList
+		want []issuerCount
+	}{
+		{name: "empty", want: []issuerCount{}},
+		{name: "without issuers", in: tokenList{{Name: "a"}, {Name: "b"}}, want: []issuerCount{}},
+		{
+			name: "counted and sorted",
+			in: tokenList{
+				{Issuer: "GitLab"}, {Issuer: "github"}, {Issuer: "AWS"},
+				{Issuer: "GitLab"}, {}, {Issuer: "GitLab"}, {Issuer: "AWS"},
+			},
+			want: []issuerCount{{Issuer: "AWS", Count: 2}, {Issuer: "github", Count: 1}, {Issuer: "GitLab", Count: 3}},
+		},
+		{
+			// Issuers only differing in case are distinct, ordered by their
+			// exact spelling
+			name: "differing case",
+			in:   tokenList{{Issuer: "github"}, {Issuer: "GitHub"}, {Issuer: "github"}},
+			want: []issuerCount{{Issuer: "GitHub", Count: 1}, {Issuer: "github", Count: 2}},
+		},
+	} {
+		if got := c.in.Issuers(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Expected %+v, got %+v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestGeneratesCodesFor(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		name    string
+		issuers []string
+		issuer  string
+		want    bool
+	}{
+		{name: "all issuers", issuer: "GitHub", want: true},
+		{name: "all issuers without issuer", want: true},
+		{name: "listed", issuers: []string{"GitLab", "GitHub"}, issuer: "GitHub", want: true},
+		{name: "different case", issuers: []string{"github"}, issuer: "GitHub", want: true},
+		{name: "whitespace in list", issuers: []string{" GitHub "}, issuer: "GitHub", want: true},
+		{name: "not listed", issuers: []string{"GitLab"}, issuer: "GitHub"},
+		{name: "prefix of listed issuer", issuers: []string{"GitHub Enterprise"}, issuer: "GitHub"},
+		{name: "without issuer", issuers: []string{"GitHub"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.CodeIssuers = c.issuers
+			if got := generatesCodesFor(c.issuer); got != c.want {
+				t.Errorf("Expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleIssuers(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","chat","broken","plain"]}}`))
+		case r.URL.Path == "/v1/totp/mail", r.URL.Path == "/v1/totp/chat":
+			res.Write([]byte(`{"data":{"issuer":"GitHub","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/broken":
+			// Not decoded as no codes are generated
+			res.Write([]byte(`{"data":{"issuer":"AWS","secret":"not base32!"}}`))
+		case r.URL.Path == "/v1/totp/plain":
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		r := httptest.NewRequest(http.MethodGet, "/issuers.json", nil)
+		r.RemoteAddr = "127.0.0.1:42424"
+		r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+		res := httptest.NewRecorder()
+
+		handleIssuers(res, r)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", res.Code, res.Body.String())
+		}
+
+		var result struct {
+			Issuers []issuerCount `json:"issuers"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			t.Fatalf("Unable to decode response: %s", err)
+		}
+
+		want := []issuerCount{{Issuer: "AWS", Count: 1}, {Issuer: "GitHub", Count: 2}}
+		if !reflect.DeepEqual(result.Issuers, want) {
+			t.Errorf("Expected issuers %+v, got %+v", want, result.Issuers)
+		}
+	})
+}
@@ -7,18 +7,21 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/tdewolff/minify"
 	"github.com/tdewolff/minify/html"
@@ -35,17 +38,119 @@ const (
 
 var (
 	cfg struct {
+		Admin struct {
+			FingerprintSalt string `flag:"admin-fingerprint-salt" env:"ADMIN_FINGERPRINT_SALT" default:"" description:"Salt to fingerprint the secrets with for admins to compare them across environments (empty disables fingerprints)"`
+			Pprof           bool   `flag:"admin-pprof" default:"false" description:"Serve the pprof profiling endpoints on --admin-pprof-listen"`
+			PprofListen     string `flag:"admin-pprof-listen" default:"127.0.0.1:6060" description:"Separate address to serve the pprof profiling endpoints on (never use the public listener)"`
+			Policy          string `flag:"admin-policy" env:"ADMIN_POLICY" default:"" description:"Vault policy granting access to admin / diagnostic endpoints (empty disables them)"`
+		}
+		Auth struct {
+			Mode             string   `flag:"auth-mode" env:"AUTH_MODE" default:"github" description:"How to authenticate users: github (oAuth2 login) or proxy (trusted identity header)"`
+			ProxyHeader      string   `flag:"auth-proxy-header" env:"AUTH_PROXY_HEADER" default:"X-Forwarded-User" description:"Header containing the user name set by the authenticating proxy"`
+			ProxyTokenRole   string   `flag:"auth-proxy-token-role" env:"AUTH_PROXY_TOKEN_ROLE" default:"" description:"Token role to create user tokens with (must allow the entity alias)"`
+			ProxyVaultToken  string   `flag:"auth-proxy-vault-token" env:"AUTH_PROXY_VAULT_TOKEN" default:"" description:"Vault token allowed to create tokens using the token role"`
+			RequiredPolicies []string `flag:"auth-required-policies" env:"AUTH_REQUIRED_POLICIES" default:"" description:"Vault policies of which the user must hold at least one to view tokens (comma separated, empty to rely on Vault ACLs only)"`
+			TrustedProxies   []string `flag:"auth-trusted-proxies" env:"AUTH_TRUSTED_PROXIES" default:"127.0.0.1/32,::1/128" description:"CIDRs the authenticating proxy sends requests from (comma separated)"`
+		}
+		BasePath string `flag:"base-path" default:"" description:"Path to mount the interface below (i.e. /otp when running behind a shared domain)"`
+		CLI      struct {
+			BackupPassphrase string `flag:"cli-backup-passphrase" env:"BACKUP_PASSPHRASE" default:"" description:"Passphrase to decrypt backup bundles with"`
+			GithubToken      string `flag:"cli-github-token" env:"GITHUB_TOKEN" default:"" description:"Github token to log into Vault with when running CLI commands"`
+			MaxNameWidth     int    `flag:"cli-max-name-width" default:"40" description:"Truncate names wider than this in CLI tables (0 to disable)"`
+			VaultToken       string `flag:"cli-vault-token" env:"VAULT_TOKEN" default:"" description:"Vault token to use when running CLI commands"`
+			Watch            bool   `flag:"cli-watch" default:"false" description:"Keep the list command running and refresh the codes every second"`
+		}
 		Github struct {
-			ClientID     string `flag:"client-id" default:"" env:"CLIENT_ID" description:"Github oAuth2 application Client ID" validate:"nonzero"`
-			ClientSecret string `flag:"client-secret" default:"" env:"CLIENT_SECRET" description:"Github oAuth2 application Client Secret" validate:"nonzero"`
+			APIURL         string        `flag:"github-api-url" default:"https://api.github.com" description:"Github API to check the memberships for the fallback tokens against"`
+			AuthMount      string        `flag:"github-auth-mount" default:"github" description:"Mount of the Github auth method in Vault"`
+			ClientID       string        `flag:"client-id" default:"" env:"CLIENT_ID" description:"Github oAuth2 application Client ID"`
+			ClientSecret   string        `flag:"client-secret" default:"" env:"CLIENT_SECRET" description:"Github oAuth2 application Client Secret"`
+			FallbackOrgs   []string      `flag:"github-fallback-orgs" env:"GITHUB_FALLBACK_ORGS" default:"" description:"Github organizations (org) or teams (org/team) whose members are shown the fallback tokens while Vault is unavailable (comma separated, empty to serve them in proxy auth mode only)"`
+			LoginTimeout   time.Duration `flag:"github-login-timeout" default:"10s" description:"Timeout for logging into Vault using the Github access token"`
+			MaxTokenLength int           `flag:"github-max-token-length" default:"255" description:"Maximum length of Github access tokens accepted for logging into Vault"`
+		}
+		HOTP struct {
+			ResyncWindow  uint64 `flag:"hotp-resync-window" default:"10" description:"Number of counters to look ahead when resyncing HOTP tokens"`
+			UpdateCounter bool   `flag:"hotp-resync-update" default:"false" description:"Store the counter found during HOTP resync in Vault"`
+		}
+		Listen   string `flag:"listen" default:":3000" description:"IP/Port to listen on"`
+		LogLevel string `flag:"log-level" default:"info" description:"Set log level (debug, info, warning, error)"`
+		OTP      struct {
+			DigitsMismatch string        `flag:"otp-digits-mismatch" default:"warn" description:"How to handle tokens not having the expected number of digits: warn (log only) or reject (skip the token)"`
+			ExpectedDigits int           `flag:"otp-expected-digits" default:"0" description:"Number of digits the codes of all tokens are expected to have (0 to disable the check)"`
+			Profile        string        `flag:"otp-profile" default:"default" description:"Profile of defaults (digits, period, algorithm) for tokens not specifying them"`
+			Skew           int           `flag:"otp-skew" default:"1" description:"Number of periods before and after the current one codes are valid in for tokens not specifying a skew (reported only, does not change the codes)"`
+			TimeOffset     time.Duration `flag:"otp-time-offset" default:"0" description:"Correction of the time codes are generated for (i.e. for services with a drifting clock) for tokens not specifying an offset"`
+			Workers        int           `flag:"otp-generate-workers" default:"0" description:"Number of tokens to generate codes for in parallel when generating the codes of a whole list (0 = one per CPU)"`
 		}
-		Listen        string `flag:"listen" default:":3000" description:"IP/Port to listen on"`
-		LogLevel      string `flag:"log-level" default:"info" description:"Set log level (debug, info, warning, error)"`
 		SessionSecret string `flag:"session-secret" default:"" env:"SESSION_SECRET" description:"Secret to encrypt the session with"`
-		Vault         struct {
-			Address     string `flag:"vault-addr" env:"VAULT_ADDR" default:"https://127.0.0.1:8200" description:"Vault API address"`
-			Prefix      string `flag:"vault-prefix" env:"VAULT_PREFIX" default:"/totp" description:"Prefix to search for OTP secrets / tokens in"`
-			SecretField string `flag:"vault-secret-field" env:"VAULT_SECRET_FIELD" default:"secret" description:"Field to search the secret in"`
+		Source        string `flag:"source" default:"vault" description:"Where to read the tokens from (vault, file)"`
+		SourceFile    string `flag:"source-file" default:"tokens.yaml" description:"JSON / YAML file to read the tokens from when using the file source"`
+		UI            struct {
+			CaseSensitiveSort bool          `flag:"ui-case-sensitive-sort" default:"false" description:"Sort tokens by name case sensitive (uppercase names first)"`
+			ColorPalette      []string      `flag:"ui-color-palette" default:"" description:"Colors (#rrggbb, comma separated) to derive the colors of tokens without color from their issuer"`
+			ExposeParams      bool          `flag:"ui-expose-params" default:"false" description:"Include the resolved digits, period, algorithm and skew used to generate the codes in the JSON"`
+			ExposePath        bool          `flag:"ui-expose-path" default:"false" description:"Include the Vault key of the tokens in the JSON (i.e. for linking to the secret)"`
+			GroupFolders      bool          `flag:"ui-group-folders" default:"false" description:"Group tokens by the folder they are stored in below the prefix"`
+			MaskCodes         bool          `flag:"ui-mask-codes" default:"false" description:"Replace all codes by placeholders (i.e. for screenshots and demos)"`
+			MaxResponseSize   int           `flag:"ui-max-response-size" default:"0" description:"Reject code responses larger than this number of bytes, clients then need to filter the tokens (0 = unlimited)"`
+			MinRefresh        time.Duration `flag:"ui-min-refresh" default:"5s" description:"Minimum time between two refreshes of the codes regardless of the token periods"`
+			NameNormalization []string      `flag:"ui-name-normalization" default:"" description:"Transformations applied to the token names: trim, collapse (whitespace), title (case), comma separated"`
+			ShowNext          time.Duration `flag:"ui-show-next" default:"5s" description:"Display the next code along with the current one this long before the codes change, the copied code switches at the boundary (0 to disable)"`
+			SortBy            string        `flag:"ui-sort-by" default:"name" description:"Order of the tokens: name, created (newest first, requires KV v2), expiry (expiring first) or path (Vault key)"`
+			SortExpiringLast  bool          `flag:"ui-sort-expiring-last" default:"false" description:"When sorting by expiry sort the codes about to change last"`
+			TypeIcons         []string      `flag:"ui-type-icons" default:"hotp:sort-numeric-asc" description:"Default icons for tokens of a type without an icon (type:icon, comma separated)"`
+		}
+		Vault struct {
+			Address            string        `flag:"vault-addr" env:"VAULT_ADDR" default:"https://127.0.0.1:8200" description:"Vault API address"`
+			ClientCert         string        `flag:"vault-client-cert" env:"VAULT_CLIENT_CERT" default:"" description:"Client certificate (PEM) to authenticate against Vault with using mTLS, reloaded when the file changes"`
+			ClientCertReload   time.Duration `flag:"vault-client-cert-reload" env:"VAULT_CLIENT_CERT_RELOAD" default:"1m" description:"How often to check the client certificate and key for changes (0 to disable reloading)"`
+			ClientKey          string        `flag:"vault-client-key" env:"VAULT_CLIENT_KEY" default:"" description:"Private key (PEM) of the client certificate"`
+			ClockSkewUnhealthy bool          `flag:"vault-clock-skew-unhealthy" env:"VAULT_CLOCK_SKEW_UNHEALTHY" default:"false" description:"Respond to /healthz with status 503 while the clock is skewed by more than --vault-max-clock-skew"`
+			CodeIssuers        []string      `flag:"vault-code-issuers" env:"VAULT_CODE_ISSUERS" default:"" description:"Only generate codes for tokens of these issuers, others are returned without code (comma separated, empty for all)"`
+			CodeMode           string        `flag:"vault-code-mode" env:"VAULT_CODE_MODE" default:"static" description:"How to handle a code stored in Vault: static (display it for keys without secret) or generate (ignore it, always generate from the secret)"`
+			CollapseScans      bool          `flag:"vault-collapse-scans" env:"VAULT_COLLAPSE_SCANS" default:"true" description:"Share the result of a running scan with concurrent requests of the same user instead of scanning again"`
+			CollapseTimeout    time.Duration `flag:"vault-collapse-timeout" env:"VAULT_COLLAPSE_TIMEOUT" default:"2m" description:"Abort a scan shared between concurrent requests after this time, the scan keeps running when the request starting it is canceled (0 to disable)"`
+			Concurrency        int           `flag:"vault-concurrency" env:"VAULT_CONCURRENCY" default:"20" description:"Maximum number of concurrent operations against Vault per scan"`
+			ConsistencyRetries int           `flag:"vault-consistency-retries" env:"VAULT_CONSISTENCY_RETRIES" default:"0" description:"Replay the X-Vault-Index of logins on subsequent requests and retry requests rejected by performance standbys not yet caught up this often (0 to disable)"`
+			CubbyholePrefix    string        `flag:"vault-cubbyhole-prefix" env:"VAULT_CUBBYHOLE_PREFIX" default:"" description:"Additionally scan this path in the cubbyhole of the user for per-user secrets (i.e. cubbyhole/totp, empty to disable)"`
+			FailoverAddresses  []string      `flag:"vault-failover-addr" env:"VAULT_FAILOVER_ADDR" default:"" description:"Vault API addresses to fail over to in order when the Vault at vault-addr is unavailable (comma separated)"`
+			FallbackTokens     []string      `flag:"vault-fallback-tokens" env:"VAULT_FALLBACK_TOKENS" default:"" description:"Break-glass TOTP tokens to serve while Vault is unavailable (Name:Secret, comma separated)"`
+			Headers            []string      `flag:"vault-header" env:"VAULT_HEADERS" default:"" description:"Additional headers to send to Vault (Name:Value, comma separated)"`
+			HTMLFields         string        `flag:"vault-html-fields" env:"VAULT_HTML_FIELDS" default:"strip" description:"How to handle HTML in the name, issuer, icon and note fields: strip (remove it), reject (skip the token) or allow"`
+			HTTPProxy          string        `flag:"vault-http-proxy" env:"VAULT_HTTP_PROXY" default:"" description:"URL of the HTTP proxy to connect to Vault through (i.e. http://proxy:3128, empty to use HTTP(S)_PROXY of the environment)"`
+			KV2CustomMeta      bool          `flag:"vault-kv2-custom-metadata" env:"VAULT_KV2_CUSTOM_METADATA" default:"false" description:"Additionally read the custom metadata of KV v2 secrets to take fields (like name or icon) from (one more read per secret)"`
+			KV2Mount           string        `flag:"vault-kv2-mount" env:"VAULT_KV2_MOUNT" default:"" description:"Mount of the KV v2 engine the prefix is located in (empty for KV v1 / TOTP backend)"`
+			KV2Subkeys         bool          `flag:"vault-kv2-subkeys" env:"VAULT_KV2_SUBKEYS" default:"false" description:"Read only the structure of KV v2 secrets through the subkeys endpoint (Vault 1.10+) when no codes are needed so secrets keeping their other fields in the custom metadata are not read"`
+			ListBatchSize      int           `flag:"vault-list-batch-size" env:"VAULT_LIST_BATCH_SIZE" default:"100" description:"Number of keys of a listing to process at once"`
+			MaxClockSkew       time.Duration `flag:"vault-max-clock-skew" env:"VAULT_MAX_CLOCK_SKEW" default:"0" description:"Compare the local clock against the Date header of Vault at startup and periodically and warn when they differ by more than this (0 to disable)"`
+			MaxListConcurrency int           `flag:"vault-max-list-concurrency" env:"VAULT_MAX_LIST_CONCURRENCY" default:"0" description:"Maximum number of concurrent List operations per scan within the overall concurrency (0 = limited by the overall concurrency only)"`
+			MaxOperations      int64         `flag:"vault-max-operations" env:"VAULT_MAX_OPERATIONS" default:"0" description:"Maximum number of List / Read operations per scan (0 = unlimited)"`
+			MaxReadConcurrency int           `flag:"vault-max-read-concurrency" env:"VAULT_MAX_READ_CONCURRENCY" default:"0" description:"Maximum number of concurrent Read operations per scan within the overall concurrency (0 = limited by the overall concurrency only)"`
+			MaxTokens          int           `flag:"vault-max-tokens" env:"VAULT_MAX_TOKENS" default:"0" description:"Fail scans finding more than this number of tokens (0 = unlimited)"`
+			MinTTL             time.Duration `flag:"vault-min-ttl" env:"VAULT_MIN_TTL" default:"30s" description:"Minimum remaining TTL of a Vault token to be reused, tokens expiring earlier are renewed or replaced"`
+			NameFields         []string      `flag:"vault-name-fields" env:"VAULT_NAME_FIELDS" default:"name,account_name" description:"Fields to read the display name from in order of precedence (comma separated)"`
+			Prefix             string        `flag:"vault-prefix" env:"VAULT_PREFIX" default:"/totp" description:"Prefix to search for OTP secrets / tokens in"`
+			PregenerateNext    time.Duration `flag:"vault-pregenerate-next" env:"VAULT_PREGENERATE_NEXT" default:"0" description:"Scan for the codes of the next period in the background this long before the codes roll over and serve the next codes from that scan (0 to disable)"`
+			RateLimitRetries   int           `flag:"vault-rate-limit-retries" env:"VAULT_RATE_LIMIT_RETRIES" default:"3" description:"Retry requests rejected by Vault rate limit quotas (429) this often after the time given in Retry-After within the scan deadline (0 to disable)"`
+			ReportEmptySecret  bool          `flag:"vault-report-empty-secret" env:"VAULT_REPORT_EMPTY_SECRET" default:"false" description:"Report keys whose secret field is empty as failures (No secret set) in the admin diagnostics instead of only logging them"`
+			ReportMalformed    bool          `flag:"vault-report-malformed" env:"VAULT_REPORT_MALFORMED" default:"false" description:"Report keys whose data is not a map of fields (i.e. a list) as failures in the admin diagnostics instead of only logging them"`
+			ReportNoFields     bool          `flag:"vault-report-no-fields" env:"VAULT_REPORT_NO_FIELDS" default:"false" description:"Report keys containing data but no OTP fields as failures in the admin diagnostics instead of silently skipping them"`
+			RequireFields      []string      `flag:"vault-require-fields" env:"VAULT_REQUIRE_FIELDS" default:"" description:"Only display secrets whose fields have these values (field=value, comma separated, all must match)"`
+			SecretDecoders     []string      `flag:"vault-secret-decoders" env:"VAULT_SECRET_DECODERS" default:"" description:"Decoders to try on the secret in order until one succeeds: base32, base32-no-pad, hex (comma separated, empty to use the secret as base32 as is)"`
+			SecretField        string        `flag:"vault-secret-field" env:"VAULT_SECRET_FIELD" default:"secret" description:"Field to search the secret in (use a dotted path like mfa.totp.seed for nested fields)"`
+			SecretFieldAliases []string      `flag:"vault-secret-field-aliases" env:"VAULT_SECRET_FIELD_ALIASES" default:"totp_secret" description:"Fields to read the secret from when the secret field is not set (comma separated, in order of precedence, include the secret field to change its position)"`
+			SecretRefDepth     int           `flag:"vault-secret-ref-depth" env:"VAULT_SECRET_REF_DEPTH" default:"3" description:"Maximum number of secret_ref references to follow to read the secret from another key (0 to disable)"`
+			SecretReplace      string        `flag:"vault-secret-replace" env:"VAULT_SECRET_REPLACE" default:"" description:"Regular expression to replace in the secret before generating codes (empty to disable)"`
+			SecretReplaceWith  string        `flag:"vault-secret-replace-with" env:"VAULT_SECRET_REPLACE_WITH" default:"" description:"Replacement for matches of vault-secret-replace (supports $1 style references)"`
+			SecretStripPrefix  string        `flag:"vault-secret-strip-prefix" env:"VAULT_SECRET_STRIP_PREFIX" default:"" description:"Prefix to remove from the secret before generating codes (i.e. base32:)"`
+			Serial             bool          `flag:"vault-serial" env:"VAULT_SERIAL" default:"false" description:"Scan strictly serial without concurrent operations against Vault"`
+			ShowDeleted        bool          `flag:"vault-show-deleted" env:"VAULT_SHOW_DELETED" default:"false" description:"Show deleted KV v2 secrets as deleted tokens instead of skipping them"`
+			SingleKey          bool          `flag:"vault-single-key" env:"VAULT_SINGLE_KEY" default:"false" description:"Read the prefix as the key of the only secret instead of listing it (no list capability required)"`
+			SoftDeadline       time.Duration `flag:"vault-soft-deadline" env:"VAULT_SOFT_DEADLINE" default:"0" description:"Return the tokens gathered so far when a scan takes longer than this (0 = wait for the whole scan)"`
+			SortedScan         bool          `flag:"vault-sorted-scan" env:"VAULT_SORTED_SCAN" default:"false" description:"Process the keys one by one in sorted order so partial results (soft deadline, operation budget) always contain the first keys (implies serial scans)"`
+			StaleRevalidate    time.Duration `flag:"vault-stale-while-revalidate" env:"VAULT_STALE_WHILE_REVALIDATE" default:"0" description:"Serve the last scan of a user up to this old immediately and refresh it in the background (0 to disable)"`
+			VerifyToken        bool          `flag:"vault-verify-token" env:"VAULT_VERIFY_TOKEN" default:"false" description:"Check the token is still valid after scanning and scan again using a new token if it expired during the scan"`
 		}
 		VersionAndExit bool `flag:"version" default:"false" description:"Print version information and exit"`
 	}
@@ -53,6 +158,9 @@ var (
 	version     = "dev"
 	mini        = minify.New()
 	cookieStore *sessions.CookieStore
+	typeIcons   map[string]string
+
+	secretReplace *regexp.Regexp
 )
 
 func loadConfig() error {
@@ -64,6 +172,121 @@ func loadConfig() error {
 		return err
 	}
 
+	switch cfg.Source {
+	case sourceVault, sourceFile:
+	default:
+		return fmt.Errorf("Unknown source %q", cfg.Source)
+	}
+
+	switch cfg.Auth.Mode {
+	case authModeGithub, authModeProxy:
+	default:
+		return fmt.Errorf("Unknown auth mode %q", cfg.Auth.Mode)
+	}
+
+	if cfg.HOTP.ResyncWindow > maxResyncWindow {
+		return fmt.Errorf("HOTP resync window %d exceeds %d", cfg.HOTP.ResyncWindow, maxResyncWindow)
+	}
+
+	if _, ok := otpProfiles[cfg.OTP.Profile]; !ok {
+		return fmt.Errorf("Unknown OTP profile %q", cfg.OTP.Profile)
+	}
+
+	if m := cfg.OTP.DigitsMismatch; m != digitsMismatchWarn && m != digitsMismatchReject {
+		return fmt.Errorf("Unknown digits mismatch mode %q", m)
+	}
+
+	if cfg.OTP.Skew < 0 || cfg.OTP.Skew > maxSkew {
+		return fmt.Errorf("OTP skew %d is out of range (0-%d)", cfg.OTP.Skew, maxSkew)
+	}
+
+	if cfg.OTP.TimeOffset > maxTimeOffset || cfg.OTP.TimeOffset < -maxTimeOffset {
+		return fmt.Errorf("OTP time offset %s exceeds %s", cfg.OTP.TimeOffset, maxTimeOffset)
+	}
+
+	switch cfg.UI.SortBy {
+	case sortByName, sortByCreated, sortByExpiry, sortByPath:
+	default:
+		return fmt.Errorf("Unknown sort order %q", cfg.UI.SortBy)
+	}
+
+	if p := cfg.Vault.HTTPProxy; p != "" {
+		if u, err := url.Parse(p); err != nil || u.Host == "" {
+			return fmt.Errorf("Invalid Vault proxy URL %q", p)
+		}
+	}
+
+	if (cfg.Vault.ClientCert == "") != (cfg.Vault.ClientKey == "") {
+		return fmt.Errorf("Client certificate and key need to be set together")
+	}
+
+	if cfg.Vault.ClientCert != "" {
+		c, err := newClientCertReloader(cfg.Vault.ClientCert, cfg.Vault.ClientKey)
+		if err != nil {
+			return err
+		}
+		clientCert = c
+	}
+
+	if h := cfg.Vault.HTMLFields; h != htmlFieldsStrip && h != htmlFieldsReject && h != htmlFieldsAllow {
+		return fmt.Errorf("Unknown HTML field mode %q", h)
+	}
+
+	if cfg.Vault.CodeMode != codeModeStatic && cfg.Vault.CodeMode != codeModeGenerate {
+		return fmt.Errorf("Unknown code mode %q", cfg.Vault.CodeMode)
+	}
+
+	if p := strings.Trim(cfg.Vault.CubbyholePrefix, "/"); p != "" && p != "cubbyhole" && !strings.HasPrefix(p, "cubbyhole/") {
+		return fmt.Errorf("Cubbyhole prefix %q is not located in the cubbyhole", cfg.Vault.CubbyholePrefix)
+	}
+
+	cfg.BasePath = normalizeBasePath(cfg.BasePath)
+
+	if cfg.Admin.Pprof && sameListenAddr(cfg.Admin.PprofListen, cfg.Listen) {
+		return errors.New("Profiling endpoints must not be served on the public listener")
+	}
+
+	if err := validateNameNormalization(cfg.UI.NameNormalization); err != nil {
+		return err
+	}
+
+	var err error
+	if typeIcons, err = parseTypeIcons(cfg.UI.TypeIcons); err != nil {
+		return err
+	}
+
+	if requiredFields, err = parseRequiredFields(cfg.Vault.RequireFields); err != nil {
+		return err
+	}
+
+	if colorPalette, err = parseColorPalette(cfg.UI.ColorPalette); err != nil {
+		return err
+	}
+
+	if vaultHeaders, err = parseVaultHeaders(cfg.Vault.Headers); err != nil {
+		return err
+	}
+
+	if fallbackTokens, err = parseFallbackTokens(cfg.Vault.FallbackTokens); err != nil {
+		return err
+	}
+
+	if err = validateSecretDecoders(cfg.Vault.SecretDecoders); err != nil {
+		return err
+	}
+
+	if cfg.Vault.SecretReplace != "" {
+		if secretReplace, err = regexp.Compile(cfg.Vault.SecretReplace); err != nil {
+			return errors.Wrap(err, "Invalid secret replace expression")
+		}
+	}
+
+	if cfg.Auth.Mode == authModeProxy {
+		if trustedProxies, err = parseTrustedProxies(cfg.Auth.TrustedProxies); err != nil {
+			return err
+		}
+	}
+
 	if l, err := log.ParseLevel(cfg.LogLevel); err == nil {
 		log.SetLevel(l)
 	} else {
@@ -87,20 +310,94 @@ func loadConfig() error {
 	return nil
 }
 
+// validateServeConfig checks the options only required to serve the
+// interface, the CLI commands don't log in users and work without them
+func validateServeConfig() error {
+	switch {
+	case cfg.Source == sourceFile:
+		log.Warnf("Serving tokens from %q without authentication", cfg.SourceFile)
+
+	case cfg.Auth.Mode == authModeGithub && (cfg.Github.ClientID == "" || cfg.Github.ClientSecret == ""):
+		return errors.New("Github client-id and client-secret are required")
+
+	case cfg.Auth.Mode == authModeProxy && (cfg.Auth.ProxyTokenRole == "" || cfg.Auth.ProxyVaultToken == ""):
+		return errors.New("Proxy token-role and vault-token are required in proxy auth mode")
+	}
+
+	return nil
+}
+
 func main() {
 	var err error
 	if err = loadConfig(); err != nil {
 		log.Fatalf("Unable to parse CLI parameters: %s", err)
 	}
 
+	if args := rconfig.Args(); len(args) > 1 {
+		switch args[1] {
+		case "backup-decrypt":
+			if len(args) < 3 {
+				log.Fatal("Usage: vault-otp-ui backup-decrypt <file>")
+			}
+			os.Exit(runBackupDecrypt(args[2]))
+		case "import-migration":
+			if len(args) < 3 {
+				log.Fatal("Usage: vault-otp-ui import-migration <otpauth-migration URL>")
+			}
+			os.Exit(runImportMigration(args[2]))
+		case "list":
+			os.Exit(runList())
+		case "validate":
+			os.Exit(runValidate())
+		}
+	}
+
+	if err = validateServeConfig(); err != nil {
+		log.Fatalf("Unable to parse CLI parameters: %s", err)
+	}
+
+	r := newRouter()
+
+	if cfg.Vault.MaxClockSkew > 0 && cfg.Source == sourceVault {
+		go watchClockSkew()
+	}
+
+	if clientCert != nil && cfg.Vault.ClientCertReload > 0 {
+		go clientCert.watch(cfg.Vault.ClientCertReload)
+	}
+
+	if cfg.Admin.Pprof {
+		go servePprof(cfg.Admin.PprofListen)
+	}
+
+	var h http.Handler = r
+	if cfg.BasePath != "" {
+		h = withBasePath(cfg.BasePath, r)
+	}
+
+	log.Fatalf("HTTP server exitted: %s", http.ListenAndServe(cfg.Listen, h))
+}
+
+// newRouter registers the handlers of the public listener
+func newRouter() *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/oauth2", handleOAuthCallback)
 	r.HandleFunc("/application.js", handleApplicationJS)
 	r.HandleFunc("/vars.js", handleApplicationVars)
 	r.HandleFunc("/codes.json", handleCodesJSON)
+	r.HandleFunc("/issuers.json", handleIssuers)
+	r.HandleFunc("/rollover.json", handleRollover)
+	r.HandleFunc("/whoami", handleWhoami)
+	r.HandleFunc("/hotp/resync", handleHOTPResync).Methods(http.MethodPost)
+	r.HandleFunc("/export", handleExport).Methods(http.MethodPost)
+	r.HandleFunc("/failures.json", handleFailures)
+	r.HandleFunc("/preview.json", handlePreview)
+	r.HandleFunc("/preview/fields", handlePreviewFields).Methods(http.MethodPost)
+	r.HandleFunc("/healthz", handleHealthz)
 	r.PathPrefix("/static").HandlerFunc(handleStatics)
 	r.HandleFunc("/", handleIndexPage)
-	log.Fatalf("HTTP server exitted: %s", http.ListenAndServe(cfg.Listen, r))
+
+	return r
 }
 
 func getFileContentFallback(filename string) (io.Reader, error) {
@@ -141,11 +438,15 @@ func handleApplicationJS(res http.ResponseWriter, r *http.Request) {
 func handleApplicationVars(w http.ResponseWriter, r *http.Request) {
 	sess, _ := cookieStore.Get(r, sessionName)
 	_, hasAccessToken := sess.Values["access_token"]
+	hasAccessToken = hasAccessToken || cfg.Source == sourceFile || cfg.Auth.Mode == authModeProxy
 
 	var buf = new(bytes.Buffer)
 
 	fmt.Fprintf(buf, "const signedIn = %v\n", hasAccessToken)
 	fmt.Fprintf(buf, "const authUrl = %q\n", getAuthenticationURL())
+	fmt.Fprintf(buf, "const groupFolders = %v\n", cfg.UI.GroupFolders)
+	fmt.Fprintf(buf, "const minRefresh = %d\n", refreshFloor())
+	fmt.Fprintf(buf, "const showNext = %d\n", int(cfg.UI.ShowNext/time.Second))
 
 	mini.Minify("application/javascript", w, buf)
 }
@@ -173,17 +474,56 @@ func handleOAuthCallback(res http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(res, r, "/", http.StatusFound)
+	http.Redirect(res, r, cfg.BasePath+"/", http.StatusFound)
 }
 
-func handleCodesJSON(res http.ResponseWriter, r *http.Request) {
+// getVaultToken ensures the user is logged in and has a valid Vault token.
+// In case of an error the response is already written to the client.
+func getVaultToken(res http.ResponseWriter, r *http.Request) (*sessions.Session, string, bool) {
 	sess, _ := cookieStore.Get(r, sessionName)
+	if cfg.Source == sourceFile {
+		// There is no Vault to log into
+		return sess, "", true
+	}
+
+	var (
+		tok string
+		ok  bool
+	)
+
+	if cfg.Auth.Mode == authModeProxy {
+		sess, tok, ok = getProxyVaultToken(res, r, sess)
+	} else {
+		sess, tok, ok = getGithubVaultToken(res, r, sess)
+	}
+
+	if !ok || len(cfg.Auth.RequiredPolicies) == 0 {
+		return sess, tok, ok
+	}
+
+	policies, err := tokenPolicies(tok)
+	if err != nil {
+		log.WithFields(log.Fields{"token": hashSecret(tok)}).Errorf("Unable to check required policies: %s", err)
+		http.Error(res, `{"error":"Unexpected error while checking permissions"}`, http.StatusInternalServerError)
+		return nil, "", false
+	}
+
+	if !hasAnyPolicy(policies, cfg.Auth.RequiredPolicies) {
+		log.WithFields(log.Fields{"token": hashSecret(tok)}).Warn("Token is missing all of the required policies")
+		http.Error(res, `{"error":"Not allowed to view tokens"}`, http.StatusForbidden)
+		return nil, "", false
+	}
+
+	return sess, tok, true
+}
+
+func getGithubVaultToken(res http.ResponseWriter, r *http.Request, sess *sessions.Session) (*sessions.Session, string, bool) {
 	iAccessToken, hasAccessToken := sess.Values["access_token"]
 	iToken := sess.Values["vault_token"]
 
 	if !hasAccessToken {
 		http.Error(res, `{"error":"Not logged in"}`, http.StatusUnauthorized)
-		return
+		return nil, "", false
 	}
 	accessToken := iAccessToken.(string)
 
@@ -194,31 +534,127 @@ func handleCodesJSON(res http.ResponseWriter, r *http.Request) {
 	}
 
 	tok, err := useOrRenewToken(tok, accessToken)
+	if errors.Cause(err) == errInvalidAccessToken {
+		log.WithError(err).Warn("Rejected access token before logging into Vault")
+		http.Error(res, `{"error":"Invalid access token, please log in again"}`, http.StatusUnauthorized)
+		return nil, "", false
+	}
+	if err != nil && len(fallbackTokens) > 0 && len(cfg.Github.FallbackOrgs) > 0 && isVaultUnavailable(err) {
+		// Vault checks the memberships on login, without Vault they need
+		// to be checked before handing out the fallback tokens
+		user := "github:" + hashSecret(accessToken)
+		member, merr := githubMemberOf(accessToken, cfg.Github.FallbackOrgs)
+		if merr != nil {
+			log.WithError(merr).WithField("user", user).Error("Unable to check Github memberships for fallback tokens")
+			http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+			return nil, "", false
+		}
+		if !member {
+			log.WithError(err).WithField("user", user).Warn("Vault is unavailable, user is not allowed to view the fallback tokens")
+			http.Error(res, `{"error":"Not allowed to view the fallback tokens"}`, http.StatusUnauthorized)
+			return nil, "", false
+		}
+
+		log.WithError(err).WithField("user", user).Warn("Vault is unavailable, continuing with fallback tokens")
+		markVaultUnavailable(r, user)
+		return sess, "", true
+	}
 	if err != nil {
 		log.Errorf("Unable to authorize against vault: %s", err)
 		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
-		return
+		return nil, "", false
 	}
 	log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Checked / renewed token")
 
-	nextTokens := r.URL.Query().Get("it") == "next"
+	return sess, tok, true
+}
+
+func handleCodesJSON(res http.ResponseWriter, r *http.Request) {
+	sess, tok, ok := getVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	var (
+		nextTokens = r.URL.Query().Get("it") == "next"
+		bothTokens = r.URL.Query().Get("it") == "both"
+		headOnly   = r.Method == http.MethodHead
+	)
+
+	var expiring int
+	if v := r.URL.Query().Get("expiring"); v != "" {
+		var err error
+		if expiring, err = strconv.Atoi(v); err != nil || expiring < 1 {
+			http.Error(res, `{"error":"Parameter expiring must be a positive number of seconds"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := withRequestID(r.Context())
+	res.Header().Set("X-Request-Id", requestIDFromContext(ctx))
+
+	// The policies are resolved once for all admin-only features below,
+	// without a token (fallback tokens) nobody is an admin
+	var (
+		isAdmin  bool
+		adminErr error
+	)
+	if tok != "" || cfg.Source == sourceFile {
+		if isAdmin, adminErr = hasAdminPolicy(tok); adminErr != nil {
+			logger(ctx).Errorf("Unable to check admin policy: %s", adminErr)
+		}
+	}
+
+	debug, _ := strconv.ParseBool(r.URL.Query().Get("debug"))
+	if debug {
+		if adminErr != nil {
+			http.Error(res, `{"error":"Unexpected error while checking permissions"}`, http.StatusInternalServerError)
+			return
+		}
+
+		if !isAdmin {
+			http.Error(res, `{"error":"Admin permissions required for debug logging"}`, http.StatusForbidden)
+			return
+		}
+
+		ctx = withDebugLogging(ctx)
+		logger(ctx).Info("Debug logging enabled for request")
+	}
+
+	if headOnly {
+		// Only the headers are sent, no need to generate any code
+		ctx = withoutCodes(ctx)
+	}
 
-	tokens, err := getSecretsFromVault(tok, nextTokens)
+	secrets, err := getSecrets(ctx, tok, nextTokens)
+	if err == nil && cfg.Vault.VerifyToken && cfg.Source == sourceVault && tok != "" && tokenExpired(tok) {
+		// The codes must not be displayed when they were fetched using
+		// a token which expired during the scan
+		logger(ctx).WithField("token", hashSecret(tok)).Warn("Token expired during the scan, logging in again")
+		if sess, tok, ok = getVaultToken(res, r); !ok {
+			return
+		}
+		secrets, err = getSecrets(ctx, tok, nextTokens)
+	}
+	if errors.Cause(err) == errTooManyTokens {
+		http.Error(res, `{"error":"Too many tokens found, please use a narrower prefix"}`, http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
-		log.Errorf("Unable to fetch codes: %s", err)
+		logger(ctx).Errorf("Unable to fetch codes: %s", err)
 		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
 		return
 	}
 
 	sess.Values["vault_token"] = tok
 	if err := sess.Save(r, res); err != nil {
-		log.Errorf("Was not able to set the cookie: %s", err)
+		logger(ctx).Errorf("Was not able to set the cookie: %s", err)
 		http.Error(res, "Something went wrong while fetching token. Sorry.", http.StatusInternalServerError)
 		return
 	}
 
 	var (
-		minPeriod   = tokenList(tokens).MinPeriod()
+		minPeriod   = tokenList(secrets.Tokens).MinPeriod()
 		pointOfTime = time.Now()
 	)
 
@@ -226,17 +662,111 @@ func handleCodesJSON(res http.ResponseWriter, r *http.Request) {
 		pointOfTime = pointOfTime.Add(time.Duration(minPeriod) * time.Second)
 	}
 
-	result := struct {
-		Tokens   []*token  `json:"tokens"`
-		NextWrap time.Time `json:"next_wrap"`
-	}{
-		Tokens:   tokens,
-		NextWrap: pointOfTime.Add(time.Duration(minPeriod-(pointOfTime.Second()%minPeriod)) * time.Second),
+	tokens := tokenList(secrets.Tokens)
+	if bothTokens && !headOnly {
+		generateAll(tokens, func(t *token) {
+			if err := t.AddNextCode(pointOfTime); err != nil {
+				logger(ctx).WithError(err).WithField("name", t.Name).Error("Unable to generate next code")
+			}
+		})
+	}
+
+	if expiring > 0 && !headOnly {
+		tokens = tokens.ExpiringWithin(expiring)
+	}
+
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		tokens = tokens.WithTag(tag)
+	}
+
+	if others := tokens.WithoutRecoveryCodes(); len(others) != len(tokens) {
+		// Recovery codes are only displayed to admins
+		if !isAdmin {
+			tokens = others
+		}
 	}
 
+	res.Header().Set("X-Token-Count", strconv.Itoa(len(tokens)))
+	res.Header().Set("ETag", tokens.ETag())
 	res.Header().Set("Content-Type", "application/json")
 	res.Header().Set("Cache-Control", "no-cache")
-	json.NewEncoder(res).Encode(result)
+
+	if headOnly {
+		return
+	}
+
+	sources := append([]sourceStatus{}, secrets.Sources...)
+	for i := range sources {
+		if sources[i].Error == "" {
+			continue
+		}
+
+		// Errors might contain details of the Vault setup, only the
+		// status is shown to other users
+		if !isAdmin {
+			sources[i].Error = ""
+		}
+	}
+
+	if cfg.Admin.FingerprintSalt != "" && isAdmin {
+		for _, t := range tokens {
+			t.SecretFingerprint = secretFingerprint(t.Secret)
+		}
+	}
+
+	if debug {
+		// Debug was only enabled for admins
+		for _, t := range tokens {
+			t.DebugGenerationTime = t.GenerationTime.String()
+		}
+	}
+
+	if cfg.UI.MaskCodes {
+		for _, t := range tokens {
+			t.MaskCodes()
+		}
+	}
+
+	result := struct {
+		Tokens      interface{}            `json:"tokens"`
+		Defaults    map[string]interface{} `json:"defaults,omitempty"` // Values omitted from compact tokens
+		NextWrap    time.Time              `json:"next_wrap"`
+		NextRefresh time.Time              `json:"next_refresh"` // Not before the refresh floor
+		Sources     []sourceStatus         `json:"sources,omitempty"`
+		Truncated   bool                   `json:"truncated,omitempty"`
+		Warnings    []tokenWarning         `json:"warnings,omitempty"`
+	}{
+		Tokens:    tokens,
+		Sources:   sources,
+		Warnings:  secrets.warningsFor(tokens),
+		Truncated: secrets.Truncated,
+		NextWrap:  pointOfTime.Add(time.Duration(minPeriod-(pointOfTime.Second()%minPeriod)) * time.Second),
+	}
+	result.NextRefresh = nextRefresh(result.NextWrap, pointOfTime, minPeriod)
+
+	if compact, _ := strconv.ParseBool(r.URL.Query().Get("compact")); compact {
+		result.Tokens, result.Defaults = compactTokens(tokens), compactDefaults()
+	}
+
+	if !nextTokens && !bothTokens {
+		// The next request will ask for the codes of the next period
+		schedulePregeneration(tok, result.NextWrap)
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		logger(ctx).Errorf("Unable to encode codes: %s", err)
+		http.Error(res, `{"error":"Unexpected error while encoding tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if cfg.UI.MaxResponseSize > 0 && len(body) > cfg.UI.MaxResponseSize {
+		logger(ctx).WithFields(log.Fields{"size": len(body), "max_size": cfg.UI.MaxResponseSize}).Error("Response exceeds the maximum size")
+		http.Error(res, `{"error":"Too many tokens to return at once, please filter them using the tag parameter or a narrower prefix"}`, http.StatusUnprocessableEntity)
+		return
+	}
+
+	res.Write(append(body, '\n'))
 }
 
 func handleStatics(res http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,870 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/rconfig/v2"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestMain(m *testing.M) {
+	// Start every test from the defaults of the flags, the arguments of
+	// the test binary are not meant for the config
+	args := os.Args
+	os.Args = args[:1]
+	if err := rconfig.Parse(&cfg); err != nil {
+		panic(err)
+	}
+	os.Args = args
+
+	os.Exit(m.Run())
+}
+
+// withArgs runs loadConfig with the given command line and environment
+// and restores the config afterwards
+func withArgs(t *testing.T, args []string, env map[string]string, fn func(err error)) {
+	t.Helper()
+
+	oldCfg, oldArgs := cfg, os.Args
+	defer func() { cfg, os.Args = oldCfg, oldArgs }()
+
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	os.Args = append([]string{oldArgs[0]}, args...)
+	fn(loadConfig())
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr bool
+		check   func() bool
+	}{
+		{
+			name:  "defaults",
+			check: func() bool { return cfg.Vault.Address == "https://127.0.0.1:8200" && cfg.Vault.Prefix == "/totp" },
+		},
+		{
+			name:  "environment overrides default",
+			env:   map[string]string{"VAULT_ADDR": "https://vault.example.com"},
+			check: func() bool { return cfg.Vault.Address == "https://vault.example.com" },
+		},
+		{
+			name:  "flag overrides environment",
+			args:  []string{"--vault-addr", "https://flag.example.com"},
+			env:   map[string]string{"VAULT_ADDR": "https://vault.example.com"},
+			check: func() bool { return cfg.Vault.Address == "https://flag.example.com" },
+		},
+		{
+			name:  "CLI commands need no OAuth credentials",
+			args:  []string{"validate"},
+			check: func() bool { return cfg.Github.ClientID == "" },
+		},
+		{name: "unknown source", args: []string{"--source", "ldap"}, wantErr: true},
+		{name: "unknown auth mode", args: []string{"--auth-mode", "saml"}, wantErr: true},
+		{name: "resync window too large", args: []string{"--hotp-resync-window", "100000"}, wantErr: true},
+		{name: "skew out of range", args: []string{"--otp-skew", "11"}, wantErr: true},
+		{name: "client cert without key", args: []string{"--vault-client-cert", "cert.pem"}, wantErr: true},
+		{name: "invalid proxy", args: []string{"--vault-http-proxy", "not a url"}, wantErr: true},
+		{name: "proxy without host", args: []string{"--vault-http-proxy", "proxy:3128"}, wantErr: true},
+		{
+			name:  "proxy from environment",
+			env:   map[string]string{"VAULT_HTTP_PROXY": "http://proxy.example.com:3128"},
+			check: func() bool { return cfg.Vault.HTTPProxy == "http://proxy.example.com:3128" },
+		},
+		{name: "unknown OTP profile", args: []string{"--otp-profile", "sha3-6"}, wantErr: true},
+		{name: "unknown digits mismatch mode", args: []string{"--otp-digits-mismatch", "ignore"}, wantErr: true},
+		{
+			name:  "expected digits",
+			args:  []string{"--otp-expected-digits", "8", "--otp-digits-mismatch", "reject"},
+			check: func() bool { return cfg.OTP.ExpectedDigits == 8 && cfg.OTP.DigitsMismatch == digitsMismatchReject },
+		},
+		{name: "unknown sort order", args: []string{"--ui-sort-by", "issuer"}, wantErr: true},
+		{
+			name:  "sort by path",
+			args:  []string{"--ui-sort-by", "path"},
+			check: func() bool { return cfg.UI.SortBy == sortByPath },
+		},
+		{name: "unknown name normalization", args: []string{"--ui-name-normalization", "trim,upper"}, wantErr: true},
+		{name: "required field without value", args: []string{"--vault-require-fields", "env"}, wantErr: true},
+		{
+			name: "required fields from environment",
+			env:  map[string]string{"VAULT_REQUIRE_FIELDS": "env=prod,team=ops"},
+			check: func() bool {
+				return len(requiredFields) == 2 && requiredFields[1] == fieldRequirement{Field: "team", Value: "ops"}
+			},
+		},
+		{
+			name:  "pprof on loopback",
+			args:  []string{"--admin-pprof"},
+			check: func() bool { return cfg.Admin.PprofListen == "127.0.0.1:6060" },
+		},
+		{name: "pprof on public listener", args: []string{"--admin-pprof", "--admin-pprof-listen", "0.0.0.0:3000"}, wantErr: true},
+		{name: "pprof overlapping public listener", args: []string{"--admin-pprof", "--admin-pprof-listen", "127.0.0.1:3000"}, wantErr: true},
+		{name: "pprof disabled", args: []string{"--admin-pprof-listen", ":3000"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, c.args, c.env, func(err error) {
+				if (err != nil) != c.wantErr {
+					t.Fatalf("loadConfig() error = %v, expected error %v", err, c.wantErr)
+				}
+				if c.check != nil && !c.check() {
+					t.Errorf("Unexpected config: %+v", cfg)
+				}
+			})
+		})
+	}
+}
+
+func TestValidateServeConfig(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "github without credentials", wantErr: true},
+		{name: "github with credentials", args: []string{"--client-id", "a", "--client-secret", "b"}},
+		{name: "github without secret", args: []string{"--client-id", "a"}, wantErr: true},
+		{name: "file source", args: []string{"--source", "file"}},
+		{name: "proxy without token role", args: []string{"--auth-mode", "proxy", "--auth-proxy-vault-token", "x"}, wantErr: true},
+		{name: "proxy without vault token", args: []string{"--auth-mode", "proxy", "--auth-proxy-token-role", "r"}, wantErr: true},
+		{name: "proxy without github credentials", args: []string{"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r", "--client-id", "a"}},
+		{name: "file source without credentials", args: []string{"--source", "file", "--auth-mode", "proxy"}},
+		{name: "proxy", args: []string{"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, c.args, nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+				if err := validateServeConfig(); (err != nil) != c.wantErr {
+					t.Errorf("validateServeConfig() error = %v, expected error %v", err, c.wantErr)
+				}
+			})
+		})
+	}
+}
+
+func TestHandleCodesJSONAdminFeatures(t *testing.T) {
+	var (
+		lookups  int
+		policies []string
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			lookups++
+			json.NewEncoder(res).Encode(map[string]interface{}{"data": map[string]interface{}{"policies": policies}})
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","backup"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/backup":
+			res.Write([]byte(`{"data":{"name":"Backup","recovery_codes":"abc-123 def-456"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+		"--admin-policy", "admin", "--admin-fingerprint-salt", "salt",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			name            string
+			policies        []string
+			url             string
+			wantStatus      int
+			wantTokens      int
+			wantFingerprint bool
+			wantGeneration  bool
+		}{
+			{name: "admin", policies: []string{"admin"}, url: "/codes.json", wantStatus: http.StatusOK, wantTokens: 2, wantFingerprint: true},
+			{name: "admin with debug", policies: []string{"admin"}, url: "/codes.json?debug=true", wantStatus: http.StatusOK, wantTokens: 2, wantFingerprint: true, wantGeneration: true},
+			{name: "user", policies: []string{"default"}, url: "/codes.json", wantStatus: http.StatusOK, wantTokens: 1},
+			{name: "user with debug", policies: []string{"default"}, url: "/codes.json?debug=true", wantStatus: http.StatusForbidden},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				lookups, policies = 0, c.policies
+
+				r := httptest.NewRequest(http.MethodGet, c.url, nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleCodesJSON(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+				if lookups != 1 {
+					t.Errorf("Expected the policies to be looked up once, got %d lookups", lookups)
+				}
+				if c.wantStatus != http.StatusOK {
+					return
+				}
+
+				var result struct {
+					Tokens []map[string]interface{} `json:"tokens"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+				if len(result.Tokens) != c.wantTokens {
+					t.Errorf("Expected %d tokens, got %+v", c.wantTokens, result.Tokens)
+				}
+				for _, tok := range result.Tokens {
+					// Only tokens with a secret have a fingerprint
+					_, hasFingerprint := tok["secret_fingerprint"]
+					if want := c.wantFingerprint && tok["name"] == "Mail"; hasFingerprint != want {
+						t.Errorf("Expected fingerprint %v for %v, got %+v", want, tok["name"], tok)
+					}
+
+					// Generation time is only exposed when debugging
+					generation, hasGeneration := tok["generation_time"].(string)
+					if hasGeneration != c.wantGeneration {
+						t.Errorf("Expected generation time %v for %v, got %+v", c.wantGeneration, tok["name"], tok)
+					}
+					if d, err := time.ParseDuration(generation); hasGeneration && (err != nil || d < 0 || d > time.Second) {
+						t.Errorf("Expected a plausible generation time for %v, got %q", tok["name"], generation)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestHandleCodesJSONRefreshFloor(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["fast"]}}`))
+		case r.URL.Path == "/v1/totp/fast":
+			res.Write([]byte(`{"data":{"name":"Fast","secret":"JBSWY3DPEHPK3PXP","period":"2"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp", "--ui-min-refresh", "10s",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			url          string
+			maxWrap      time.Duration // Latest boundary of the codes from now
+			wantCodesAt  time.Duration // Offset of the time the codes are generated for
+			wantUpcoming bool
+		}{
+			{url: "/codes.json", maxWrap: 2 * time.Second},
+			{url: "/codes.json?it=next", maxWrap: 4 * time.Second, wantCodesAt: 2 * time.Second, wantUpcoming: true},
+		} {
+			r := httptest.NewRequest(http.MethodGet, c.url, nil)
+			r.RemoteAddr = "127.0.0.1:42424"
+			r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+			res := httptest.NewRecorder()
+
+			start := time.Now()
+			handleCodesJSON(res, r)
+
+			if res.Code != http.StatusOK {
+				t.Fatalf("%s: Expected status 200, got %d: %s", c.url, res.Code, res.Body.String())
+			}
+
+			var result struct {
+				Tokens      []map[string]interface{} `json:"tokens"`
+				NextWrap    time.Time                `json:"next_wrap"`
+				NextRefresh time.Time                `json:"next_refresh"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				t.Fatalf("%s: Unable to decode response: %s", c.url, err)
+			}
+
+			// The codes wrap with the period of the token, only the
+			// refresh is held back by the floor
+			if wrap := result.NextWrap.Sub(start); wrap <= 0 || wrap > c.maxWrap+time.Second {
+				t.Errorf("%s: Expected the codes to wrap within %s, got %s", c.url, c.maxWrap, wrap)
+			}
+			if refresh := result.NextRefresh.Sub(start); refresh < 10*time.Second-time.Second {
+				t.Errorf("%s: Expected the refresh not before the floor of 10s, got %s", c.url, refresh)
+			}
+
+			if len(result.Tokens) != 1 {
+				t.Fatalf("%s: Expected one token, got %+v", c.url, result.Tokens)
+			}
+			ok, err := totp.ValidateCustom(result.Tokens[0]["code"].(string), "JBSWY3DPEHPK3PXP", start.Add(c.wantCodesAt), totp.ValidateOpts{Period: 2, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+			if err != nil || !ok {
+				t.Errorf("%s: Expected the code of %s from now, got %v (%v)", c.url, c.wantCodesAt, result.Tokens[0]["code"], err)
+			}
+			if upcoming, _ := result.Tokens[0]["upcoming"].(bool); upcoming != c.wantUpcoming {
+				t.Errorf("%s: Expected upcoming %v, got %+v", c.url, c.wantUpcoming, result.Tokens[0])
+			}
+		}
+	})
+}
+
+func TestHandleCodesJSONExpiring(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["fast","slow"]}}`))
+		case r.URL.Path == "/v1/totp/fast":
+			res.Write([]byte(`{"data":{"name":"Fast","secret":"JBSWY3DPEHPK3PXP","period":"2"}}`))
+		case r.URL.Path == "/v1/totp/slow":
+			res.Write([]byte(`{"data":{"name":"Slow","secret":"JBSWY3DPEHPK3PXP","period":"3600"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	if time.Now().Unix()%3600 > 3590 {
+		t.Skip("Slow token is about to expire as well")
+	}
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			url        string
+			wantStatus int
+			wantTokens []string
+		}{
+			{url: "/codes.json", wantStatus: http.StatusOK, wantTokens: []string{"Fast", "Slow"}},
+			{url: "/codes.json?expiring=5", wantStatus: http.StatusOK, wantTokens: []string{"Fast"}},
+			{url: "/codes.json?expiring=0", wantStatus: http.StatusBadRequest},
+			{url: "/codes.json?expiring=-3", wantStatus: http.StatusBadRequest},
+			{url: "/codes.json?expiring=soon", wantStatus: http.StatusBadRequest},
+		} {
+			r := httptest.NewRequest(http.MethodGet, c.url, nil)
+			r.RemoteAddr = "127.0.0.1:42424"
+			r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+			res := httptest.NewRecorder()
+
+			handleCodesJSON(res, r)
+
+			if res.Code != c.wantStatus {
+				t.Errorf("%s: Expected status %d, got %d: %s", c.url, c.wantStatus, res.Code, res.Body.String())
+				continue
+			}
+			if c.wantStatus != http.StatusOK {
+				continue
+			}
+
+			var result struct {
+				Tokens []struct {
+					Name string `json:"name"`
+				} `json:"tokens"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				t.Fatalf("%s: Unable to decode response: %s", c.url, err)
+			}
+
+			var names []string
+			for _, tok := range result.Tokens {
+				names = append(names, tok.Name)
+			}
+			if !reflect.DeepEqual(names, c.wantTokens) {
+				t.Errorf("%s: Expected tokens %v, got %v", c.url, c.wantTokens, names)
+			}
+		}
+	})
+}
+
+func TestHandleCodesJSONTooManyTokens(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["a","b"]}}`))
+		default:
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp", "--vault-max-tokens", "1",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		r := httptest.NewRequest(http.MethodGet, "/codes.json", nil)
+		r.RemoteAddr = "127.0.0.1:42424"
+		r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+		res := httptest.NewRecorder()
+
+		handleCodesJSON(res, r)
+
+		if res.Code != http.StatusUnprocessableEntity || !strings.Contains(res.Body.String(), "narrower prefix") {
+			t.Errorf("Expected status 422 asking for a narrower prefix, got %d: %s", res.Code, res.Body.String())
+		}
+	})
+}
+
+func TestHandleCodesJSONMaxResponseSize(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","large"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP","tags":"work"}}`))
+		case r.URL.Path == "/v1/totp/large":
+			res.Write([]byte(`{"data":{"name":"` + strings.Repeat("Large", 200) + `","secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		request := func(url string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodGet, url, nil)
+			r.RemoteAddr = "127.0.0.1:42424"
+			r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+			res := httptest.NewRecorder()
+
+			handleCodesJSON(res, r)
+			return res
+		}
+
+		// The name of the large token alone exceeds the limit used below
+		if unlimited := request("/codes.json"); unlimited.Code != http.StatusOK || unlimited.Body.Len() < 1000 {
+			t.Fatalf("Expected the full response without limit, got %d: %d bytes", unlimited.Code, unlimited.Body.Len())
+		}
+
+		for _, c := range []struct {
+			name       string
+			maxSize    int
+			url        string
+			wantStatus int
+		}{
+			{name: "unlimited", url: "/codes.json", wantStatus: http.StatusOK},
+			{name: "too large", maxSize: 1000, url: "/codes.json", wantStatus: http.StatusUnprocessableEntity},
+			{name: "filtered", maxSize: 1000, url: "/codes.json?tag=work", wantStatus: http.StatusOK},
+			{name: "large enough", maxSize: 10000, url: "/codes.json", wantStatus: http.StatusOK},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				cfg.UI.MaxResponseSize = c.maxSize
+
+				res := request(c.url)
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+				if c.wantStatus != http.StatusOK {
+					if !strings.Contains(res.Body.String(), "filter them using the tag parameter") {
+						t.Errorf("Expected a hint to filter the tokens, got %s", res.Body.String())
+					}
+					return
+				}
+
+				if c.maxSize > 0 && res.Body.Len() > c.maxSize+1 {
+					t.Errorf("Expected at most %d bytes, got %d", c.maxSize, res.Body.Len())
+				}
+				var result struct {
+					Tokens []*token `json:"tokens"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Errorf("Expected a complete JSON response, got error %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestHandleCodesJSONMaskCodes(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+		default:
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP","digits":"8"}}`))
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name     string
+		args     []string
+		wantMask bool
+	}{
+		{name: "plain"},
+		{name: "masked", args: []string{"--ui-mask-codes"}, wantMask: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, append([]string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+			}, c.args...), nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				r := httptest.NewRequest(http.MethodGet, "/codes.json?it=both", nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleCodesJSON(res, r)
+
+				if res.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", res.Code, res.Body.String())
+				}
+				body := res.Body.String()
+
+				var result struct {
+					Tokens []struct {
+						Code     string `json:"code"`
+						NextCode string `json:"next_code"`
+					} `json:"tokens"`
+				}
+				if err := json.Unmarshal([]byte(body), &result); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+				if len(result.Tokens) != 1 {
+					t.Fatalf("Expected one token, got %s", body)
+				}
+				tok := result.Tokens[0]
+
+				if !c.wantMask {
+					if len(tok.Code) != 8 || len(tok.NextCode) != 8 {
+						t.Errorf("Expected 8 digit codes, got %q / %q", tok.Code, tok.NextCode)
+					}
+					return
+				}
+
+				if tok.Code != "••••••••" || tok.NextCode != "••••••••" {
+					t.Errorf("Expected masked codes, got %q / %q", tok.Code, tok.NextCode)
+				}
+				code, err := totp.GenerateCodeCustom("JBSWY3DPEHPK3PXP", time.Now(), totp.ValidateOpts{
+					Period: 30, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA1,
+				})
+				if err != nil {
+					t.Fatalf("Unable to generate code: %s", err)
+				}
+				if strings.Contains(body, code) {
+					t.Errorf("Expected the real code %s not to be serialized: %s", code, body)
+				}
+			})
+		})
+	}
+}
+
+func TestHandleCodesJSONSources(t *testing.T) {
+	var policies []string
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case "/v1/auth/token/lookup-self":
+			json.NewEncoder(res).Encode(map[string]interface{}{"data": map[string]interface{}{"policies": policies}})
+		case "/v1/totp":
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+		case "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case "/v1/cubbyhole/totp":
+			http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp", "--vault-cubbyhole-prefix", "cubbyhole/totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+		"--admin-policy", "admin",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			name      string
+			policies  []string
+			wantError bool
+		}{
+			{name: "admin", policies: []string{"admin"}, wantError: true},
+			{name: "user", policies: []string{"default"}},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				policies = c.policies
+
+				r := httptest.NewRequest(http.MethodGet, "/codes.json", nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleCodesJSON(res, r)
+
+				if res.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", res.Code, res.Body.String())
+				}
+
+				var result struct {
+					Tokens  []*token       `json:"tokens"`
+					Sources []sourceStatus `json:"sources"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+
+				if len(result.Tokens) != 1 || len(result.Sources) != 2 {
+					t.Fatalf("Expected the token and the status of both sources, got %+v / %+v", result.Tokens, result.Sources)
+				}
+				if s := result.Sources[0]; s.Source != "totp" || s.Status != sourceStatusOK || s.Error != "" {
+					t.Errorf("Expected the prefix to be ok, got %+v", s)
+				}
+
+				s := result.Sources[1]
+				if s.Source != "cubbyhole/totp" || s.Status != sourceStatusError {
+					t.Errorf("Expected the cubbyhole to have failed, got %+v", s)
+				}
+				// Details of the error are only shown to admins
+				if hasError := strings.Contains(s.Error, "permission denied"); hasError != c.wantError || (!c.wantError && s.Error != "") {
+					t.Errorf("Expected error details %v, got %+v", c.wantError, s)
+				}
+			})
+		}
+	})
+}
+
+func TestHandleCodesJSONVerifyToken(t *testing.T) {
+	var (
+		creates      int32
+		lookupStatus int
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		tok := r.Header.Get("X-Vault-Token")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			n := atomic.AddInt32(&creates, 1)
+			fmt.Fprintf(res, `{"auth":{"client_token":"s.user%d"}}`, n)
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			if tok == "s.user1" && lookupStatus != http.StatusOK {
+				// The first token expires while being used for the scan
+				http.Error(res, `{"errors":["permission denied"]}`, lookupStatus)
+				return
+			}
+			res.Write([]byte(`{"data":{"policies":["default"]}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			fmt.Fprintf(res, `{"data":{"name":"Mail of %s","secret":"JBSWY3DPEHPK3PXP"}}`, tok)
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name         string
+		args         []string
+		lookupStatus int
+		wantName     string
+		wantCreates  int32
+	}{
+		{name: "disabled", lookupStatus: http.StatusForbidden, wantName: "Mail of s.user1", wantCreates: 1},
+		{name: "still valid", args: []string{"--vault-verify-token"}, lookupStatus: http.StatusOK, wantName: "Mail of s.user1", wantCreates: 1},
+		{name: "expired during scan", args: []string{"--vault-verify-token"}, lookupStatus: http.StatusForbidden, wantName: "Mail of s.user2", wantCreates: 2},
+		// Vault failing to answer is no proof of an expired token
+		{name: "unable to verify", args: []string{"--vault-verify-token"}, lookupStatus: http.StatusInternalServerError, wantName: "Mail of s.user1", wantCreates: 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			atomic.StoreInt32(&creates, 0)
+			lookupStatus = c.lookupStatus
+
+			withArgs(t, append([]string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp", "--vault-rate-limit-retries", "0",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+			}, c.args...), nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				r := httptest.NewRequest(http.MethodGet, "/codes.json", nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleCodesJSON(res, r)
+
+				if res.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", res.Code, res.Body.String())
+				}
+
+				var result struct {
+					Tokens []struct {
+						Name string `json:"name"`
+					} `json:"tokens"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+				if len(result.Tokens) != 1 || result.Tokens[0].Name != c.wantName {
+					t.Errorf("Expected only %q, got %+v", c.wantName, result.Tokens)
+				}
+				if n := atomic.LoadInt32(&creates); n != c.wantCreates {
+					t.Errorf("Expected %d created tokens, got %d", c.wantCreates, n)
+				}
+			})
+		})
+	}
+}
+
+func TestHandleCodesJSONHead(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","broken"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/broken":
+			res.Write([]byte(`{"data":{"name":"Broken","secret":"not base32!"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			method    string
+			wantCount string
+			wantBody  bool
+		}{
+			{method: http.MethodGet, wantCount: "1", wantBody: true},
+			// Without generating codes the broken secret is not noticed
+			{method: http.MethodHead, wantCount: "2"},
+		} {
+			r := httptest.NewRequest(c.method, "/codes.json", nil)
+			r.RemoteAddr = "127.0.0.1:42424"
+			r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+			res := httptest.NewRecorder()
+
+			handleCodesJSON(res, r)
+
+			if res.Code != http.StatusOK {
+				t.Fatalf("%s: Expected status 200, got %d: %s", c.method, res.Code, res.Body.String())
+			}
+			if n := res.Header().Get("X-Token-Count"); n != c.wantCount {
+				t.Errorf("%s: Expected %s tokens, got %q", c.method, c.wantCount, n)
+			}
+			if res.Header().Get("ETag") == "" {
+				t.Errorf("%s: Expected an ETag", c.method)
+			}
+			if hasBody := res.Body.Len() > 0; hasBody != c.wantBody {
+				t.Errorf("%s: Expected body %v, got %q", c.method, c.wantBody, res.Body.String())
+			}
+		}
+	})
+}
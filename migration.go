@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// migrationEntry is one OtpParameters message of the Google
+// Authenticator export
+type migrationEntry struct {
+	Secret    []byte
+	Name      string
+	Issuer    string
+	Algorithm uint64
+	Digits    uint64
+	Type      uint64
+	Counter   uint64
+
+	// Err is set for entries which could not be parsed
+	Err error
+}
+
+var migrationKeyCleaner = regexp.MustCompile(`[^a-z0-9._@-]+`)
+
+// parseMigrationURL decodes the protobuf payload contained in an
+// otpauth-migration://offline?data=... URL
+func parseMigrationURL(in string) ([]migrationEntry, error) {
+	u, err := url.Parse(strings.TrimSpace(in))
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid migration URL")
+	}
+
+	if u.Scheme != "otpauth-migration" {
+		return nil, errors.Errorf("Unsupported URL scheme %q", u.Scheme)
+	}
+
+	data := u.Query().Get("data")
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		// Some exporters strip the padding
+		if raw, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+			return nil, errors.Wrap(err, "Invalid payload encoding")
+		}
+	}
+
+	var entries []migrationEntry
+	err = walkProtoFields(raw, func(num int, wireType int, v uint64, b []byte) error {
+		if num != 1 || wireType != 2 {
+			// Version and batch information is not required
+			return nil
+		}
+
+		e, err := parseMigrationEntry(b)
+		if err != nil {
+			// The entry is skipped on import instead of dropping the
+			// other entries of the export
+			e = migrationEntry{Err: errors.Wrap(err, "malformed entry")}
+		}
+		entries = append(entries, e)
+		return nil
+	})
+
+	return entries, err
+}
+
+func parseMigrationEntry(raw []byte) (migrationEntry, error) {
+	var e migrationEntry
+
+	err := walkProtoFields(raw, func(num int, wireType int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			e.Secret = b
+		case 2:
+			e.Name = string(b)
+		case 3:
+			e.Issuer = string(b)
+		case 4:
+			e.Algorithm = v
+		case 5:
+			e.Digits = v
+		case 6:
+			e.Type = v
+		case 7:
+			e.Counter = v
+		}
+		return nil
+	})
+
+	return e, err
+}
+
+// walkProtoFields iterates the fields of a protobuf message. Only the
+// varint and length-delimited wire types used by the export are
+// supported.
+func walkProtoFields(raw []byte, fn func(num, wireType int, v uint64, b []byte) error) error {
+	for len(raw) > 0 {
+		key, n := binary.Uvarint(raw)
+		if n <= 0 {
+			return errors.New("Invalid field key in payload")
+		}
+		raw = raw[n:]
+
+		var (
+			num      = int(key >> 3)
+			wireType = int(key & 0x7)
+			v        uint64
+			b        []byte
+		)
+
+		switch wireType {
+		case 0:
+			if v, n = binary.Uvarint(raw); n <= 0 {
+				return errors.New("Invalid varint in payload")
+			}
+			raw = raw[n:]
+
+		case 2:
+			l, n := binary.Uvarint(raw)
+			if n <= 0 || uint64(len(raw)-n) < l {
+				return errors.New("Invalid length in payload")
+			}
+			b = raw[n : n+int(l)]
+			raw = raw[n+int(l):]
+
+		default:
+			return errors.Errorf("Unsupported wire type %d in payload", wireType)
+		}
+
+		if err := fn(num, wireType, v, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fields converts the entry into the fields stored in Vault
+func (e migrationEntry) fields() (map[string]interface{}, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+
+	if len(e.Secret) == 0 {
+		return nil, errors.New("entry has no secret")
+	}
+
+	data := map[string]interface{}{
+		cfg.Vault.SecretField: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(e.Secret),
+		"name":                e.Name,
+	}
+
+	if e.Issuer != "" {
+		data["issuer"] = e.Issuer
+	}
+
+	switch e.Algorithm {
+	case 0, 1:
+		// Unspecified / SHA1 is the default
+	case 2:
+		data["algorithm"] = "SHA256"
+	case 3:
+		data["algorithm"] = "SHA512"
+	case 4:
+		data["algorithm"] = "MD5"
+	default:
+		return nil, errors.Errorf("unknown algorithm %d", e.Algorithm)
+	}
+
+	switch e.Digits {
+	case 0, 1:
+		data["digits"] = "6"
+	case 2:
+		data["digits"] = "8"
+	default:
+		return nil, errors.Errorf("unknown digit count %d", e.Digits)
+	}
+
+	switch e.Type {
+	case 0, 2:
+		data["type"] = tokenTypeTOTP
+	case 1:
+		data["type"] = tokenTypeHOTP
+		data["counter"] = strconv.FormatUint(e.Counter, 10)
+	default:
+		return nil, errors.Errorf("unknown type %d", e.Type)
+	}
+
+	return data, nil
+}
+
+// key returns the key below the prefix to store the entry in
+func (e migrationEntry) key() string {
+	name := e.Name
+	if e.Issuer != "" && !strings.HasPrefix(name, e.Issuer) {
+		name = e.Issuer + "_" + name
+	}
+
+	return strings.Trim(migrationKeyCleaner.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+}
+
+// runImportMigration imports the tokens of a Google Authenticator export
+// into Vault and returns the exit code to use
+func runImportMigration(migrationURL string) int {
+	if cfg.Source != sourceVault {
+		fmt.Fprintln(os.Stderr, "Importing is only supported for the vault source")
+		return 1
+	}
+
+	entries, err := parseMigrationURL(migrationURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse migration payload: %s\n", err)
+		return 1
+	}
+
+	tok, err := useOrRenewToken(cfg.CLI.VaultToken, cfg.CLI.GithubToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to authorize against vault: %s\n", err)
+		return 1
+	}
+
+	client, err := newVaultClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create client: %s\n", err)
+		return 1
+	}
+	client.SetToken(tok)
+
+	var failed int
+	for i, e := range entries {
+		data, err := e.fields()
+		key := e.key()
+		if err == nil && key == "" {
+			err = errors.New("entry has no name")
+		}
+
+		if err != nil {
+			fmt.Printf("Skipped entry %d: %s\n", i+1, err)
+			failed++
+			continue
+		}
+
+		k := path.Join(scanRoot(), key)
+
+		existing, err := client.Logical().Read(kvReadPath(k))
+		if err != nil {
+			fmt.Printf("Skipped %s: unable to check for existing key: %s\n", k, err)
+			failed++
+			continue
+		}
+
+		if d, deleted := kvData(k, existing); d != nil && !deleted {
+			fmt.Printf("Skipped %s: key already exists\n", k)
+			continue
+		}
+
+		if isKV2Key(k) {
+			// KV v2 expects the fields wrapped into a data object
+			data = map[string]interface{}{"data": data}
+		}
+
+		if _, err = client.Logical().Write(kvReadPath(k), data); err != nil {
+			fmt.Printf("Failed to write %s: %s\n", k, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("Imported %s\n", k)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
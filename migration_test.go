@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// protoField encodes one field of a protobuf message, b is used for the
+// length-delimited wire type, v for varints
+func protoField(num, wireType int, v uint64, b []byte) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	out := append([]byte{}, buf[:binary.PutUvarint(buf, uint64(num<<3|wireType))]...)
+
+	if wireType == 2 {
+		v = uint64(len(b))
+	}
+	out = append(out, buf[:binary.PutUvarint(buf, v)]...)
+
+	return append(out, b...)
+}
+
+func TestWalkProtoFields(t *testing.T) {
+	type field struct {
+		Num, WireType int
+		V             uint64
+		B             string
+	}
+
+	for _, c := range []struct {
+		name    string
+		raw     []byte
+		want    []field
+		wantErr bool
+	}{
+		{name: "empty message"},
+		{name: "varint", raw: []byte{0x08, 0x96, 0x01}, want: []field{{Num: 1, WireType: 0, V: 150}}},
+		{name: "length-delimited", raw: []byte{0x12, 0x02, 'h', 'i'}, want: []field{{Num: 2, WireType: 2, B: "hi"}}},
+		{name: "empty bytes", raw: []byte{0x12, 0x00}, want: []field{{Num: 2, WireType: 2, B: ""}}},
+		{name: "truncated key", raw: []byte{0x80}, wantErr: true},
+		{name: "missing varint", raw: []byte{0x08}, wantErr: true},
+		{name: "truncated varint", raw: []byte{0x08, 0x96}, wantErr: true},
+		{name: "missing length", raw: []byte{0x12}, wantErr: true},
+		{name: "truncated bytes", raw: []byte{0x12, 0x05, 'h', 'i'}, wantErr: true},
+		{name: "length exceeding int", raw: []byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, wantErr: true},
+		{name: "unsupported wire type", raw: []byte{0x0d, 0x00, 0x00, 0x00, 0x00}, wantErr: true},
+	} {
+		var got []field
+		err := walkProtoFields(c.raw, func(num, wireType int, v uint64, b []byte) error {
+			got = append(got, field{Num: num, WireType: wireType, V: v, B: string(b)})
+			return nil
+		})
+
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: walkProtoFields() error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got fields %+v, expected %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseMigrationURL(t *testing.T) {
+	entry := append(protoField(1, 2, 0, []byte("12345678901234567890")), protoField(2, 2, 0, []byte("jdoe@example.com"))...)
+	entry = append(entry, protoField(3, 2, 0, []byte("Example"))...)
+	entry = append(entry, protoField(4, 0, 1, nil)...)
+	entry = append(entry, protoField(5, 0, 2, nil)...)
+	entry = append(entry, protoField(6, 0, 2, nil)...)
+
+	payload := append(protoField(1, 2, 0, entry), protoField(2, 0, 1, nil)...)
+
+	for _, c := range []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{name: "padded", in: "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload))},
+		{name: "without padding", in: "otpauth-migration://offline?data=" + url.QueryEscape(base64.RawStdEncoding.EncodeToString(payload))},
+		{name: "truncated payload", in: "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload[:len(payload)-4])), wantErr: true},
+		{name: "wrong scheme", in: "otpauth://totp/Example?secret=GEZDGNBV", wantErr: true},
+		{name: "invalid encoding", in: "otpauth-migration://offline?data=%21%21", wantErr: true},
+	} {
+		entries, err := parseMigrationURL(c.in)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: parseMigrationURL() error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("%s: expected one entry, got %+v", c.name, entries)
+		}
+
+		fields, err := entries[0].fields()
+		if err != nil {
+			t.Fatalf("%s: fields() returned error: %s", c.name, err)
+		}
+
+		want := map[string]interface{}{
+			cfg.Vault.SecretField: rfc4226Secret,
+			"name":                "jdoe@example.com",
+			"issuer":              "Example",
+			"digits":              "8",
+			"type":                tokenTypeTOTP,
+		}
+		if !reflect.DeepEqual(fields, want) {
+			t.Errorf("%s: got fields %+v, expected %+v", c.name, fields, want)
+		}
+		if key := entries[0].key(); key != "example_jdoe@example.com" {
+			t.Errorf("%s: got key %q", c.name, key)
+		}
+	}
+
+	// No prefix of the payload must make the parser panic
+	for i := range payload {
+		parseMigrationURL("otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload[:i])))
+	}
+}
+
+func TestParseMigrationURLMalformedEntry(t *testing.T) {
+	entry := append(protoField(1, 2, 0, []byte("12345678901234567890")), protoField(2, 2, 0, []byte("jdoe@example.com"))...)
+
+	for _, c := range []struct {
+		name      string
+		malformed []byte
+	}{
+		{name: "truncated entry", malformed: entry[:len(entry)-1]},
+		{name: "invalid field key", malformed: []byte{0x80}},
+		{name: "unsupported wire type", malformed: protoField(1, 1, 0, nil)},
+	} {
+		payload := append(protoField(1, 2, 0, c.malformed), protoField(1, 2, 0, entry)...)
+
+		entries, err := parseMigrationURL("otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload)))
+		if err != nil {
+			t.Errorf("%s: parseMigrationURL() returned error: %s", c.name, err)
+			continue
+		}
+
+		if len(entries) != 2 {
+			t.Errorf("%s: expected both entries, got %+v", c.name, entries)
+			continue
+		}
+
+		if _, err := entries[0].fields(); err == nil {
+			t.Errorf("%s: expected the malformed entry to be skipped", c.name)
+		}
+		if _, err := entries[1].fields(); err != nil || entries[1].key() != "jdoe@example.com" {
+			t.Errorf("%s: expected the valid entry to be imported, got error %v (key %q)", c.name, err, entries[1].key())
+		}
+	}
+}
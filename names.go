@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	nameNormalizeCollapse = "collapse"
+	nameNormalizeTitle    = "title"
+	nameNormalizeTrim     = "trim"
+)
+
+func validateNameNormalization(in []string) error {
+	for _, n := range in {
+		switch strings.TrimSpace(n) {
+		case "", nameNormalizeCollapse, nameNormalizeTitle, nameNormalizeTrim:
+		default:
+			return errors.Errorf("Unknown name normalization %q", n)
+		}
+	}
+
+	return nil
+}
+
+// normalizeName applies the configured transformations to the name of
+// a token in the order they were given
+func normalizeName(name string) string {
+	for _, n := range cfg.UI.NameNormalization {
+		switch strings.TrimSpace(n) {
+		case nameNormalizeCollapse:
+			name = strings.Join(strings.Fields(name), " ")
+		case nameNormalizeTitle:
+			// Lowercase first to also fix names given in all caps
+			name = strings.Title(strings.ToLower(name))
+		case nameNormalizeTrim:
+			name = strings.TrimSpace(name)
+		}
+	}
+
+	return name
+}
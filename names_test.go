@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		name           string
+		normalizations []string
+		in             string
+		want           string
+	}{
+		{name: "none", in: "  github  mail ", want: "  github  mail "},
+		{name: "trim", normalizations: []string{"trim"}, in: " \tgithub  mail \n", want: "github  mail"},
+		{name: "collapse", normalizations: []string{"collapse"}, in: "  github \t mail\n", want: "github mail"},
+		{name: "title", normalizations: []string{"title"}, in: "GITHUB mail", want: "Github Mail"},
+		{name: "title keeps whitespace", normalizations: []string{"title"}, in: " git  hub ", want: " Git  Hub "},
+		{name: "all", normalizations: []string{"trim", " collapse ", "title"}, in: "  GITHUB   (work) ", want: "Github (Work)"},
+		{name: "empty entries", normalizations: []string{"", "trim"}, in: " github ", want: "github"},
+	} {
+		cfg.UI.NameNormalization = c.normalizations
+		if got := normalizeName(c.in); got != c.want {
+			t.Errorf("%s: normalizeName(%q) = %q, expected %q", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestValidateNameNormalization(t *testing.T) {
+	for _, c := range []struct {
+		in      []string
+		wantErr bool
+	}{
+		{in: nil},
+		{in: []string{""}},
+		{in: []string{"trim", " collapse", "title "}},
+		{in: []string{"trim", "upper"}, wantErr: true},
+		{in: []string{"Trim"}, wantErr: true},
+	} {
+		if err := validateNameNormalization(c.in); (err != nil) != c.wantErr {
+			t.Errorf("validateNameNormalization(%q) = %v, expected error %v", c.in, err, c.wantErr)
+		}
+	}
+}
+
+func TestTokenFromDataNormalizedName(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.UI.NameNormalization = []string{"trim", "collapse", "title"}
+
+	for _, c := range []struct {
+		in          string
+		wantName    string
+		wantRawName string
+	}{
+		{in: "  GITHUB   mail ", wantName: "Github Mail", wantRawName: "  GITHUB   mail "},
+		// Already normalized names do not carry a raw name
+		{in: "Github Mail", wantName: "Github Mail"},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"name":   c.in,
+			"secret": "JBSWY3DPEHPK3PXP",
+		})
+		if tok.Name != c.wantName || tok.RawName != c.wantRawName {
+			t.Errorf("%q: Expected name %q / raw name %q, got %q / %q", c.in, c.wantName, c.wantRawName, tok.Name, tok.RawName)
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 func getAuthenticationURL() string {
@@ -32,3 +35,71 @@ func getAccessToken(code string) (string, error) {
 	r := map[string]string{}
 	return r["access_token"], json.NewDecoder(resp.Body).Decode(&r)
 }
+
+// githubMemberOf checks whether the owner of the access token is an active
+// member of one of the organizations (org) or teams (org/team)
+func githubMemberOf(accessToken string, allowed []string) (bool, error) {
+	var login string
+
+	for _, a := range allowed {
+		parts := strings.SplitN(strings.TrimSpace(a), "/", 2)
+		if parts[0] == "" {
+			continue
+		}
+
+		p := "user/memberships/orgs/" + url.PathEscape(parts[0])
+		if len(parts) == 2 {
+			if login == "" {
+				var user struct {
+					Login string `json:"login"`
+				}
+				if found, err := githubAPI(accessToken, "user", &user); err != nil || !found {
+					return false, err
+				}
+				login = user.Login
+			}
+			p = strings.Join([]string{"orgs", url.PathEscape(parts[0]), "teams", url.PathEscape(parts[1]), "memberships", url.PathEscape(login)}, "/")
+		}
+
+		var membership struct {
+			State string `json:"state"`
+		}
+		found, err := githubAPI(accessToken, p, &membership)
+		if err != nil {
+			return false, err
+		}
+		if found && membership.State == "active" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// githubAPI fetches the path from the Github API using the access token.
+// Responses denying access (invalid token, not a member) are reported as
+// not found.
+func githubAPI(accessToken, p string, out interface{}) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(cfg.Github.APIURL, "/")+"/"+p, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "Unable to create request")
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Authorization", "token "+accessToken)
+
+	resp, err := (&http.Client{Timeout: cfg.Github.LoginTimeout}).Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "Unable to query Github")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.Errorf("Github responded with status %d", resp.StatusCode)
+	}
+
+	return true, errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "Unable to decode Github response")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+)
+
+// tokenPolicies looks up the policies (including identity policies)
+// attached to the token
+func tokenPolicies(tok string) ([]string, error) {
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create client")
+	}
+
+	client.SetToken(tok)
+	s, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to lookup token")
+	}
+
+	policies, err := s.TokenPolicies()
+	return policies, errors.Wrap(err, "Unable to read token policies")
+}
+
+// hasAnyPolicy checks whether one of the wanted policies is contained in
+// the policies of the token
+func hasAnyPolicy(policies, wanted []string) bool {
+	for _, w := range wanted {
+		for _, p := range policies {
+			if p == w {
+				return true
+			}
+		}
+	}
+
+	return false
+}
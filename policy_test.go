@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestHasAnyPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policies, wanted []string
+		want             bool
+	}{
+		{policies: []string{"default", "totp-read"}, wanted: []string{"totp-read"}, want: true},
+		{policies: []string{"default", "totp-read"}, wanted: []string{"admin", "totp-read"}, want: true},
+		{policies: []string{"default"}, wanted: []string{"totp-read"}},
+		// Policy names are case sensitive in Vault
+		{policies: []string{"TOTP-read"}, wanted: []string{"totp-read"}},
+		{policies: nil, wanted: []string{"totp-read"}},
+		{policies: []string{"default"}, wanted: nil},
+	} {
+		if got := hasAnyPolicy(c.policies, c.wanted); got != c.want {
+			t.Errorf("hasAnyPolicy(%v, %v) = %v, expected %v", c.policies, c.wanted, got, c.want)
+		}
+	}
+}
+
+func TestTokenPolicies(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Vault-Token") != "s.user" {
+			http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		res.Write([]byte(`{"data":{"policies":["default"],"identity_policies":["totp-read"]}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+
+	policies, err := tokenPolicies("s.user")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	sort.Strings(policies)
+	if want := []string{"default", "totp-read"}; !reflect.DeepEqual(policies, want) {
+		t.Errorf("Expected token and identity policies %v, got %v", want, policies)
+	}
+
+	if _, err := tokenPolicies("s.other"); err == nil {
+		t.Error("Expected an error for a rejected lookup")
+	}
+}
+
+func TestRequiredPolicies(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			res.Write([]byte(`{"data":{"policies":["default","totp-read"]}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name       string
+		required   string
+		wantStatus int
+	}{
+		{name: "no requirement", wantStatus: http.StatusOK},
+		{name: "holding a required policy", required: "admin,totp-read", wantStatus: http.StatusOK},
+		{name: "missing the required policies", required: "admin,totp-write", wantStatus: http.StatusForbidden},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, []string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+				"--auth-required-policies", c.required,
+			}, nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				r := httptest.NewRequest(http.MethodGet, "/codes.json", nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleCodesJSON(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Errorf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// servePprof exposes the profiling endpoints on their own listener which
+// must never be the public one
+func servePprof(addr string) {
+	log.WithField("listen", addr).Warn("Serving profiling endpoints, do not expose this listener")
+	log.Errorf("Profiling server exitted: %s", http.ListenAndServe(addr, newPprofMux()))
+}
+
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+// sameListenAddr checks whether listening on both addresses overlaps.
+// The addresses are compared resolved as a listener on all interfaces
+// (like ":3000") includes every other address using the same port.
+func sameListenAddr(a, b string) bool {
+	ta, errA := net.ResolveTCPAddr("tcp", a)
+	tb, errB := net.ResolveTCPAddr("tcp", b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+
+	if ta.Port != tb.Port {
+		return false
+	}
+
+	return ta.IP == nil || ta.IP.IsUnspecified() || tb.IP == nil || tb.IP.IsUnspecified() || ta.IP.Equal(tb.IP)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSameListenAddr(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{a: ":3000", b: ":3000", want: true},
+		{a: ":3000", b: "0.0.0.0:3000", want: true},
+		{a: "[::]:3000", b: "127.0.0.1:3000", want: true},
+		{a: "127.0.0.1:3000", b: ":3000", want: true},
+		{a: "127.0.0.1:3000", b: "127.0.0.1:3000", want: true},
+		{a: "127.0.0.1:6060", b: ":3000", want: false},
+		{a: "127.0.0.1:3000", b: "127.0.0.2:3000", want: false},
+		{a: "[::1]:3000", b: "127.0.0.1:3000", want: false},
+		{a: "not an address", b: ":3000", want: false},
+	} {
+		if got := sameListenAddr(c.a, c.b); got != c.want {
+			t.Errorf("sameListenAddr(%q, %q) = %v, expected %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestPprofEndpoints(t *testing.T) {
+	withArgs(t, nil, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+		if cfg.Admin.Pprof {
+			t.Fatal("Expected profiling to be disabled by default")
+		}
+
+		for _, c := range []struct {
+			name    string
+			handler http.Handler
+			want    bool
+		}{
+			{name: "public listener", handler: newRouter()},
+			{name: "profiling listener", handler: newPprofMux(), want: true},
+		} {
+			for path, marker := range map[string]string{
+				"/debug/pprof/":        "goroutine",
+				"/debug/pprof/cmdline": os.Args[0],
+			} {
+				res := httptest.NewRecorder()
+				c.handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+
+				served := res.Code == http.StatusOK && strings.Contains(res.Body.String(), marker)
+				if served != c.want {
+					t.Errorf("%s: Expected %s to be served %v, got status %d", c.name, path, c.want, res.Code)
+				}
+			}
+		}
+	})
+}
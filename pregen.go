@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pregeneratedScan is the result of a scan for the next period run in
+// the background shortly before the codes roll over
+type pregeneratedScan struct {
+	boundary  time.Time
+	generated time.Time
+	result    *scanResult
+}
+
+var (
+	pregenerated     = map[string]*pregeneratedScan{}
+	pregeneratedLock sync.Mutex
+)
+
+// schedulePregeneration starts a scan for the next period using the
+// token of the user shortly before the given boundary. Only one scan is
+// scheduled per user and boundary.
+func schedulePregeneration(tok string, boundary time.Time) {
+	if cfg.Vault.PregenerateNext <= 0 || cfg.Source != sourceVault || tok == "" {
+		return
+	}
+
+	key := hashSecret(tok)
+	// The wrap time contains the fraction of the second of the request
+	boundary = boundary.Truncate(time.Second)
+
+	pregeneratedLock.Lock()
+	defer pregeneratedLock.Unlock()
+
+	for k, p := range pregenerated {
+		if !time.Now().Before(p.boundary) {
+			delete(pregenerated, k)
+		}
+	}
+
+	if p, ok := pregenerated[key]; ok && p.boundary.Equal(boundary) {
+		return
+	}
+
+	entry := &pregeneratedScan{boundary: boundary}
+	pregenerated[key] = entry
+
+	time.AfterFunc(time.Until(boundary.Add(-cfg.Vault.PregenerateNext)), func() {
+		ctx := withRequestID(context.Background())
+
+		result, err := getSecretsFromVault(ctx, tok, true)
+		if err != nil {
+			logger(ctx).WithError(err).Warn("Unable to pre-generate codes for the next period")
+			return
+		}
+
+		pregeneratedLock.Lock()
+		defer pregeneratedLock.Unlock()
+
+		entry.generated, entry.result = time.Now(), result
+		logger(ctx).WithField("token", key).Debug("Pre-generated codes for the next period")
+	})
+}
+
+// pregeneratedSecrets returns a copy of the pre-generated scan for the
+// next period of the user if there is one for the upcoming boundary
+func pregeneratedSecrets(ctx context.Context, tok string, next bool) *scanResult {
+	if cfg.Vault.PregenerateNext <= 0 || !next || !codesWanted(ctx) {
+		return nil
+	}
+
+	pregeneratedLock.Lock()
+	defer pregeneratedLock.Unlock()
+
+	p, ok := pregenerated[hashSecret(tok)]
+	if !ok || p.result == nil || !time.Now().Before(p.boundary) {
+		return nil
+	}
+
+	// The codes stay the same until the boundary, only the time they
+	// remain valid for shrinks
+	elapsed := int(time.Since(p.generated) / time.Second)
+
+	result := p.result.copy()
+	for _, t := range result.Tokens {
+		if t.RemainingSeconds > 0 {
+			t.RemainingSeconds -= elapsed
+		}
+	}
+
+	logger(ctx).WithField("token", hashSecret(tok)).Debug("Serving pre-generated codes for the next period")
+	return result
+}
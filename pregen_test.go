@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestPregeneration(t *testing.T) {
+	var scans int32
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") == "true" {
+			atomic.AddInt32(&scans, 1)
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+			return
+		}
+		res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() {
+		cfg = oldCfg
+
+		pregeneratedLock.Lock()
+		pregenerated = map[string]*pregeneratedScan{}
+		pregeneratedLock.Unlock()
+	}()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.PregenerateNext = time.Minute
+
+	// The whole test needs to happen before the boundary
+	boundary := time.Unix((time.Now().Unix()/30+1)*30, 0)
+	if time.Until(boundary) < 3*time.Second {
+		time.Sleep(time.Until(boundary))
+		boundary = boundary.Add(30 * time.Second)
+	}
+
+	// Starts the scan right away as the boundary is closer than the
+	// configured time, scheduling again must not start another scan
+	schedulePregeneration("s.user", boundary.Add(300*time.Millisecond))
+	schedulePregeneration("s.user", boundary)
+
+	ctx := context.Background()
+	var res *scanResult
+	for deadline := time.Now().Add(2 * time.Second); res == nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		res = pregeneratedSecrets(ctx, "s.user", true)
+	}
+	if res == nil {
+		t.Fatal("Expected pre-generated codes")
+	}
+	if n := atomic.LoadInt32(&scans); n != 1 {
+		t.Errorf("Expected one scan for the boundary, got %d", n)
+	}
+
+	want, err := totp.GenerateCodeCustom("JBSWY3DPEHPK3PXP", boundary, totp.ValidateOpts{
+		Period: 30, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		t.Fatalf("Unable to generate code: %s", err)
+	}
+	if len(res.Tokens) != 1 || res.Tokens[0].Code != want {
+		t.Fatalf("Expected the code %s valid at the boundary, got %+v", want, res.Tokens)
+	}
+	// Valid for the remaining time of the current and the whole next period
+	if remaining, max := res.Tokens[0].RemainingSeconds, int(time.Until(boundary)/time.Second)+32; remaining <= 30 || remaining > max {
+		t.Errorf("Expected between 30 and %d remaining seconds, got %d", max, remaining)
+	}
+
+	// Served copies must not change the pre-generated scan
+	res.Tokens[0].Code = "000000"
+	if again := pregeneratedSecrets(ctx, "s.user", true); again == nil || again.Tokens[0].Code != want {
+		t.Errorf("Expected the pre-generated code to stay %s, got %+v", want, again)
+	}
+
+	for _, c := range []struct {
+		name string
+		ctx  context.Context
+		tok  string
+		next bool
+	}{
+		{name: "current codes", ctx: ctx, tok: "s.user"},
+		{name: "other user", ctx: ctx, tok: "s.other", next: true},
+		{name: "without codes", ctx: withoutCodes(ctx), tok: "s.user", next: true},
+	} {
+		if res := pregeneratedSecrets(c.ctx, c.tok, c.next); res != nil {
+			t.Errorf("%s: Expected no pre-generated codes, got %+v", c.name, res)
+		}
+	}
+
+	// Scans for past boundaries are not served anymore
+	pregeneratedLock.Lock()
+	pregenerated[hashSecret("s.user")].boundary = time.Now().Add(-time.Second)
+	pregeneratedLock.Unlock()
+	if res := pregeneratedSecrets(ctx, "s.user", true); res != nil {
+		t.Errorf("Expected no codes after the boundary, got %+v", res)
+	}
+
+	cfg.Vault.PregenerateNext = 0
+	schedulePregeneration("s.disabled", boundary)
+	pregeneratedLock.Lock()
+	_, scheduled := pregenerated[hashSecret("s.disabled")]
+	pregeneratedLock.Unlock()
+	if scheduled {
+		t.Error("Expected no pre-generation when disabled")
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp/totp"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// maxPreviewFieldsSize limits the size of the fields posted to preview
+const maxPreviewFieldsSize = 64 << 10
+
+type previewStrip struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Period   int    `json:"period,omitempty"`
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+	Next     string `json:"next"`
+}
+
+// PreviewStrip generates the codes of the previous, current and next
+// period (or counter for HOTP tokens) at the given point of time
+func (t *token) PreviewStrip(now time.Time) (*previewStrip, error) {
+	if t.Secret == "" {
+		return nil, errors.New("Token has no secret to generate codes from")
+	}
+
+	var (
+		codes  = make([]string, 3)
+		err    error
+		period int
+	)
+
+	switch t.Type {
+	case tokenTypeHOTP:
+		for i := range codes {
+			if i == 0 && t.Counter == 0 {
+				// There is no code before the first counter
+				continue
+			}
+			if i == 2 && t.NoNext {
+				// The upcoming code must not be exposed
+				continue
+			}
+
+			if codes[i], err = t.hotpCode(t.Counter + uint64(i) - 1); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		var opts totp.ValidateOpts
+		if opts, err = t.totpOpts(); err != nil {
+			return nil, err
+		}
+
+		period = int(opts.Period)
+		for i := range codes {
+			if i == 2 && t.NoNext {
+				// The upcoming code must not be exposed
+				continue
+			}
+
+			if codes[i], err = t.codeAt(now.Add(time.Duration(i-1)*time.Duration(period)*time.Second), opts); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cfg.UI.MaskCodes {
+		for i := range codes {
+			codes[i] = maskCode(codes[i])
+		}
+	}
+
+	return &previewStrip{
+		Name:     t.Name,
+		Type:     t.Type,
+		Period:   period,
+		Previous: codes[0],
+		Current:  codes[1],
+		Next:     codes[2],
+	}, nil
+}
+
+func handlePreview(res http.ResponseWriter, r *http.Request) {
+	_, tok, ok := getAdminVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		http.Error(res, `{"error":"Parameter name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx := withRequestID(r.Context())
+	res.Header().Set("X-Request-Id", requestIDFromContext(ctx))
+
+	secrets, err := getSecrets(ctx, tok, false)
+	if err != nil {
+		logger(ctx).Errorf("Unable to fetch codes: %s", err)
+		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var t *token
+	for _, c := range secrets.Tokens {
+		if c.Name == name {
+			t = c
+			break
+		}
+	}
+
+	if t == nil {
+		http.Error(res, `{"error":"No token with that name found"}`, http.StatusNotFound)
+		return
+	}
+
+	if p := r.FormValue("period"); p != "" {
+		// Override the period for this preview only, the token in the
+		// scan result must not be changed
+		period, err := strconv.Atoi(p)
+		if err != nil || period < 1 || t.Type == tokenTypeHOTP {
+			http.Error(res, `{"error":"Parameter period must be a positive number of seconds for TOTP tokens"}`, http.StatusBadRequest)
+			return
+		}
+
+		override := *t
+		override.Period = period
+		t = &override
+	}
+
+	strip, err := t.PreviewStrip(time.Now())
+	if err != nil {
+		logger(ctx).WithError(err).WithField("name", t.Name).Error("Unable to generate codes")
+		http.Error(res, `{"error":"Unable to generate codes for token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(res).Encode(strip)
+}
+
+// handlePreviewFields parses the posted raw fields of a secret the same
+// way secrets read from Vault are parsed and returns the resulting token
+// including its code. Nothing is written to Vault.
+func handlePreviewFields(res http.ResponseWriter, r *http.Request) {
+	if _, _, ok := getAdminVaultToken(res, r); !ok {
+		return
+	}
+
+	ctx := withRequestID(r.Context())
+	res.Header().Set("X-Request-Id", requestIDFromContext(ctx))
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(res, r.Body, maxPreviewFieldsSize))
+	if err != nil {
+		http.Error(res, `{"error":"Unable to read fields"}`, http.StatusBadRequest)
+		return
+	}
+
+	// JSON is valid YAML, parsing it the same way as the file source
+	// yields the shape of Vault secret data
+	var raw interface{}
+	if err = yaml.Unmarshal(body, &raw); err != nil {
+		http.Error(res, `{"error":"Fields must be a JSON object"}`, http.StatusBadRequest)
+		return
+	}
+
+	data, ok := normalizeFileData(raw).(map[string]interface{})
+	if !ok {
+		http.Error(res, `{"error":"Fields must be a JSON object"}`, http.StatusBadRequest)
+		return
+	}
+
+	t := tokenFromData(ctx, "preview", data)
+
+	if err = t.sanitizeFields(); err == nil && !t.hasCodeSource() {
+		err = errors.New("Fields contain no secret to generate a code from")
+	}
+	if err == nil {
+		err = t.GenerateCode(false)
+	}
+	if err != nil {
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(res).Encode(struct {
+			Error string `json:"error"`
+		}{err.Error()})
+		return
+	}
+
+	t.Fingerprint = t.ConfigFingerprint()
+	if cfg.UI.MaskCodes {
+		t.MaskCodes()
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(res).Encode(struct {
+		Token *token `json:"token"`
+	}{t})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestPreviewStrip(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 15, 0, time.UTC)
+	totpCode := func(at time.Time, period uint) string {
+		code, err := totp.GenerateCodeCustom("JBSWY3DPEHPK3PXP", at, totp.ValidateOpts{Period: period, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+		if err != nil {
+			t.Fatalf("Unable to generate expected code: %s", err)
+		}
+		return code
+	}
+
+	for _, c := range []struct {
+		name    string
+		tok     token
+		want    previewStrip
+		wantErr bool
+	}{
+		{
+			name: "totp",
+			tok:  token{Name: "Mail", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP},
+			want: previewStrip{Name: "Mail", Type: tokenTypeTOTP, Period: 30,
+				Previous: totpCode(now.Add(-30*time.Second), 30), Current: totpCode(now, 30), Next: totpCode(now.Add(30*time.Second), 30)},
+		},
+		{
+			name: "totp with own period",
+			tok:  token{Name: "Mail", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 60},
+			want: previewStrip{Name: "Mail", Type: tokenTypeTOTP, Period: 60,
+				Previous: totpCode(now.Add(-60*time.Second), 60), Current: totpCode(now, 60), Next: totpCode(now.Add(60*time.Second), 60)},
+		},
+		{
+			name: "totp without next",
+			tok:  token{Name: "Mail", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, NoNext: true},
+			want: previewStrip{Name: "Mail", Type: tokenTypeTOTP, Period: 30,
+				Previous: totpCode(now.Add(-30*time.Second), 30), Current: totpCode(now, 30)},
+		},
+		{
+			// RFC 4226 appendix D values for the counters 0 - 2
+			name: "hotp",
+			tok:  token{Name: "VPN", Secret: rfc4226Secret, Type: tokenTypeHOTP, Counter: 1},
+			want: previewStrip{Name: "VPN", Type: tokenTypeHOTP, Previous: "755224", Current: "287082", Next: "359152"},
+		},
+		{
+			name: "hotp at the first counter",
+			tok:  token{Name: "VPN", Secret: rfc4226Secret, Type: tokenTypeHOTP},
+			want: previewStrip{Name: "VPN", Type: tokenTypeHOTP, Current: "755224", Next: "287082"},
+		},
+		{name: "without secret", tok: token{Name: "Stored", Code: "123456", Type: tokenTypeTOTP}, wantErr: true},
+	} {
+		strip, err := c.tok.PreviewStrip(now)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: Unexpected error %v", c.name, err)
+			continue
+		}
+		if !c.wantErr && *strip != c.want {
+			t.Errorf("%s: Expected %+v, got %+v", c.name, c.want, *strip)
+		}
+	}
+}
+
+func TestHandlePreview(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			res.Write([]byte(`{"data":{"policies":["admin"]}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","vpn"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/vpn":
+			res.Write([]byte(`{"data":{"name":"VPN","secret":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","type":"hotp","counter":"1"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name       string
+		policy     string
+		url        string
+		wantStatus int
+		wantPeriod int
+	}{
+		{name: "totp", policy: "admin", url: "/admin/preview?name=Mail", wantStatus: http.StatusOK, wantPeriod: 30},
+		{name: "period override", policy: "admin", url: "/admin/preview?name=Mail&period=60", wantStatus: http.StatusOK, wantPeriod: 60},
+		{name: "hotp", policy: "admin", url: "/admin/preview?name=VPN", wantStatus: http.StatusOK},
+		{name: "period override for hotp", policy: "admin", url: "/admin/preview?name=VPN&period=60", wantStatus: http.StatusBadRequest},
+		{name: "zero period", policy: "admin", url: "/admin/preview?name=Mail&period=0", wantStatus: http.StatusBadRequest},
+		{name: "negative period", policy: "admin", url: "/admin/preview?name=Mail&period=-30", wantStatus: http.StatusBadRequest},
+		{name: "period with unit", policy: "admin", url: "/admin/preview?name=Mail&period=60s", wantStatus: http.StatusBadRequest},
+		{name: "missing name", policy: "admin", url: "/admin/preview", wantStatus: http.StatusBadRequest},
+		{name: "unknown name", policy: "admin", url: "/admin/preview?name=Chat", wantStatus: http.StatusNotFound},
+		{name: "without admin policy", policy: "root", url: "/admin/preview?name=Mail", wantStatus: http.StatusForbidden},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, []string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+				"--admin-policy", c.policy,
+			}, nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				r := httptest.NewRequest(http.MethodGet, c.url, nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handlePreview(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+				if c.wantStatus != http.StatusOK {
+					return
+				}
+
+				var strip previewStrip
+				if err := json.NewDecoder(res.Body).Decode(&strip); err != nil {
+					t.Fatalf("Unable to decode strip: %s", err)
+				}
+				if strip.Period != c.wantPeriod || strip.Current == "" || strip.Previous == "" || strip.Next == "" {
+					t.Errorf("Expected a complete strip with period %d, got %+v", c.wantPeriod, strip)
+				}
+				if c.wantPeriod == 0 {
+					return
+				}
+
+				// The codes must be generated using the overridden period
+				ok, err := totp.ValidateCustom(strip.Current, "JBSWY3DPEHPK3PXP", time.Now(), totp.ValidateOpts{Period: uint(c.wantPeriod), Skew: 1, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+				if err != nil || !ok {
+					t.Errorf("Expected the current code for a period of %d, got %q (%v)", c.wantPeriod, strip.Current, err)
+				}
+			})
+		})
+	}
+}
+
+func TestHandlePreviewFields(t *testing.T) {
+	var writes []string
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			res.Write([]byte(`{"data":{"policies":["admin"]}}`))
+		default:
+			if r.Method != http.MethodGet {
+				writes = append(writes, r.Method+" "+r.URL.Path)
+			}
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name       string
+		policy     string
+		body       string
+		wantStatus int
+		wantError  string
+		wantToken  func(map[string]interface{}) bool
+	}{
+		{
+			name:       "totp",
+			policy:     "admin",
+			body:       `{"name":"Mail","secret":"JBSWY3DPEHPK3PXP","digits":"8","period":"60","algorithm":"sha256"}`,
+			wantStatus: http.StatusOK,
+			wantToken: func(tok map[string]interface{}) bool {
+				code, _ := tok["code"].(string)
+				return tok["name"] == "Mail" && len(code) == 8 && tok["period"] == float64(60) && tok["digits"] == float64(8)
+			},
+		},
+		{
+			name:       "numbers instead of strings",
+			policy:     "admin",
+			body:       `{"name":"Mail","secret":"JBSWY3DPEHPK3PXP","digits":6,"period":30}`,
+			wantStatus: http.StatusOK,
+			wantToken: func(tok map[string]interface{}) bool {
+				code, _ := tok["code"].(string)
+				return len(code) == 6
+			},
+		},
+		{
+			name:       "hotp",
+			policy:     "admin",
+			body:       `{"name":"VPN","secret":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","type":"hotp","counter":"1"}`,
+			wantStatus: http.StatusOK,
+			// RFC 4226 appendix D, counter 1
+			wantToken: func(tok map[string]interface{}) bool { return tok["code"] == "287082" },
+		},
+		{name: "invalid secret", policy: "admin", body: `{"secret":"not base32!"}`, wantStatus: http.StatusUnprocessableEntity, wantError: "Decoding of secret"},
+		{name: "unknown algorithm", policy: "admin", body: `{"secret":"JBSWY3DPEHPK3PXP","algorithm":"whirlpool"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "no secret", policy: "admin", body: `{"name":"Mail"}`, wantStatus: http.StatusUnprocessableEntity, wantError: "no secret"},
+		{name: "no object", policy: "admin", body: `["JBSWY3DPEHPK3PXP"]`, wantStatus: http.StatusBadRequest},
+		{name: "malformed", policy: "admin", body: `{"secret":`, wantStatus: http.StatusBadRequest},
+		{name: "too large", policy: "admin", body: `{"note":"` + strings.Repeat("a", maxPreviewFieldsSize) + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "without admin policy", policy: "root", body: `{"secret":"JBSWY3DPEHPK3PXP"}`, wantStatus: http.StatusForbidden},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, []string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+				"--admin-policy", c.policy,
+			}, nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				writes = nil
+				r := httptest.NewRequest(http.MethodPost, "/preview/fields", strings.NewReader(c.body))
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handlePreviewFields(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+				if len(writes) > 0 {
+					t.Errorf("Expected nothing to be written to Vault, got %v", writes)
+				}
+				if c.wantError != "" && !strings.Contains(res.Body.String(), c.wantError) {
+					t.Errorf("Expected error %q, got %s", c.wantError, res.Body.String())
+				}
+				if c.wantToken == nil {
+					return
+				}
+
+				var result struct {
+					Token map[string]interface{} `json:"token"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+				if !c.wantToken(result.Token) {
+					t.Errorf("Unexpected token %+v", result.Token)
+				}
+				if _, ok := result.Token["secret"]; ok {
+					t.Errorf("Expected the secret not to be returned, got %+v", result.Token)
+				}
+			})
+		})
+	}
+}
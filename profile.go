@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+)
+
+// otpProfile contains the defaults applied to tokens not specifying
+// their own digits, period or algorithm
+type otpProfile struct {
+	Digits    int
+	Period    int
+	Algorithm otp.Algorithm
+}
+
+var otpProfiles = map[string]otpProfile{
+	"authy":    {Digits: 7, Period: 10, Algorithm: otp.AlgorithmSHA1},
+	"default":  {Digits: 6, Period: 30, Algorithm: otp.AlgorithmSHA1},
+	"sha1-8":   {Digits: 8, Period: 30, Algorithm: otp.AlgorithmSHA1},
+	"sha256-6": {Digits: 6, Period: 30, Algorithm: otp.AlgorithmSHA256},
+	"sha256-8": {Digits: 8, Period: 30, Algorithm: otp.AlgorithmSHA256},
+	"sha512-6": {Digits: 6, Period: 30, Algorithm: otp.AlgorithmSHA512},
+	"sha512-8": {Digits: 8, Period: 30, Algorithm: otp.AlgorithmSHA512},
+}
+
+// activeProfile returns the profile configured, validity of the
+// profile name is checked when loading the config
+func activeProfile() otpProfile {
+	return otpProfiles[cfg.OTP.Profile]
+}
+
+// parseAlgorithm reads the algorithm by its name or by the number some
+// tools use to encode it (0 = SHA1, 1 = SHA256, 2 = SHA512)
+func parseAlgorithm(in string) (otp.Algorithm, error) {
+	switch strings.ToUpper(strings.Replace(strings.TrimSpace(in), "-", "", -1)) {
+	case "SHA1", "0":
+		return otp.AlgorithmSHA1, nil
+	case "SHA256", "1":
+		return otp.AlgorithmSHA256, nil
+	case "SHA512", "2":
+		return otp.AlgorithmSHA512, nil
+	case "MD5":
+		return otp.AlgorithmMD5, nil
+	default:
+		return 0, errors.Errorf("Unsupported algorithm %q", in)
+	}
+}
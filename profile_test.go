@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pquerna/otp"
+)
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, c := range []struct {
+		in      string
+		want    otp.Algorithm
+		wantErr bool
+	}{
+		{in: "SHA1", want: otp.AlgorithmSHA1},
+		{in: "sha-256", want: otp.AlgorithmSHA256},
+		{in: " sha512 ", want: otp.AlgorithmSHA512},
+		{in: "md5", want: otp.AlgorithmMD5},
+		{in: "0", want: otp.AlgorithmSHA1},
+		{in: "1", want: otp.AlgorithmSHA256},
+		{in: "2", want: otp.AlgorithmSHA512},
+		{in: "3", wantErr: true},
+		{in: "sha3", wantErr: true},
+		{in: "", wantErr: true},
+	} {
+		got, err := parseAlgorithm(c.in)
+		if (err != nil) != c.wantErr || (!c.wantErr && got != c.want) {
+			t.Errorf("parseAlgorithm(%q) = %v, %v, expected %v (error %v)", c.in, got, err, c.want, c.wantErr)
+		}
+	}
+}
+
+func TestTOTPOptsProfile(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		profile   string
+		tok       token
+		digits    otp.Digits
+		period    uint
+		algorithm otp.Algorithm
+		wantErr   bool
+	}{
+		{profile: "default", digits: 6, period: 30, algorithm: otp.AlgorithmSHA1},
+		{profile: "sha512-8", digits: 8, period: 30, algorithm: otp.AlgorithmSHA512},
+		{profile: "authy", digits: 7, period: 10, algorithm: otp.AlgorithmSHA1},
+		// Fields of the token take precedence over the profile
+		{profile: "sha512-8", tok: token{Digits: 6, Period: 60, Algorithm: "sha1"}, digits: 6, period: 60, algorithm: otp.AlgorithmSHA1},
+		{profile: "default", tok: token{Algorithm: "sha256"}, digits: 6, period: 30, algorithm: otp.AlgorithmSHA256},
+		{profile: "default", tok: token{Algorithm: "whirlpool"}, wantErr: true},
+	} {
+		cfg.OTP.Profile = c.profile
+
+		opts, err := c.tok.totpOpts()
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s %+v: Unexpected error %v", c.profile, c.tok, err)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+		if opts.Digits != c.digits || opts.Period != c.period || opts.Algorithm != c.algorithm {
+			t.Errorf("%s %+v: Expected %d digits / %ds / %v, got %d / %ds / %v",
+				c.profile, c.tok, c.digits, c.period, c.algorithm, opts.Digits, opts.Period, opts.Algorithm)
+		}
+	}
+}
+
+func TestHOTPCodeProfile(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.OTP.Profile = "sha512-8"
+
+	tok := &token{Secret: rfc4226Secret, Type: tokenTypeHOTP}
+	code, err := tok.hotpCode(0)
+	if err != nil {
+		t.Fatalf("Unable to generate code: %s", err)
+	}
+	if len(code) != 8 {
+		t.Errorf("Expected 8 digit code of the profile, got %q", code)
+	}
+
+	// The algorithm of the token wins: RFC 4226 test value for SHA1
+	tok.Algorithm, tok.Digits = "sha1", 6
+	if code, err = tok.hotpCode(0); err != nil || code != "755224" {
+		t.Errorf("Expected code 755224, got %q (%v)", code, err)
+	}
+}
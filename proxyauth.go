@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	authModeGithub = "github"
+	authModeProxy  = "proxy"
+)
+
+var trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses the list of CIDRs proxies are allowed to
+// send identity headers from
+func parseTrustedProxies(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid trusted proxy CIDR %q", c)
+		}
+		nets = append(nets, n)
+	}
+
+	if len(nets) == 0 {
+		return nil, errors.New("At least one trusted proxy CIDR is required in proxy auth mode")
+	}
+
+	return nets, nil
+}
+
+// isTrustedProxy checks whether the request was directly sent by one of
+// the proxies allowed to assert the identity of the user
+func isTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getProxyVaultToken is the proxy auth mode counterpart of getVaultToken:
+// the user is taken from the identity header and a Vault token is
+// created for the entity alias of that user.
+func getProxyVaultToken(res http.ResponseWriter, r *http.Request, sess *sessions.Session) (*sessions.Session, string, bool) {
+	if !isTrustedProxy(r) {
+		log.WithField("remote_addr", r.RemoteAddr).Warn("Rejected request from untrusted proxy")
+		http.Error(res, `{"error":"Request not sent by trusted proxy"}`, http.StatusForbidden)
+		return nil, "", false
+	}
+
+	user := r.Header.Get(cfg.Auth.ProxyHeader)
+	if user == "" {
+		http.Error(res, `{"error":"Not logged in"}`, http.StatusUnauthorized)
+		return nil, "", false
+	}
+
+	var tok string
+	if u, _ := sess.Values["proxy_user"].(string); u == user {
+		// Only reuse the token if it was issued for the same user
+		tok, _ = sess.Values["vault_token"].(string)
+	}
+
+	tok, err := useOrCreateProxyToken(tok, user)
+	if err != nil && len(fallbackTokens) > 0 && isVaultUnavailable(err) {
+		log.WithError(err).WithField("user", user).Warn("Vault is unavailable, continuing with fallback tokens")
+		sess.Values["proxy_user"] = user
+		markVaultUnavailable(r, user)
+		return sess, "", true
+	}
+	if err != nil {
+		log.WithField("user", user).Errorf("Unable to authorize against vault: %s", err)
+		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+		return nil, "", false
+	}
+	log.WithFields(log.Fields{"token": hashSecret(tok), "user": user}).Debugf("Checked / created proxy token")
+
+	sess.Values["proxy_user"] = user
+	return sess, tok, true
+}
+
+// useOrCreateProxyToken returns the given token if it is still valid or
+// creates a new one using the token role with the user as entity alias
+func useOrCreateProxyToken(tok, user string) (string, error) {
+	v, err, _ := loginGroup.Do("proxy:"+user, func() (interface{}, error) {
+		return loginProxyUser(tok, user)
+	})
+
+	newTok, _ := v.(string)
+	return newTok, err
+}
+
+func loginProxyUser(tok, user string) (string, error) {
+	var newTok string
+
+	err := withVaultFailover(func(client *api.Client) error {
+		if tok != "" && tokenIsValid(client, tok) {
+			newTok = tok
+			return nil
+		}
+
+		client.SetToken(cfg.Auth.ProxyVaultToken)
+		s, err := client.Auth().Token().CreateWithRole(&api.TokenCreateRequest{
+			EntityAlias: user,
+		}, cfg.Auth.ProxyTokenRole)
+		if err != nil {
+			return err
+		}
+
+		if s == nil || s.Auth == nil {
+			return errors.New("Token creation returned no token")
+		}
+
+		newTok = s.Auth.ClientToken
+		return nil
+	})
+
+	return newTok, errors.Wrap(err, "Unable to create token")
+}
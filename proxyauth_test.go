@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestProxyAuthTrustedProxies(t *testing.T) {
+	var aliases []string
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			var req struct {
+				EntityAlias string `json:"entity_alias"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			aliases = append(aliases, req.EntityAlias)
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-prefix", "totp",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+		"--auth-trusted-proxies", "10.0.0.0/8, fd00::/8",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			name       string
+			remoteAddr string
+			user       string
+			wantStatus int
+		}{
+			{name: "trusted IPv4", remoteAddr: "10.1.2.3:42424", user: "jdoe", wantStatus: http.StatusOK},
+			{name: "trusted IPv6", remoteAddr: "[fd00::1]:42424", user: "jdoe", wantStatus: http.StatusOK},
+			{name: "trusted without user", remoteAddr: "10.1.2.3:42424", wantStatus: http.StatusUnauthorized},
+			{name: "untrusted IPv4", remoteAddr: "192.168.1.1:42424", user: "admin", wantStatus: http.StatusForbidden},
+			// The default loopback CIDRs are replaced by the configured ones
+			{name: "untrusted loopback", remoteAddr: "127.0.0.1:42424", user: "admin", wantStatus: http.StatusForbidden},
+			{name: "untrusted IPv6", remoteAddr: "[::1]:42424", user: "admin", wantStatus: http.StatusForbidden},
+			{name: "invalid address", remoteAddr: "proxy", user: "admin", wantStatus: http.StatusForbidden},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				aliases = nil
+
+				r := httptest.NewRequest(http.MethodGet, "/codes.json", nil)
+				r.RemoteAddr = c.remoteAddr
+				if c.user != "" {
+					r.Header.Set(cfg.Auth.ProxyHeader, c.user)
+				}
+				res := httptest.NewRecorder()
+
+				handleCodesJSON(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+
+				// Only the identity asserted by a trusted proxy gets a token
+				wantAliases := 0
+				if c.wantStatus == http.StatusOK {
+					wantAliases = 1
+				}
+				if len(aliases) != wantAliases || (wantAliases == 1 && aliases[0] != c.user) {
+					t.Errorf("Expected %d token(s) for %q, got %v", wantAliases, c.user, aliases)
+				}
+			})
+		}
+	})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	for _, c := range []struct {
+		in      []string
+		want    int
+		wantErr bool
+	}{
+		{in: []string{"127.0.0.1/32", "::1/128"}, want: 2},
+		{in: []string{" 10.0.0.0/8 ", ""}, want: 1},
+		{in: []string{"10.0.0.1"}, wantErr: true},
+		{in: []string{""}, wantErr: true},
+		{in: nil, wantErr: true},
+	} {
+		nets, err := parseTrustedProxies(c.in)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%q: parseTrustedProxies() error = %v, expected error %v", c.in, err, c.wantErr)
+			continue
+		}
+		if len(nets) != c.want {
+			t.Errorf("%q: Expected %d networks, got %v", c.in, c.want, nets)
+		}
+	}
+}
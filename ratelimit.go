@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultRetryAfter is used for rate limited responses without a valid
+// Retry-After header
+const defaultRetryAfter = time.Second
+
+// rateLimitTransport retries requests rejected by the rate limit quotas
+// of Vault (429) after the time given in their Retry-After header as
+// long as the wait fits into the deadline of the scan
+type rateLimitTransport struct {
+	next http.RoundTripper
+}
+
+func (rl *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The requests of a scan carry its context, the retries end with it
+	scope := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := rl.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= cfg.Vault.RateLimitRetries || !canReplay(req) {
+			return resp, nil
+		}
+
+		wait := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if deadline, ok := scope.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			// Waiting would exceed the deadline, the request fails anyway
+			return resp, nil
+		}
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		logger(scope).WithFields(log.Fields{"path": req.URL.Path, "attempt": attempt + 1, "wait": wait}).Warn("Rate limited by Vault, retrying")
+
+		select {
+		case <-scope.Done():
+			return nil, scope.Err()
+		case <-time.After(wait):
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// parseRetryAfter reads the Retry-After header given either in seconds
+// or as HTTP date
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	return defaultRetryAfter
+}
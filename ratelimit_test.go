@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		in   string
+		want time.Duration
+	}{
+		{in: "2", want: 2 * time.Second},
+		{in: "0", want: 0},
+		{in: now.Add(5 * time.Second).Format(http.TimeFormat), want: 5 * time.Second},
+		{in: now.Add(-5 * time.Second).Format(http.TimeFormat), want: 0},
+		{in: "", want: defaultRetryAfter},
+		{in: "-1", want: defaultRetryAfter},
+		{in: "soon", want: defaultRetryAfter},
+	} {
+		if got := parseRetryAfter(c.in, now); got != c.want {
+			t.Errorf("parseRetryAfter(%q) = %s, expected %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		requests   int
+		limitFor   int // Number of requests rejected by the quota
+		retryAfter string
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests++
+		res.Header().Set("Content-Type", "application/json")
+		if requests <= limitFor {
+			res.Header().Set("Retry-After", retryAfter)
+			http.Error(res, `{"errors":["request path \"totp/mail\": rate limit quota exceeded"]}`, http.StatusTooManyRequests)
+			return
+		}
+		res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.RateLimitRetries = 2
+
+	for _, c := range []struct {
+		name         string
+		limitFor     int
+		retryAfter   string
+		deadline     time.Duration
+		wantRequests int
+		wantWait     time.Duration
+		wantErr      error
+	}{
+		{name: "not limited", wantRequests: 1},
+		{name: "429 then success", limitFor: 1, retryAfter: "0", wantRequests: 2},
+		{name: "honors Retry-After", limitFor: 1, retryAfter: "1", wantRequests: 2, wantWait: time.Second},
+		{name: "limited after all retries", limitFor: 3, retryAfter: "0", wantRequests: 3, wantErr: errRateLimited},
+		// Waiting would exceed the deadline of the scan, no retry
+		{name: "wait exceeding the deadline", limitFor: 1, retryAfter: "5", deadline: time.Second, wantRequests: 1, wantErr: errRateLimited},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			lock.Lock()
+			requests, limitFor, retryAfter = 0, c.limitFor, c.retryAfter
+			lock.Unlock()
+
+			client, err := newVaultClient()
+			if err != nil {
+				t.Fatalf("Unable to create client: %s", err)
+			}
+
+			ctx := context.Background()
+			if c.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.deadline)
+				defer cancel()
+			}
+
+			start := time.Now()
+			_, err = logicalRequest(ctx, client, "totp/mail", false)
+			elapsed := time.Since(start)
+
+			if errors.Cause(err) != c.wantErr {
+				t.Errorf("Expected error %v, got %v", c.wantErr, err)
+			}
+			lock.Lock()
+			if requests != c.wantRequests {
+				t.Errorf("Expected %d requests, got %d", c.wantRequests, requests)
+			}
+			lock.Unlock()
+			if elapsed < c.wantWait || elapsed > c.wantWait+500*time.Millisecond {
+				t.Errorf("Expected to wait %s, took %s", c.wantWait, elapsed)
+			}
+		})
+	}
+}
+
+func TestScanVaultRateLimited(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		mailHits int
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","limited"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			lock.Lock()
+			mailHits++
+			first := mailHits == 1
+			lock.Unlock()
+			if first {
+				res.Header().Set("Retry-After", "0")
+				http.Error(res, `{"errors":["rate limit quota exceeded"]}`, http.StatusTooManyRequests)
+				return
+			}
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			res.Header().Set("Retry-After", "0")
+			http.Error(res, `{"errors":["rate limit quota exceeded"]}`, http.StatusTooManyRequests)
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.RateLimitRetries = 1
+
+	res, err := scanVault(context.Background(), "s.user", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(res.Tokens) != 1 || res.Tokens[0].Name != "Mail" {
+		t.Errorf("Expected the retried Mail token, got %+v", res.Tokens)
+	}
+
+	// Keys still limited after the retries are failures, not silently
+	// dropped
+	if len(res.Failures) != 1 || res.Failures[0].Path != "totp/limited" {
+		t.Errorf("Expected a failure for the limited key, got %+v", res.Failures)
+	}
+}
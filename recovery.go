@@ -0,0 +1,45 @@
+package main
+
+import "strings"
+
+// tokenTypeRecovery marks entries storing static recovery codes instead
+// of a secret to generate codes from
+const tokenTypeRecovery = "recovery"
+
+// parseRecoveryCodes reads the recovery codes from a list or a string
+// separated by newlines, commas or spaces
+func parseRecoveryCodes(v interface{}) []string {
+	var raw []string
+	switch tv := v.(type) {
+	case string:
+		raw = strings.FieldsFunc(tv, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' })
+	case []interface{}:
+		for _, e := range tv {
+			if es, ok := e.(string); ok {
+				raw = append(raw, es)
+			}
+		}
+	}
+
+	var codes []string
+	for _, c := range raw {
+		if c = strings.TrimSpace(c); c != "" {
+			codes = append(codes, c)
+		}
+	}
+
+	return codes
+}
+
+// WithoutRecoveryCodes returns the tokens not being recovery codes which
+// are only displayed to admins
+func (t tokenList) WithoutRecoveryCodes() tokenList {
+	out := tokenList{}
+	for _, tok := range t {
+		if tok.Type != tokenTypeRecovery {
+			out = append(out, tok)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const (
+	ctxKeyRequestID contextKey = iota
+	ctxKeyWithoutCodes
+	ctxKeyLogger
+	ctxKeyVaultUnavailable
+	ctxKeyRawFields
+)
+
+// withRequestID attaches a new request ID to the context unless the
+// context already carries one
+func withRequestID(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(ctxKeyRequestID).(string); ok {
+		return ctx
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return context.WithValue(ctx, ctxKeyRequestID, fmt.Sprintf("%x", b))
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyRequestID).(string)
+	return id
+}
+
+// withDebugLogging attaches a logger emitting debug logs to the context
+// so a single request can be diagnosed without raising the log level of
+// all other requests
+func withDebugLogging(ctx context.Context) context.Context {
+	std := log.StandardLogger()
+
+	l := log.New()
+	l.Out = std.Out
+	l.Formatter = std.Formatter
+	l.Hooks = std.Hooks
+	l.Level = log.DebugLevel
+
+	return context.WithValue(ctx, ctxKeyLogger, l)
+}
+
+// logger returns a log entry annotated with the request ID stored in
+// the context to correlate log lines of one request
+func logger(ctx context.Context) *log.Entry {
+	base := log.StandardLogger()
+	if l, ok := ctx.Value(ctxKeyLogger).(*log.Logger); ok {
+		base = l
+	}
+
+	entry := log.NewEntry(base)
+	if id := requestIDFromContext(ctx); id != "" {
+		return entry.WithField("request_id", id)
+	}
+
+	return entry
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestWithRequestID(t *testing.T) {
+	ctx := withRequestID(context.Background())
+	id := requestIDFromContext(ctx)
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(id) {
+		t.Fatalf("Expected 16 hex characters as request ID, got %q", id)
+	}
+
+	// Nested handlers keep the ID of the request
+	if got := requestIDFromContext(withRequestID(ctx)); got != id {
+		t.Errorf("Expected ID %q to be kept, got %q", id, got)
+	}
+
+	if other := requestIDFromContext(withRequestID(context.Background())); other == id {
+		t.Errorf("Expected a new ID for another request, got %q twice", id)
+	}
+
+	if got := requestIDFromContext(context.Background()); got != "" {
+		t.Errorf("Expected no ID without request, got %q", got)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		ctx    context.Context
+		wantID bool
+	}{
+		{name: "background", ctx: context.Background()},
+		{name: "request", ctx: withRequestID(context.Background()), wantID: true},
+	} {
+		entry := logger(c.ctx)
+
+		id, ok := entry.Data["request_id"]
+		if ok != c.wantID || (ok && id != requestIDFromContext(c.ctx)) {
+			t.Errorf("%s: Expected request_id %v, got %v", c.name, c.wantID, entry.Data)
+		}
+	}
+}
+
+func TestWithDebugLogging(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") == "true" {
+			res.Write([]byte(`{"data":{"keys":["mail","notes"]}}`))
+			return
+		}
+		if r.URL.Path == "/v1/totp/notes" {
+			res.Write([]byte(`{"data":{"text":"no secret"}}`))
+			return
+		}
+		res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg, oldOut, oldLevel := cfg, log.StandardLogger().Out, log.GetLevel()
+	defer func() {
+		cfg = oldCfg
+		log.SetOutput(oldOut)
+		log.SetLevel(oldLevel)
+	}()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	log.SetLevel(log.InfoLevel)
+
+	for _, c := range []struct {
+		name      string
+		debug     bool
+		wantDebug []string
+	}{
+		{name: "normal request"},
+		{name: "debug request", debug: true, wantDebug: []string{"Listing keys", "Reading key", "Skipping key without secret"}},
+	} {
+		buf := new(bytes.Buffer)
+		log.SetOutput(buf)
+
+		ctx := withRequestID(context.Background())
+		if c.debug {
+			ctx = withDebugLogging(ctx)
+		}
+
+		if _, err := scanVault(ctx, "s.user", false); err != nil {
+			t.Fatalf("%s: Unexpected error: %s", c.name, err)
+		}
+
+		out := buf.String()
+		if !c.debug && strings.Contains(out, "level=debug") {
+			t.Errorf("%s: Expected no debug logs, got %q", c.name, out)
+		}
+		for _, msg := range c.wantDebug {
+			if !strings.Contains(out, msg) {
+				t.Errorf("%s: Expected debug log %q, got %q", c.name, msg, out)
+			}
+		}
+		if c.debug && !strings.Contains(out, "request_id="+requestIDFromContext(ctx)) {
+			t.Errorf("%s: Expected the debug logs to carry the request ID, got %q", c.name, out)
+		}
+
+		// Other requests keep logging at the configured level
+		if log.GetLevel() != log.InfoLevel {
+			t.Errorf("%s: Expected the standard logger to stay at info level, got %s", c.name, log.GetLevel())
+		}
+		buf.Reset()
+		logger(context.Background()).Debug("Not for this request")
+		if buf.Len() != 0 {
+			t.Errorf("%s: Expected no debug logs without debug context, got %q", c.name, buf.String())
+		}
+	}
+}
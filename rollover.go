@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// NextRollover returns the soonest time one of the codes changes taking
+// the period, T0 and offset of every token into account. Tokens whose
+// codes don't expire by time are skipped.
+func (t tokenList) NextRollover(now time.Time) (time.Time, bool) {
+	var next time.Time
+
+	for _, tok := range t {
+		if tok.Type == tokenTypeHOTP || tok.Deleted || tok.Secret == "" {
+			continue
+		}
+
+		opts, err := tok.totpOpts()
+		if err != nil {
+			continue
+		}
+
+		boundary := time.Unix(now.Unix()-tok.periodOffset(now, opts)+int64(opts.Period), 0)
+		if next.IsZero() || boundary.Before(next) {
+			next = boundary
+		}
+	}
+
+	return next, !next.IsZero()
+}
+
+func handleRollover(res http.ResponseWriter, r *http.Request) {
+	sess, tok, ok := getVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	ctx := withRequestID(r.Context())
+	res.Header().Set("X-Request-Id", requestIDFromContext(ctx))
+
+	// The periods are stored in the secrets which are not read for
+	// metadata-only scans using the subkeys endpoint
+	secrets, err := getSecrets(ctx, tok, false)
+	if err != nil {
+		logger(ctx).Errorf("Unable to fetch tokens: %s", err)
+		http.Error(res, `{"error":"Unexpected error while fetching tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	sess.Values["vault_token"] = tok
+	if err := sess.Save(r, res); err != nil {
+		logger(ctx).Errorf("Was not able to set the cookie: %s", err)
+		http.Error(res, "Something went wrong while fetching token. Sorry.", http.StatusInternalServerError)
+		return
+	}
+
+	result := struct {
+		NextRollover *time.Time `json:"next_rollover,omitempty"`
+		Seconds      int        `json:"seconds,omitempty"`
+	}{}
+
+	now := time.Now()
+	if next, ok := tokenList(secrets.Tokens).NextRollover(now); ok {
+		result.NextRollover = &next
+		result.Seconds = int(next.Sub(now.Truncate(time.Second)) / time.Second)
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(res).Encode(result)
+}
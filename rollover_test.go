@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestTokenListNextRollover(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	offset := func(d time.Duration) *time.Duration { return &d }
+	totp := func(period int) *token {
+		return &token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: period}
+	}
+
+	// 1045 is 25s into a 30s and a 60s period
+	now := time.Unix(1045, 0)
+
+	for _, c := range []struct {
+		name       string
+		profile    string
+		timeOffset time.Duration
+		tokens     tokenList
+		want       int64
+	}{
+		{name: "no tokens"},
+		{name: "single token", tokens: tokenList{totp(60)}, want: 1080},
+		{name: "soonest token", tokens: tokenList{totp(60), totp(30), totp(90)}, want: 1050},
+		{name: "profile period", profile: "authy", tokens: tokenList{totp(0), totp(60)}, want: 1050},
+		{name: "t0", tokens: tokenList{{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 60, T0: 30}}, want: 1050},
+		{name: "token offset", tokens: tokenList{{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 60, Offset: offset(10 * time.Second)}}, want: 1070},
+		{name: "global offset", timeOffset: -5 * time.Second, tokens: tokenList{totp(60)}, want: 1085},
+		{name: "global offset crossing the period", timeOffset: 20 * time.Second, tokens: tokenList{totp(30)}, want: 1060},
+		{
+			name: "tokens without time based codes",
+			tokens: tokenList{
+				{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeHOTP},
+				{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 30, Deleted: true},
+				{Type: tokenTypeTOTP, Period: 30, StoredCode: "123456"},
+				{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 30, Offset: offset(2 * time.Hour)},
+			},
+		},
+		{
+			name: "skipped tokens next to time based codes",
+			tokens: tokenList{
+				{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeHOTP},
+				{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 10, Deleted: true},
+				totp(60),
+			},
+			want: 1080,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.OTP.Profile = "default"
+			if c.profile != "" {
+				cfg.OTP.Profile = c.profile
+			}
+			cfg.OTP.TimeOffset = c.timeOffset
+
+			next, ok := c.tokens.NextRollover(now)
+			if ok != (c.want != 0) {
+				t.Fatalf("Expected a rollover %v, got %v (%s)", c.want != 0, ok, next)
+			}
+			if ok && next.Unix() != c.want {
+				t.Errorf("Expected the rollover at %d, got %d", c.want, next.Unix())
+			}
+		})
+	}
+}
+
+func TestHandleRollover(t *testing.T) {
+	var keys string
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case keys == "":
+			res.WriteHeader(http.StatusForbidden)
+			res.Write([]byte(`{"errors":["permission denied"]}`))
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":` + keys + `}}`))
+		case r.URL.Path == "/v1/totp/slow":
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP","period":"3600"}}`))
+		case r.URL.Path == "/v1/totp/fast":
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP","period":"5"}}`))
+		case r.URL.Path == "/v1/totp/vpn":
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP","type":"hotp","counter":"1"}}`))
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name        string
+		keys        string
+		wantStatus  int
+		wantMaxSecs int // 0 for no rollover
+	}{
+		{name: "soonest rollover", keys: `["slow","fast","vpn"]`, wantStatus: http.StatusOK, wantMaxSecs: 5},
+		{name: "hourly rollover", keys: `["slow","vpn"]`, wantStatus: http.StatusOK, wantMaxSecs: 3600},
+		{name: "without time based codes", keys: `["vpn"]`, wantStatus: http.StatusOK},
+		{name: "no tokens", keys: `[]`, wantStatus: http.StatusOK},
+		{name: "scan failing", wantStatus: http.StatusInternalServerError},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			keys = c.keys
+
+			withArgs(t, []string{
+				"--vault-addr", vault.URL, "--vault-prefix", "totp",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+			}, nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				r := httptest.NewRequest(http.MethodGet, "/rollover.json", nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				before := time.Now().Truncate(time.Second)
+				handleRollover(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+				if c.wantStatus != http.StatusOK {
+					return
+				}
+				if cc := res.Header().Get("Cache-Control"); cc != "no-cache" {
+					t.Errorf("Expected the response not to be cached, got %q", cc)
+				}
+
+				var result struct {
+					NextRollover *time.Time `json:"next_rollover"`
+					Seconds      int        `json:"seconds"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+
+				if c.wantMaxSecs == 0 {
+					if result.NextRollover != nil || result.Seconds != 0 {
+						t.Errorf("Expected no rollover, got %+v", result)
+					}
+					return
+				}
+
+				if result.NextRollover == nil {
+					t.Fatal("Expected a rollover")
+				}
+				if result.Seconds < 1 || result.Seconds > c.wantMaxSecs {
+					t.Errorf("Expected the rollover within %ds, got %ds", c.wantMaxSecs, result.Seconds)
+				}
+				if !result.NextRollover.After(before) || result.NextRollover.Unix()%int64(c.wantMaxSecs) != 0 {
+					t.Errorf("Expected the rollover on a period boundary after %s, got %s", before, result.NextRollover)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	htmlFieldsAllow  = "allow"
+	htmlFieldsReject = "reject"
+	htmlFieldsStrip  = "strip"
+)
+
+var (
+	// htmlChars are the characters required to create HTML tags
+	htmlChars = "<>"
+
+	// iconPattern matches the names of FontAwesome icons
+	iconPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+)
+
+// sanitizeFields prevents HTML written into the fields displayed in the
+// interface from reaching the page. Depending on the configuration the
+// HTML is stripped or the token is rejected.
+func (t *token) sanitizeFields() error {
+	if cfg.Vault.HTMLFields == htmlFieldsAllow {
+		return nil
+	}
+
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{
+		{"issuer", &t.Issuer},
+		{"name", &t.Name},
+		{"note", &t.Note},
+	} {
+		if !strings.ContainsAny(*f.value, htmlChars) {
+			continue
+		}
+
+		if cfg.Vault.HTMLFields == htmlFieldsReject {
+			return errors.Errorf("Field %s contains HTML", f.name)
+		}
+
+		*f.value = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(htmlChars, r) {
+				return -1
+			}
+			return r
+		}, *f.value)
+	}
+
+	if !iconPattern.MatchString(t.Icon) {
+		if cfg.Vault.HTMLFields == htmlFieldsReject {
+			return errors.New("Field icon contains an invalid icon name")
+		}
+		t.Icon = defaultIcon(t.Type)
+	}
+
+	return nil
+}
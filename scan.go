@@ -0,0 +1,797 @@
+package main
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// errTooManyTokens is returned when the scan finds more tokens than the
+// configured limit allows to be returned
+var errTooManyTokens = errors.New("Too many tokens found")
+
+// errNoOTPFields is reported for keys containing data but none of the
+// fields to generate a code from
+var errNoOTPFields = errors.New("No OTP fields found")
+
+// errEmptySecret is reported for keys whose secret field is set to an
+// empty value
+var errEmptySecret = errors.New("No secret set")
+
+const (
+	sourceStatusError   = "error"
+	sourceStatusOK      = "ok"
+	sourceStatusPartial = "partial"
+)
+
+type scanResult struct {
+	Tokens    []*token
+	Failures  []scanFailure
+	Sources   []sourceStatus
+	Truncated bool
+	// Warnings are non-fatal problems of the scan (i.e. skipped keys) in
+	// addition to the warnings of the tokens
+	Warnings []tokenWarning
+}
+
+// sourceStatus describes the outcome of scanning one of the prefixes
+// the tokens are merged from
+type sourceStatus struct {
+	Source string `json:"source"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *scanResult) copy() *scanResult {
+	out := &scanResult{
+		Tokens:    make([]*token, len(s.Tokens)),
+		Failures:  append([]scanFailure{}, s.Failures...),
+		Sources:   append([]sourceStatus{}, s.Sources...),
+		Truncated: s.Truncated,
+		Warnings:  append([]tokenWarning{}, s.Warnings...),
+	}
+
+	for i, t := range s.Tokens {
+		c := *t
+		out.Tokens[i] = &c
+	}
+
+	return out
+}
+
+// scanFailure describes a secret found during the scan which did not
+// produce a code. It must never contain the secret itself.
+type scanFailure struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type secretScanner struct {
+	client *api.Client
+	next   bool
+
+	// root is the key to start the scan at, when optional the root not
+	// existing is not considered an error
+	root     string
+	optional bool
+	// singleKey reads the root as the only secret instead of listing it
+	singleKey bool
+
+	operations int64
+	partial    int32
+	tooMany    int32
+	truncated  int32
+
+	// cancel stops the remaining operations of the scan
+	cancel context.CancelFunc
+
+	// slots limits the number of concurrent operations against Vault,
+	// listSlots and readSlots (if set) additionally limit the List and
+	// Read operations on their own
+	slots     chan struct{}
+	listSlots chan struct{}
+	readSlots chan struct{}
+	wg        *sync.WaitGroup
+
+	failures []scanFailure
+	warnings []tokenWarning
+	resp     []*token
+	respLock sync.Mutex
+	rootErr  error
+}
+
+// scanGroup collapses concurrent scans using the same token into one
+var scanGroup singleflight.Group
+
+func getSecretsFromVault(ctx context.Context, tok string, next bool) (*scanResult, error) {
+	if !cfg.Vault.CollapseScans {
+		return scanVault(ctx, tok, next)
+	}
+
+	key := strings.Join([]string{
+		hashSecret(tok),
+		scanRoot(),
+		strconv.FormatBool(next),
+		strconv.FormatBool(codesWanted(ctx)),
+		strconv.FormatBool(rawFieldsWanted(ctx)),
+	}, "\x00")
+
+	ch := scanGroup.DoChan(key, func() (interface{}, error) {
+		// The scan is shared with the other callers and must not be
+		// aborted when the request starting it goes away
+		var scanCtx context.Context = detachedContext{ctx}
+		if cfg.Vault.CollapseTimeout > 0 {
+			var cancel context.CancelFunc
+			scanCtx, cancel = context.WithTimeout(scanCtx, cfg.Vault.CollapseTimeout)
+			defer cancel()
+		}
+
+		return scanVault(scanCtx, tok, next)
+	})
+
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		// Only this caller stops waiting, the scan continues for the others
+		return nil, errors.Wrap(ctx.Err(), "Stopped waiting for the scan")
+	}
+
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	if res.Shared {
+		logger(ctx).WithField("token", hashSecret(tok)).Debug("Shared scan result with concurrent request")
+	}
+
+	// The tokens are modified by the handlers, every caller needs its
+	// own copy of them
+	return res.Val.(*scanResult).copy(), nil
+}
+
+// detachedContext carries the values of the parent context (logger,
+// request ID, scan options) but neither its deadline nor its cancellation
+type detachedContext struct{ parent context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// scanVault scans the Vault addresses in order until one of them is
+// available. Vaults not accepting the token of the user reject the scan,
+// the next login then switches to the available Vault.
+func scanVault(ctx context.Context, tok string, next bool) (*scanResult, error) {
+	var result *scanResult
+
+	err := withVaultFailover(func(client *api.Client) error {
+		client.SetToken(tok)
+
+		scanner := newSecretScanner(client, next, scanRoot(), false)
+		scanner.singleKey = cfg.Vault.SingleKey
+
+		var cubby *secretScanner
+		if cfg.Vault.CubbyholePrefix != "" {
+			// The cubbyhole is scoped to the token of the user so it might
+			// contain per-user secrets to be merged with the shared ones
+			cubby = newSecretScanner(client, next, strings.Trim(cfg.Vault.CubbyholePrefix, "/"), true)
+		}
+
+		var err error
+		result, err = scanWithCubbyhole(ctx, scanner, cubby)
+		return err
+	})
+
+	return result, err
+}
+
+// scanWithCubbyhole runs the scan of the prefix and the one of the
+// cubbyhole (if any) after another within one soft deadline and merges
+// their results. A failing cubbyhole is only reported as source error.
+func scanWithCubbyhole(ctx context.Context, scanner, cubbyScanner *secretScanner) (*scanResult, error) {
+	if cfg.Vault.SoftDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Vault.SoftDeadline)
+		defer cancel()
+	}
+
+	result, err := scanner.run(ctx)
+	if err != nil || cubbyScanner == nil {
+		return result, err
+	}
+
+	cubby, err := cubbyScanner.run(ctx)
+	if err != nil {
+		logger(ctx).WithError(err).Warn("Unable to scan cubbyhole, continuing without cubbyhole tokens")
+		result.Sources = append(result.Sources, sourceStatus{
+			Source: cubbyScanner.root,
+			Status: sourceStatusError,
+			Error:  err.Error(),
+		})
+		return result, nil
+	}
+
+	result.Tokens = append(result.Tokens, cubby.Tokens...)
+	result.Failures = append(result.Failures, cubby.Failures...)
+	result.Warnings = append(result.Warnings, cubby.Warnings...)
+	result.Sources = append(result.Sources, cubby.Sources...)
+	result.Truncated = result.Truncated || cubby.Truncated
+	sort.Sort(tokenList(result.Tokens))
+
+	return result, nil
+}
+
+func newSecretScanner(client *api.Client, next bool, root string, optional bool) *secretScanner {
+	concurrency := cfg.Vault.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &secretScanner{
+		client: client,
+		next:   next,
+
+		root:     root,
+		optional: optional,
+
+		slots:     make(chan struct{}, concurrency),
+		listSlots: optionalSlots(cfg.Vault.MaxListConcurrency),
+		readSlots: optionalSlots(cfg.Vault.MaxReadConcurrency),
+		wg:        new(sync.WaitGroup),
+
+		resp: []*token{},
+	}
+}
+
+func (s *secretScanner) run(ctx context.Context) (*scanResult, error) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	defer s.cancel()
+
+	done := make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		if s.singleKey {
+			s.dispatch(func() { s.scanSingleKey(ctx, s.root) })
+		} else {
+			s.dispatch(func() { s.scanKeyForSubKeys(ctx, s.root) })
+		}
+
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Return what was gathered until now, the remaining operations
+		// are skipped as the context is done
+		atomic.StoreInt32(&s.partial, 1)
+	}
+
+	if atomic.LoadInt32(&s.tooMany) == 1 {
+		logger(ctx).WithField("max_tokens", cfg.Vault.MaxTokens).Error("Scan found too many tokens")
+		return nil, errors.Wrapf(errTooManyTokens, "More than %d tokens found, use a narrower prefix", cfg.Vault.MaxTokens)
+	}
+
+	s.respLock.Lock()
+	tokens := append([]*token{}, s.resp...)
+	rootErr := s.rootErr
+	s.respLock.Unlock()
+
+	if rootErr != nil {
+		// Nothing could be listed at all, most likely Vault is unavailable
+		if s.singleKey {
+			return nil, errors.Wrapf(rootErr, "Unable to read key %q", s.root)
+		}
+		return nil, errors.Wrapf(rootErr, "Unable to list keys %q", s.root)
+	}
+
+	if codesWanted(ctx) {
+		tokens = s.generateCodes(ctx, tokens)
+	}
+
+	s.respLock.Lock()
+	failures := append([]scanFailure{}, s.failures...)
+	warnings := append([]tokenWarning{}, s.warnings...)
+	s.respLock.Unlock()
+
+	sort.Sort(tokenList(tokens))
+	sortWarnings(warnings)
+
+	result := &scanResult{
+		Tokens:    tokens,
+		Failures:  failures,
+		Warnings:  warnings,
+		Truncated: atomic.LoadInt32(&s.truncated) == 1 || atomic.LoadInt32(&s.partial) == 1,
+	}
+
+	status := sourceStatus{Source: s.root, Status: sourceStatusOK}
+	if result.Truncated || len(failures) > 0 {
+		status.Status = sourceStatusPartial
+	}
+	result.Sources = []sourceStatus{status}
+
+	if atomic.LoadInt32(&s.truncated) == 1 {
+		logger(ctx).WithField("max_operations", cfg.Vault.MaxOperations).Warn("Scan exceeded the operation budget, results are truncated")
+	}
+
+	if atomic.LoadInt32(&s.partial) == 1 {
+		logger(ctx).WithField("soft_deadline", cfg.Vault.SoftDeadline).Warn("Scan did not finish in time, results are partial")
+	}
+
+	return result, nil
+}
+
+// withoutCodes marks the scan to only collect the metadata of the tokens
+// without generating their codes
+// scanAbandoned tells whether the request failed because the scan was
+// given up (deadline, cancellation, too many tokens) while it was running.
+// The result is already returned without the key, the error is not to be
+// reported for it.
+func scanAbandoned(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil
+}
+
+func withoutCodes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyWithoutCodes, true)
+}
+
+func codesWanted(ctx context.Context) bool {
+	without, _ := ctx.Value(ctxKeyWithoutCodes).(bool)
+	return !without
+}
+
+// takeOperation accounts for one List / Read operation against Vault and
+// reports whether the operation is within the budget and the deadline of
+// the scan
+func (s *secretScanner) takeOperation(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		atomic.StoreInt32(&s.partial, 1)
+		return false
+	}
+
+	if cfg.Vault.MaxOperations <= 0 {
+		return true
+	}
+
+	if atomic.AddInt64(&s.operations, 1) > cfg.Vault.MaxOperations {
+		atomic.StoreInt32(&s.truncated, 1)
+		return false
+	}
+
+	return true
+}
+
+// dispatch executes the function in the background unless the scan is
+// configured to be performed strictly serial (or in order)
+func (s *secretScanner) dispatch(fn func()) {
+	if cfg.Vault.Serial || cfg.Vault.SortedScan {
+		fn()
+		return
+	}
+
+	go fn()
+}
+
+// acquire takes a slot of the given operation type (if limited) and
+// one of the overall slots, always in that order
+func (s *secretScanner) acquire(op chan struct{}) {
+	if op != nil {
+		op <- struct{}{}
+	}
+	s.slots <- struct{}{}
+}
+
+func (s *secretScanner) release(op chan struct{}) {
+	<-s.slots
+	if op != nil {
+		<-op
+	}
+}
+
+// optionalSlots creates the slots for the given limit, without limit
+// (limit < 1) there are no slots to take
+func optionalSlots(limit int) chan struct{} {
+	if limit < 1 {
+		return nil
+	}
+
+	return make(chan struct{}, limit)
+}
+
+// scanRoot returns the key to start the scan for secrets at
+func scanRoot() string {
+	return strings.TrimRight(cfg.Vault.Prefix, "*")
+}
+
+// folderOf returns the folder of the key relative to the scan root
+func (s *secretScanner) folderOf(key string) string {
+	if s.singleKey {
+		return ""
+	}
+
+	return strings.Trim(strings.TrimPrefix(path.Dir(key), path.Clean(s.root)), "/")
+}
+
+func (s *secretScanner) scanKeyForSubKeys(ctx context.Context, key string) {
+	defer s.wg.Done()
+
+	if !s.takeOperation(ctx) {
+		return
+	}
+
+	logger(ctx).WithField("key", key).Debug("Listing keys")
+
+	s.acquire(s.listSlots)
+	sec, err := logicalRequest(ctx, s.client, kvListPath(key), true)
+	s.release(s.listSlots)
+
+	if scanAbandoned(ctx, err) {
+		return
+	}
+
+	if err != nil {
+		logger(ctx).Errorf("Unable to list keys %q: %s", key, err)
+		if key == s.root {
+			s.setRootErr(err)
+		}
+		return
+	}
+
+	if sec == nil {
+		if key == s.root && s.optional {
+			logger(ctx).WithField("key", key).Debug("Optional scan root does not exist")
+			return
+		}
+		logger(ctx).Errorf("There is no key %q", key)
+		return
+	}
+
+	if sec.Data["keys"] == nil {
+		return
+	}
+
+	keys, ok := sec.Data["keys"].([]interface{})
+	if !ok {
+		logger(ctx).WithField("key", key).Errorf("Listing returned keys of unexpected type %T", sec.Data["keys"])
+		return
+	}
+
+	if cfg.Vault.SortedScan {
+		// Processing the keys one by one in order makes the tokens found
+		// until a deadline always the ones of the first keys
+		sort.SliceStable(keys, func(i, j int) bool {
+			ki, _ := keys[i].(string)
+			kj, _ := keys[j].(string)
+			return ki < kj
+		})
+	}
+
+	batchSize := cfg.Vault.ListBatchSize
+	if batchSize < 1 {
+		batchSize = len(keys)
+	}
+
+	// Process the keys in batches and wait for the reads of each batch to
+	// complete before starting the next one to keep the number of pending
+	// reads bounded for folders containing lots of keys
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		batch := new(sync.WaitGroup)
+		for _, sk := range keys[start:end] {
+			sks, ok := sk.(string)
+			if !ok || sks == "" {
+				logger(ctx).WithField("key", key).Warnf("Skipping listed entry of unexpected type %T", sk)
+				continue
+			}
+
+			k := path.Join(key, sks)
+			if strings.HasSuffix(sks, "/") {
+				s.wg.Add(1)
+				s.dispatch(func() { s.scanKeyForSubKeys(ctx, k) })
+				continue
+			}
+
+			batch.Add(1)
+			s.dispatch(func() {
+				defer batch.Done()
+				s.fetchTokenFromKey(ctx, k)
+			})
+		}
+		batch.Wait()
+	}
+}
+
+// scanSingleKey reads the key as the only secret without listing it so
+// the token does not need the list capability
+func (s *secretScanner) scanSingleKey(ctx context.Context, key string) {
+	defer s.wg.Done()
+
+	s.fetchTokenFromKey(ctx, key)
+}
+
+func (s *secretScanner) setRootErr(err error) {
+	s.respLock.Lock()
+	defer s.respLock.Unlock()
+
+	s.rootErr = err
+}
+
+func (s *secretScanner) fetchTokenFromKey(ctx context.Context, k string) {
+	if !s.takeOperation(ctx) {
+		return
+	}
+
+	if useSubkeys(ctx, k) {
+		s.fetchStructureFromKey(ctx, k)
+		return
+	}
+
+	s.readTokenFromKey(ctx, k)
+}
+
+// readTokenFromKey reads the secret stored in the key (and its custom
+// metadata) and adds the token built from it
+func (s *secretScanner) readTokenFromKey(ctx context.Context, k string) {
+	logger(ctx).WithField("key", k).Debug("Reading key")
+
+	customMeta := s.fetchCustomMetadata(ctx, k)
+
+	s.acquire(s.readSlots)
+	sec, err := logicalRequest(ctx, s.client, kvReadPath(k), false)
+	s.release(s.readSlots)
+
+	if scanAbandoned(ctx, err) {
+		return
+	}
+
+	if err == nil && kvMalformed(k, sec) {
+		err = errMalformedSecret
+	}
+
+	if err == errMalformedSecret {
+		logger(ctx).WithField("key", k).Warn("Skipping key with malformed data, expected a map of fields")
+		if cfg.Vault.ReportMalformed {
+			s.addFailure(&token{Name: k, Path: k}, err)
+		}
+		s.addSkipped(k, err)
+		return
+	}
+
+	if err != nil {
+		logger(ctx).Errorf("Unable to read from key %q: %s", k, err)
+		if s.singleKey {
+			s.setRootErr(err)
+		} else {
+			if errors.Cause(err) == errRateLimited {
+				// Still limited after all retries, needs to be visible
+				s.addFailure(&token{Name: k, Path: k}, err)
+			}
+			s.addSkipped(k, errKeyUnreadable)
+		}
+		return
+	}
+
+	data, deleted := kvData(k, sec)
+	if deleted {
+		s.handleDeletedKey(ctx, k)
+		return
+	}
+
+	if data == nil {
+		if s.singleKey {
+			s.setRootErr(errors.Errorf("There is no key %q", k))
+		}
+		// Key without any data? Weird.
+		return
+	}
+
+	var stored map[string]interface{}
+	if rawFieldsWanted(ctx) {
+		// Keep the fields as stored, without the merged custom metadata
+		stored = make(map[string]interface{}, len(data))
+		for f, v := range data {
+			stored[f] = v
+		}
+	}
+
+	// Fields of the secret take precedence over the custom metadata
+	for f, v := range <-customMeta {
+		if _, ok := data[f]; !ok {
+			data[f] = v
+		}
+	}
+
+	tok, err := buildToken(ctx, k, data, buildOptions{
+		Created: kvCreatedTime(k, sec),
+		Folder:  s.folderOf(k),
+		ReadKey: s.readKey(ctx),
+		Fields:  stored,
+	})
+	if scanAbandoned(ctx, err) {
+		// Reading the referenced secret was aborted
+		return
+	}
+
+	if err != nil {
+		if handleBuildError(ctx, k, err) {
+			s.addFailure(tok, err)
+		}
+		s.addSkipped(k, err)
+		if tok != nil && tok.Fields != nil {
+			// The fields of rejected keys are still to be backed up
+			s.addToken(tok)
+		}
+		return
+	}
+
+	if tok != nil {
+		s.addToken(tok)
+	}
+}
+
+// fetchCustomMetadata reads the custom metadata of KV v2 keys in the
+// background while the secret is read, the channel receives nil when
+// there is none
+func (s *secretScanner) fetchCustomMetadata(ctx context.Context, k string) <-chan map[string]interface{} {
+	ch := make(chan map[string]interface{}, 1)
+	if !cfg.Vault.KV2CustomMeta || !isKV2Key(k) {
+		ch <- nil
+		return ch
+	}
+
+	s.dispatch(func() {
+		if !s.takeOperation(ctx) {
+			ch <- nil
+			return
+		}
+
+		s.acquire(s.readSlots)
+		sec, err := logicalRequest(ctx, s.client, kvPath(k, "metadata"), false)
+		s.release(s.readSlots)
+
+		if scanAbandoned(ctx, err) {
+			ch <- nil
+			return
+		}
+
+		if err != nil {
+			logger(ctx).WithError(err).WithField("key", k).Warn("Unable to read custom metadata")
+			ch <- nil
+			return
+		}
+
+		var meta map[string]interface{}
+		if sec != nil {
+			meta, _ = sec.Data["custom_metadata"].(map[string]interface{})
+		}
+		ch <- meta
+	})
+
+	return ch
+}
+
+// readKey returns a reader accounting the reads against the limits of
+// the scan
+func (s *secretScanner) readKey(ctx context.Context) keyReader {
+	return func(key string) (*api.Secret, error) {
+		if !s.takeOperation(ctx) {
+			return nil, errors.New("Scan limits exceeded")
+		}
+
+		s.acquire(s.readSlots)
+		defer s.release(s.readSlots)
+
+		return logicalRequest(ctx, s.client, kvReadPath(key), false)
+	}
+}
+
+// handleDeletedKey skips soft-deleted KV v2 secrets or adds them as
+// deleted tokens without a code if configured to show them
+func (s *secretScanner) handleDeletedKey(ctx context.Context, k string) {
+	if !cfg.Vault.ShowDeleted {
+		logger(ctx).WithField("key", k).Debug("Skipping deleted secret")
+		return
+	}
+
+	tok := &token{
+		Deleted: true,
+		Icon:    "trash",
+		Name:    k,
+		Path:    k,
+		Type:    tokenTypeTOTP,
+	}
+
+	if cfg.UI.GroupFolders {
+		tok.Folder = s.folderOf(k)
+	}
+	tok.Fingerprint = tok.ConfigFingerprint()
+
+	s.addToken(tok)
+}
+
+func (s *secretScanner) addToken(tok *token) {
+	s.respLock.Lock()
+	defer s.respLock.Unlock()
+	s.resp = append(s.resp, tok)
+
+	if cfg.Vault.MaxTokens > 0 && len(s.resp) > cfg.Vault.MaxTokens {
+		atomic.StoreInt32(&s.tooMany, 1)
+		s.cancel()
+	}
+}
+
+// generateCodes generates the codes of the tokens found by the scan all at
+// once using the generate workers instead of one by one while reading the
+// keys. Tokens whose code can't be generated are reported like tokens
+// failing to build and left out.
+func (s *secretScanner) generateCodes(ctx context.Context, tokens []*token) []*token {
+	var (
+		failed     = map[*token]bool{}
+		failedLock sync.Mutex
+	)
+
+	generateAll(tokens, func(t *token) {
+		if t.Deleted || t.MetadataOnly {
+			return
+		}
+
+		if err := t.GenerateCode(s.next); err != nil {
+			err = errors.Wrap(err, "Unable to generate code")
+			if handleBuildError(ctx, t.Path, err) {
+				s.addFailure(t, err)
+			}
+			s.addSkipped(t.Path, err)
+
+			failedLock.Lock()
+			failed[t] = true
+			failedLock.Unlock()
+		}
+	})
+
+	if len(failed) == 0 {
+		return tokens
+	}
+
+	out := make([]*token, 0, len(tokens)-len(failed))
+	for _, t := range tokens {
+		if !failed[t] {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func (s *secretScanner) addFailure(tok *token, err error) {
+	s.respLock.Lock()
+	defer s.respLock.Unlock()
+	s.failures = append(s.failures, scanFailure{Name: tok.Name, Path: tok.Path, Error: err.Error()})
+}
+
+// addSkipped records the warning for a key skipped because of the error
+func (s *secretScanner) addSkipped(key string, err error) {
+	w, ok := skipWarning(key, err)
+	if !ok {
+		return
+	}
+
+	s.respLock.Lock()
+	defer s.respLock.Unlock()
+	s.warnings = append(s.warnings, w)
+}
@@ -0,0 +1,1318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// blockingVault serves one key in every listed path and counts the
+// listings, listing the blocking paths waits until release is closed or
+// the request is aborted
+func blockingVault(lists *int32, started chan<- string, release <-chan struct{}, blocking ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") != "true" {
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+			return
+		}
+
+		atomic.AddInt32(lists, 1)
+
+		for _, p := range blocking {
+			if r.URL.Path == "/v1/"+p {
+				started <- p
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+	}))
+}
+
+func TestGetSecretsFromVaultCanceledCaller(t *testing.T) {
+	var (
+		started = make(chan string, 10)
+		release = make(chan struct{})
+		lists   int32
+	)
+
+	vault := blockingVault(&lists, started, release, "totp")
+	defer vault.Close()
+	var unblock sync.Once
+	defer unblock.Do(func() { close(release) })
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.CollapseScans = true
+	cfg.Vault.CollapseTimeout = 10 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := getSecretsFromVault(ctx, "s.user", false)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan *scanResult, 1)
+	go func() {
+		res, err := getSecretsFromVault(context.Background(), "s.user", false)
+		if err != nil {
+			t.Errorf("Second caller got error: %s", err)
+		}
+		second <- res
+	}()
+	// Give the second caller the time to join the running scan
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-firstErr:
+		if errors.Cause(err) != context.Canceled {
+			t.Errorf("Expected the canceled caller to get context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Canceled caller still waits for the scan")
+	}
+
+	unblock.Do(func() { close(release) })
+	res := <-second
+	if res == nil {
+		t.Fatal("Second caller got no result")
+	}
+	if res.Truncated || len(res.Tokens) != 1 {
+		t.Errorf("Expected the complete scan, got truncated=%v tokens=%d", res.Truncated, len(res.Tokens))
+	}
+	if n := atomic.LoadInt32(&lists); n != 1 {
+		t.Errorf("Expected one shared scan, got %d listings", n)
+	}
+}
+
+func TestScanWithCubbyholeSoftDeadline(t *testing.T) {
+	var (
+		started = make(chan string, 10)
+		release = make(chan struct{})
+		lists   int32
+	)
+
+	vault := blockingVault(&lists, started, release, "totp", "cubbyhole/totp")
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.SoftDeadline = 200 * time.Millisecond
+
+	client, err := newVaultClient()
+	if err != nil {
+		t.Fatalf("Unable to create client: %s", err)
+	}
+	scanner := newSecretScanner(client, false, "totp", false)
+	cubby := newSecretScanner(client, false, "cubbyhole/totp", true)
+
+	// Requests left running by a failing test must be done before the
+	// config is restored
+	defer func() {
+		close(release)
+		scanner.wg.Wait()
+		cubby.wg.Wait()
+	}()
+
+	start := time.Now()
+	res, err := scanWithCubbyhole(context.Background(), scanner, cubby)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// The cubbyhole scan must not get a deadline of its own
+	if elapsed := time.Since(start); elapsed > 350*time.Millisecond {
+		t.Errorf("Scan took %s, expected about the soft deadline of %s", elapsed, cfg.Vault.SoftDeadline)
+	}
+
+	if !res.Truncated || len(res.Sources) != 2 {
+		t.Fatalf("Expected truncated results of both sources, got %+v", res)
+	}
+	for _, s := range res.Sources {
+		if s.Status != sourceStatusPartial {
+			t.Errorf("Expected source %q to be partial, got %q", s.Source, s.Status)
+		}
+	}
+	if len(res.Failures) != 0 || len(res.Warnings) != 0 {
+		t.Errorf("Expected the aborted listings not to be reported, got %+v / %+v", res.Failures, res.Warnings)
+	}
+
+	// The listings still running at the deadline are aborted instead of
+	// waiting for Vault to answer them
+	aborted := make(chan struct{})
+	go func() {
+		scanner.wg.Wait()
+		cubby.wg.Wait()
+		close(aborted)
+	}()
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("Requests of the scan are still running after the deadline")
+	}
+}
+
+func TestScanVaultSkippedKeys(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","empty","internal","userdata"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/empty":
+			res.Write([]byte(`{"data":{"name":"Empty","secret":""}}`))
+		case r.URL.Path == "/v1/totp/userdata":
+			res.Write([]byte(`{"data":{"username":"jdoe"}}`))
+		default:
+			http.Error(res, `{"errors":["internal error"]}`, http.StatusInternalServerError)
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.RateLimitRetries = 0
+
+	res, err := scanVault(context.Background(), "s.user", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(res.Tokens) != 1 {
+		t.Fatalf("Expected only the Mail token, got %+v", res.Tokens)
+	}
+
+	want := []tokenWarning{
+		{Name: "totp/empty", Warning: "Skipped key with empty secret field"},
+		{Name: "totp/internal", Warning: "Skipped key which could not be read"},
+	}
+	if !reflect.DeepEqual(res.Warnings, want) {
+		t.Errorf("Unexpected warnings:\n got %+v\nwant %+v", res.Warnings, want)
+	}
+}
+
+func TestScanVaultFailover(t *testing.T) {
+	// Nothing listens on the address of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to allocate address: %s", err)
+	}
+	unavailable := "http://" + l.Addr().String()
+	l.Close()
+
+	forbidden := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+	}))
+	defer forbidden.Close()
+
+	sealed := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		http.Error(res, `{"errors":["Vault is sealed"]}`, http.StatusServiceUnavailable)
+	}))
+	defer sealed.Close()
+
+	var scans int32
+	live := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") == "true" {
+			atomic.AddInt32(&scans, 1)
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+			return
+		}
+		res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer live.Close()
+
+	oldCfg, oldActive := cfg, atomic.LoadInt32(&activeVault)
+	defer func() { cfg = oldCfg; atomic.StoreInt32(&activeVault, oldActive) }()
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.RateLimitRetries = 0
+
+	for _, c := range []struct {
+		name       string
+		primary    string
+		wantErr    bool
+		wantActive int32
+	}{
+		{name: "dead primary", primary: unavailable, wantActive: 1},
+		{name: "sealed primary", primary: sealed.URL, wantActive: 1},
+		{name: "live primary", primary: live.URL, wantActive: 0},
+		// The token is rejected, the other Vault would not accept it either
+		{name: "rejecting primary", primary: forbidden.URL, wantErr: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.Address, cfg.Vault.FailoverAddresses = c.primary, []string{live.URL}
+			atomic.StoreInt32(&activeVault, 0)
+			atomic.StoreInt32(&scans, 0)
+
+			res, err := scanVault(context.Background(), "s.user", false)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("scanVault() error = %v, expected error %v", err, c.wantErr)
+			}
+			if c.wantErr {
+				if n := atomic.LoadInt32(&scans); n != 0 {
+					t.Errorf("Expected no scan of the secondary Vault, got %d", n)
+				}
+				return
+			}
+
+			if len(res.Tokens) != 1 || res.Tokens[0].Name != "Mail" {
+				t.Errorf("Expected the Mail token, got %+v", res.Tokens)
+			}
+			if n := atomic.LoadInt32(&activeVault); n != c.wantActive {
+				t.Errorf("Expected Vault %d to be active, got %d", c.wantActive, n)
+			}
+		})
+	}
+}
+
+func TestScanVaultListBatches(t *testing.T) {
+	var (
+		inFlight, maxInFlight int32
+		reads                 int32
+	)
+
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = `"key-` + strconv.Itoa(i) + `"`
+	}
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") == "true" {
+			res.Write([]byte(`{"data":{"keys":[` + strings.Join(keys, ",") + `]}}`))
+			return
+		}
+
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&reads, 1)
+
+		// Keep the reads pending long enough to overlap
+		time.Sleep(5 * time.Millisecond)
+		res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.Concurrency = 50
+
+	for _, c := range []struct {
+		batchSize int
+		wantMax   int32
+	}{
+		{batchSize: 10, wantMax: 10},
+		{batchSize: 25, wantMax: 25},
+		{batchSize: 0, wantMax: 50},
+	} {
+		cfg.Vault.ListBatchSize = c.batchSize
+		atomic.StoreInt32(&maxInFlight, 0)
+		atomic.StoreInt32(&reads, 0)
+
+		res, err := scanVault(context.Background(), "s.user", false)
+		if err != nil {
+			t.Fatalf("batch size %d: Unexpected error: %s", c.batchSize, err)
+		}
+
+		if len(res.Tokens) != len(keys) || atomic.LoadInt32(&reads) != int32(len(keys)) {
+			t.Errorf("batch size %d: Expected all %d keys to be read, got %d tokens from %d reads", c.batchSize, len(keys), len(res.Tokens), reads)
+		}
+
+		// The reads of a batch run in parallel, the next batch only starts
+		// when all of them are done
+		if max := atomic.LoadInt32(&maxInFlight); max > c.wantMax || max < 2 {
+			t.Errorf("batch size %d: Expected up to %d parallel reads, got %d", c.batchSize, c.wantMax, max)
+		}
+	}
+}
+
+func TestScannerFolderOf(t *testing.T) {
+	for _, c := range []struct {
+		root, key string
+		singleKey bool
+		want      string
+	}{
+		{root: "totp", key: "totp/mail", want: ""},
+		{root: "totp/", key: "totp/work/mail", want: "work"},
+		{root: "/totp", key: "/totp/work/team/mail", want: "work/team"},
+		{root: "secret/totp", key: "secret/totp/private/bank", want: "private"},
+		// A single key requested has no folder to group into
+		{root: "totp/work/mail", key: "totp/work/mail", singleKey: true, want: ""},
+	} {
+		s := &secretScanner{root: c.root, singleKey: c.singleKey}
+		if got := s.folderOf(c.key); got != c.want {
+			t.Errorf("folderOf(%q) below %q = %q, expected %q", c.key, c.root, got, c.want)
+		}
+	}
+}
+
+func TestScanOperationBudget(t *testing.T) {
+	var requests int32
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") == "true" {
+			res.Write([]byte(`{"data":{"keys":["a","b","c"]}}`))
+			return
+		}
+		res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+
+	for _, c := range []struct {
+		budget        int64
+		wantTokens    int
+		wantTruncated bool
+	}{
+		{budget: 0, wantTokens: 3},
+		{budget: 4, wantTokens: 3},
+		{budget: 3, wantTokens: 2, wantTruncated: true},
+		// Only the listing fits into the budget
+		{budget: 1, wantTokens: 0, wantTruncated: true},
+	} {
+		atomic.StoreInt32(&requests, 0)
+		cfg.Vault.MaxOperations = c.budget
+
+		res, err := scanVault(context.Background(), "s.user", false)
+		if err != nil {
+			t.Fatalf("budget %d: Unexpected error: %s", c.budget, err)
+		}
+
+		if len(res.Tokens) != c.wantTokens || res.Truncated != c.wantTruncated {
+			t.Errorf("budget %d: Expected %d tokens (truncated=%v), got %d (truncated=%v)",
+				c.budget, c.wantTokens, c.wantTruncated, len(res.Tokens), res.Truncated)
+		}
+		if n := atomic.LoadInt32(&requests); c.budget > 0 && int64(n) > c.budget {
+			t.Errorf("budget %d: Expected at most %d requests, got %d", c.budget, c.budget, n)
+		}
+	}
+}
+
+func TestScanDeletedKV2Secrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/secret/metadata/totp":
+			res.Write([]byte(`{"data":{"keys":["mail","gone"]}}`))
+		case "/v1/secret/data/totp/mail":
+			res.Write([]byte(`{"data":{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"},"metadata":{"deletion_time":"","destroyed":false}}}`))
+		case "/v1/secret/data/totp/gone":
+			// Vault answers reads of deleted versions with 404 and the metadata
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"data":{"data":null,"metadata":{"deletion_time":"2020-01-01T00:00:00Z","destroyed":false}}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "secret/totp"
+	cfg.Vault.KV2Mount = "secret"
+
+	for _, c := range []struct {
+		showDeleted bool
+		want        []string
+	}{
+		{want: []string{"Mail"}},
+		{showDeleted: true, want: []string{"Mail", "secret/totp/gone"}},
+	} {
+		cfg.Vault.ShowDeleted = c.showDeleted
+
+		res, err := scanVault(context.Background(), "s.user", false)
+		if err != nil {
+			t.Fatalf("show deleted %v: Unexpected error: %s", c.showDeleted, err)
+		}
+
+		var names []string
+		for _, tok := range res.Tokens {
+			names = append(names, tok.Name)
+			if tok.Deleted != (tok.Name != "Mail") || (tok.Deleted && tok.Code != "") {
+				t.Errorf("show deleted %v: Unexpected token %+v", c.showDeleted, tok)
+			}
+		}
+		if !reflect.DeepEqual(names, c.want) {
+			t.Errorf("show deleted %v: Expected tokens %v, got %v", c.showDeleted, c.want, names)
+		}
+		if len(res.Failures) != 0 {
+			t.Errorf("show deleted %v: Expected no failures, got %+v", c.showDeleted, res.Failures)
+		}
+	}
+}
+
+func TestScanSerial(t *testing.T) {
+	var (
+		inFlight, maxInFlight int32
+		lock                  sync.Mutex
+		reads                 []string
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		// Give concurrent requests the chance to overlap
+		time.Sleep(5 * time.Millisecond)
+
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/totp":
+			res.Write([]byte(`{"data":{"keys":["a","b","team/","c"]}}`))
+		case "/v1/totp/team":
+			res.Write([]byte(`{"data":{"keys":["d","e"]}}`))
+		default:
+			lock.Lock()
+			reads = append(reads, strings.TrimPrefix(r.URL.Path, "/v1/totp/"))
+			lock.Unlock()
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.Serial = true
+
+	res, err := scanVault(context.Background(), "s.user", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(res.Tokens) != 5 {
+		t.Errorf("Expected 5 tokens, got %d", len(res.Tokens))
+	}
+	if m := atomic.LoadInt32(&maxInFlight); m != 1 {
+		t.Errorf("Expected no concurrent requests, got up to %d at once", m)
+	}
+	// Keys are processed one after another in the order of the listing
+	if want := []string{"a", "b", "team/d", "team/e", "c"}; !reflect.DeepEqual(reads, want) {
+		t.Errorf("Expected reads in order %v, got %v", want, reads)
+	}
+}
+
+func TestScanVaultSoftDeadline(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/totp":
+			res.Write([]byte(`{"data":{"keys":["mail","slow/"]}}`))
+		case "/v1/totp/slow":
+			// Answers after the deadline of the scan only
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+			res.Write([]byte(`{"data":{"keys":["other"]}}`))
+		default:
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		}
+	}))
+	// Closing waits for the aborted listing before the config is restored
+	defer vault.Close()
+
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.SoftDeadline = 200 * time.Millisecond
+
+	start := time.Now()
+	res, err := scanVault(context.Background(), "s.user", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Scan took %s, expected to return at the soft deadline of %s", elapsed, cfg.Vault.SoftDeadline)
+	}
+	// The tokens read until the deadline are returned marked as partial
+	if !res.Truncated || len(res.Tokens) != 1 || res.Tokens[0].Path != "totp/mail" {
+		t.Errorf("Expected the partial result with the mail token, got truncated=%v tokens=%+v", res.Truncated, res.Tokens)
+	}
+	if len(res.Failures) != 0 {
+		t.Errorf("Expected the aborted listing not to be a failure, got %+v", res.Failures)
+	}
+}
+
+func TestScanVaultMaxTokens(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("list") == "true" {
+			res.Write([]byte(`{"data":{"keys":["a","b","c"]}}`))
+			return
+		}
+		res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+
+	for _, c := range []struct {
+		max     int
+		wantErr bool
+	}{
+		{max: 0},
+		{max: 3},
+		{max: 2, wantErr: true},
+		{max: 1, wantErr: true},
+	} {
+		cfg.Vault.MaxTokens = c.max
+
+		res, err := scanVault(context.Background(), "s.user", false)
+		if c.wantErr {
+			if errors.Cause(err) != errTooManyTokens {
+				t.Errorf("max %d: Expected errTooManyTokens, got %v / %+v", c.max, err, res)
+			}
+			continue
+		}
+		if err != nil || len(res.Tokens) != 3 {
+			t.Errorf("max %d: Expected all 3 tokens, got %v / %+v", c.max, err, res)
+		}
+	}
+}
+
+func TestScanVaultUnexpectedListEntries(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		listing   string
+		wantNames []string
+	}{
+		{name: "mixed entries", listing: `{"data":{"keys":["mail",42,"",null,{"a":"b"},"other"]}}`, wantNames: []string{"Mail", "Other"}},
+		{name: "keys not a list", listing: `{"data":{"keys":"mail"}}`},
+		{name: "only unusable entries", listing: `{"data":{"keys":[42,null]}}`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+				res.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Query().Get("list") == "true":
+					res.Write([]byte(c.listing))
+				case r.URL.Path == "/v1/totp/mail":
+					res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+				case r.URL.Path == "/v1/totp/other":
+					res.Write([]byte(`{"data":{"name":"Other","secret":"JBSWY3DPEHPK3PXP"}}`))
+				default:
+					http.NotFound(res, r)
+				}
+			}))
+			defer vault.Close()
+
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = "totp"
+
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			var names []string
+			for _, tok := range res.Tokens {
+				names = append(names, tok.Name)
+			}
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Errorf("Expected tokens %v, got %v", c.wantNames, names)
+			}
+			if len(res.Failures) != 0 {
+				t.Errorf("Expected no failures, got %+v", res.Failures)
+			}
+		})
+	}
+}
+
+func TestScanVaultSingleKey(t *testing.T) {
+	var lists int32
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			atomic.AddInt32(&lists, 1)
+			http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		case r.URL.Path == "/v1/team/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/team/totp/userdata":
+			res.Write([]byte(`{"data":{"username":"jdoe"}}`))
+		case r.URL.Path == "/v1/team/totp/denied":
+			http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name      string
+		prefix    string
+		wantNames []string
+		wantErr   string
+	}{
+		{name: "secret", prefix: "team/totp/mail", wantNames: []string{"Mail"}},
+		{name: "without secret", prefix: "team/totp/userdata"},
+		{name: "missing key", prefix: "team/totp/missing", wantErr: `There is no key "team/totp/missing"`},
+		{name: "read denied", prefix: "team/totp/denied", wantErr: `Unable to read key "team/totp/denied"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = c.prefix
+			cfg.Vault.SingleKey = true
+
+			atomic.StoreInt32(&lists, 0)
+			res, err := scanVault(context.Background(), "s.user", false)
+			if n := atomic.LoadInt32(&lists); n != 0 {
+				t.Errorf("Expected no list requests, got %d", n)
+			}
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("Expected error %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			var names []string
+			for _, tok := range res.Tokens {
+				names = append(names, tok.Name)
+				// There are no folders without listing
+				if tok.Folder != "" {
+					t.Errorf("Expected no folder for %q, got %q", tok.Name, tok.Folder)
+				}
+			}
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Errorf("Expected tokens %v, got %v", c.wantNames, names)
+			}
+		})
+	}
+}
+
+func TestScanKV2CustomMetadata(t *testing.T) {
+	var metaReads int32
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/totp/") {
+			atomic.AddInt32(&metaReads, 1)
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/metadata/totp":
+			res.Write([]byte(`{"data":{"keys":["mail","denied","plain"]}}`))
+		case "/v1/secret/metadata/totp/mail":
+			res.Write([]byte(`{"data":{"custom_metadata":{"name":"Metadata Mail","icon":"envelope"}}}`))
+		case "/v1/secret/metadata/totp/denied":
+			http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		case "/v1/secret/metadata/totp/plain":
+			res.Write([]byte(`{"data":{"custom_metadata":null}}`))
+		case "/v1/secret/data/totp/mail":
+			res.Write([]byte(`{"data":{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"},"metadata":{}}}`))
+		case "/v1/secret/data/totp/denied":
+			res.Write([]byte(`{"data":{"data":{"name":"Denied","secret":"JBSWY3DPEHPK3PXP","icon":"lock"},"metadata":{}}}`))
+		case "/v1/secret/data/totp/plain":
+			res.Write([]byte(`{"data":{"data":{"name":"Plain","secret":"JBSWY3DPEHPK3PXP"},"metadata":{}}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name          string
+		customMeta    bool
+		serial        bool
+		wantIcons     map[string]string
+		wantMetaReads int32
+	}{
+		{
+			name:      "disabled",
+			wantIcons: map[string]string{"Mail": "key", "Denied": "lock", "Plain": "key"},
+		},
+		{
+			name:          "enabled",
+			customMeta:    true,
+			wantIcons:     map[string]string{"Mail": "envelope", "Denied": "lock", "Plain": "key"},
+			wantMetaReads: 3,
+		},
+		{
+			name:          "enabled serial",
+			customMeta:    true,
+			serial:        true,
+			wantIcons:     map[string]string{"Mail": "envelope", "Denied": "lock", "Plain": "key"},
+			wantMetaReads: 3,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = "secret/totp"
+			cfg.Vault.KV2Mount = "secret"
+			cfg.Vault.KV2CustomMeta = c.customMeta
+			cfg.Vault.Serial = c.serial
+
+			atomic.StoreInt32(&metaReads, 0)
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			// The name of the secret takes precedence over the metadata
+			icons := map[string]string{}
+			for _, tok := range res.Tokens {
+				icons[tok.Name] = tok.Icon
+			}
+			if !reflect.DeepEqual(icons, c.wantIcons) {
+				t.Errorf("Expected icons %v, got %v", c.wantIcons, icons)
+			}
+			if n := atomic.LoadInt32(&metaReads); n != c.wantMetaReads {
+				t.Errorf("Expected %d metadata reads, got %d", c.wantMetaReads, n)
+			}
+			// Unreadable metadata does not keep the secret from being displayed
+			if len(res.Failures) != 0 {
+				t.Errorf("Expected no failures, got %+v", res.Failures)
+			}
+		})
+	}
+}
+
+func TestScanVaultReportNoFields(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","userdata","backup"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/userdata":
+			res.Write([]byte(`{"data":{"username":"jdoe"}}`))
+		case r.URL.Path == "/v1/totp/backup":
+			res.Write([]byte(`{"data":{"name":"Backup","recovery_codes":"abc-123 def-456"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, report := range []bool{false, true} {
+		t.Run(strconv.FormatBool(report), func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = "totp"
+			cfg.Vault.ReportNoFields = report
+
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			// Recovery codes are a code source of their own
+			if len(res.Tokens) != 2 {
+				t.Errorf("Expected the Mail and Backup tokens, got %+v", res.Tokens)
+			}
+
+			want := []scanFailure{}
+			if report {
+				want = []scanFailure{{Name: "totp/userdata", Path: "totp/userdata", Error: "No OTP fields found"}}
+			}
+			if len(res.Failures) != len(want) || (len(want) > 0 && !reflect.DeepEqual(res.Failures, want)) {
+				t.Errorf("Expected failures %+v, got %+v", want, res.Failures)
+			}
+		})
+	}
+}
+
+func TestScanVaultCodeIssuers(t *testing.T) {
+	var sharedReads int32
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","chat","stored","ref","backup","plain"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","issuer":"github","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/chat":
+			res.Write([]byte(`{"data":{"name":"Chat","issuer":"GitLab","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/totp/stored":
+			res.Write([]byte(`{"data":{"name":"Stored","issuer":"GitLab","code":"123456"}}`))
+		case r.URL.Path == "/v1/totp/ref":
+			res.Write([]byte(`{"data":{"name":"Ref","issuer":"GitLab","secret_ref":"shared/key"}}`))
+		case r.URL.Path == "/v1/totp/backup":
+			res.Write([]byte(`{"data":{"name":"Backup","issuer":"GitLab","recovery_codes":"abc-123 def-456"}}`))
+		case r.URL.Path == "/v1/totp/plain":
+			res.Write([]byte(`{"data":{"name":"Plain","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case r.URL.Path == "/v1/shared/key":
+			atomic.AddInt32(&sharedReads, 1)
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.CodeMode = codeModeStatic
+	cfg.Vault.SecretRefDepth = 1
+
+	for _, c := range []struct {
+		name             string
+		issuers          []string
+		wantMetadataOnly []string
+		wantSharedReads  int32
+	}{
+		{name: "all issuers", wantSharedReads: 1},
+		{
+			name:             "restricted",
+			issuers:          []string{"GitHub"},
+			wantMetadataOnly: []string{"Backup", "Chat", "Plain", "Ref", "Stored"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.CodeIssuers = c.issuers
+
+			atomic.StoreInt32(&sharedReads, 0)
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if len(res.Tokens) != 6 || len(res.Failures) != 0 {
+				t.Fatalf("Expected all tokens without failures, got %+v / %+v", res.Tokens, res.Failures)
+			}
+
+			var metadataOnly []string
+			for _, tok := range res.Tokens {
+				if !tok.MetadataOnly {
+					if tok.Code == "" && len(tok.RecoveryCodes) == 0 {
+						t.Errorf("Expected a code for %q, got %+v", tok.Name, tok)
+					}
+					continue
+				}
+
+				metadataOnly = append(metadataOnly, tok.Name)
+				// Nothing to derive a code from must be left in the token
+				if tok.Code != "" || tok.Secret != "" || tok.StoredCode != "" || tok.RecoveryCodes != nil {
+					t.Errorf("Expected no code material for %q, got %+v", tok.Name, tok)
+				}
+			}
+			sort.Strings(metadataOnly)
+			if !reflect.DeepEqual(metadataOnly, c.wantMetadataOnly) {
+				t.Errorf("Expected metadata only tokens %v, got %v", c.wantMetadataOnly, metadataOnly)
+			}
+
+			// References are not followed for tokens without codes
+			if n := atomic.LoadInt32(&sharedReads); n != c.wantSharedReads {
+				t.Errorf("Expected %d reads of the referenced key, got %d", c.wantSharedReads, n)
+			}
+		})
+	}
+}
+
+func TestScanVaultMalformedSecrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/totp", "/v1/secret/metadata/totp":
+			res.Write([]byte(`{"data":{"keys":["mail","list"]}}`))
+		case "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case "/v1/totp/list":
+			// Does not even parse into a secret
+			res.Write([]byte(`{"data":["JBSWY3DPEHPK3PXP"]}`))
+		case "/v1/secret/data/totp/mail":
+			res.Write([]byte(`{"data":{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"},"metadata":{}}}`))
+		case "/v1/secret/data/totp/list":
+			res.Write([]byte(`{"data":{"data":["JBSWY3DPEHPK3PXP"],"metadata":{}}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name   string
+		prefix string
+		mount  string
+		report bool
+	}{
+		{name: "KV v1", prefix: "totp"},
+		{name: "KV v1 reported", prefix: "totp", report: true},
+		{name: "KV v2", prefix: "secret/totp", mount: "secret"},
+		{name: "KV v2 reported", prefix: "secret/totp", mount: "secret", report: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = c.prefix
+			cfg.Vault.KV2Mount = c.mount
+			cfg.Vault.ReportMalformed = c.report
+
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if len(res.Tokens) != 1 || res.Tokens[0].Name != "Mail" {
+				t.Errorf("Expected only the Mail token, got %+v", res.Tokens)
+			}
+
+			want := []scanFailure{}
+			if c.report {
+				key := c.prefix + "/list"
+				want = []scanFailure{{Name: key, Path: key, Error: errMalformedSecret.Error()}}
+			}
+			if len(res.Failures) != len(want) || (len(want) > 0 && !reflect.DeepEqual(res.Failures, want)) {
+				t.Errorf("Expected failures %+v, got %+v", want, res.Failures)
+			}
+		})
+	}
+}
+
+func TestScanVaultSources(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/totp":
+			res.Write([]byte(`{"data":{"keys":["mail"]}}`))
+		case "/v1/partial":
+			res.Write([]byte(`{"data":{"keys":["mail","broken"]}}`))
+		case "/v1/cubbyhole/totp":
+			res.Write([]byte(`{"data":{"keys":["chat"]}}`))
+		case "/v1/cubbyhole/denied":
+			http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		case "/v1/totp/mail", "/v1/partial/mail", "/v1/cubbyhole/totp/chat":
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		case "/v1/partial/broken":
+			res.Write([]byte(`{"data":{"secret":"not base32!"}}`))
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name      string
+		prefix    string
+		cubbyhole string
+		want      []sourceStatus
+		wantError string // Contained in the error of the last source
+	}{
+		{name: "prefix", prefix: "totp", want: []sourceStatus{{Source: "totp", Status: sourceStatusOK}}},
+		{name: "failures", prefix: "partial", want: []sourceStatus{{Source: "partial", Status: sourceStatusPartial}}},
+		{
+			name:      "cubbyhole",
+			prefix:    "totp",
+			cubbyhole: "/cubbyhole/totp/",
+			want:      []sourceStatus{{Source: "totp", Status: sourceStatusOK}, {Source: "cubbyhole/totp", Status: sourceStatusOK}},
+		},
+		{
+			// Users without secrets of their own have no cubbyhole prefix
+			name:      "missing cubbyhole",
+			prefix:    "totp",
+			cubbyhole: "cubbyhole/missing",
+			want:      []sourceStatus{{Source: "totp", Status: sourceStatusOK}, {Source: "cubbyhole/missing", Status: sourceStatusOK}},
+		},
+		{
+			name:      "failing cubbyhole",
+			prefix:    "totp",
+			cubbyhole: "cubbyhole/denied",
+			want:      []sourceStatus{{Source: "totp", Status: sourceStatusOK}, {Source: "cubbyhole/denied", Status: sourceStatusError}},
+			wantError: "permission denied",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = c.prefix
+			cfg.Vault.CubbyholePrefix = c.cubbyhole
+
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			got := append([]sourceStatus{}, res.Sources...)
+			if n := len(got); n > 0 {
+				if !strings.Contains(got[n-1].Error, c.wantError) || (c.wantError == "") != (got[n-1].Error == "") {
+					t.Errorf("Expected error %q for the last source, got %q", c.wantError, got[n-1].Error)
+				}
+				got[n-1].Error = ""
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Expected sources %+v, got %+v", c.want, res.Sources)
+			}
+		})
+	}
+}
+
+func TestScanOperationConcurrency(t *testing.T) {
+	var lists, maxLists, reads, maxReads, all, maxAll int32
+	track := func(cur, max *int32) func() {
+		n := atomic.AddInt32(cur, 1)
+		for {
+			m := atomic.LoadInt32(max)
+			if n <= m || atomic.CompareAndSwapInt32(max, m, n) {
+				break
+			}
+		}
+		return func() { atomic.AddInt32(cur, -1) }
+	}
+
+	rootKeys := []string{"a/", "b/", "c/", "d/"}
+	for i := 0; i < 10; i++ {
+		rootKeys = append(rootKeys, "key"+strconv.Itoa(i))
+	}
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		defer track(&all, &maxAll)()
+		if r.URL.Query().Get("list") == "true" {
+			defer track(&lists, &maxLists)()
+		} else {
+			defer track(&reads, &maxReads)()
+		}
+		// Give concurrent requests the chance to overlap
+		time.Sleep(10 * time.Millisecond)
+
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/totp":
+			json.NewEncoder(res).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": rootKeys}})
+		case "/v1/totp/a", "/v1/totp/b", "/v1/totp/c", "/v1/totp/d":
+			res.Write([]byte(`{"data":{"keys":["x","y","z"]}}`))
+		default:
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name                string
+		concurrency         int
+		maxList, maxRead    int
+		wantLists, wantRead int32 // Upper bounds of the concurrent operations
+		wantAll             int32
+	}{
+		{name: "overall only", concurrency: 3, wantLists: 3, wantRead: 3, wantAll: 3},
+		{name: "list limit", concurrency: 10, maxList: 1, wantLists: 1, wantRead: 10, wantAll: 10},
+		{name: "read limit", concurrency: 10, maxRead: 2, wantLists: 10, wantRead: 2, wantAll: 10},
+		{name: "both limits", concurrency: 10, maxList: 1, maxRead: 2, wantLists: 1, wantRead: 2, wantAll: 3},
+		// The separate limits never exceed the overall concurrency
+		{name: "limits above overall", concurrency: 2, maxList: 5, maxRead: 5, wantLists: 2, wantRead: 2, wantAll: 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = "totp"
+			cfg.Vault.Concurrency = c.concurrency
+			cfg.Vault.MaxListConcurrency = c.maxList
+			cfg.Vault.MaxReadConcurrency = c.maxRead
+
+			for _, v := range []*int32{&maxLists, &maxReads, &maxAll} {
+				atomic.StoreInt32(v, 0)
+			}
+
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if len(res.Tokens) != 22 {
+				t.Errorf("Expected 22 tokens, got %d", len(res.Tokens))
+			}
+
+			if m := atomic.LoadInt32(&maxLists); m > c.wantLists {
+				t.Errorf("Expected at most %d concurrent lists, got %d", c.wantLists, m)
+			}
+			// All cases allow at least two reads at once
+			if m := atomic.LoadInt32(&maxReads); m > c.wantRead || m < 2 {
+				t.Errorf("Expected 2 to %d concurrent reads, got %d", c.wantRead, m)
+			}
+			if m := atomic.LoadInt32(&maxAll); m > c.wantAll {
+				t.Errorf("Expected at most %d concurrent operations, got %d", c.wantAll, m)
+			}
+		})
+	}
+}
+
+func TestScanVaultSortedScan(t *testing.T) {
+	var (
+		requests     []string
+		requestsLock sync.Mutex
+		inFlight     int32
+		maxInFlight  int32
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		// Give concurrent requests the chance to overlap
+		time.Sleep(time.Millisecond)
+
+		requestsLock.Lock()
+		requests = append(requests, strings.TrimPrefix(r.URL.Path, "/v1/"))
+		requestsLock.Unlock()
+
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/totp" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","chat/","bank","alpha"]}}`))
+		case r.URL.Path == "/v1/totp/chat" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["zulip","irc"]}}`))
+		case strings.HasPrefix(r.URL.Path, "/v1/totp/"):
+			fmt.Fprintf(res, `{"data":{"name":%q,"secret":"JBSWY3DPEHPK3PXP"}}`, path.Base(r.URL.Path))
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name          string
+		sorted        bool
+		maxOperations int64
+		wantNames     []string
+		wantRequests  []string
+		wantTruncated bool
+	}{
+		{
+			name:         "sorted",
+			sorted:       true,
+			wantNames:    []string{"alpha", "bank", "irc", "mail", "zulip"},
+			wantRequests: []string{"totp", "totp/alpha", "totp/bank", "totp/chat", "totp/chat/irc", "totp/chat/zulip", "totp/mail"},
+		},
+		{
+			name:          "sorted within budget",
+			sorted:        true,
+			maxOperations: 3,
+			wantNames:     []string{"alpha", "bank"},
+			wantRequests:  []string{"totp", "totp/alpha", "totp/bank"},
+			wantTruncated: true,
+		},
+		{
+			name:          "sorted into folder within budget",
+			sorted:        true,
+			maxOperations: 5,
+			wantNames:     []string{"alpha", "bank", "irc"},
+			wantRequests:  []string{"totp", "totp/alpha", "totp/bank", "totp/chat", "totp/chat/irc"},
+			wantTruncated: true,
+		},
+		{
+			name:      "unsorted",
+			wantNames: []string{"alpha", "bank", "irc", "mail", "zulip"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = "totp"
+			cfg.Vault.SortedScan = c.sorted
+			cfg.Vault.MaxOperations = c.maxOperations
+			cfg.Vault.ListBatchSize = 2
+
+			requestsLock.Lock()
+			requests = nil
+			requestsLock.Unlock()
+			atomic.StoreInt32(&maxInFlight, 0)
+
+			res, err := scanVault(context.Background(), "s.user", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			names := []string{}
+			for _, tok := range res.Tokens {
+				names = append(names, tok.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Errorf("Expected tokens %v, got %v", c.wantNames, names)
+			}
+			if res.Truncated != c.wantTruncated {
+				t.Errorf("Expected truncated %v, got %v", c.wantTruncated, res.Truncated)
+			}
+
+			if !c.sorted {
+				return
+			}
+
+			requestsLock.Lock()
+			defer requestsLock.Unlock()
+			if !reflect.DeepEqual(requests, c.wantRequests) {
+				t.Errorf("Expected requests %v, got %v", c.wantRequests, requests)
+			}
+			if m := atomic.LoadInt32(&maxInFlight); m != 1 {
+				t.Errorf("Expected the keys to be processed one by one, got %d concurrent requests", m)
+			}
+		})
+	}
+}
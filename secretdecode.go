@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// secretDecoders contains the strategies to decode secrets with, they
+// return the raw key bytes
+var secretDecoders = map[string]func(string) ([]byte, error){
+	"base32":        base32.StdEncoding.DecodeString,
+	"base32-no-pad": base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString,
+	"hex":           hex.DecodeString,
+}
+
+func validateSecretDecoders(in []string) error {
+	for _, d := range in {
+		if _, ok := secretDecoders[d]; !ok {
+			return errors.Errorf("Unknown secret decoder %q", d)
+		}
+	}
+
+	return nil
+}
+
+// decodeSecret tries the configured decoders in order and returns the
+// secret re-encoded as base32 using the first decoder yielding a key.
+// Without decoders configured the secret is returned unchanged.
+func decodeSecret(ctx context.Context, key, secret string) string {
+	if len(cfg.Vault.SecretDecoders) == 0 || secret == "" {
+		return secret
+	}
+
+	// Secrets are frequently stored in groups separated by spaces
+	clean := strings.Join(strings.Fields(secret), "")
+
+	for _, name := range cfg.Vault.SecretDecoders {
+		input := clean
+		if name != "hex" {
+			input = strings.ToUpper(input)
+		}
+
+		raw, err := secretDecoders[name](input)
+		if err != nil || len(raw) == 0 {
+			continue
+		}
+
+		logger(ctx).WithFields(log.Fields{"key": key, "decoder": name}).Debug("Decoded secret")
+		return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+	}
+
+	logger(ctx).WithField("key", key).Warn("None of the secret decoders was able to decode the secret")
+	return secret
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestDecodeSecret(t *testing.T) {
+	oldCfg, oldOut, oldLevel := cfg, log.StandardLogger().Out, log.GetLevel()
+	defer func() {
+		cfg = oldCfg
+		log.SetOutput(oldOut)
+		log.SetLevel(oldLevel)
+	}()
+	log.SetLevel(log.DebugLevel)
+
+	chain := []string{"base32", "base32-no-pad", "hex"}
+
+	// All secrets encode the key "1234"
+	for _, c := range []struct {
+		name        string
+		decoders    []string
+		secret      string
+		want        string
+		wantDecoder string
+	}{
+		{name: "base32", decoders: chain, secret: "GEZDGNA=", want: "GEZDGNA", wantDecoder: "base32"},
+		{name: "base32 grouped lowercase", decoders: chain, secret: "gezd gna=", want: "GEZDGNA", wantDecoder: "base32"},
+		{name: "base32 without padding", decoders: chain, secret: "GEZDGNA", want: "GEZDGNA", wantDecoder: "base32-no-pad"},
+		{name: "hex", decoders: chain, secret: "31323334", want: "GEZDGNA", wantDecoder: "hex"},
+		{name: "hex grouped", decoders: chain, secret: "3132 3334", want: "GEZDGNA", wantDecoder: "hex"},
+		// Valid in both encodings, the order of the chain decides
+		{name: "base32 before hex", decoders: []string{"base32", "hex"}, secret: "ABCDEF23", want: "ABCDEF23", wantDecoder: "base32"},
+		{name: "hex before base32", decoders: []string{"hex", "base32"}, secret: "ABCDEF23", want: "VPG66IY", wantDecoder: "hex"},
+		{name: "no decoder matching", decoders: []string{"hex"}, secret: "GEZDGNA=", want: "GEZDGNA="},
+		{name: "no decoders", secret: "31323334", want: "31323334"},
+	} {
+		cfg.Vault.SecretDecoders = c.decoders
+		buf := new(bytes.Buffer)
+		log.SetOutput(buf)
+
+		if got := decodeSecret(context.Background(), "totp/mail", c.secret); got != c.want {
+			t.Errorf("%s: Expected secret %q, got %q", c.name, c.want, got)
+		}
+
+		switch {
+		case c.wantDecoder != "" && !strings.Contains(buf.String(), "decoder="+c.wantDecoder+" "):
+			t.Errorf("%s: Expected decoder %s to be logged, got %q", c.name, c.wantDecoder, buf.String())
+		case c.wantDecoder == "" && len(c.decoders) > 0 && !strings.Contains(buf.String(), "None of the secret decoders"):
+			t.Errorf("%s: Expected a warning about the undecodable secret, got %q", c.name, buf.String())
+		}
+	}
+}
+
+func TestDecodeSecretGeneratesCodes(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.SecretDecoders = []string{"base32", "hex"}
+
+	// RFC 6238 appendix B, T = 59 using the key as hex
+	tok := tokenFromData(context.Background(), "totp/mail", map[string]interface{}{
+		"secret": "3132333435363738393031323334353637383930",
+		"digits": "8",
+	})
+	if tok.Secret != rfc4226Secret {
+		t.Fatalf("Expected the secret %q, got %q", rfc4226Secret, tok.Secret)
+	}
+
+	code, err := totp.GenerateCodeCustom(tok.Secret, time.Unix(59, 0), totp.ValidateOpts{
+		Period: 30, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil || code != "94287082" {
+		t.Errorf("Expected code 94287082, got %q / %v", code, err)
+	}
+}
+
+func TestValidateSecretDecoders(t *testing.T) {
+	for _, c := range []struct {
+		in      []string
+		wantErr bool
+	}{
+		{in: nil},
+		{in: []string{"base32", "base32-no-pad", "hex"}},
+		{in: []string{"base64"}, wantErr: true},
+		{in: []string{"HEX"}, wantErr: true},
+	} {
+		if err := validateSecretDecoders(c.in); (err != nil) != c.wantErr {
+			t.Errorf("validateSecretDecoders(%q) = %v, expected error %v", c.in, err, c.wantErr)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+// secretRefField contains the key to read the secret from instead of
+// storing the secret itself (i.e. to rotate it at a central place)
+const secretRefField = "secret_ref"
+
+// keyReader reads a key from Vault, used to account the reads against
+// the limits of the operation reading them
+type keyReader func(key string) (*api.Secret, error)
+
+// secretRef returns the key referenced in the data, empty when there is
+// no reference or following references is disabled
+func secretRef(data map[string]interface{}) string {
+	if cfg.Vault.SecretRefDepth < 1 {
+		return ""
+	}
+
+	ref, _ := data[secretRefField].(string)
+	return ref
+}
+
+// resolveSecretRef follows the references starting at ref until a key
+// containing the secret is found. At most the configured depth of
+// references is followed and loops are detected.
+func resolveSecretRef(ctx context.Context, read keyReader, key, ref string) (string, error) {
+	seen := map[string]bool{strings.Trim(key, "/"): true}
+
+	for depth := 0; depth < cfg.Vault.SecretRefDepth; depth++ {
+		ref = strings.Trim(ref, "/")
+		if seen[ref] {
+			return "", errors.Errorf("Reference loop detected at key %q", ref)
+		}
+		seen[ref] = true
+
+		sec, err := read(ref)
+		if err != nil {
+			return "", errors.Wrapf(err, "Unable to read referenced key %q", ref)
+		}
+
+		data, deleted := kvData(ref, sec)
+		if data == nil || deleted {
+			return "", errors.Errorf("Referenced key %q does not exist", ref)
+		}
+
+		if secret := lookupSecret(ctx, ref, data); secret != "" {
+			return secret, nil
+		}
+
+		next, _ := data[secretRefField].(string)
+		if next == "" {
+			return "", errors.Errorf("Referenced key %q contains no secret", ref)
+		}
+		ref = next
+	}
+
+	return "", errors.Errorf("Secret not found within %d references", cfg.Vault.SecretRefDepth)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+func TestResolveSecretRef(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.SecretField = "secret"
+
+	keys := map[string]map[string]interface{}{
+		"seeds/github":  {"secret": "JBSWY3DPEHPK3PXP"},
+		"seeds/chained": {"secret_ref": "seeds/github"},
+		"seeds/longer":  {"secret_ref": "seeds/chained"},
+		"seeds/loop-a":  {"secret_ref": "seeds/loop-b"},
+		"seeds/loop-b":  {"secret_ref": "/seeds/loop-a/"},
+		"seeds/self":    {"secret_ref": "seeds/self"},
+		"seeds/empty":   {"name": "Empty"},
+		"seeds/back":    {"secret_ref": "totp/mail"},
+	}
+
+	var reads []string
+	read := func(key string) (*api.Secret, error) {
+		reads = append(reads, key)
+		if key == "seeds/broken" {
+			return nil, errors.New("permission denied")
+		}
+		data, ok := keys[key]
+		if !ok {
+			return nil, nil
+		}
+		return &api.Secret{Data: data}, nil
+	}
+
+	for _, c := range []struct {
+		name      string
+		ref       string
+		depth     int
+		want      string
+		wantErr   string
+		wantReads int
+	}{
+		{name: "one hop", ref: "seeds/github", depth: 3, want: "JBSWY3DPEHPK3PXP", wantReads: 1},
+		{name: "surrounding slashes", ref: "/seeds/github/", depth: 3, want: "JBSWY3DPEHPK3PXP", wantReads: 1},
+		{name: "two hops", ref: "seeds/chained", depth: 3, want: "JBSWY3DPEHPK3PXP", wantReads: 2},
+		{name: "depth exceeded", ref: "seeds/longer", depth: 2, wantErr: "Secret not found within 2 references", wantReads: 2},
+		{name: "depth reached", ref: "seeds/longer", depth: 3, want: "JBSWY3DPEHPK3PXP", wantReads: 3},
+		{name: "loop", ref: "seeds/loop-a", depth: 10, wantErr: `Reference loop detected at key "seeds/loop-a"`, wantReads: 2},
+		{name: "self reference", ref: "seeds/self", depth: 10, wantErr: `Reference loop detected at key "seeds/self"`, wantReads: 1},
+		{name: "back to the referencing key", ref: "seeds/back", depth: 10, wantErr: `Reference loop detected at key "totp/mail"`, wantReads: 1},
+		{name: "missing key", ref: "seeds/missing", depth: 3, wantErr: `Referenced key "seeds/missing" does not exist`, wantReads: 1},
+		{name: "no secret", ref: "seeds/empty", depth: 3, wantErr: `Referenced key "seeds/empty" contains no secret`, wantReads: 1},
+		{name: "read error", ref: "seeds/broken", depth: 3, wantErr: `Unable to read referenced key "seeds/broken": permission denied`, wantReads: 1},
+	} {
+		cfg.Vault.SecretRefDepth = c.depth
+		reads = nil
+
+		got, err := resolveSecretRef(context.Background(), read, "totp/mail", c.ref)
+		switch {
+		case c.wantErr == "" && err != nil:
+			t.Errorf("%s: Unexpected error: %s", c.name, err)
+		case c.wantErr != "" && (err == nil || err.Error() != c.wantErr):
+			t.Errorf("%s: Expected error %q, got %v", c.name, c.wantErr, err)
+		case got != c.want:
+			t.Errorf("%s: Expected secret %q, got %q", c.name, c.want, got)
+		}
+		if len(reads) != c.wantReads {
+			t.Errorf("%s: Expected %d reads, got %v", c.name, c.wantReads, reads)
+		}
+	}
+}
+
+func TestSecretRef(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		depth int
+		data  map[string]interface{}
+		want  string
+	}{
+		{depth: 3, data: map[string]interface{}{"secret_ref": "seeds/github"}, want: "seeds/github"},
+		{depth: 0, data: map[string]interface{}{"secret_ref": "seeds/github"}},
+		{depth: 3, data: map[string]interface{}{"secret_ref": 42}},
+		{depth: 3, data: map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}},
+	} {
+		cfg.Vault.SecretRefDepth = c.depth
+		if got := secretRef(c.data); got != c.want {
+			t.Errorf("depth %d, data %v: Expected %q, got %q", c.depth, c.data, c.want, got)
+		}
+	}
+}
+
+func TestScanVaultSecretRef(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/totp" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","chat","looping"]}}`))
+		case r.URL.Path == "/v1/totp/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret_ref":"seeds/github"}}`))
+		case r.URL.Path == "/v1/totp/chat":
+			res.Write([]byte(`{"data":{"name":"Chat","secret_ref":"seeds/missing"}}`))
+		case r.URL.Path == "/v1/totp/looping":
+			res.Write([]byte(`{"data":{"name":"Looping","secret_ref":"totp/looping"}}`))
+		case r.URL.Path == "/v1/seeds/github":
+			res.Write([]byte(`{"data":{"secret":"JBSWY3DPEHPK3PXP"}}`))
+		default:
+			// Vault answers reads of missing keys without errors
+			http.Error(res, `{"errors":[]}`, http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Vault.Prefix = "totp"
+	cfg.Vault.SecretRefDepth = 3
+
+	res, err := scanVault(context.Background(), "s.user", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(res.Tokens) != 1 || res.Tokens[0].Name != "Mail" || len(res.Tokens[0].Code) != 6 {
+		t.Fatalf("Expected the Mail token with a code, got %+v", res.Tokens)
+	}
+
+	failures := map[string]string{}
+	for _, f := range res.Failures {
+		failures[f.Path] = f.Error
+	}
+	for path, want := range map[string]string{
+		"totp/chat":    "does not exist",
+		"totp/looping": "Reference loop detected",
+	} {
+		if !strings.Contains(failures[path], want) {
+			t.Errorf("Expected failure %q for %s, got %+v", want, path, res.Failures)
+		}
+	}
+}
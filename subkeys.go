@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// useSubkeys checks whether the structure of the key is to be read
+// through the subkeys endpoint of KV v2 instead of reading the secret.
+// Neither codes nor the backup can be built without the values.
+func useSubkeys(ctx context.Context, k string) bool {
+	return cfg.Vault.KV2Subkeys && !codesWanted(ctx) && !rawFieldsWanted(ctx) && isKV2Key(k)
+}
+
+// fetchStructureFromKey reads only the names of the fields of the key
+// without their values (and by that without the secret) and adds a token
+// carrying the custom metadata of the key if it has one of the secret
+// fields. Keys storing other fields in the secret are read completely as
+// their values are required to build the token.
+func (s *secretScanner) fetchStructureFromKey(ctx context.Context, k string) {
+	logger(ctx).WithField("key", k).Debug("Reading subkeys of key")
+
+	s.acquire(s.readSlots)
+	sec, err := logicalRequest(ctx, s.client, kvPath(k, "subkeys"), false)
+	s.release(s.readSlots)
+
+	if scanAbandoned(ctx, err) {
+		return
+	}
+
+	if err != nil {
+		logger(ctx).Errorf("Unable to read subkeys of key %q: %s", k, err)
+		if s.singleKey {
+			s.setRootErr(err)
+		} else {
+			if errors.Cause(err) == errRateLimited {
+				// Still limited after all retries, needs to be visible
+				s.addFailure(&token{Name: k, Path: k}, err)
+			}
+			s.addSkipped(k, errKeyUnreadable)
+		}
+		return
+	}
+
+	// The response carries the metadata of the version like the read of
+	// the secret does
+	_, deleted := kvData(k, sec)
+	if deleted {
+		s.handleDeletedKey(ctx, k)
+		return
+	}
+
+	var subkeys map[string]interface{}
+	if sec != nil {
+		subkeys, _ = sec.Data["subkeys"].(map[string]interface{})
+	}
+
+	if subkeys == nil {
+		if s.singleKey {
+			s.setRootErr(errors.Errorf("There is no key %q", k))
+		}
+		return
+	}
+
+	if !hasSecretField(subkeys) {
+		logger(ctx).WithField("key", k).Debug("Skipping key without secret field")
+		return
+	}
+
+	if f, ok := valueField(subkeys); ok {
+		logger(ctx).WithFields(log.Fields{"key": k, "field": f}).Debug("Key stores fields besides the secret, reading it")
+		if s.takeOperation(ctx) {
+			s.readTokenFromKey(ctx, k)
+		}
+		return
+	}
+
+	tok, err := buildToken(ctx, k, <-s.fetchCustomMetadata(ctx, k), buildOptions{
+		Created:   kvCreatedTime(k, sec),
+		Folder:    s.folderOf(k),
+		Structure: true,
+	})
+	if err != nil {
+		if handleBuildError(ctx, k, err) {
+			s.addFailure(tok, err)
+		}
+		s.addSkipped(k, err)
+		return
+	}
+
+	if tok != nil {
+		s.addToken(tok)
+	}
+}
+
+// valueField returns a field of the subkeys whose value is required to
+// build the token: Everything besides the secret fields (and the secret
+// reference) is read from the secret and takes precedence over the custom
+// metadata, fields shared with the name or the required fields included.
+func valueField(subkeys map[string]interface{}) (string, bool) {
+	structural := map[string]bool{secretRefField: true}
+	for _, f := range secretFields() {
+		structural[topLevelField(f)] = true
+	}
+
+	for _, f := range cfg.Vault.NameFields {
+		delete(structural, topLevelField(f))
+	}
+	for _, r := range requiredFields {
+		delete(structural, topLevelField(r.Field))
+	}
+
+	fields := make([]string, 0, len(subkeys))
+	for f := range subkeys {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	for _, f := range fields {
+		if !structural[f] {
+			return f, true
+		}
+	}
+
+	return "", false
+}
+
+// topLevelField returns the field of the secret data a (dotted) field
+// path starts at
+func topLevelField(field string) string {
+	return strings.SplitN(field, ".", 2)[0]
+}
+
+// hasSecretField checks whether one of the secret fields (or a secret
+// reference) is present in the subkeys of a secret
+func hasSecretField(subkeys map[string]interface{}) bool {
+	fields := secretFields()
+	if cfg.Vault.SecretRefDepth > 0 {
+		fields = append(fields, secretRefField)
+	}
+
+	for _, f := range fields {
+		if _, ok := lookupField(subkeys, f); ok {
+			return true
+		}
+	}
+
+	return false
+}
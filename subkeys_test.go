@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestSubkeysCallers(t *testing.T) {
+	type kv2Key struct {
+		data, meta map[string]interface{}
+	}
+
+	// meta keeps all fields in the custom metadata, stored and mixed keep
+	// (some of) them in the secret
+	keys := map[string]kv2Key{
+		"meta": {
+			data: map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"},
+			meta: map[string]interface{}{"name": "Meta", "issuer": "GitHub", "env": "prod"},
+		},
+		"stored": {
+			data: map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP", "name": "Stored", "issuer": "GitLab", "env": "prod", "period": "60"},
+		},
+		"mixed": {
+			data: map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP", "env": "dev"},
+			meta: map[string]interface{}{"name": "Mixed", "issuer": "GitHub"},
+		},
+	}
+
+	var (
+		reads     = map[string]int{}
+		readsLock sync.Mutex
+	)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		meta := map[string]interface{}{"created_time": "2020-01-01T00:00:00Z", "version": 1}
+
+		switch p := r.URL.Path; {
+		case p == "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case p == "/v1/secret/metadata/totp" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["meta","stored","mixed"]}}`))
+		case strings.HasPrefix(p, "/v1/secret/subkeys/totp/"):
+			subkeys := map[string]interface{}{}
+			for f := range keys[strings.TrimPrefix(p, "/v1/secret/subkeys/totp/")].data {
+				subkeys[f] = nil
+			}
+			json.NewEncoder(res).Encode(map[string]interface{}{"data": map[string]interface{}{"subkeys": subkeys, "metadata": meta}})
+		case strings.HasPrefix(p, "/v1/secret/metadata/totp/"):
+			k := keys[strings.TrimPrefix(p, "/v1/secret/metadata/totp/")]
+			json.NewEncoder(res).Encode(map[string]interface{}{"data": map[string]interface{}{"custom_metadata": k.meta}})
+		case strings.HasPrefix(p, "/v1/secret/data/totp/"):
+			name := strings.TrimPrefix(p, "/v1/secret/data/totp/")
+			readsLock.Lock()
+			reads[name]++
+			readsLock.Unlock()
+			json.NewEncoder(res).Encode(map[string]interface{}{"data": map[string]interface{}{"data": keys[name].data, "metadata": meta}})
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	request := func(method, url string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, nil)
+		r.RemoteAddr = "127.0.0.1:42424"
+		r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+		res := httptest.NewRecorder()
+
+		if strings.HasPrefix(url, "/issuers.json") {
+			handleIssuers(res, r)
+		} else {
+			handleCodesJSON(res, r)
+		}
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s %s: Expected status 200, got %d: %s", method, url, res.Code, res.Body.String())
+		}
+		return res
+	}
+
+	for _, c := range []struct {
+		name        string
+		args        []string
+		wantIssuers []issuerCount
+		wantTokens  string
+		wantReads   map[string]int // Reads of the secrets by the HEAD and issuers requests
+	}{
+		{
+			name:        "full reads",
+			wantIssuers: []issuerCount{{Issuer: "GitHub", Count: 2}, {Issuer: "GitLab", Count: 1}},
+			wantTokens:  "3",
+			wantReads:   map[string]int{"meta": 2, "stored": 2, "mixed": 2},
+		},
+		{
+			name:        "subkeys",
+			args:        []string{"--vault-kv2-subkeys"},
+			wantIssuers: []issuerCount{{Issuer: "GitHub", Count: 2}, {Issuer: "GitLab", Count: 1}},
+			wantTokens:  "3",
+			wantReads:   map[string]int{"stored": 2, "mixed": 2},
+		},
+		{
+			name:        "full reads with required fields",
+			args:        []string{"--vault-require-fields", "env=prod"},
+			wantIssuers: []issuerCount{{Issuer: "GitHub", Count: 1}, {Issuer: "GitLab", Count: 1}},
+			wantTokens:  "2",
+			wantReads:   map[string]int{"meta": 2, "stored": 2, "mixed": 2},
+		},
+		{
+			name:        "subkeys with required fields",
+			args:        []string{"--vault-kv2-subkeys", "--vault-require-fields", "env=prod"},
+			wantIssuers: []issuerCount{{Issuer: "GitHub", Count: 1}, {Issuer: "GitLab", Count: 1}},
+			wantTokens:  "2",
+			wantReads:   map[string]int{"stored": 2, "mixed": 2},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, append([]string{
+				"--vault-addr", vault.URL, "--vault-prefix", "secret/totp",
+				"--vault-kv2-mount", "secret", "--vault-kv2-custom-metadata",
+				"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+			}, c.args...), nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				oldStore := cookieStore
+				defer func() { cookieStore = oldStore }()
+				cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+				get := request(http.MethodGet, "/codes.json")
+
+				readsLock.Lock()
+				reads = map[string]int{}
+				readsLock.Unlock()
+
+				head := request(http.MethodHead, "/codes.json")
+				if head.Body.Len() != 0 {
+					t.Errorf("Expected no body for HEAD, got %q", head.Body.String())
+				}
+				for _, h := range []string{"X-Token-Count", "ETag"} {
+					if head.Header().Get(h) != get.Header().Get(h) {
+						t.Errorf("Header %s of HEAD (%q) differs from GET (%q)", h, head.Header().Get(h), get.Header().Get(h))
+					}
+				}
+				if n := get.Header().Get("X-Token-Count"); n != c.wantTokens {
+					t.Errorf("Expected %s tokens, got %s", c.wantTokens, n)
+				}
+
+				var issuers struct {
+					Issuers []issuerCount `json:"issuers"`
+				}
+				if err := json.NewDecoder(request(http.MethodGet, "/issuers.json").Body).Decode(&issuers); err != nil {
+					t.Fatalf("Unable to decode issuers: %s", err)
+				}
+				if !reflect.DeepEqual(issuers.Issuers, c.wantIssuers) {
+					t.Errorf("Expected issuers %+v, got %+v", c.wantIssuers, issuers.Issuers)
+				}
+
+				readsLock.Lock()
+				defer readsLock.Unlock()
+				if !reflect.DeepEqual(reads, c.wantReads) {
+					t.Errorf("Expected secret reads %v, got %v", c.wantReads, reads)
+				}
+			})
+		})
+	}
+}
+
+func TestScanVaultSubkeys(t *testing.T) {
+	// Subkeys and custom metadata of the keys, the secrets themselves all
+	// read as "Read" to tell them apart from the structure
+	keys := map[string]struct{ subkeys, meta string }{
+		"mail":     {subkeys: `{"secret":null}`, meta: `{"name":"Mail","issuer":"GitHub"}`},
+		"stored":   {subkeys: `{"secret":null,"issuer":null}`, meta: `{"name":"Stored"}`},
+		"alias":    {subkeys: `{"totp_secret":null}`, meta: `{"name":"Alias"}`},
+		"nested":   {subkeys: `{"mfa":{"seed":null}}`, meta: `{"name":"Nested"}`},
+		"ref":      {subkeys: `{"secret_ref":null}`, meta: `{"name":"Ref"}`},
+		"userdata": {subkeys: `{"username":null}`, meta: `{"name":"Userdata"}`},
+		"html":     {subkeys: `{"secret":null}`, meta: `{"name":"<b>HTML</b>"}`},
+	}
+
+	var subkeyReads, dataReads int32
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+
+		switch p := r.URL.Path; {
+		case p == "/v1/secret/metadata/totp" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","stored","alias","nested","ref","userdata","html","deleted","denied"]}}`))
+		case p == "/v1/secret/subkeys/totp/deleted":
+			atomic.AddInt32(&subkeyReads, 1)
+			res.Write([]byte(`{"data":{"subkeys":null,"metadata":{"deletion_time":"2020-01-02T00:00:00Z","version":2}}}`))
+		case p == "/v1/secret/subkeys/totp/denied":
+			atomic.AddInt32(&subkeyReads, 1)
+			res.WriteHeader(http.StatusForbidden)
+			res.Write([]byte(`{"errors":["permission denied"]}`))
+		case strings.HasPrefix(p, "/v1/secret/subkeys/totp/"):
+			atomic.AddInt32(&subkeyReads, 1)
+			k, ok := keys[strings.TrimPrefix(p, "/v1/secret/subkeys/totp/")]
+			if !ok {
+				res.WriteHeader(http.StatusNotFound)
+				res.Write([]byte(`{"errors":[]}`))
+				return
+			}
+			res.Write([]byte(`{"data":{"subkeys":` + k.subkeys + `,"metadata":{"created_time":"2020-01-01T00:00:00Z","version":1}}}`))
+		case strings.HasPrefix(p, "/v1/secret/metadata/totp/"):
+			k := keys[strings.TrimPrefix(p, "/v1/secret/metadata/totp/")]
+			if k.meta == "" {
+				k.meta = "null"
+			}
+			res.Write([]byte(`{"data":{"custom_metadata":` + k.meta + `}}`))
+		case strings.HasPrefix(p, "/v1/secret/data/totp/"):
+			atomic.AddInt32(&dataReads, 1)
+			res.Write([]byte(`{"data":{"data":{"name":"Read","secret":"JBSWY3DPEHPK3PXP"},"metadata":{"version":1}}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	for _, c := range []struct {
+		name         string
+		withCodes    bool
+		disabled     bool
+		prefix       string
+		secretField  string
+		secretRefs   bool
+		showDeleted  bool
+		singleKey    bool
+		wantErr      string
+		wantNames    []string
+		wantFailures []string
+		wantSubkeys  bool
+		wantReads    int32 // Secrets read for their stored fields
+	}{
+		{
+			name:         "structure only",
+			wantNames:    []string{"Alias", "Mail", "Read"},
+			wantFailures: []string{"secret/totp/html"},
+			wantSubkeys:  true,
+			wantReads:    1,
+		},
+		{
+			name:         "nested secret field",
+			secretField:  "mfa.seed",
+			wantNames:    []string{"Alias", "Nested"},
+			wantFailures: []string{},
+			wantSubkeys:  true,
+		},
+		{
+			name:         "secret references",
+			secretRefs:   true,
+			wantNames:    []string{"Alias", "Mail", "Read", "Ref"},
+			wantFailures: []string{"secret/totp/html"},
+			wantSubkeys:  true,
+			wantReads:    1,
+		},
+		{
+			name:         "deleted shown",
+			showDeleted:  true,
+			wantNames:    []string{"Alias", "Mail", "Read", "secret/totp/deleted"},
+			wantFailures: []string{"secret/totp/html"},
+			wantSubkeys:  true,
+			wantReads:    1,
+		},
+		{name: "codes wanted", withCodes: true},
+		{name: "disabled", disabled: true},
+		{name: "single key", prefix: "secret/totp/mail", singleKey: true, wantNames: []string{"Mail"}, wantFailures: []string{}, wantSubkeys: true},
+		{name: "single key with stored fields", prefix: "secret/totp/stored", singleKey: true, wantNames: []string{"Read"}, wantFailures: []string{}, wantSubkeys: true, wantReads: 1},
+		{name: "single key without secret field", prefix: "secret/totp/userdata", singleKey: true, wantNames: []string{}, wantFailures: []string{}, wantSubkeys: true},
+		{name: "single key missing", prefix: "secret/totp/missing", singleKey: true, wantErr: "There is no key", wantSubkeys: true},
+		{name: "single key denied", prefix: "secret/totp/denied", singleKey: true, wantErr: "permission denied", wantSubkeys: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg, oldRequired := cfg, requiredFields
+			defer func() { cfg, requiredFields = oldCfg, oldRequired }()
+			requiredFields = nil
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.Prefix = "secret/totp"
+			if c.prefix != "" {
+				cfg.Vault.Prefix = c.prefix
+			}
+			cfg.Vault.KV2Mount = "secret"
+			cfg.Vault.KV2CustomMeta = true
+			cfg.Vault.KV2Subkeys = !c.disabled
+			cfg.Vault.HTMLFields = htmlFieldsReject
+			cfg.Vault.SecretField = "secret"
+			if c.secretField != "" {
+				cfg.Vault.SecretField = c.secretField
+			}
+			cfg.Vault.SecretFieldAliases = []string{"totp_secret"}
+			cfg.Vault.SecretRefDepth = 0
+			if c.secretRefs {
+				cfg.Vault.SecretRefDepth = 1
+			}
+			cfg.Vault.ShowDeleted = c.showDeleted
+			cfg.Vault.SingleKey = c.singleKey
+
+			ctx := withoutCodes(context.Background())
+			if c.withCodes {
+				ctx = context.Background()
+			}
+
+			atomic.StoreInt32(&subkeyReads, 0)
+			atomic.StoreInt32(&dataReads, 0)
+
+			res, err := scanVault(ctx, "s.user", false)
+
+			subkeys, data := atomic.LoadInt32(&subkeyReads), atomic.LoadInt32(&dataReads)
+			if c.wantSubkeys && (subkeys == 0 || data != c.wantReads) {
+				t.Errorf("Expected subkeys and %d secrets to be read, got %d subkey and %d secret reads", c.wantReads, subkeys, data)
+			}
+			if !c.wantSubkeys && (subkeys != 0 || data == 0) {
+				t.Errorf("Expected only secrets to be read, got %d subkey and %d secret reads", subkeys, data)
+			}
+
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if c.wantNames == nil {
+				// The secrets were read, the structure is not relevant
+				return
+			}
+
+			names := []string{}
+			for _, tok := range res.Tokens {
+				names = append(names, tok.Name)
+				if (tok.Secret != "") != (tok.Name == "Read") {
+					t.Errorf("Expected the secret only for read keys, got %q for %q", tok.Secret, tok.Name)
+				}
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Errorf("Expected tokens %v, got %v", c.wantNames, names)
+			}
+
+			failures := []string{}
+			for _, f := range res.Failures {
+				failures = append(failures, f.Path)
+			}
+			if !reflect.DeepEqual(failures, c.wantFailures) {
+				t.Errorf("Expected failures %v, got %v", c.wantFailures, failures)
+			}
+		})
+	}
+}
+
+func TestHasSecretField(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		name       string
+		field      string
+		secretRefs bool
+		subkeys    map[string]interface{}
+		want       bool
+	}{
+		{name: "secret field", field: "secret", subkeys: map[string]interface{}{"secret": nil}, want: true},
+		{name: "alias", field: "secret", subkeys: map[string]interface{}{"totp_secret": nil}, want: true},
+		{name: "other fields", field: "secret", subkeys: map[string]interface{}{"username": nil, "name": nil}},
+		{name: "empty", field: "secret", subkeys: map[string]interface{}{}},
+		{name: "nested field", field: "mfa.seed", subkeys: map[string]interface{}{"mfa": map[string]interface{}{"seed": nil}}, want: true},
+		{name: "nested field missing", field: "mfa.seed", subkeys: map[string]interface{}{"mfa": map[string]interface{}{"user": nil}}},
+		{name: "nested parent without children", field: "mfa.seed", subkeys: map[string]interface{}{"mfa": nil}},
+		{name: "reference disabled", field: "secret", subkeys: map[string]interface{}{"secret_ref": nil}},
+		{name: "reference enabled", field: "secret", secretRefs: true, subkeys: map[string]interface{}{"secret_ref": nil}, want: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.SecretField = c.field
+			cfg.Vault.SecretFieldAliases = []string{"totp_secret"}
+			cfg.Vault.SecretRefDepth = 0
+			if c.secretRefs {
+				cfg.Vault.SecretRefDepth = 1
+			}
+
+			if got := hasSecretField(c.subkeys); got != c.want {
+				t.Errorf("Expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestValueField(t *testing.T) {
+	oldCfg, oldRequired := cfg, requiredFields
+	defer func() { cfg, requiredFields = oldCfg, oldRequired }()
+
+	for _, c := range []struct {
+		name     string
+		field    string
+		required []fieldRequirement
+		subkeys  map[string]interface{}
+		want     string
+	}{
+		{name: "secret only", field: "secret", subkeys: map[string]interface{}{"secret": nil}},
+		{name: "alias and reference", field: "secret", subkeys: map[string]interface{}{"totp_secret": nil, "secret_ref": nil}},
+		{name: "nested secret field", field: "mfa.seed", subkeys: map[string]interface{}{"mfa": map[string]interface{}{"seed": nil}}},
+		{name: "issuer", field: "secret", subkeys: map[string]interface{}{"secret": nil, "issuer": nil}, want: "issuer"},
+		{name: "name field", field: "secret", subkeys: map[string]interface{}{"secret": nil, "account_name": nil}, want: "account_name"},
+		{name: "first field in order", field: "secret", subkeys: map[string]interface{}{"secret": nil, "period": nil, "digits": nil}, want: "digits"},
+		{name: "required field", field: "secret", required: []fieldRequirement{{Field: "env", Value: "prod"}}, subkeys: map[string]interface{}{"secret": nil, "env": nil}, want: "env"},
+		{name: "name as secret field", field: "name", subkeys: map[string]interface{}{"name": nil}, want: "name"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.Vault.SecretField = c.field
+			cfg.Vault.SecretFieldAliases = []string{"totp_secret"}
+
+			requiredFields = c.required
+
+			f, ok := valueField(c.subkeys)
+			if ok != (c.want != "") || f != c.want {
+				t.Errorf("Expected field %q, got %q (%v)", c.want, f, ok)
+			}
+		})
+	}
+}
@@ -1,42 +1,320 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"math"
+	"net/http"
+	"net/url"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
 	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
 	"github.com/pquerna/otp/totp"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 )
 
+const (
+	tokenTypeHOTP = "hotp"
+	tokenTypeTOTP = "totp"
+)
+
+const (
+	codeModeGenerate = "generate"
+	codeModeStatic   = "static"
+)
+
+const (
+	// maxSkew limits the number of periods codes are accepted in before
+	// and after the current one
+	maxSkew = 10
+	// maxTimeOffset limits the correction of the time codes are
+	// generated for
+	maxTimeOffset = time.Hour
+)
+
+const (
+	sortByCreated = "created"
+	sortByExpiry  = "expiry"
+	sortByName    = "name"
+	sortByPath    = "path"
+)
+
+// tokenParams are the resolved options the code of a token was
+// generated with including the defaults of the profile
+type tokenParams struct {
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period,omitempty"`
+	Algorithm string `json:"algorithm"`
+	Skew      int    `json:"skew,omitempty"`
+	Offset    int    `json:"offset,omitempty"` // Seconds
+}
+
 type token struct {
-	Code   string `json:"code"`
-	Icon   string `json:"icon"`
-	Name   string `json:"name"`
-	Secret string `json:"-"`
-	Digits int    `json:"digits"`
-	Period int    `json:"period"`
+	Code        string   `json:"code"`
+	Color       string   `json:"color,omitempty"`
+	Deleted     bool     `json:"deleted,omitempty"`
+	Fallback    bool     `json:"fallback,omitempty"` // Break-glass token served while Vault is unavailable
+	Fingerprint string   `json:"fingerprint"`        // Hash of the configuration (not the secret) for change detection
+	Folder      string   `json:"folder,omitempty"`
+	Icon        string   `json:"icon"`
+	Image       string   `json:"image,omitempty"` // URL of a logo to display instead of the icon
+	Issuer      string   `json:"issuer,omitempty"`
+	Name        string   `json:"name"`
+	Note        string   `json:"note,omitempty"`
+	RawName     string   `json:"raw_name,omitempty"` // Name before normalization, only set when it differs
+	SourcePath  string   `json:"path,omitempty"`     // Key the token was read from, only set when enabled
+	Tags        []string `json:"tags,omitempty"`
+	Type        string   `json:"type"`
+
+	Created    time.Time `json:"-"` // Creation of the secret version, only known for KV v2
+	Path       string    `json:"-"`
+	Secret     string    `json:"-"`
+	StoredCode string    `json:"-"` // Code read from Vault (i.e. computed by the TOTP backend)
+
+	Algorithm string `json:"-"`
+	Counter   uint64 `json:"-"`
+	Digits    int    `json:"digits"`
+	Encoding  string `json:"-"`
+	Period    int    `json:"period"`
+	T0        int64  `json:"-"` // Unix time to start counting periods at (defaults to the epoch)
+
+	// Offset and skew override the global settings when set
+	Offset *time.Duration `json:"-"`
+	Skew   *int           `json:"-"`
+
+	// RemainingSeconds is the time the code is still valid for, it is
+	// not set for codes not expiring by time (HOTP, codes read from Vault)
+	RemainingSeconds int `json:"remaining_seconds,omitempty"`
+
+	// Params contains the options actually used to generate the code,
+	// only set when enabled
+	Params *tokenParams `json:"params,omitempty"`
+
+	// RecoveryCodes are static codes stored instead of a secret, they are
+	// only returned to admins and must never be logged
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+
+	// MetadataOnly marks tokens of issuers no codes are generated for
+	MetadataOnly bool `json:"metadata_only,omitempty"`
+
+	// Warnings are non-fatal problems of the token like ignored fields
+	Warnings []string `json:"-"`
+
+	// Fields are the fields stored in the key, only kept for the backup
+	Fields map[string]interface{} `json:"-"`
+
+	// NoNext suppresses the code of the next period for tokens whose
+	// upcoming code must not be exposed
+	NoNext bool `json:"no_next,omitempty"`
+
+	// Upcoming marks the code to be the one of the next period instead
+	// of the currently valid one
+	Upcoming bool `json:"upcoming,omitempty"`
+
+	// Only set when requesting the current and the next code at once:
+	// The current code is valid until the next code becomes valid
+	NextCode      string     `json:"next_code,omitempty"`
+	NextValidFrom *time.Time `json:"next_valid_from,omitempty"`
+	ValidUntil    *time.Time `json:"valid_until,omitempty"`
+
+	// SecretFingerprint is a salted hash of the secret to compare secrets
+	// across environments, only set for admins
+	SecretFingerprint string `json:"secret_fingerprint,omitempty"`
+
+	// GenerationTime is the time spent generating the code, it is only
+	// exposed to admins requesting debug information
+	GenerationTime      time.Duration `json:"-"`
+	DebugGenerationTime string        `json:"generation_time,omitempty"`
+}
+
+// ConfigFingerprint calculates a hash over the configuration of the
+// token to detect changes. The secret and the code are not part of it.
+func (t *token) ConfigFingerprint() string {
+	h := sha256.New()
+	for _, v := range []interface{}{
+		t.Name, t.Issuer, t.Type, t.Digits, t.Period, t.Algorithm,
+		t.Encoding, t.T0, t.Icon, t.Image, t.Folder, t.Deleted,
+		strings.Join(t.Tags, ","), t.Color,
+	} {
+		fmt.Fprintf(h, "%v\x00", v)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
+
+// usesStoredCode tells whether the code stored in Vault is displayed
+// instead of generating one. A secret stored along with the code wins as
+// the stored code might be left over from an earlier import.
+func (t *token) usesStoredCode() bool {
+	return t.StoredCode != "" && t.Secret == "" && cfg.Vault.CodeMode == codeModeStatic
+}
+
+// resolveDefaults fills in the digits and period of the profile for
+// tokens not configuring them without generating a code
+func (t *token) resolveDefaults() error {
+	if t.Type == tokenTypeRecovery {
+		return nil
+	}
+
+	if t.usesStoredCode() {
+		if t.Period == 0 && t.Type != tokenTypeHOTP {
+			t.Period = activeProfile().Period
+		}
+		return nil
+	}
+
+	if t.Type == tokenTypeHOTP {
+		return nil
+	}
+
+	opts, err := t.totpOpts()
+	if err != nil {
+		return err
+	}
+
+	t.Digits, t.Period = int(opts.Digits), int(opts.Period)
+	return nil
 }
 
 func (t *token) GenerateCode(next bool) error {
-	secret := t.Secret
+	defer func(start time.Time) { t.GenerationTime = time.Since(start) }(time.Now())
+
+	if t.Type == tokenTypeRecovery {
+		// Nothing to generate, the recovery codes are displayed as they are
+		return nil
+	}
+
+	if t.usesStoredCode() {
+		// Code was computed by Vault, display it as is
+		t.Code = t.StoredCode
+		return t.resolveDefaults()
+	}
+
+	if t.Secret == "" {
+		return errors.New("Token has no secret to generate a code from")
+	}
+
+	if t.Type == tokenTypeHOTP {
+		// HOTP codes are bound to the counter, not to the time
+		opts, err := t.hotpOpts()
+		if err != nil {
+			return err
+		}
+		if cfg.UI.ExposeParams {
+			t.Params = &tokenParams{Digits: int(opts.Digits), Algorithm: opts.Algorithm.String()}
+		}
+
+		t.Code, err = t.encodeCode(t.Counter, opts)
+		return err
+	}
+
+	opts, err := t.totpOpts()
+	if err != nil {
+		return err
+	}
+
+	if cfg.UI.ExposeParams {
+		t.Params = &tokenParams{
+			Digits:    int(opts.Digits),
+			Period:    int(opts.Period),
+			Algorithm: opts.Algorithm.String(),
+			Skew:      int(opts.Skew),
+			Offset:    int(t.timeOffset() / time.Second),
+		}
+	}
+
+	// Expose the resolved values to enable clients to refresh each token
+	// according to its own period
+	t.Digits, t.Period = int(opts.Digits), int(opts.Period)
+
+	var (
+		now         = time.Now()
+		pointOfTime = now
+	)
+
+	// The current code expires at the end of the current period, the next
+	// code is valid for another period
+	t.RemainingSeconds = int(int64(opts.Period) - t.periodOffset(now, opts))
+	if next {
+		pointOfTime = pointOfTime.Add(time.Duration(opts.Period) * time.Second)
+		t.RemainingSeconds += int(opts.Period)
+	}
+	t.Upcoming = next
+
+	if next && t.NoNext {
+		t.Code = ""
+		return nil
+	}
 
-	if n := len(secret) % 8; n != 0 {
-		secret = secret + strings.Repeat("=", 8-n)
+	t.Code, err = t.codeAt(pointOfTime, opts)
+	return err
+}
+
+// AddNextCode generates the code of the next period in addition to the
+// current one and sets the boundary between both codes. It does nothing
+// for codes not expiring by time and tokens not exposing the next code.
+func (t *token) AddNextCode(now time.Time) error {
+	if t.Type == tokenTypeHOTP || t.NoNext || t.Secret == "" {
+		return nil
 	}
 
+	opts, err := t.totpOpts()
+	if err != nil {
+		return err
+	}
+
+	boundary := time.Unix(now.Unix()-t.periodOffset(now, opts)+int64(opts.Period), 0)
+	if t.NextCode, err = t.codeAt(boundary, opts); err != nil {
+		return err
+	}
+
+	t.ValidUntil, t.NextValidFrom = &boundary, &boundary
+	return nil
+}
+
+// MaskCodes replaces the codes of the token by placeholders of the same
+// length so the real codes never leave the server
+func (t *token) MaskCodes() {
+	t.Code = maskCode(t.Code)
+	t.NextCode = maskCode(t.NextCode)
+	for i := range t.RecoveryCodes {
+		t.RecoveryCodes[i] = maskCode(t.RecoveryCodes[i])
+	}
+}
+
+func maskCode(code string) string {
+	return strings.Repeat("•", utf8.RuneCountInString(code))
+}
+
+// totpOpts resolves the options to generate TOTP codes for the token
+// using the active profile for everything not set on the token
+func (t *token) totpOpts() (totp.ValidateOpts, error) {
+	profile := activeProfile()
 	opts := totp.ValidateOpts{
-		Period:    30,
-		Skew:      1,
-		Digits:    otp.DigitsSix,
-		Algorithm: otp.AlgorithmSHA1,
+		Period:    uint(profile.Period),
+		Skew:      uint(cfg.OTP.Skew),
+		Digits:    otp.Digits(profile.Digits),
+		Algorithm: profile.Algorithm,
+	}
+
+	if t.Skew != nil {
+		if *t.Skew < 0 || *t.Skew > maxSkew {
+			return opts, errors.Errorf("Skew %d is out of range (0-%d)", *t.Skew, maxSkew)
+		}
+		opts.Skew = uint(*t.Skew)
+	}
+
+	if t.Offset != nil && (*t.Offset > maxTimeOffset || *t.Offset < -maxTimeOffset) {
+		return opts, errors.Errorf("Offset %s exceeds %s", *t.Offset, maxTimeOffset)
 	}
 
 	if t.Digits != 0 {
@@ -47,27 +325,134 @@ func (t *token) GenerateCode(next bool) error {
 		opts.Period = uint(t.Period)
 	}
 
-	var pointOfTime = time.Now()
-	if next {
-		pointOfTime = pointOfTime.Add(time.Duration(t.Period) * time.Second)
+	if t.Algorithm != "" {
+		var err error
+		if opts.Algorithm, err = parseAlgorithm(t.Algorithm); err != nil {
+			return opts, err
+		}
 	}
 
-	var err error
-	t.Code, err = totp.GenerateCodeCustom(strings.ToUpper(secret), pointOfTime, opts)
-	return err
+	return opts, nil
+}
+
+// timeOffset returns the correction of the time to generate the codes
+// for, the offset of the token takes precedence over the global one
+func (t *token) timeOffset() time.Duration {
+	if t.Offset != nil {
+		return *t.Offset
+	}
+	return cfg.OTP.TimeOffset
+}
+
+// periodOffset returns the number of seconds elapsed since the start of
+// the current period taking the T0 and the time offset of the token into
+// account
+func (t *token) periodOffset(now time.Time, opts totp.ValidateOpts) int64 {
+	p := int64(opts.Period)
+	return ((now.Add(t.timeOffset()).Unix()-t.T0)%p + p) % p
+}
+
+// codeAt generates the TOTP code valid at the given point of time
+// corrected by the time offset. The skew does not change the code, it
+// only describes the window the service accepts codes in and is reported
+// for display. Points of time before the T0 have no counter and are
+// rejected.
+func (t *token) codeAt(pointOfTime time.Time, opts totp.ValidateOpts) (string, error) {
+	elapsed := pointOfTime.Add(t.timeOffset()).Unix() - t.T0
+	if elapsed < 0 {
+		return "", errors.Errorf("Field t0 %d is in the future", t.T0)
+	}
+
+	counter := uint64(elapsed / int64(opts.Period))
+	return t.encodeCode(counter, hotp.ValidateOpts{
+		Digits:    opts.Digits,
+		Algorithm: opts.Algorithm,
+	})
+}
+
+// hasCodeSource tells whether there is anything to derive a code from
+// (or recovery codes to display instead)
+func (t *token) hasCodeSource() bool {
+	return t.Secret != "" || t.usesStoredCode() || t.Type == tokenTypeRecovery
 }
 
 // Sorter interface
 
 type tokenList []*token
 
-func (t tokenList) Len() int           { return len(t) }
-func (t tokenList) Less(i, j int) bool { return strings.ToLower(t[i].Name) < strings.ToLower(t[j].Name) }
-func (t tokenList) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t tokenList) Len() int      { return len(t) }
+func (t tokenList) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
+func (t tokenList) Less(i, j int) bool {
+	if cfg.UI.SortBy == sortByPath && t[i].Path != t[j].Path {
+		return pathLess(t[i].Path, t[j].Path)
+	}
+
+	if t[i].Folder != t[j].Folder {
+		// Folders are sorted before the tokens in the root of the prefix
+		switch {
+		case t[i].Folder == "":
+			return false
+		case t[j].Folder == "":
+			return true
+		}
+		return sortKey(t[i].Folder) < sortKey(t[j].Folder)
+	}
+
+	if cfg.UI.SortBy == sortByCreated && !t[i].Created.Equal(t[j].Created) {
+		// Newest first, tokens without creation time are sorted last
+		return t[i].Created.After(t[j].Created)
+	}
+
+	if cfg.UI.SortBy == sortByExpiry && t[i].RemainingSeconds != t[j].RemainingSeconds {
+		// Tokens not expiring by time are sorted last
+		switch {
+		case t[i].RemainingSeconds == 0:
+			return false
+		case t[j].RemainingSeconds == 0:
+			return true
+		}
+		return (t[i].RemainingSeconds < t[j].RemainingSeconds) != cfg.UI.SortExpiringLast
+	}
+
+	return sortKey(t[i].Name) < sortKey(t[j].Name)
+}
+
+// pathLess compares the keys the way they are laid out in Vault: Folder
+// by folder with the keys of a folder before its sub-folders
+func pathLess(a, b string) bool {
+	if da, db := path.Dir(a), path.Dir(b); da != db {
+		switch {
+		case da == ".":
+			return true
+		case db == ".":
+			return false
+		}
+
+		pa, pb := strings.Split(da, "/"), strings.Split(db, "/")
+		for n := 0; n < len(pa) && n < len(pb); n++ {
+			if pa[n] != pb[n] {
+				return sortKey(pa[n]) < sortKey(pb[n])
+			}
+		}
+		return len(pa) < len(pb)
+	}
+
+	return sortKey(path.Base(a)) < sortKey(path.Base(b))
+}
+
+// sortKey returns the representation of the string to compare when
+// sorting tokens
+func sortKey(in string) string {
+	if cfg.UI.CaseSensitiveSort {
+		return in
+	}
+	return strings.ToLower(in)
+}
 
 func (t tokenList) LongestName() (l int) {
 	for _, s := range t {
-		if ll := len(s.Name); ll > l {
+		if ll := utf8.RuneCountInString(s.Name); ll > l {
 			l = ll
 		}
 	}
@@ -75,6 +460,65 @@ func (t tokenList) LongestName() (l int) {
 	return
 }
 
+// ExpiringWithin returns the tokens whose code expires in less than the
+// given number of seconds
+func (t tokenList) ExpiringWithin(seconds int) tokenList {
+	out := tokenList{}
+	for _, tok := range t {
+		if tok.RemainingSeconds > 0 && tok.RemainingSeconds < seconds {
+			out = append(out, tok)
+		}
+	}
+
+	return out
+}
+
+// ETag identifies the set of tokens by their configuration fingerprints.
+// It is a weak ETag as the codes are not part of it.
+func (t tokenList) ETag() string {
+	fps := []string{}
+	for _, tok := range t {
+		fps = append(fps, tok.ConfigFingerprint())
+	}
+	// The order depends on the codes when sorting by expiry
+	sort.Strings(fps)
+
+	h := sha256.New()
+	for _, fp := range fps {
+		fmt.Fprintf(h, "%s\x00", fp)
+	}
+	return fmt.Sprintf("W/%q", fmt.Sprintf("%x", h.Sum(nil))[:16])
+}
+
+// WithTag filters the list for tokens carrying the given tag
+func (t tokenList) WithTag(tag string) tokenList {
+	out := tokenList{}
+	for _, tok := range t {
+		if tok.HasTag(tag) {
+			out = append(out, tok)
+		}
+	}
+
+	return out
+}
+
+// refreshFloor returns the minimum number of seconds between two
+// refreshes of the codes
+func refreshFloor() int {
+	return int(cfg.UI.MinRefresh / time.Second)
+}
+
+// nextRefresh returns the first wrap of the codes at least the refresh
+// floor after the point of time. Tokens with tiny periods must not cause
+// rapid re-scans while their codes still wrap at the actual boundary.
+func nextRefresh(wrap, pointOfTime time.Time, period int) time.Time {
+	floor := pointOfTime.Add(time.Duration(refreshFloor()) * time.Second)
+	for wrap.Before(floor) {
+		wrap = wrap.Add(time.Duration(period) * time.Second)
+	}
+	return wrap
+}
+
 func (t tokenList) MinPeriod() int {
 	var m int = math.MaxInt32
 
@@ -86,177 +530,491 @@ func (t tokenList) MinPeriod() int {
 
 	if m == math.MaxInt32 {
 		// Fallback: Everything uses the default value
-		m = 30
+		m = activeProfile().Period
 	}
 
 	return m
 }
 
-func useOrRenewToken(tok, accessToken string) (string, error) {
-	client, err := api.NewClient(&api.Config{
-		Address: cfg.Vault.Address,
-	})
-
+// tokenExpired checks whether the token was rejected by Vault, other
+// errors (like Vault being unavailable) do not count as expired
+func tokenExpired(tok string) bool {
+	client, err := newVaultClient()
 	if err != nil {
-		return "", fmt.Errorf("Unable to create client: %s", err)
+		log.WithError(err).Error("Unable to create client")
+		return false
 	}
 
-	if tok != "" {
-		client.SetToken(tok)
-		s, err := client.Auth().Token().LookupSelf()
-		if err == nil && s.Data != nil {
-			log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Token is valid for another %vs", s.Data["ttl"])
-			return tok, nil
-		}
+	client.SetToken(tok)
+	_, err = client.Auth().Token().LookupSelf()
+	if rerr, ok := errors.Cause(err).(*api.ResponseError); ok && rerr.StatusCode == http.StatusForbidden {
+		return true
+	}
 
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"token": hashSecret(tok)}).Warn("Unable to verify token")
+	}
+	return false
+}
+
+// tokenIsValid checks whether the given token still can be used to
+// access Vault and does not expire within the minimum TTL. The token is
+// set on the client afterwards.
+func tokenIsValid(client *api.Client, tok string) bool {
+	client.SetToken(tok)
+	s, err := client.Auth().Token().LookupSelf()
+	if err != nil || s.Data == nil {
 		log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Token did not met requirements: err = %s", err)
 		if s != nil {
 			log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Token did not met requirements: data = %v", s.Data)
 		}
+		return false
 	}
 
-	s, err := client.Logical().Write("auth/github/login", map[string]interface{}{"token": accessToken})
-	if err != nil || s.Auth == nil {
-		return "", fmt.Errorf("Login did not work: Error = %s", err)
+	ttl, err := s.TokenTTL()
+	if err != nil {
+		log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Unable to parse token TTL: %s", err)
+		return false
 	}
-	return s.Auth.ClientToken, nil
+
+	// A TTL of zero is used for tokens not expiring at all
+	if ttl == 0 || ttl >= cfg.Vault.MinTTL {
+		log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Token is valid for another %s", ttl)
+		return true
+	}
+
+	if renewable, _ := s.TokenIsRenewable(); renewable {
+		rs, err := client.Auth().Token().RenewSelf(0)
+		if err == nil {
+			if ttl, err = rs.TokenTTL(); err == nil && ttl >= cfg.Vault.MinTTL {
+				log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Token was renewed, valid for another %s", ttl)
+				return true
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{"token": hashSecret(tok)}).Debugf("Token expires in %s, less than the minimum of %s", ttl, cfg.Vault.MinTTL)
+	return false
 }
 
-func getSecretsFromVault(tok string, next bool) ([]*token, error) {
-	client, err := api.NewClient(&api.Config{
-		Address: cfg.Vault.Address,
+var errInvalidAccessToken = errors.New("Invalid access token")
+
+// loginGroup collapses concurrent logins of the same user into one
+// call against Vault
+var loginGroup singleflight.Group
+
+func useOrRenewToken(tok, accessToken string) (string, error) {
+	v, err, shared := loginGroup.Do("github:"+hashSecret(accessToken), func() (interface{}, error) {
+		return loginGithub(tok, accessToken)
 	})
+	if shared {
+		log.WithFields(log.Fields{"token": hashSecret(tok)}).Debug("Shared login result with concurrent request")
+	}
 
-	if err != nil {
-		return nil, fmt.Errorf("Unable to create client: %s", err)
+	newTok, _ := v.(string)
+	return newTok, err
+}
+
+// validateAccessToken rejects access tokens which cannot be valid
+// before sending them to Vault and producing a failed login
+func validateAccessToken(accessToken string) error {
+	if accessToken == "" {
+		return errors.Wrap(errInvalidAccessToken, "Access token is empty")
 	}
 
-	client.SetToken(tok)
+	if cfg.Github.MaxTokenLength > 0 && len(accessToken) > cfg.Github.MaxTokenLength {
+		return errors.Wrapf(errInvalidAccessToken, "Access token is longer than %d characters", cfg.Github.MaxTokenLength)
+	}
+
+	return nil
+}
+
+func loginGithub(tok, accessToken string) (string, error) {
+	var newTok string
+
+	err := withVaultFailover(func(client *api.Client) error {
+		if tok != "" && tokenIsValid(client, tok) {
+			newTok = tok
+			return nil
+		}
+
+		if err := validateAccessToken(accessToken); err != nil {
+			return err
+		}
+
+		client.SetClientTimeout(cfg.Github.LoginTimeout)
+		s, err := client.Logical().Write(path.Join("auth", strings.Trim(cfg.Github.AuthMount, "/"), "login"), map[string]interface{}{"token": accessToken})
+		if err != nil {
+			return err
+		}
 
-	key := cfg.Vault.Prefix
-
-	resp := []*token{}
-	respChan := make(chan *token, 100)
-
-	keyPoolChan := make(chan string, 100)
-
-	scanPool := make(chan string, 100)
-	scanPool <- strings.TrimRight(key, "*")
-
-	done := make(chan struct{})
-	defer func() { done <- struct{}{} }()
-
-	wg := new(sync.WaitGroup)
-	wg.Add(1)
-
-	go func() {
-		for {
-			select {
-			case key := <-scanPool:
-				go scanKeyForSubKeys(client, key, scanPool, keyPoolChan, wg)
-			case key := <-keyPoolChan:
-				go fetchTokenFromKey(client, key, respChan, wg, next)
-			case t := <-respChan:
-				resp = append(resp, t)
-				wg.Done()
-			case <-done:
-				close(scanPool)
-				close(keyPoolChan)
-				close(respChan)
-				return
+		if s == nil || s.Auth == nil {
+			return errors.New("Login returned no token")
+		}
+
+		newTok = s.Auth.ClientToken
+		return nil
+	})
+
+	return newTok, errors.Wrap(err, "Login did not work")
+}
+
+func tokenFromData(ctx context.Context, key string, data map[string]interface{}) *token {
+	tok := &token{
+		Name: key,
+		Path: key,
+		Type: tokenTypeTOTP,
+	}
+
+	tok.Secret = transformSecret(ctx, key, lookupSecret(ctx, key, data))
+
+	if cfg.UI.ExposePath {
+		tok.SourcePath = key
+	}
+
+	var (
+		err       error
+		tokConfig map[string]interface{}
+	)
+	for k, v := range data {
+		switch k {
+		case "config":
+			if tokConfig, err = parseTokenConfig(v); err != nil {
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse config")
+			}
+		case "code":
+			tok.StoredCode = fieldString(v)
+		case "color":
+			if tok.Color, err = parseColor(fieldString(v)); err != nil {
+				tok.warn(logger(ctx).WithError(err).WithField("key", key), "Ignoring color")
+			}
+		case "icon":
+			tok.Icon = fieldString(v)
+		case "issuer":
+			tok.Issuer = fieldString(v)
+		case "no_next":
+			if tok.NoNext, err = strconv.ParseBool(fieldString(v)); err != nil {
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse no_next")
+			}
+		case "recovery_codes":
+			tok.RecoveryCodes = parseRecoveryCodes(v)
+		case "note":
+			tok.Note = fieldString(v)
+		case "image":
+			if tok.Image, err = parseImageURL(fieldString(v)); err != nil {
+				tok.warn(logger(ctx).WithError(err).WithField("key", key), "Ignoring image")
+			}
+		case "algorithm":
+			// Might be stored as a number (json.Number when read from Vault)
+			tok.Algorithm = fmt.Sprint(v)
+		case "encoding":
+			tok.Encoding = strings.ToLower(fieldString(v))
+		case "tags":
+			tok.Tags = parseTags(v)
+		case "t0":
+			tok.T0, err = strconv.ParseInt(fieldString(v), 10, 64)
+			if err != nil {
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse t0")
+			}
+		case "offset":
+			var offset int
+			if offset, err = strconv.Atoi(fieldString(v)); err != nil {
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse offset")
+				break
+			}
+			d := time.Duration(offset) * time.Second
+			tok.Offset = &d
+		case "skew":
+			var skew int
+			if skew, err = strconv.Atoi(fieldString(v)); err != nil {
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse skew")
+				break
+			}
+			tok.Skew = &skew
+		case "type":
+			tok.Type = strings.ToLower(fieldString(v))
+		case "counter":
+			tok.Counter, err = strconv.ParseUint(fieldString(v), 10, 64)
+			if err != nil {
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse counter")
+			}
+		case "digits":
+			tok.Digits, err = strconv.Atoi(fieldString(v))
+			if err != nil {
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse digits")
+			}
+		case "period":
+			tok.Period, err = strconv.Atoi(fieldString(v))
+			switch {
+			case err != nil:
+				logger(ctx).WithError(err).WithField("key", key).Error("Unable to parse period")
+			case tok.Period <= 0:
+				// Unlike a missing period this most likely is a mistake
+				tok.warn(logger(ctx).WithFields(log.Fields{"key": key, "period": tok.Period}), "Ignoring explicitly stored period, using the default")
+				tok.Period = 0
 			}
 		}
-	}()
+	}
 
-	wg.Wait()
+	if tok.Secret == "" && len(tok.RecoveryCodes) > 0 {
+		tok.Type = tokenTypeRecovery
+	} else if tok.Type == tokenTypeRecovery {
+		// Recovery codes can't be combined with a secret
+		tok.Type, tok.RecoveryCodes = tokenTypeTOTP, nil
+	}
 
-	sort.Sort(tokenList(resp))
+	if err = tok.applyConfig(tokConfig); err != nil {
+		tok.warn(logger(ctx).WithError(err).WithField("key", key), "Ignoring invalid config, using the defaults")
+	}
+	tok.clampDigits(logger(ctx).WithField("key", key))
+
+	// The first name field set wins, map iteration order must not decide
+	var nameField string
+	ignoredNameFields := []string{}
+	for _, f := range cfg.Vault.NameFields {
+		v, ok := lookupField(data, f)
+		if !ok {
+			continue
+		}
+		name, _ := v.(string)
+		switch {
+		case name == "":
+			continue
+		case nameField == "":
+			nameField, tok.Name = f, name
+		default:
+			ignoredNameFields = append(ignoredNameFields, f)
+		}
+	}
+
+	if len(ignoredNameFields) > 0 {
+		logger(ctx).WithFields(log.Fields{
+			"key":     key,
+			"field":   nameField,
+			"ignored": strings.Join(ignoredNameFields, ","),
+		}).Debug("Multiple name fields set, using first one")
+	}
+
+	if name := normalizeName(tok.Name); name != tok.Name {
+		tok.RawName, tok.Name = tok.Name, name
+	}
+
+	if tok.Icon == "" {
+		tok.Icon = defaultIcon(tok.Type)
+	}
+
+	if tok.Color == "" {
+		tok.Color = issuerColor(tok.Issuer)
+	}
 
-	return resp, nil
+	return tok
 }
 
-func scanKeyForSubKeys(client *api.Client, key string, subKeyChan, tokenKeyChan chan string, wg *sync.WaitGroup) {
-	defer wg.Done()
+// defaultIcon returns the icon for tokens without an explicit icon
+func defaultIcon(tokenType string) string {
+	if icon, ok := typeIcons[tokenType]; ok {
+		return icon
+	}
 
-	s, err := client.Logical().List(key)
-	if err != nil {
-		log.Errorf("Unable to list keys %q: %s", key, err)
-		return
+	return "key"
+}
+
+func parseTypeIcons(in []string) (map[string]string, error) {
+	icons := map[string]string{}
+	for _, e := range in {
+		parts := strings.SplitN(e, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("Invalid type icon %q, expected type:icon", e)
+		}
+		icons[strings.ToLower(parts[0])] = parts[1]
 	}
 
-	if s == nil {
-		log.Errorf("There is no key %q", key)
-		return
+	return icons, nil
+}
+
+// fieldString converts the value of a field to a string. Fields of KV v2
+// secrets keep their JSON type (json.Number, bool) while the values are
+// parsed from strings.
+func fieldString(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tv
+	case float64:
+		// Avoid the exponent fmt uses for large numbers like timestamps
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	default:
+		return fmt.Sprint(tv)
 	}
+}
 
-	if s.Data["keys"] != nil {
-		for _, sk := range s.Data["keys"].([]interface{}) {
-			sks := sk.(string)
-			if strings.HasSuffix(sks, "/") {
-				wg.Add(1)
-				subKeyChan <- path.Join(key, sks)
-			} else {
-				wg.Add(1)
-				tokenKeyChan <- path.Join(key, sks)
+// parseTags reads the tags of a token either from a comma-separated
+// string or from a list of strings, empty tags are dropped
+func parseTags(v interface{}) []string {
+	var raw []string
+	switch tv := v.(type) {
+	case string:
+		raw = strings.Split(tv, ",")
+	case []interface{}:
+		for _, e := range tv {
+			if es, ok := e.(string); ok {
+				raw = append(raw, es)
 			}
 		}
 	}
+
+	var tags []string
+	for _, t := range raw {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
 }
 
-func fetchTokenFromKey(client *api.Client, k string, respChan chan *token, wg *sync.WaitGroup, next bool) {
-	defer wg.Done()
+// HasTag checks case-insensitively whether the token carries the tag
+func (t *token) HasTag(tag string) bool {
+	for _, tt := range t.Tags {
+		if strings.EqualFold(tt, tag) {
+			return true
+		}
+	}
 
-	data, err := client.Logical().Read(k)
-	if err != nil {
-		log.Errorf("Unable to read from key %q: %s", k, err)
-		return
+	return false
+}
+
+// secretFields returns the fields to read the secret from in the order of
+// their precedence: the configured field followed by its aliases. When
+// the aliases contain the configured field their order is used as is to
+// prefer other fields (i.e. during migrations of the canonical field).
+func secretFields() []string {
+	var (
+		fields  []string
+		ordered bool
+	)
+
+	for _, a := range cfg.Vault.SecretFieldAliases {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		if a == cfg.Vault.SecretField {
+			ordered = true
+		}
+		fields = append(fields, a)
 	}
 
-	if data.Data == nil {
-		// Key without any data? Weird.
-		return
+	if !ordered {
+		fields = append([]string{cfg.Vault.SecretField}, fields...)
 	}
 
-	tok := &token{
-		Icon: "key",
-		Name: k,
+	return fields
+}
+
+// hasEmptySecretField checks whether one of the secret fields is present
+// but contains an empty value
+func hasEmptySecretField(data map[string]interface{}) bool {
+	for _, f := range secretFields() {
+		v, ok := lookupField(data, f)
+		if !ok {
+			continue
+		}
+
+		if sv, isString := v.(string); v == nil || (isString && strings.TrimSpace(sv) == "") {
+			return true
+		}
 	}
 
-	for k, v := range data.Data {
-		switch k {
-		case cfg.Vault.SecretField:
-			tok.Secret = v.(string)
-		case "code":
-			tok.Code = v.(string)
-		case "name":
-			tok.Name = v.(string)
-		case "account_name":
-			tok.Name = v.(string)
-		case "icon":
-			tok.Icon = v.(string)
-		case "digits":
-			tok.Digits, err = strconv.Atoi(v.(string))
-			if err != nil {
-				log.WithError(err).Error("Unable to parse digits")
-			}
-		case "period":
-			tok.Period, err = strconv.Atoi(v.(string))
-			if err != nil {
-				log.WithError(err).Error("Unable to parse digits")
+	return false
+}
+
+// lookupSecret reads the secret from the first of the secret fields set
+// in the data. When multiple of them are set a warning is logged as this
+// most likely is an accidental duplicate.
+func lookupSecret(ctx context.Context, key string, data map[string]interface{}) string {
+	var (
+		secret string
+		found  []string
+	)
+
+	for _, f := range secretFields() {
+		v, ok := lookupField(data, f)
+		if !ok {
+			continue
+		}
+
+		// Whitespace only is treated like an empty field
+		if sv, _ := v.(string); strings.TrimSpace(sv) != "" {
+			found = append(found, f)
+			if secret == "" {
+				secret = sv
 			}
 		}
 	}
 
-	if err = tok.GenerateCode(next); err != nil {
-		log.WithError(err).WithField("name", tok.Name).Error("Unable to generate code")
-		return
+	if len(found) > 1 {
+		logger(ctx).WithFields(log.Fields{
+			"key":    key,
+			"fields": strings.Join(found, ","),
+			"used":   found[0],
+		}).Warn("Key contains multiple secret fields")
+	}
+
+	return secret
+}
+
+// parseImageURL ensures the image is an absolute http(s) URL to prevent
+// other schemes (like javascript:) from ending up in the page
+func parseImageURL(in string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(in))
+	if err != nil {
+		return "", errors.Wrap(err, "Invalid image URL")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.Errorf("Unsupported image URL scheme %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return "", errors.New("Image URL has no host")
+	}
+
+	return u.String(), nil
+}
+
+// transformSecret applies the configured transformations to the secret
+// read from Vault before it is used to generate codes
+func transformSecret(ctx context.Context, key, secret string) string {
+	if cfg.Vault.SecretStripPrefix != "" {
+		secret = strings.TrimPrefix(secret, cfg.Vault.SecretStripPrefix)
+	}
+
+	if secretReplace != nil {
+		secret = secretReplace.ReplaceAllString(secret, cfg.Vault.SecretReplaceWith)
+	}
+
+	return decodeSecret(ctx, key, secret)
+}
+
+// lookupField retrieves a field from the secret data. The field name may
+// be a dotted path (i.e. "mfa.totp.seed") to traverse into nested maps.
+// Flat field names containing dots take precedence over the traversal.
+func lookupField(data map[string]interface{}, field string) (interface{}, bool) {
+	if v, ok := data[field]; ok {
+		return v, true
+	}
+
+	parts := strings.SplitN(field, ".", 2)
+	if len(parts) < 2 {
+		return nil, false
 	}
 
-	if tok.Code == "" {
-		// Nothing ended in us having a code, does not seem to be something for us
-		return
+	sub, ok := data[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
 	}
 
-	wg.Add(1)
-	respChan <- tok
+	return lookupField(sub, parts[1])
 }
@@ -0,0 +1,1493 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestFieldString(t *testing.T) {
+	for _, c := range []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, ""},
+		{"30", "30"},
+		{json.Number("30"), "30"},
+		{float64(30), "30"},
+		{float64(1700000000), "1700000000"},
+		{1.5, "1.5"},
+		{true, "true"},
+		{false, "false"},
+	} {
+		if got := fieldString(c.in); got != c.want {
+			t.Errorf("fieldString(%#v) = %q, expected %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestTokenFromDataNoNext(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  bool
+	}{
+		{"true", true},
+		{"false", false},
+		{true, true},
+		{false, false},
+		{json.Number("1"), true},
+		{float64(0), false},
+		{"maybe", false},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret":  "JBSWY3DPEHPK3PXP",
+			"no_next": c.value,
+		})
+		if tok.NoNext != c.want {
+			t.Errorf("no_next %#v: NoNext = %v, expected %v", c.value, tok.NoNext, c.want)
+		}
+	}
+}
+
+func TestTokenFromDataT0(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  int64
+	}{
+		{"1700000000", 1700000000},
+		{json.Number("1700000000"), 1700000000},
+		{float64(1700000000), 1700000000},
+		{true, 0},
+		{"soon", 0},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret": "JBSWY3DPEHPK3PXP",
+			"t0":     c.value,
+		})
+		if tok.T0 != c.want {
+			t.Errorf("t0 %#v: T0 = %d, expected %d", c.value, tok.T0, c.want)
+		}
+	}
+}
+
+func TestTokenFromDataOffsetSkew(t *testing.T) {
+	for _, c := range []struct {
+		offset, skew interface{}
+		wantOffset   string
+		wantSkew     int
+		wantParsed   bool
+	}{
+		{"-15", "2", "-15s", 2, true},
+		{json.Number("30"), json.Number("0"), "30s", 0, true},
+		{float64(-5), float64(3), "-5s", 3, true},
+		{true, false, "", 0, false},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret": "JBSWY3DPEHPK3PXP",
+			"offset": c.offset,
+			"skew":   c.skew,
+		})
+
+		if !c.wantParsed {
+			if tok.Offset != nil || tok.Skew != nil {
+				t.Errorf("offset %#v / skew %#v: expected both to be ignored", c.offset, c.skew)
+			}
+			continue
+		}
+
+		if tok.Offset == nil || tok.Offset.String() != c.wantOffset {
+			t.Errorf("offset %#v: Offset = %v, expected %s", c.offset, tok.Offset, c.wantOffset)
+		}
+		if tok.Skew == nil || *tok.Skew != c.wantSkew {
+			t.Errorf("skew %#v: Skew = %v, expected %d", c.skew, tok.Skew, c.wantSkew)
+		}
+	}
+}
+
+func TestTokenFromDataImage(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"https://example.com/logo.png", "https://example.com/logo.png"},
+		{"javascript:alert(1)", ""},
+		{json.Number("42"), ""},
+		{true, ""},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret": "JBSWY3DPEHPK3PXP",
+			"image":  c.value,
+		})
+		if tok.Image != c.want {
+			t.Errorf("image %#v: Image = %q, expected %q", c.value, tok.Image, c.want)
+		}
+	}
+}
+
+func TestTokenFromDataColor(t *testing.T) {
+	for _, c := range []struct {
+		value    interface{}
+		want     string
+		warnings int
+	}{
+		{"#FF0000", "#ff0000", 0},
+		{"red", "", 1},
+		{json.Number("123"), "", 1},
+		{false, "", 1},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret": "JBSWY3DPEHPK3PXP",
+			"color":  c.value,
+		})
+		if tok.Color != c.want {
+			t.Errorf("color %#v: Color = %q, expected %q", c.value, tok.Color, c.want)
+		}
+		if len(tok.Warnings) != c.warnings {
+			t.Errorf("color %#v: got warnings %v, expected %d", c.value, tok.Warnings, c.warnings)
+		}
+	}
+}
+
+func TestTokenFromDataPeriod(t *testing.T) {
+	for _, c := range []struct {
+		value      interface{}
+		want       int
+		wantPeriod int // Period used to generate the code
+		warnings   int
+	}{
+		{nil, 0, 30, 0},
+		{"60", 60, 60, 0},
+		{json.Number("60"), 60, 60, 0},
+		// Explicitly stored periods of zero or below fall back to the default
+		{"0", 0, 30, 1},
+		{json.Number("0"), 0, 30, 1},
+		{"-30", 0, 30, 1},
+		// Unparsable periods are logged as errors only
+		{"thirty", 0, 30, 0},
+	} {
+		data := map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}
+		if c.value != nil {
+			data["period"] = c.value
+		}
+
+		tok := tokenFromData(context.Background(), "key", data)
+		if tok.Period != c.want {
+			t.Errorf("period %#v: Period = %d, expected %d", c.value, tok.Period, c.want)
+		}
+		if len(tok.Warnings) != c.warnings {
+			t.Errorf("period %#v: got warnings %v, expected %d", c.value, tok.Warnings, c.warnings)
+		}
+
+		if err := tok.GenerateCode(false); err != nil {
+			t.Errorf("period %#v: Unable to generate code: %s", c.value, err)
+			continue
+		}
+		if tok.Period != c.wantPeriod {
+			t.Errorf("period %#v: Expected the code to be generated with period %d, got %d", c.value, c.wantPeriod, tok.Period)
+		}
+	}
+}
+
+func TestTokenFromDataNote(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"Shared with ops", "Shared with ops"},
+		{json.Number("1234"), "1234"},
+		{float64(12.5), "12.5"},
+		{true, "true"},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret": "JBSWY3DPEHPK3PXP",
+			"note":   c.value,
+		})
+		if tok.Note != c.want {
+			t.Errorf("note %#v: Note = %q, expected %q", c.value, tok.Note, c.want)
+		}
+	}
+}
+
+func TestTokenFromDataHOTPFields(t *testing.T) {
+	for _, c := range []struct {
+		name                   string
+		data                   map[string]interface{}
+		wantType               string
+		wantCounter            uint64
+		wantDigits, wantPeriod int
+	}{
+		{
+			name:     "strings",
+			data:     map[string]interface{}{"type": "HOTP", "counter": "7", "digits": "8"},
+			wantType: "hotp", wantCounter: 7, wantDigits: 8,
+		},
+		{
+			name:     "json numbers",
+			data:     map[string]interface{}{"type": "totp", "counter": json.Number("12"), "digits": json.Number("6"), "period": json.Number("60")},
+			wantType: "totp", wantCounter: 12, wantDigits: 6, wantPeriod: 60,
+		},
+		{
+			name:     "floats",
+			data:     map[string]interface{}{"counter": float64(3), "digits": float64(7), "period": float64(45)},
+			wantType: "totp", wantCounter: 3, wantDigits: 7, wantPeriod: 45,
+		},
+		{
+			name:     "booleans",
+			data:     map[string]interface{}{"type": true, "counter": true, "digits": false, "period": true},
+			wantType: "true",
+		},
+	} {
+		c.data["secret"] = "JBSWY3DPEHPK3PXP"
+		tok := tokenFromData(context.Background(), "key", c.data)
+
+		if tok.Type != c.wantType || tok.Counter != c.wantCounter || tok.Digits != c.wantDigits || tok.Period != c.wantPeriod {
+			t.Errorf("%s: got type=%q counter=%d digits=%d period=%d, expected type=%q counter=%d digits=%d period=%d",
+				c.name, tok.Type, tok.Counter, tok.Digits, tok.Period,
+				c.wantType, c.wantCounter, c.wantDigits, c.wantPeriod)
+		}
+	}
+}
+
+func TestTokenFromDataIssuer(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"GitHub", "GitHub"},
+		{json.Number("1337"), "1337"},
+		{float64(42), "42"},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret": "JBSWY3DPEHPK3PXP",
+			"issuer": c.value,
+		})
+		if tok.Issuer != c.want {
+			t.Errorf("issuer %#v: Issuer = %q, expected %q", c.value, tok.Issuer, c.want)
+		}
+	}
+}
+
+func TestTokenFromDataEncoding(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"ALNUM", encodingAlnum},
+		{"decimal", encodingDecimal},
+		{true, "true"},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret":   "JBSWY3DPEHPK3PXP",
+			"encoding": c.value,
+		})
+		if tok.Encoding != c.want {
+			t.Errorf("encoding %#v: Encoding = %q, expected %q", c.value, tok.Encoding, c.want)
+		}
+	}
+}
+
+func TestCodeAtT0(t *testing.T) {
+	opts := totp.ValidateOpts{Period: 30, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA1}
+
+	for _, c := range []struct {
+		name    string
+		t0      int64
+		at      int64
+		want    string
+		wantErr bool
+	}{
+		// RFC 6238 appendix B, T = 59
+		{name: "epoch", t0: 0, at: 59, want: "94287082"},
+		{name: "shifted epoch", t0: 30, at: 89, want: "94287082"},
+		{name: "start of first period", t0: 1000, at: 1000, want: "84755224"},
+		{name: "before t0", t0: 1000, at: 999, wantErr: true},
+	} {
+		tok := &token{Secret: rfc4226Secret, T0: c.t0}
+
+		code, err := tok.codeAt(time.Unix(c.at, 0), opts)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: codeAt() error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+		if code != c.want {
+			t.Errorf("%s: codeAt() = %q, expected %q", c.name, code, c.want)
+		}
+	}
+}
+
+func TestGenerateCodeStoredCode(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		name       string
+		mode       string
+		secret     string
+		stored     string
+		wantStored bool
+		wantErr    bool
+	}{
+		{name: "static code only", mode: codeModeStatic, stored: "123456", wantStored: true},
+		{name: "static secret and code", mode: codeModeStatic, secret: "JBSWY3DPEHPK3PXP", stored: "123456"},
+		{name: "static secret only", mode: codeModeStatic, secret: "JBSWY3DPEHPK3PXP"},
+		{name: "generate code only", mode: codeModeGenerate, stored: "123456", wantErr: true},
+		{name: "generate secret and code", mode: codeModeGenerate, secret: "JBSWY3DPEHPK3PXP", stored: "123456"},
+		{name: "generate secret only", mode: codeModeGenerate, secret: "JBSWY3DPEHPK3PXP"},
+	} {
+		cfg.Vault.CodeMode = c.mode
+		tok := &token{Type: tokenTypeTOTP, Secret: c.secret, StoredCode: c.stored}
+
+		err := tok.GenerateCode(false)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: GenerateCode() error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+
+		if c.wantStored {
+			if tok.Code != c.stored {
+				t.Errorf("%s: Expected the stored code %q, got %q", c.name, c.stored, tok.Code)
+			}
+			continue
+		}
+
+		if len(tok.Code) != tok.Digits {
+			t.Errorf("%s: Expected a generated code of %d digits, got %q", c.name, tok.Digits, tok.Code)
+		}
+		if tok.Code == c.stored {
+			t.Errorf("%s: Expected a generated code, got the stored one", c.name)
+		}
+	}
+}
+
+func TestGenerateCodeParams(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.OTP.Skew = 1
+	cfg.Vault.CodeMode = codeModeStatic
+
+	for _, c := range []struct {
+		name     string
+		disabled bool
+		profile  string
+		tok      *token
+		want     *tokenParams
+		wantJSON string
+	}{
+		{
+			name:     "disabled",
+			disabled: true,
+			tok:      &token{Type: tokenTypeTOTP, Secret: "JBSWY3DPEHPK3PXP"},
+		},
+		{
+			name:     "totp defaults",
+			tok:      &token{Type: tokenTypeTOTP, Secret: "JBSWY3DPEHPK3PXP"},
+			want:     &tokenParams{Digits: 6, Period: 30, Algorithm: "SHA1", Skew: 1},
+			wantJSON: `{"digits":6,"period":30,"algorithm":"SHA1","skew":1}`,
+		},
+		{
+			name: "totp fields",
+			tok:  &token{Type: tokenTypeTOTP, Secret: "JBSWY3DPEHPK3PXP", Digits: 8, Period: 60, Algorithm: "sha256"},
+			want: &tokenParams{Digits: 8, Period: 60, Algorithm: "SHA256", Skew: 1},
+		},
+		{
+			name:    "totp profile",
+			profile: "sha512-8",
+			tok:     &token{Type: tokenTypeTOTP, Secret: "JBSWY3DPEHPK3PXP"},
+			want:    &tokenParams{Digits: 8, Period: 30, Algorithm: "SHA512", Skew: 1},
+		},
+		{
+			// HOTP codes have neither a period nor a skew
+			name:     "hotp",
+			tok:      &token{Type: tokenTypeHOTP, Secret: rfc4226Secret, Counter: 1, Algorithm: "sha256"},
+			want:     &tokenParams{Digits: 6, Algorithm: "SHA256"},
+			wantJSON: `{"digits":6,"algorithm":"SHA256"}`,
+		},
+		{
+			name: "stored code",
+			tok:  &token{Type: tokenTypeTOTP, StoredCode: "123456"},
+		},
+		{
+			name: "recovery codes",
+			tok:  &token{Type: tokenTypeRecovery, RecoveryCodes: []string{"abc-123"}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.UI.ExposeParams = !c.disabled
+			cfg.OTP.Profile = "default"
+			if c.profile != "" {
+				cfg.OTP.Profile = c.profile
+			}
+
+			if err := c.tok.GenerateCode(false); err != nil {
+				t.Fatalf("Unable to generate code: %s", err)
+			}
+			if !reflect.DeepEqual(c.tok.Params, c.want) {
+				t.Errorf("Expected params %+v, got %+v", c.want, c.tok.Params)
+			}
+
+			if c.wantJSON == "" {
+				return
+			}
+			buf, err := json.Marshal(c.tok.Params)
+			if err != nil {
+				t.Fatalf("Unable to marshal params: %s", err)
+			}
+			if string(buf) != c.wantJSON {
+				t.Errorf("Expected JSON %s, got %s", c.wantJSON, buf)
+			}
+		})
+	}
+}
+
+func TestGenerateCodeOffsetSkew(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.UI.ExposeParams = true
+	cfg.OTP.Skew = 1
+	cfg.OTP.TimeOffset = 0
+
+	offset := func(d time.Duration) *time.Duration { return &d }
+	skew := func(s int) *int { return &s }
+
+	for _, c := range []struct {
+		name       string
+		offset     *time.Duration
+		skew       *int
+		wantOffset time.Duration
+		wantSkew   int
+	}{
+		{name: "defaults", wantSkew: 1},
+		{name: "skew only", skew: skew(3), wantSkew: 3},
+		{name: "zero skew", skew: skew(0), wantSkew: 0},
+		{name: "offset only", offset: offset(-30 * time.Second), wantOffset: -30 * time.Second, wantSkew: 1},
+		{name: "offset and skew", offset: offset(30 * time.Second), skew: skew(2), wantOffset: 30 * time.Second, wantSkew: 2},
+		{name: "offset and zero skew", offset: offset(90 * time.Second), skew: skew(0), wantOffset: 90 * time.Second, wantSkew: 0},
+	} {
+		tok := &token{Type: tokenTypeTOTP, Secret: "JBSWY3DPEHPK3PXP", Offset: c.offset, Skew: c.skew}
+
+		before := time.Now()
+		if err := tok.GenerateCode(false); err != nil {
+			t.Errorf("%s: GenerateCode() returned error: %s", c.name, err)
+			continue
+		}
+		after := time.Now()
+
+		if tok.Params == nil || tok.Params.Skew != c.wantSkew || tok.Params.Offset != int(c.wantOffset/time.Second) {
+			t.Errorf("%s: Expected skew %d and offset %s in the params, got %+v", c.name, c.wantSkew, c.wantOffset, tok.Params)
+		}
+
+		// Only the offset changes the code, the skew is reported only
+		var matched bool
+		for _, at := range []time.Time{before, after} {
+			want, err := totp.GenerateCode("JBSWY3DPEHPK3PXP", at.Add(c.wantOffset))
+			if err != nil {
+				t.Fatalf("%s: Unable to generate code: %s", c.name, err)
+			}
+			matched = matched || tok.Code == want
+		}
+		if !matched {
+			t.Errorf("%s: Expected the code of the time corrected by %s, got %q", c.name, c.wantOffset, tok.Code)
+		}
+	}
+}
+
+func TestLookupField(t *testing.T) {
+	data := map[string]interface{}{
+		"secret":    "flat",
+		"mfa.seed":  "dotted",
+		"mfa":       map[string]interface{}{"seed": "nested", "totp": map[string]interface{}{"seed": "deep"}},
+		"scalar":    "value",
+		"collision": map[string]interface{}{"a.b": "inner dotted"},
+	}
+
+	for _, c := range []struct {
+		field  string
+		want   interface{}
+		wantOK bool
+	}{
+		{field: "secret", want: "flat", wantOK: true},
+		{field: "mfa.totp.seed", want: "deep", wantOK: true},
+		// The flat field containing a dot wins over the traversal
+		{field: "mfa.seed", want: "dotted", wantOK: true},
+		{field: "collision.a.b", want: "inner dotted", wantOK: true},
+		{field: "mfa.missing", wantOK: false},
+		{field: "scalar.below", wantOK: false},
+		{field: "missing", wantOK: false},
+	} {
+		got, ok := lookupField(data, c.field)
+		if ok != c.wantOK || got != c.want {
+			t.Errorf("lookupField(%q) = %v, %v, expected %v, %v", c.field, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestTokenFromDataNestedSecret(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.SecretField = "mfa.totp.seed"
+
+	for _, c := range []struct {
+		name string
+		data map[string]interface{}
+		want string
+	}{
+		{
+			name: "nested secret",
+			data: map[string]interface{}{"mfa": map[string]interface{}{"totp": map[string]interface{}{"seed": "JBSWY3DPEHPK3PXP"}}},
+			want: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			name: "path not leading to a string",
+			data: map[string]interface{}{"mfa": map[string]interface{}{"totp": "JBSWY3DPEHPK3PXP"}},
+		},
+		{
+			name: "default field is not used",
+			data: map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"},
+		},
+	} {
+		if tok := tokenFromData(context.Background(), "key", c.data); tok.Secret != c.want {
+			t.Errorf("%s: Secret = %q, expected %q", c.name, tok.Secret, c.want)
+		}
+	}
+}
+
+func TestTokenListFolderOrder(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.UI.SortBy = sortByName
+
+	tokens := tokenList{
+		{Name: "root-b"},
+		{Name: "zebra", Folder: "Work"},
+		{Name: "db", Folder: "ops"},
+		{Name: "Root-A"},
+		{Name: "mail", Folder: "private"},
+		{Name: "Alpha", Folder: "Work"},
+		{Name: "bank", Folder: "private"},
+	}
+	sort.Sort(tokens)
+
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Folder+"/"+tok.Name)
+	}
+	// Folders first (case insensitive), tokens of the root last
+	want := []string{"ops/db", "private/bank", "private/mail", "Work/Alpha", "Work/zebra", "/Root-A", "/root-b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected order %v, got %v", want, got)
+	}
+}
+
+func TestParseTypeIcons(t *testing.T) {
+	for _, c := range []struct {
+		in      []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{in: nil, want: map[string]string{}},
+		{in: []string{"hotp:sort-numeric-asc"}, want: map[string]string{"hotp": "sort-numeric-asc"}},
+		{in: []string{"HOTP:counter", "totp:clock-o"}, want: map[string]string{"hotp": "counter", "totp": "clock-o"}},
+		{in: []string{"hotp"}, wantErr: true},
+		{in: []string{":key"}, wantErr: true},
+		{in: []string{"hotp:"}, wantErr: true},
+	} {
+		got, err := parseTypeIcons(c.in)
+		if (err != nil) != c.wantErr || (!c.wantErr && !reflect.DeepEqual(got, c.want)) {
+			t.Errorf("parseTypeIcons(%v) = %v, %v, expected %v (error %v)", c.in, got, err, c.want, c.wantErr)
+		}
+	}
+}
+
+func TestTokenFromDataTypeIcon(t *testing.T) {
+	oldIcons := typeIcons
+	defer func() { typeIcons = oldIcons }()
+	typeIcons = map[string]string{tokenTypeHOTP: "sort-numeric-asc"}
+
+	for _, c := range []struct {
+		name string
+		data map[string]interface{}
+		want string
+	}{
+		{name: "configured type", data: map[string]interface{}{"type": "hotp"}, want: "sort-numeric-asc"},
+		{name: "unconfigured type", data: map[string]interface{}{}, want: "key"},
+		{name: "explicit icon", data: map[string]interface{}{"type": "hotp", "icon": "github"}, want: "github"},
+	} {
+		c.data["secret"] = "JBSWY3DPEHPK3PXP"
+		if tok := tokenFromData(context.Background(), "key", c.data); tok.Icon != c.want {
+			t.Errorf("%s: Icon = %q, expected %q", c.name, tok.Icon, c.want)
+		}
+	}
+}
+
+func TestTokenListExpiringWithin(t *testing.T) {
+	tokens := tokenList{
+		{Name: "soon", RemainingSeconds: 3},
+		{Name: "edge", RemainingSeconds: 10},
+		{Name: "later", RemainingSeconds: 25},
+		// Codes not expiring by time are never about to expire
+		{Name: "counter"},
+	}
+
+	for _, c := range []struct {
+		seconds int
+		want    []string
+	}{
+		{seconds: 1, want: nil},
+		{seconds: 10, want: []string{"soon"}},
+		{seconds: 11, want: []string{"soon", "edge"}},
+		{seconds: 60, want: []string{"soon", "edge", "later"}},
+	} {
+		var got []string
+		for _, tok := range tokens.ExpiringWithin(c.seconds) {
+			got = append(got, tok.Name)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ExpiringWithin(%d) = %v, expected %v", c.seconds, got, c.want)
+		}
+	}
+}
+
+func TestGenerateCodeRemainingSeconds(t *testing.T) {
+	for _, next := range []bool{false, true} {
+		tok := &token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Period: 30}
+		before := time.Now()
+		if err := tok.GenerateCode(next); err != nil {
+			t.Fatalf("Unable to generate code: %s", err)
+		}
+
+		want := 30 - int(before.Unix()%30)
+		if next {
+			want += 30
+		}
+		// Allow the period to wrap while generating
+		if tok.RemainingSeconds != want && tok.RemainingSeconds != want-1 && tok.RemainingSeconds != want+29 {
+			t.Errorf("next=%v: Expected %d remaining seconds, got %d", next, want, tok.RemainingSeconds)
+		}
+	}
+}
+
+func TestTokenIsValid(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.MinTTL = time.Minute
+
+	for _, c := range []struct {
+		name      string
+		lookup    string // Empty to reject the token
+		renew     string // Empty to reject the renewal
+		want      bool
+		wantRenew bool
+	}{
+		{name: "long TTL", lookup: `{"data":{"ttl":3600}}`, want: true},
+		{name: "not expiring", lookup: `{"data":{"ttl":0}}`, want: true},
+		{name: "short TTL", lookup: `{"data":{"ttl":30}}`},
+		{name: "renewed", lookup: `{"data":{"ttl":30,"renewable":true}}`, renew: `{"auth":{"client_token":"s.user","lease_duration":3600}}`, want: true, wantRenew: true},
+		{name: "renewal capped", lookup: `{"data":{"ttl":30,"renewable":true}}`, renew: `{"auth":{"client_token":"s.user","lease_duration":45}}`, wantRenew: true},
+		{name: "renewal failed", lookup: `{"data":{"ttl":30,"renewable":true}}`, wantRenew: true},
+		{name: "invalid token"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var renewed bool
+			vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+				res.Header().Set("Content-Type", "application/json")
+				body := c.lookup
+				if r.URL.Path == "/v1/auth/token/renew-self" {
+					renewed, body = true, c.renew
+				}
+				if body == "" {
+					http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+					return
+				}
+				res.Write([]byte(body))
+			}))
+			defer vault.Close()
+
+			cfg.Vault.Address = vault.URL
+			client, err := newVaultClient()
+			if err != nil {
+				t.Fatalf("Unable to create client: %s", err)
+			}
+
+			if got := tokenIsValid(client, "s.user"); got != c.want {
+				t.Errorf("Expected valid=%v, got %v", c.want, got)
+			}
+			if renewed != c.wantRenew {
+				t.Errorf("Expected renewal=%v, got %v", c.wantRenew, renewed)
+			}
+		})
+	}
+}
+
+func TestResolveDefaults(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		name       string
+		profile    string
+		tok        token
+		wantDigits int
+		wantPeriod int
+	}{
+		{name: "profile defaults", profile: "default", tok: token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP}, wantDigits: 6, wantPeriod: 30},
+		{name: "other profile", profile: "authy", tok: token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP}, wantDigits: 7, wantPeriod: 10},
+		{name: "own values", profile: "authy", tok: token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Digits: 8, Period: 60}, wantDigits: 8, wantPeriod: 60},
+		// Stored codes are refreshed in the period of the profile
+		{name: "stored code", profile: "authy", tok: token{StoredCode: "123456", Type: tokenTypeTOTP}, wantPeriod: 10},
+		// Counter based codes have no period to refresh them in
+		{name: "hotp", profile: "default", tok: token{Secret: rfc4226Secret, Type: tokenTypeHOTP}},
+	} {
+		cfg.OTP.Profile = c.profile
+
+		for _, generate := range []bool{false, true} {
+			tok := c.tok
+			var err error
+			if generate {
+				err = tok.GenerateCode(false)
+			} else {
+				err = tok.resolveDefaults()
+			}
+			if err != nil {
+				t.Fatalf("%s (generate %v): Unexpected error: %s", c.name, generate, err)
+			}
+
+			if tok.Period != c.wantPeriod || (c.wantDigits != 0 && tok.Digits != c.wantDigits) {
+				t.Errorf("%s (generate %v): Expected %d digits / %ds, got %d / %ds", c.name, generate, c.wantDigits, c.wantPeriod, tok.Digits, tok.Period)
+			}
+		}
+	}
+}
+
+func TestTokenListSortByCreated(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.UI.SortBy = sortByCreated
+
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	tokens := tokenList{
+		{Name: "old", Created: day(1)},
+		{Name: "unknown-b"},
+		{Name: "new", Created: day(3)},
+		{Name: "unknown-a"},
+		{Name: "same-b", Created: day(2)},
+		{Name: "same-a", Created: day(2)},
+	}
+	sort.Sort(tokens)
+
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Name)
+	}
+	// Newest first, equal times by name, unknown creation last
+	want := []string{"new", "same-a", "same-b", "old", "unknown-a", "unknown-b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected order %v, got %v", want, got)
+	}
+}
+
+func TestTokenListSortByExpiry(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.UI.SortBy = sortByExpiry
+
+	for _, c := range []struct {
+		name         string
+		expiringLast bool
+		want         []string
+	}{
+		// Equal remaining times by name, codes not expiring by time last
+		{name: "expiring first", want: []string{"soon", "mid-a", "mid-b", "late", "hotp-a", "hotp-b"}},
+		{name: "expiring last", expiringLast: true, want: []string{"late", "mid-a", "mid-b", "soon", "hotp-a", "hotp-b"}},
+	} {
+		cfg.UI.SortExpiringLast = c.expiringLast
+
+		tokens := tokenList{
+			{Name: "hotp-b"},
+			{Name: "late", RemainingSeconds: 55},
+			{Name: "mid-b", RemainingSeconds: 12},
+			{Name: "soon", RemainingSeconds: 2},
+			{Name: "hotp-a"},
+			{Name: "mid-a", RemainingSeconds: 12},
+		}
+		sort.Sort(tokens)
+
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, tok.Name)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Expected order %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestPathLess(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		a, b          string
+		caseSensitive bool
+		want          bool
+	}{
+		{a: "totp/a", b: "totp/b", want: true},
+		{a: "totp/b", b: "totp/a"},
+		{a: "totp/a", b: "totp/a"},
+		// Keys of a folder come before its sub-folders
+		{a: "totp/z", b: "totp/a/a", want: true},
+		{a: "totp/a/a", b: "totp/z"},
+		{a: "totp/a/b/c", b: "totp/a/c"},
+		{a: "totp/a/c", b: "totp/a/b/c", want: true},
+		// Sibling folders by name regardless of their depth
+		{a: "totp/a/b/c", b: "totp/b/a", want: true},
+		{a: "totp/b/a", b: "totp/a/b/c"},
+		// Keys without folder come first
+		{a: "mail", b: "totp/a", want: true},
+		{a: "totp/a", b: "mail"},
+		{a: "Mail", b: "chat"},
+		{a: "Mail", b: "chat", caseSensitive: true, want: true},
+		{a: "totp/Work/mail", b: "totp/home/mail"},
+		{a: "totp/Work/mail", b: "totp/home/mail", caseSensitive: true, want: true},
+	} {
+		cfg.UI.CaseSensitiveSort = c.caseSensitive
+		if got := pathLess(c.a, c.b); got != c.want {
+			t.Errorf("pathLess(%q, %q) (case sensitive %v) = %v, expected %v", c.a, c.b, c.caseSensitive, got, c.want)
+		}
+	}
+}
+
+func TestTokenListSortByPath(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.UI.SortBy = sortByPath
+
+	// Folders are ignored in favor of the path, tokens of the same
+	// path are ordered by name
+	tokens := tokenList{
+		{Name: "A", Path: "totp/work/z", Folder: "work"},
+		{Name: "B", Path: "totp/mail"},
+		{Name: "C", Path: "totp/work/a", Folder: "work"},
+		{Name: "E", Path: "totp/chat"},
+		{Name: "D", Path: "totp/chat"},
+		{Name: "F", Path: "totp/home/sub/a", Folder: "home/sub"},
+	}
+	sort.Sort(tokens)
+
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Name)
+	}
+	if want := []string{"D", "E", "B", "F", "C", "A"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected order %v, got %v", want, got)
+	}
+}
+
+func TestSecretFields(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		field   string
+		aliases []string
+		want    []string
+	}{
+		{field: "secret", want: []string{"secret"}},
+		{field: "secret", aliases: []string{"totp_secret", " seed "}, want: []string{"secret", "totp_secret", "seed"}},
+		{field: "secret", aliases: []string{"", "totp_secret"}, want: []string{"secret", "totp_secret"}},
+		// Listing the secret field in the aliases defines its precedence
+		{field: "secret", aliases: []string{"totp_secret", "secret"}, want: []string{"totp_secret", "secret"}},
+	} {
+		cfg.Vault.SecretField, cfg.Vault.SecretFieldAliases = c.field, c.aliases
+		if got := secretFields(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("secretFields() with %q / %q = %q, expected %q", c.field, c.aliases, got, c.want)
+		}
+	}
+}
+
+func TestLookupSecret(t *testing.T) {
+	oldCfg, oldOut := cfg, log.StandardLogger().Out
+	defer func() {
+		cfg = oldCfg
+		log.SetOutput(oldOut)
+	}()
+	cfg.Vault.SecretField, cfg.Vault.SecretFieldAliases = "secret", []string{"totp_secret"}
+
+	for _, c := range []struct {
+		name          string
+		data          map[string]interface{}
+		want          string
+		wantDuplicate bool
+	}{
+		{name: "secret field", data: map[string]interface{}{"secret": "AAAA"}, want: "AAAA"},
+		{name: "alias", data: map[string]interface{}{"totp_secret": "BBBB"}, want: "BBBB"},
+		{name: "duplicate", data: map[string]interface{}{"secret": "AAAA", "totp_secret": "BBBB"}, want: "AAAA", wantDuplicate: true},
+		// Empty fields are no duplicates and do not shadow the alias
+		{name: "empty secret field", data: map[string]interface{}{"secret": " ", "totp_secret": "BBBB"}, want: "BBBB"},
+		{name: "none", data: map[string]interface{}{"name": "Mail"}, want: ""},
+	} {
+		buf := new(bytes.Buffer)
+		log.SetOutput(buf)
+
+		if got := lookupSecret(context.Background(), "totp/mail", c.data); got != c.want {
+			t.Errorf("%s: Expected secret %q, got %q", c.name, c.want, got)
+		}
+		if logged := strings.Contains(buf.String(), "multiple secret fields"); logged != c.wantDuplicate {
+			t.Errorf("%s: Expected duplicate warning %v, got log %q", c.name, c.wantDuplicate, buf.String())
+		}
+	}
+}
+
+func TestTokenFromDataSecretPrecedence(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	data := map[string]interface{}{"secret": "AAAA", "totp_secret": "BBBB", "seed": "CCCC"}
+
+	for _, c := range []struct {
+		name    string
+		field   string
+		aliases []string
+		want    string
+	}{
+		{name: "secret field first", field: "secret", aliases: []string{"totp_secret", "seed"}, want: "AAAA"},
+		{name: "alias preferred", field: "secret", aliases: []string{"totp_secret", "secret"}, want: "BBBB"},
+		{name: "secret field last", field: "secret", aliases: []string{"seed", "totp_secret", "secret"}, want: "CCCC"},
+		{name: "other canonical field", field: "seed", aliases: []string{"secret"}, want: "CCCC"},
+		{name: "other canonical field ordered", field: "seed", aliases: []string{"totp_secret", "seed", "secret"}, want: "BBBB"},
+	} {
+		cfg.Vault.SecretField, cfg.Vault.SecretFieldAliases = c.field, c.aliases
+
+		if tok := tokenFromData(context.Background(), "totp/mail", data); tok.Secret != c.want {
+			t.Errorf("%s: Expected secret %q, got %q", c.name, c.want, tok.Secret)
+		}
+	}
+}
+
+func TestTokenFromDataExposePath(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, expose := range []bool{false, true} {
+		cfg.UI.ExposePath = expose
+
+		tok := tokenFromData(context.Background(), "totp/team/mail", map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"})
+		raw, err := json.Marshal(tok)
+		if err != nil {
+			t.Fatalf("Unable to marshal token: %s", err)
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			t.Fatalf("Unable to unmarshal token: %s", err)
+		}
+
+		path, ok := fields["path"]
+		if ok != expose || (expose && path != "totp/team/mail") {
+			t.Errorf("expose %v: Unexpected path in the JSON: %s", expose, raw)
+		}
+	}
+}
+
+func TestTokenListCaseSensitiveSort(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.UI.SortBy = sortByName
+
+	for _, c := range []struct {
+		caseSensitive bool
+		want          []string
+	}{
+		{want: []string{"apple", "Banana", "cherry", "Docker"}},
+		// Uppercase letters sort before all lowercase ones
+		{caseSensitive: true, want: []string{"Banana", "Docker", "apple", "cherry"}},
+	} {
+		cfg.UI.CaseSensitiveSort = c.caseSensitive
+
+		tokens := tokenList{{Name: "cherry"}, {Name: "Docker"}, {Name: "apple"}, {Name: "Banana"}}
+		sort.Sort(tokens)
+
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, tok.Name)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("case sensitive %v: Expected order %v, got %v", c.caseSensitive, c.want, got)
+		}
+	}
+}
+
+func TestNextRefresh(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	now := time.Date(2020, 1, 1, 12, 0, 1, 0, time.UTC)
+	at := func(sec int) time.Time { return now.Add(time.Duration(sec) * time.Second) }
+
+	for _, c := range []struct {
+		name   string
+		floor  time.Duration
+		wrap   int // Seconds from now
+		period int
+		want   int
+	}{
+		{name: "no floor", wrap: 1, period: 2, want: 1},
+		{name: "wrap after the floor", floor: 10 * time.Second, wrap: 29, period: 30, want: 29},
+		// Stays on the boundaries of the period
+		{name: "tiny period", floor: 10 * time.Second, wrap: 1, period: 2, want: 11},
+		{name: "floor on a boundary", floor: 9 * time.Second, wrap: 1, period: 2, want: 9},
+		{name: "floor between boundaries", floor: 10 * time.Second, wrap: 3, period: 4, want: 11},
+	} {
+		cfg.UI.MinRefresh = c.floor
+		if got := nextRefresh(at(c.wrap), now, c.period); !got.Equal(at(c.want)) {
+			t.Errorf("%s: Expected refresh %s after now, got %s", c.name, at(c.want).Sub(now), got.Sub(now))
+		}
+	}
+}
+
+func TestTokenFromDataNameFields(t *testing.T) {
+	oldCfg, oldOut, oldLevel := cfg, log.StandardLogger().Out, log.GetLevel()
+	defer func() {
+		cfg = oldCfg
+		log.SetOutput(oldOut)
+		log.SetLevel(oldLevel)
+	}()
+	log.SetLevel(log.DebugLevel)
+
+	for _, c := range []struct {
+		name        string
+		fields      []string
+		data        map[string]interface{}
+		want        string
+		wantIgnored string // Name fields logged as ignored, empty for no log
+	}{
+		{name: "default fields", fields: []string{"name", "account_name"}, data: map[string]interface{}{"account_name": "Account"}, want: "Account"},
+		{name: "precedence", fields: []string{"name", "account_name"}, data: map[string]interface{}{"name": "Name", "account_name": "Account"}, want: "Name", wantIgnored: "account_name"},
+		{name: "custom order", fields: []string{"account_name", "name"}, data: map[string]interface{}{"name": "Name", "account_name": "Account"}, want: "Account", wantIgnored: "name"},
+		{name: "empty field skipped", fields: []string{"label", "name"}, data: map[string]interface{}{"label": "", "name": "Name"}, want: "Name"},
+		{name: "empty field not ignored", fields: []string{"name", "label"}, data: map[string]interface{}{"label": "", "name": "Name"}, want: "Name"},
+		{name: "non-string field not ignored", fields: []string{"name", "label"}, data: map[string]interface{}{"label": 42, "name": "Name"}, want: "Name"},
+		{
+			name:        "multiple ignored",
+			fields:      []string{"label", "name", "account_name"},
+			data:        map[string]interface{}{"label": "Label", "name": "Name", "account_name": "Account"},
+			want:        "Label",
+			wantIgnored: "name,account_name",
+		},
+		{name: "nested field", fields: []string{"meta.label"}, data: map[string]interface{}{"meta": map[string]interface{}{"label": "Nested"}}, want: "Nested"},
+		{name: "key as fallback", fields: []string{"label"}, data: map[string]interface{}{"name": "Name"}, want: "totp/mail"},
+	} {
+		cfg.Vault.NameFields = c.fields
+		c.data["secret"] = "JBSWY3DPEHPK3PXP"
+		buf := new(bytes.Buffer)
+		log.SetOutput(buf)
+
+		if tok := tokenFromData(context.Background(), "totp/mail", c.data); tok.Name != c.want {
+			t.Errorf("%s: Name = %q, expected %q", c.name, tok.Name, c.want)
+		}
+
+		logged := strings.Contains(buf.String(), "Multiple name fields set")
+		switch {
+		case c.wantIgnored == "" && logged:
+			t.Errorf("%s: Expected no log about ignored name fields, got %q", c.name, buf.String())
+		case c.wantIgnored != "" && (!logged || !regexp.MustCompile(`ignored="?`+regexp.QuoteMeta(c.wantIgnored)+`"?\s`).MatchString(buf.String())):
+			t.Errorf("%s: Expected ignored fields %q to be logged, got %q", c.name, c.wantIgnored, buf.String())
+		}
+	}
+}
+
+func TestTransformSecret(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		args    []string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "unchanged", in: "base32:JBSWY3DPEHPK3PXP", want: "base32:JBSWY3DPEHPK3PXP"},
+		{name: "strip prefix", args: []string{"--vault-secret-strip-prefix", "base32:"}, in: "base32:JBSWY3DPEHPK3PXP", want: "JBSWY3DPEHPK3PXP"},
+		{name: "prefix missing", args: []string{"--vault-secret-strip-prefix", "base32:"}, in: "JBSWY3DPEHPK3PXP", want: "JBSWY3DPEHPK3PXP"},
+		{name: "replace", args: []string{"--vault-secret-replace", "-"}, in: "JBSW-Y3DP-EHPK-3PXP", want: "JBSWY3DPEHPK3PXP"},
+		{
+			name: "replace with reference",
+			args: []string{"--vault-secret-replace", `^otpauth-seed\((\w+)\)$`, "--vault-secret-replace-with", "$1"},
+			in:   "otpauth-seed(JBSWY3DPEHPK3PXP)",
+			want: "JBSWY3DPEHPK3PXP",
+		},
+		// The prefix is stripped before the expression is applied
+		{
+			name: "strip and replace",
+			args: []string{"--vault-secret-strip-prefix", "seed:", "--vault-secret-replace", "^seed:"},
+			in:   "seed:seed:JBSWY3DPEHPK3PXP",
+			want: "JBSWY3DPEHPK3PXP",
+		},
+		{name: "invalid expression", args: []string{"--vault-secret-replace", "(["}, wantErr: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldReplace := secretReplace
+			defer func() { secretReplace = oldReplace }()
+
+			withArgs(t, c.args, nil, func(err error) {
+				if (err != nil) != c.wantErr {
+					t.Fatalf("loadConfig() returned error %v, expected error %v", err, c.wantErr)
+				}
+				if c.wantErr {
+					return
+				}
+
+				if got := transformSecret(context.Background(), "totp/mail", c.in); got != c.want {
+					t.Errorf("Expected secret %q, got %q", c.want, got)
+				}
+			})
+		})
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	base := token{Name: "Mail", Issuer: "GitHub", Type: tokenTypeTOTP, Digits: 6, Period: 30, Secret: "JBSWY3DPEHPK3PXP", Code: "123456"}
+	fp := base.ConfigFingerprint()
+	if len(fp) != 16 {
+		t.Fatalf("Expected 16 hex characters, got %q", fp)
+	}
+
+	for _, c := range []struct {
+		name       string
+		modify     func(*token)
+		wantChange bool
+	}{
+		{name: "unchanged", modify: func(*token) {}},
+		{name: "secret", modify: func(t *token) { t.Secret = "GEZDGNBVGY3TQOJQ" }},
+		{name: "code", modify: func(t *token) { t.Code = "654321" }},
+		{name: "name", modify: func(t *token) { t.Name = "Mail (work)" }, wantChange: true},
+		{name: "period", modify: func(t *token) { t.Period = 60 }, wantChange: true},
+		{name: "digits", modify: func(t *token) { t.Digits = 8 }, wantChange: true},
+		{name: "algorithm", modify: func(t *token) { t.Algorithm = "sha256" }, wantChange: true},
+		{name: "deleted", modify: func(t *token) { t.Deleted = true }, wantChange: true},
+		// The separator keeps values moving between fields apart
+		{name: "shifted values", modify: func(t *token) { t.Name, t.Issuer = "MailGit", "Hub" }, wantChange: true},
+	} {
+		tok := base
+		c.modify(&tok)
+		if changed := tok.ConfigFingerprint() != fp; changed != c.wantChange {
+			t.Errorf("%s: Expected fingerprint change %v, got %v", c.name, c.wantChange, changed)
+		}
+	}
+}
+
+func TestTokenAddNextCode(t *testing.T) {
+	offset := 20 * time.Second
+
+	for _, c := range []struct {
+		name         string
+		tok          token
+		now          int64
+		wantBoundary int64
+		wantNext     string
+	}{
+		// RFC 6238 appendix B, T = 1111111109 and T = 1111111111 are in
+		// consecutive periods
+		{name: "end of period", tok: token{Digits: 8}, now: 1111111109, wantBoundary: 1111111110, wantNext: "14050471"},
+		{name: "start of period", tok: token{Digits: 8}, now: 1111111080, wantBoundary: 1111111110, wantNext: "14050471"},
+		{name: "shifted epoch", tok: token{Digits: 8, T0: 30}, now: 1111111139, wantBoundary: 1111111140, wantNext: "14050471"},
+		{name: "offset", tok: token{Digits: 8, Offset: &offset}, now: 1111111089, wantBoundary: 1111111090, wantNext: "14050471"},
+		{name: "longer period", tok: token{Digits: 8, Period: 60}, now: 1111111109, wantBoundary: 1111111140},
+		{name: "hotp", tok: token{Type: tokenTypeHOTP}, now: 1111111109},
+		{name: "no next", tok: token{NoNext: true}, now: 1111111109},
+		{name: "no secret", tok: token{StoredCode: "123456"}, now: 1111111109},
+	} {
+		tok := c.tok
+		if tok.StoredCode == "" {
+			tok.Secret = rfc4226Secret
+		}
+
+		if err := tok.AddNextCode(time.Unix(c.now, 0)); err != nil {
+			t.Errorf("%s: Unexpected error: %s", c.name, err)
+			continue
+		}
+
+		if c.wantBoundary == 0 {
+			if tok.NextCode != "" || tok.ValidUntil != nil || tok.NextValidFrom != nil {
+				t.Errorf("%s: Expected no next code, got %q (%v / %v)", c.name, tok.NextCode, tok.ValidUntil, tok.NextValidFrom)
+			}
+			continue
+		}
+
+		if tok.ValidUntil == nil || tok.NextValidFrom == nil || !tok.ValidUntil.Equal(*tok.NextValidFrom) {
+			t.Fatalf("%s: Expected matching boundaries, got %v / %v", c.name, tok.ValidUntil, tok.NextValidFrom)
+		}
+		if got := tok.ValidUntil.Unix(); got != c.wantBoundary {
+			t.Errorf("%s: Expected boundary %d, got %d", c.name, c.wantBoundary, got)
+		}
+		if c.wantNext != "" && tok.NextCode != c.wantNext {
+			t.Errorf("%s: Expected next code %q, got %q", c.name, c.wantNext, tok.NextCode)
+		}
+
+		// The current code is the one of the last second before the boundary
+		opts, _ := tok.totpOpts()
+		current, _ := tok.codeAt(time.Unix(c.now, 0), opts)
+		if before, _ := tok.codeAt(tok.ValidUntil.Add(-time.Second), opts); before != current {
+			t.Errorf("%s: Expected the current code %q to be valid until the boundary, got %q", c.name, current, before)
+		}
+		if next, _ := tok.codeAt(*tok.NextValidFrom, opts); next != tok.NextCode {
+			t.Errorf("%s: Expected the next code %q from the boundary, got %q", c.name, tok.NextCode, next)
+		}
+	}
+}
+
+func TestTokenFromDataTags(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		tags interface{}
+		want []string
+	}{
+		{name: "absent"},
+		{name: "empty string", tags: ""},
+		{name: "single", tags: "prod", want: []string{"prod"}},
+		{name: "comma-separated", tags: " prod, personal ,,work", want: []string{"prod", "personal", "work"}},
+		{name: "list", tags: []interface{}{"prod", 42, " ", "work"}, want: []string{"prod", "work"}},
+		{name: "unexpected type", tags: 42},
+	} {
+		data := map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}
+		if c.tags != nil {
+			data["tags"] = c.tags
+		}
+
+		tok := tokenFromData(context.Background(), "key", data)
+		if !reflect.DeepEqual(tok.Tags, c.want) {
+			t.Errorf("%s: Expected tags %#v, got %#v", c.name, c.want, tok.Tags)
+		}
+	}
+}
+
+func TestTokenListWithTag(t *testing.T) {
+	tokens := tokenList{
+		{Name: "Prod", Tags: []string{"prod", "work"}},
+		{Name: "Personal", Tags: []string{"Personal"}},
+		{Name: "Untagged"},
+	}
+
+	for _, c := range []struct {
+		tag  string
+		want []string
+	}{
+		{tag: "prod", want: []string{"Prod"}},
+		{tag: "work", want: []string{"Prod"}},
+		{tag: "PERSONAL", want: []string{"Personal"}},
+		{tag: "staging", want: []string{}},
+	} {
+		got := []string{}
+		for _, tok := range tokens.WithTag(c.tag) {
+			got = append(got, tok.Name)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Expected tokens %v, got %v", c.tag, c.want, got)
+		}
+	}
+}
+
+func TestTokenMaskCodes(t *testing.T) {
+	tok := &token{Code: "123456", NextCode: "12345678", RecoveryCodes: []string{"abcd-efgh", ""}}
+	tok.MaskCodes()
+
+	want := &token{Code: "••••••", NextCode: "••••••••", RecoveryCodes: []string{"•••••••••", ""}}
+	if !reflect.DeepEqual(tok, want) {
+		t.Errorf("Expected %+v, got %+v", want, tok)
+	}
+}
+
+func TestTokenUpcoming(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.CodeMode = codeModeStatic
+
+	for _, c := range []struct {
+		name         string
+		tok          *token
+		next         bool
+		wantUpcoming bool
+	}{
+		{name: "current totp", tok: &token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP}},
+		{name: "next totp", tok: &token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP}, next: true, wantUpcoming: true},
+		{name: "previously upcoming totp", tok: &token{Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP, Upcoming: true}},
+		// Codes not bound to the time have no next period
+		{name: "next hotp", tok: &token{Secret: rfc4226Secret, Type: tokenTypeHOTP, Counter: 1}, next: true},
+		{name: "next stored code", tok: &token{StoredCode: "123456", Type: tokenTypeTOTP}, next: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.tok.GenerateCode(c.next); err != nil {
+				t.Fatalf("Unable to generate code: %s", err)
+			}
+			if c.tok.Upcoming != c.wantUpcoming {
+				t.Errorf("Expected upcoming %v, got %v", c.wantUpcoming, c.tok.Upcoming)
+			}
+
+			buf, err := json.Marshal(c.tok)
+			if err != nil {
+				t.Fatalf("Unable to marshal token: %s", err)
+			}
+			if got := strings.Contains(string(buf), `"upcoming":true`); got != c.wantUpcoming {
+				t.Errorf("Expected upcoming %v in JSON, got %s", c.wantUpcoming, buf)
+			}
+		})
+	}
+}
+
+func TestTokenGenerationTime(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		tok     *token
+		wantErr bool
+	}{
+		{name: "totp", tok: &token{Name: "Mail", Secret: "JBSWY3DPEHPK3PXP", Type: tokenTypeTOTP}},
+		{name: "invalid secret", tok: &token{Name: "Broken", Secret: "not base32!", Type: tokenTypeTOTP}, wantErr: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			// Time of a previous generation must not leak into the next one
+			c.tok.GenerationTime = time.Hour
+
+			if err := c.tok.GenerateCode(false); (err != nil) != c.wantErr {
+				t.Fatalf("GenerateCode() error = %v, expected error %v", err, c.wantErr)
+			}
+			if c.tok.GenerationTime <= 0 || c.tok.GenerationTime >= time.Hour {
+				t.Errorf("Expected the generation time to be measured, got %s", c.tok.GenerationTime)
+			}
+
+			// Only the explicitly set debug field is serialized
+			buf, err := json.Marshal(c.tok)
+			if err != nil {
+				t.Fatalf("Unable to marshal token: %s", err)
+			}
+			if strings.Contains(string(buf), "generation_time") {
+				t.Errorf("Expected no generation time without debugging, got %s", buf)
+			}
+		})
+	}
+}
+
+func TestTokenFromDataNumericAlgorithm(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		algorithm interface{}
+		want      otp.Algorithm
+		wantErr   bool
+	}{
+		{name: "name", algorithm: "sha256", want: otp.AlgorithmSHA256},
+		{name: "json number", algorithm: json.Number("1"), want: otp.AlgorithmSHA256},
+		{name: "float", algorithm: float64(2), want: otp.AlgorithmSHA512},
+		{name: "int", algorithm: 0, want: otp.AlgorithmSHA1},
+		{name: "numeric string", algorithm: "2", want: otp.AlgorithmSHA512},
+		{name: "unknown number", algorithm: json.Number("7"), wantErr: true},
+		{name: "fraction", algorithm: 1.5, wantErr: true},
+	} {
+		tok := tokenFromData(context.Background(), "key", map[string]interface{}{
+			"secret":    "JBSWY3DPEHPK3PXP",
+			"algorithm": c.algorithm,
+		})
+
+		opts, err := tok.totpOpts()
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: totpOpts() error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && opts.Algorithm != c.want {
+			t.Errorf("%s: Expected algorithm %v, got %v", c.name, c.want, opts.Algorithm)
+		}
+	}
+}
+
+func TestUseOrRenewTokenAccessTokenValidation(t *testing.T) {
+	var logins int32
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/v1/auth/github/login" {
+			http.NotFound(res, r)
+			return
+		}
+		atomic.AddInt32(&logins, 1)
+		res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Github.AuthMount = "github"
+	cfg.Github.LoginTimeout = time.Second
+
+	for _, c := range []struct {
+		name      string
+		maxLength int
+		token     string
+		wantErr   bool
+	}{
+		{name: "valid", maxLength: 40, token: strings.Repeat("a", 40)},
+		{name: "empty", maxLength: 40, token: "", wantErr: true},
+		{name: "oversized", maxLength: 40, token: strings.Repeat("a", 41), wantErr: true},
+		{name: "no length limit", maxLength: 0, token: strings.Repeat("b", 4096)},
+		{name: "empty without length limit", maxLength: 0, token: "", wantErr: true},
+	} {
+		cfg.Github.MaxTokenLength = c.maxLength
+		atomic.StoreInt32(&logins, 0)
+
+		tok, err := useOrRenewToken("", c.token)
+		if c.wantErr {
+			if errors.Cause(err) != errInvalidAccessToken || isVaultUnavailable(err) {
+				t.Errorf("%s: Expected errInvalidAccessToken, got %v", c.name, err)
+			}
+			if n := atomic.LoadInt32(&logins); n != 0 {
+				t.Errorf("%s: Expected no login against Vault, got %d", c.name, n)
+			}
+			continue
+		}
+
+		if err != nil || tok != "s.user" {
+			t.Errorf("%s: Expected the token of the login, got %q / %v", c.name, tok, err)
+		}
+		if n := atomic.LoadInt32(&logins); n != 1 {
+			t.Errorf("%s: Expected one login against Vault, got %d", c.name, n)
+		}
+	}
+}
+
+func TestTokenListETag(t *testing.T) {
+	mail := &token{Name: "Mail", Digits: 6, Period: 30, Code: "123456"}
+	chat := &token{Name: "Chat", Digits: 8, Period: 30, Code: "87654321"}
+
+	etag := tokenList{mail, chat}.ETag()
+	if !regexp.MustCompile(`^W/"[0-9a-f]{16}"$`).MatchString(etag) {
+		t.Fatalf("Expected a weak ETag, got %q", etag)
+	}
+
+	for _, c := range []struct {
+		name      string
+		tokens    tokenList
+		wantEqual bool
+	}{
+		{name: "other order", tokens: tokenList{chat, mail}, wantEqual: true},
+		{name: "other codes", tokens: tokenList{mail, {Name: "Chat", Digits: 8, Period: 30, Code: "11111111"}}, wantEqual: true},
+		{name: "token removed", tokens: tokenList{mail}},
+		{name: "token added", tokens: tokenList{mail, chat, {Name: "Bank"}}},
+		{name: "config changed", tokens: tokenList{mail, {Name: "Chat", Digits: 6, Period: 30}}},
+		{name: "empty", tokens: tokenList{}},
+	} {
+		if equal := c.tokens.ETag() == etag; equal != c.wantEqual {
+			t.Errorf("%s: Expected equal ETag %v, got %v", c.name, c.wantEqual, equal)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// parseTokenConfig reads the config field holding digits, period and
+// algorithm in one place. It is either a nested map or a string
+// containing a JSON object.
+func parseTokenConfig(v interface{}) (map[string]interface{}, error) {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		return tv, nil
+
+	case string:
+		var out map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader([]byte(tv)))
+		dec.UseNumber()
+		if err := dec.Decode(&out); err != nil {
+			return nil, errors.Wrap(err, "Config is no JSON object")
+		}
+		return out, nil
+
+	default:
+		return nil, errors.Errorf("Config of unexpected type %T", v)
+	}
+}
+
+// applyConfig sets the values from the config field which are not
+// already set through their own fields. An invalid config is not applied
+// at all.
+func (t *token) applyConfig(conf map[string]interface{}) error {
+	digits, period, algorithm := t.Digits, t.Period, t.Algorithm
+
+	if v, ok := conf["digits"]; ok && digits == 0 {
+		var err error
+		switch digits, err = strconv.Atoi(fmt.Sprint(v)); {
+		case err != nil:
+			return errors.Wrap(err, "Unable to parse digits")
+		case digits < minDigits || digits > maxDigits:
+			return errors.Errorf("Field digits %d out of range (%d-%d)", digits, minDigits, maxDigits)
+		}
+	}
+
+	if v, ok := conf["period"]; ok && period == 0 {
+		var err error
+		switch period, err = strconv.Atoi(fmt.Sprint(v)); {
+		case err != nil:
+			return errors.Wrap(err, "Unable to parse period")
+		case period <= 0:
+			return errors.Errorf("Field period %d must be positive", period)
+		}
+	}
+
+	if v, ok := conf["algorithm"]; ok && algorithm == "" {
+		algorithm = fmt.Sprint(v)
+	}
+
+	t.Digits, t.Period, t.Algorithm = digits, period, algorithm
+	return nil
+}
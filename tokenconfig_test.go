@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTokenConfig(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		in      interface{}
+		wantErr bool
+		want    map[string]interface{}
+	}{
+		{name: "map", in: map[string]interface{}{"digits": "8"}, want: map[string]interface{}{"digits": "8"}},
+		{name: "JSON string", in: `{"digits":8,"period":60}`, want: map[string]interface{}{"digits": json.Number("8"), "period": json.Number("60")}},
+		{name: "invalid JSON", in: `{"digits":`, wantErr: true},
+		{name: "JSON list", in: `[8, 60]`, wantErr: true},
+		{name: "number", in: json.Number("8"), wantErr: true},
+	} {
+		got, err := parseTokenConfig(c.in)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+		for k, v := range c.want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %#v, expected %#v", c.name, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		tok        token
+		conf       map[string]interface{}
+		wantErr    bool
+		wantDigits int
+		wantPeriod int
+		wantAlgo   string
+	}{
+		{
+			name:       "all values",
+			conf:       map[string]interface{}{"digits": json.Number("8"), "period": json.Number("60"), "algorithm": "SHA256"},
+			wantDigits: 8, wantPeriod: 60, wantAlgo: "SHA256",
+		},
+		{
+			name:       "own fields take precedence",
+			tok:        token{Digits: 6, Period: 30},
+			conf:       map[string]interface{}{"digits": "8", "period": "60"},
+			wantDigits: 6, wantPeriod: 30,
+		},
+		{name: "zero period", conf: map[string]interface{}{"period": "0"}, wantErr: true},
+		{name: "negative period", conf: map[string]interface{}{"period": float64(-30)}, wantErr: true},
+		{name: "too few digits", conf: map[string]interface{}{"digits": "2"}, wantErr: true},
+		{name: "too many digits", conf: map[string]interface{}{"digits": "12"}, wantErr: true},
+		{name: "invalid digits", conf: map[string]interface{}{"digits": true}, wantErr: true},
+		{
+			name:    "invalid config is not applied partially",
+			conf:    map[string]interface{}{"digits": "8", "period": "-1", "algorithm": "SHA512"},
+			wantErr: true,
+		},
+	} {
+		tok := c.tok
+		err := tok.applyConfig(c.conf)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: error = %v, expected error %v", c.name, err, c.wantErr)
+			continue
+		}
+
+		want := token{Digits: c.wantDigits, Period: c.wantPeriod, Algorithm: c.wantAlgo}
+		if c.wantErr {
+			want = c.tok
+		}
+		if tok.Digits != want.Digits || tok.Period != want.Period || tok.Algorithm != want.Algorithm {
+			t.Errorf("%s: got digits=%d period=%d algorithm=%q, expected digits=%d period=%d algorithm=%q",
+				c.name, tok.Digits, tok.Period, tok.Algorithm, want.Digits, want.Period, want.Algorithm)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+type validationProblem struct {
+	Key     string
+	Problem string
+}
+
+type validationReport struct {
+	Keys     int
+	Tokens   int
+	Problems []validationProblem
+}
+
+// runValidate checks the configuration against Vault without starting
+// the server and returns the exit code to use
+func runValidate() int {
+	if cfg.Source == sourceFile {
+		return validateFile()
+	}
+
+	fmt.Printf("Vault address:  %s\n", cfg.Vault.Address)
+	fmt.Printf("Prefix:         %s\n", cfg.Vault.Prefix)
+	fmt.Printf("Secret field:   %s\n", cfg.Vault.SecretField)
+
+	tok, err := useOrRenewToken(cfg.CLI.VaultToken, cfg.CLI.GithubToken)
+	if err != nil {
+		fmt.Printf("Authentication: failed (%s)\n", err)
+		return 1
+	}
+	fmt.Printf("Authentication: ok (token %s)\n", hashSecret(tok))
+
+	report, err := validateSecrets(tok)
+	if err != nil {
+		fmt.Printf("Listing:        failed (%s)\n", err)
+		return 1
+	}
+
+	fmt.Printf("Keys found:     %d\n", report.Keys)
+	fmt.Printf("OTP secrets:    %d\n", report.Tokens)
+
+	if len(report.Problems) > 0 {
+		fmt.Printf("\nProblems:\n")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, p := range report.Problems {
+			fmt.Fprintf(w, "  %s\t%s\n", p.Key, p.Problem)
+		}
+		w.Flush()
+	}
+
+	if report.Tokens == 0 {
+		fmt.Printf("\nNo usable OTP secrets found below prefix %q\n", cfg.Vault.Prefix)
+		return 1
+	}
+
+	return 0
+}
+
+func validateFile() int {
+	fmt.Printf("Source file:    %s\n", cfg.SourceFile)
+
+	secrets, err := getSecretsFromFile(context.Background(), false)
+	if err != nil {
+		fmt.Printf("Loading:        failed (%s)\n", err)
+		return 1
+	}
+
+	fmt.Printf("OTP secrets:    %d\n", len(secrets.Tokens))
+
+	if len(secrets.Tokens) == 0 {
+		fmt.Printf("\nNo usable OTP secrets found in %q\n", cfg.SourceFile)
+		return 1
+	}
+
+	return 0
+}
+
+func validateSecrets(tok string) (*validationReport, error) {
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create client")
+	}
+
+	client.SetToken(tok)
+
+	report := &validationReport{}
+	root := scanRoot()
+
+	if cfg.Vault.SingleKey {
+		report.check(client, root)
+		return report, nil
+	}
+
+	s, err := client.Logical().List(kvListPath(root))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to list keys %q", root)
+	}
+
+	if s == nil || s.Data["keys"] == nil {
+		return nil, errors.Errorf("There is no key %q", root)
+	}
+
+	report.walk(client, root, s)
+
+	return report, nil
+}
+
+func (v *validationReport) walk(client *api.Client, key string, s *api.Secret) {
+	keys, ok := s.Data["keys"].([]interface{})
+	if !ok {
+		v.addProblem(key, fmt.Sprintf("listing returned keys of unexpected type %T", s.Data["keys"]))
+		return
+	}
+
+	for _, sk := range keys {
+		sks, ok := sk.(string)
+		if !ok || sks == "" {
+			v.addProblem(key, fmt.Sprintf("listing contains entry of unexpected type %T", sk))
+			continue
+		}
+
+		k := path.Join(key, sks)
+
+		if !strings.HasSuffix(sks, "/") {
+			v.check(client, k)
+			continue
+		}
+
+		sub, err := client.Logical().List(kvListPath(k))
+		switch {
+		case err != nil:
+			v.addProblem(k, fmt.Sprintf("unable to list: %s", err))
+		case sub == nil || sub.Data["keys"] == nil:
+			v.addProblem(k, "folder is empty")
+		default:
+			v.walk(client, k, sub)
+		}
+	}
+}
+
+func (v *validationReport) check(client *api.Client, k string) {
+	v.Keys++
+
+	sec, err := client.Logical().Read(kvReadPath(k))
+	if _, ok := err.(*json.UnmarshalTypeError); ok || (err == nil && kvMalformed(k, sec)) {
+		v.addProblem(k, "secret data is not a map of fields")
+		return
+	}
+	if err != nil {
+		v.addProblem(k, fmt.Sprintf("unable to read: %s", err))
+		return
+	}
+
+	data, deleted := kvData(k, sec)
+	if deleted {
+		v.addProblem(k, "secret is deleted")
+		return
+	}
+
+	if data == nil {
+		v.addProblem(k, "key has no data")
+		return
+	}
+
+	tok, err := buildToken(context.Background(), k, data, buildOptions{
+		Created: kvCreatedTime(k, sec),
+		ReadKey: func(key string) (*api.Secret, error) { return client.Logical().Read(kvReadPath(key)) },
+		Codes:   true,
+	})
+	switch errors.Cause(err) {
+	case nil:
+	case errEmptySecret:
+		v.addProblem(k, "secret field is empty")
+		return
+	case errNoOTPFields:
+		v.addProblem(k, fmt.Sprintf("missing secret field (%s)", strings.Join(secretFields(), ", ")))
+		return
+	default:
+		v.addProblem(k, err.Error())
+		return
+	}
+
+	if tok == nil {
+		// Not matching the required fields is intentionally not displayed,
+		// not a problem
+		return
+	}
+
+	v.Tokens++
+}
+
+func (v *validationReport) addProblem(key, problem string) {
+	v.Problems = append(v.Problems, validationProblem{Key: key, Problem: problem})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout runs fn and returns what it printed to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "vault-otp-ui")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	oldStdout := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = oldStdout }()
+
+	fn()
+
+	out, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("Unable to read output: %s", err)
+	}
+	return string(out)
+}
+
+func TestRunValidate(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			if r.Header.Get("X-Vault-Token") != "s.valid" {
+				http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+				return
+			}
+			res.Write([]byte(`{"data":{"ttl":86400,"policies":["default"]}}`))
+		case r.URL.Path == "/v1/totp" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","userdata"]}}`))
+		case r.URL.Path == "/v1/broken" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["userdata"]}}`))
+		case r.URL.Path == "/v1/mixed" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail",42,"",null,"flat/"]}}`))
+		case r.URL.Path == "/v1/mixed/flat" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":"mail"}}`))
+		case r.URL.Path == "/v1/malformed" && r.URL.Query().Get("list") == "true":
+			res.Write([]byte(`{"data":{"keys":["mail","list"]}}`))
+		case r.URL.Path == "/v1/malformed/list":
+			res.Write([]byte(`{"data":["JBSWY3DPEHPK3PXP"]}`))
+		case r.URL.Path == "/v1/totp/mail", r.URL.Path == "/v1/mixed/mail", r.URL.Path == "/v1/malformed/mail":
+			res.Write([]byte(`{"data":{"name":"Mail","secret":"JBSWY3DPEHPK3PXP"}}`))
+		case strings.HasSuffix(r.URL.Path, "/userdata"):
+			res.Write([]byte(`{"data":{"username":"jdoe"}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	// Nothing listens on the address of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to allocate address: %s", err)
+	}
+	unavailable := "http://" + l.Addr().String()
+	l.Close()
+
+	dir, err := ioutil.TempDir("", "vault-otp-ui")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokens := filepath.Join(dir, "tokens.yaml")
+	if err = ioutil.WriteFile(tokens, []byte("mail:\n  name: Mail\n  secret: JBSWY3DPEHPK3PXP\n"), 0600); err != nil {
+		t.Fatalf("Unable to write source file: %s", err)
+	}
+
+	for _, c := range []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOutput []string
+	}{
+		{
+			name:       "good config",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid"},
+			wantOutput: []string{"Authentication: ok", "Keys found:     2", "OTP secrets:    1", "totp/userdata", "missing secret field"},
+		},
+		{
+			name:       "rejected token",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.expired"},
+			wantCode:   1,
+			wantOutput: []string{"Authentication: failed"},
+		},
+		{
+			name:       "unavailable Vault",
+			args:       []string{"--vault-addr", unavailable, "--cli-vault-token", "s.valid"},
+			wantCode:   1,
+			wantOutput: []string{"Authentication: failed"},
+		},
+		{
+			name:       "missing prefix",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--vault-prefix", "missing"},
+			wantCode:   1,
+			wantOutput: []string{"Listing:        failed"},
+		},
+		{
+			name:       "no usable secrets",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--vault-prefix", "broken"},
+			wantCode:   1,
+			wantOutput: []string{"OTP secrets:    0", "No usable OTP secrets found"},
+		},
+		{
+			name:       "listing with entries of unexpected type",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--vault-prefix", "mixed"},
+			wantOutput: []string{"OTP secrets:    1", "listing contains entry of unexpected type json.Number", "listing contains entry of unexpected type <nil>", "listing returned keys of unexpected type string"},
+		},
+		{
+			name:       "malformed secret",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--vault-prefix", "malformed"},
+			wantOutput: []string{"Keys found:     2", "OTP secrets:    1", "malformed/list", "secret data is not a map of fields"},
+		},
+		{
+			name:       "unexpected digits rejected",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--otp-expected-digits", "8", "--otp-digits-mismatch", "reject"},
+			wantCode:   1,
+			wantOutput: []string{"OTP secrets:    0", "totp/mail", "Token has 6 digits instead of the expected 8"},
+		},
+		{
+			name:       "unexpected digits warned",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--otp-expected-digits", "8"},
+			wantOutput: []string{"OTP secrets:    1"},
+		},
+		{
+			name:       "single key",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--vault-prefix", "totp/mail", "--vault-single-key"},
+			wantOutput: []string{"Keys found:     1", "OTP secrets:    1"},
+		},
+		{
+			name:       "single key without secret",
+			args:       []string{"--vault-addr", vault.URL, "--cli-vault-token", "s.valid", "--vault-prefix", "totp/userdata", "--vault-single-key"},
+			wantCode:   1,
+			wantOutput: []string{"OTP secrets:    0", "missing secret field"},
+		},
+		{
+			name:       "good source file",
+			args:       []string{"--source", "file", "--source-file", tokens},
+			wantOutput: []string{"OTP secrets:    1"},
+		},
+		{
+			name:       "missing source file",
+			args:       []string{"--source", "file", "--source-file", filepath.Join(dir, "missing.yaml")},
+			wantCode:   1,
+			wantOutput: []string{"Loading:        failed"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			withArgs(t, append(c.args, "validate"), nil, func(err error) {
+				if err != nil {
+					t.Fatalf("loadConfig() returned error: %s", err)
+				}
+
+				var code int
+				out := captureStdout(t, func() { code = runValidate() })
+
+				if code != c.wantCode {
+					t.Errorf("Expected exit code %d, got %d:\n%s", c.wantCode, code, out)
+				}
+				for _, want := range c.wantOutput {
+					if !strings.Contains(out, want) {
+						t.Errorf("Expected output to contain %q:\n%s", want, out)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// errRateLimited is returned for requests still rejected by the rate
+// limit quotas of Vault after all retries
+var errRateLimited = errors.New("Rate limited by Vault")
+
+// errKeyUnreadable marks keys skipped as reading them failed
+var errKeyUnreadable = errors.New("Unable to read key")
+
+// errMalformedSecret is returned for secrets whose data is not a map of
+// fields (i.e. a list produced by a broken write)
+var errMalformedSecret = errors.New("Secret data is not a map of fields")
+
+var (
+	vaultHeaders http.Header
+
+	// activeVault is the index of the address in vaultAddresses the last
+	// successful login happened against
+	activeVault int32
+)
+
+// vaultAddresses returns the configured Vault addresses in the order they
+// are tried in
+func vaultAddresses() []string {
+	return append([]string{cfg.Vault.Address}, cfg.Vault.FailoverAddresses...)
+}
+
+// newVaultClient creates a client for the active Vault including the
+// additional headers to pass through proxies in front of Vault
+func newVaultClient() (*api.Client, error) {
+	return newVaultClientFor(vaultAddresses()[atomic.LoadInt32(&activeVault)])
+}
+
+func newVaultClientFor(addr string) (*api.Client, error) {
+	conf := &api.Config{
+		Address: addr,
+	}
+
+	if cfg.Vault.ConsistencyRetries > 0 || cfg.Vault.RateLimitRetries > 0 || cfg.Vault.HTTPProxy != "" || clientCert != nil {
+		conf.HttpClient = api.DefaultConfig().HttpClient
+	}
+
+	if clientCert != nil {
+		transport, ok := conf.HttpClient.Transport.(*http.Transport)
+		if !ok {
+			return nil, errors.Errorf("Unable to set client certificate on transport of type %T", conf.HttpClient.Transport)
+		}
+		// Taken on every handshake so rotated certificates are used for
+		// new connections without recreating the clients
+		transport.TLSClientConfig.GetClientCertificate = clientCert.GetClientCertificate
+	}
+
+	if cfg.Vault.HTTPProxy != "" {
+		// Without a proxy configured the HTTP(S)_PROXY variables of the
+		// environment are used by the default transport
+		proxy, err := url.Parse(cfg.Vault.HTTPProxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to parse proxy URL")
+		}
+
+		transport, ok := conf.HttpClient.Transport.(*http.Transport)
+		if !ok {
+			return nil, errors.Errorf("Unable to set proxy on transport of type %T", conf.HttpClient.Transport)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.Vault.RateLimitRetries > 0 {
+		conf.HttpClient.Transport = &rateLimitTransport{next: conf.HttpClient.Transport}
+	}
+
+	if cfg.Vault.ConsistencyRetries > 0 {
+		conf.HttpClient.Transport = &consistencyTransport{addr: addr, next: conf.HttpClient.Transport}
+	}
+
+	client, err := api.NewClient(conf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vaultHeaders) > 0 {
+		client.SetHeaders(vaultHeaders)
+	}
+
+	return client, nil
+}
+
+// withVaultFailover executes the function against the configured Vault
+// addresses in order until one of them is available. The address used
+// successfully is stored to be used by subsequent operations. As tokens
+// are only valid in the cluster they were issued by, the function needs
+// to take care of the login.
+func withVaultFailover(fn func(client *api.Client) error) error {
+	var err error
+
+	for i, addr := range vaultAddresses() {
+		var client *api.Client
+		if client, err = newVaultClientFor(addr); err != nil {
+			return errors.Wrap(err, "Unable to create client")
+		}
+
+		if err = fn(client); err == nil {
+			if atomic.SwapInt32(&activeVault, int32(i)) != int32(i) {
+				log.WithField("vault_addr", addr).Info("Switched active Vault")
+			}
+			return nil
+		}
+
+		if !isVaultUnavailable(err) {
+			return err
+		}
+
+		log.WithError(err).WithField("vault_addr", addr).Warn("Vault is unavailable, trying next address")
+	}
+
+	return err
+}
+
+// isVaultUnavailable checks whether the error indicates the Vault could
+// not be reached or is not able to serve requests (sealed, standby, ...)
+// in contrast to rejecting the request
+func isVaultUnavailable(err error) bool {
+	switch errors.Cause(err) {
+	case errInvalidAccessToken:
+		// Rejected without contacting Vault
+		return false
+	case errTooManyTokens, context.Canceled, context.DeadlineExceeded:
+		// Vault answered but the scan was not successful
+		return false
+	}
+
+	if rerr, ok := errors.Cause(err).(*api.ResponseError); ok {
+		return rerr.StatusCode >= http.StatusInternalServerError
+	}
+	return err != nil
+}
+
+// parseVaultHeaders parses the headers given as "Name:Value". As the
+// values might contain credentials errors only refer to the position.
+func parseVaultHeaders(in []string) (http.Header, error) {
+	hdr := http.Header{}
+	for i, e := range in {
+		parts := strings.SplitN(e, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.Errorf("Invalid Vault header at position %d, expected Name:Value", i+1)
+		}
+		hdr.Add(textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1]))
+	}
+
+	return hdr, nil
+}
+
+// kvListPath translates the logical key into the path to list on the
+// configured KV engine
+func kvListPath(key string) string {
+	return kvPath(key, "metadata")
+}
+
+// kvReadPath translates the logical key into the path to read / write
+// on the configured KV engine
+func kvReadPath(key string) string {
+	return kvPath(key, "data")
+}
+
+// isKV2Key checks whether the key is stored in the configured KV v2
+// engine. Keys outside of it (like the cubbyhole) are used as they are.
+func isKV2Key(key string) bool {
+	mount := strings.Trim(cfg.Vault.KV2Mount, "/")
+	if mount == "" {
+		return false
+	}
+
+	key = strings.Trim(key, "/")
+	return key == mount || strings.HasPrefix(key, mount+"/")
+}
+
+func kvPath(key, kind string) string {
+	if !isKV2Key(key) {
+		// KV v1 / TOTP backend / cubbyhole: Paths are used as they are
+		return key
+	}
+
+	mount := strings.Trim(cfg.Vault.KV2Mount, "/")
+	key = strings.Trim(key, "/")
+	if key == mount {
+		return path.Join(mount, kind)
+	}
+
+	return path.Join(mount, kind, strings.TrimPrefix(key, mount+"/"))
+}
+
+// kvData extracts the secret data from a read response and unwraps the
+// response of a KV v2 engine. Deleted reports a soft-deleted or
+// destroyed version of a KV v2 secret.
+func kvData(key string, sec *api.Secret) (data map[string]interface{}, deleted bool) {
+	if sec == nil {
+		return nil, false
+	}
+
+	if !isKV2Key(key) {
+		return sec.Data, false
+	}
+
+	if meta, ok := sec.Data["metadata"].(map[string]interface{}); ok {
+		if dt, _ := meta["deletion_time"].(string); dt != "" {
+			deleted = true
+		}
+		if d, _ := meta["destroyed"].(bool); d {
+			deleted = true
+		}
+	}
+
+	data, _ = sec.Data["data"].(map[string]interface{})
+	return data, deleted
+}
+
+// kvMalformed checks whether the read response of a KV v2 secret carries
+// data which is not a map of fields
+func kvMalformed(key string, sec *api.Secret) bool {
+	if sec == nil || !isKV2Key(key) || sec.Data["data"] == nil {
+		return false
+	}
+
+	_, ok := sec.Data["data"].(map[string]interface{})
+	return !ok
+}
+
+// kvCreatedTime extracts the creation time of the secret version from a
+// KV v2 read response. For other engines the zero time is returned.
+func kvCreatedTime(key string, sec *api.Secret) time.Time {
+	if sec == nil || !isKV2Key(key) {
+		return time.Time{}
+	}
+
+	meta, _ := sec.Data["metadata"].(map[string]interface{})
+	ct, _ := meta["created_time"].(string)
+
+	t, _ := time.Parse(time.RFC3339Nano, ct)
+	return t
+}
+
+// kvVersion extracts the version of the secret from a KV v2 read response
+// to write the next version using check-and-set. For other engines 0 is
+// returned.
+func kvVersion(key string, sec *api.Secret) int64 {
+	if sec == nil || !isKV2Key(key) {
+		return 0
+	}
+
+	meta, _ := sec.Data["metadata"].(map[string]interface{})
+	v, _ := strconv.ParseInt(fieldString(meta["version"]), 10, 64)
+	return v
+}
+
+// logicalRequest reads or lists (list set) the given path like the
+// Logical() helpers of the client do but passes the context on to the
+// transport
+func logicalRequest(ctx context.Context, client *api.Client, p string, list bool) (*api.Secret, error) {
+	r := client.NewRequest(http.MethodGet, "/v1/"+p)
+	if list {
+		r.Params.Set("list", "true")
+	}
+
+	resp, err := client.RawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		// The client treats 429 as success as it is used by the health
+		// endpoint of standby nodes
+		return nil, errRateLimited
+	}
+
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		// Missing paths are no error unless the response carries data
+		secret, parseErr := api.ParseSecret(resp.Body)
+		switch {
+		case parseErr == io.EOF:
+			return nil, nil
+		case parseErr != nil:
+			return nil, err
+		case secret != nil && (len(secret.Warnings) > 0 || len(secret.Data) > 0):
+			return secret, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := api.ParseSecret(resp.Body)
+	if _, ok := err.(*json.UnmarshalTypeError); ok {
+		return nil, errMalformedSecret
+	}
+
+	return secret, err
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestParseVaultHeaders(t *testing.T) {
+	for _, c := range []struct {
+		in      []string
+		want    http.Header
+		wantErr bool
+	}{
+		{in: nil, want: http.Header{}},
+		{in: []string{"x-tenant: team-a"}, want: http.Header{"X-Tenant": {"team-a"}}},
+		// Values may contain colons, repeated names add values
+		{in: []string{"X-Forwarded-For:10.0.0.1", "X-Trace: a:b", "x-forwarded-for:10.0.0.2"}, want: http.Header{
+			"X-Forwarded-For": {"10.0.0.1", "10.0.0.2"},
+			"X-Trace":         {"a:b"},
+		}},
+		{in: []string{"X-Empty:"}, want: http.Header{"X-Empty": {""}}},
+		{in: []string{"X-Tenant"}, wantErr: true},
+		{in: []string{" :value"}, wantErr: true},
+	} {
+		got, err := parseVaultHeaders(c.in)
+		if (err != nil) != c.wantErr || (!c.wantErr && !reflect.DeepEqual(got, c.want)) {
+			t.Errorf("parseVaultHeaders(%q) = %v, %v, expected %v (error %v)", c.in, got, err, c.want, c.wantErr)
+		}
+	}
+}
+
+func TestNewVaultClientHeaders(t *testing.T) {
+	var got http.Header
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"data":{}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg, oldHeaders := cfg, vaultHeaders
+	defer func() { cfg, vaultHeaders = oldCfg, oldHeaders }()
+	cfg.Vault.Address = vault.URL
+	vaultHeaders = http.Header{"X-Tenant": {"team-a"}}
+
+	client, err := newVaultClient()
+	if err != nil {
+		t.Fatalf("Unable to create client: %s", err)
+	}
+	client.SetToken("s.user")
+
+	if _, err := client.Logical().Read("totp/mail"); err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+
+	// The headers are sent in addition to the ones of the client
+	if got.Get("X-Tenant") != "team-a" || got.Get("X-Vault-Token") != "s.user" {
+		t.Errorf("Expected the tenant and token headers, got %v", got)
+	}
+}
+
+func TestNewVaultClientProxy(t *testing.T) {
+	var viaVault, viaProxy int32
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&viaVault, 1)
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"data":{}}`))
+	}))
+	defer vault.Close()
+
+	// The proxy answers itself instead of forwarding the request, the
+	// proxied request carries the address of Vault
+	proxy := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		if "http://"+r.URL.Host != vault.URL {
+			http.Error(res, `{"errors":["unexpected target"]}`, http.StatusBadGateway)
+			return
+		}
+		atomic.AddInt32(&viaProxy, 1)
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"data":{}}`))
+	}))
+	defer proxy.Close()
+
+	for _, c := range []struct {
+		name      string
+		proxy     string
+		retries   int
+		wantProxy bool
+	}{
+		{name: "direct"},
+		{name: "proxy", proxy: proxy.URL, wantProxy: true},
+		{name: "proxy with retrying transports", proxy: proxy.URL, retries: 2, wantProxy: true},
+		// The proxy must not stick to the transport of other clients
+		{name: "direct after proxy"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCfg := cfg
+			defer func() { cfg = oldCfg }()
+			cfg.Vault.Address = vault.URL
+			cfg.Vault.HTTPProxy = c.proxy
+			cfg.Vault.ConsistencyRetries = c.retries
+			cfg.Vault.RateLimitRetries = c.retries
+
+			atomic.StoreInt32(&viaVault, 0)
+			atomic.StoreInt32(&viaProxy, 0)
+
+			client, err := newVaultClient()
+			if err != nil {
+				t.Fatalf("Unable to create client: %s", err)
+			}
+			if _, err := client.Logical().Read("totp/mail"); err != nil {
+				t.Fatalf("Request failed: %s", err)
+			}
+
+			if p, v := atomic.LoadInt32(&viaProxy), atomic.LoadInt32(&viaVault); (p == 1) != c.wantProxy || p+v != 1 {
+				t.Errorf("Expected the request through the proxy %v, got %d proxied and %d direct requests", c.wantProxy, p, v)
+			}
+		})
+	}
+}
+
+func TestKVPath(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	for _, c := range []struct {
+		mount, key string
+		wantList   string
+		wantRead   string
+	}{
+		{mount: "", key: "totp/mail", wantList: "totp/mail", wantRead: "totp/mail"},
+		{mount: "secret", key: "secret/totp/mail", wantList: "secret/metadata/totp/mail", wantRead: "secret/data/totp/mail"},
+		{mount: "/secret/", key: "/secret/totp/", wantList: "secret/metadata/totp", wantRead: "secret/data/totp"},
+		{mount: "secret", key: "secret", wantList: "secret/metadata", wantRead: "secret/data"},
+		// Keys outside of the engine are used as they are
+		{mount: "secret", key: "cubbyhole/totp", wantList: "cubbyhole/totp", wantRead: "cubbyhole/totp"},
+	} {
+		cfg.Vault.KV2Mount = c.mount
+		if got := kvListPath(c.key); got != c.wantList {
+			t.Errorf("kvListPath(%q) on mount %q = %q, expected %q", c.key, c.mount, got, c.wantList)
+		}
+		if got := kvReadPath(c.key); got != c.wantRead {
+			t.Errorf("kvReadPath(%q) on mount %q = %q, expected %q", c.key, c.mount, got, c.wantRead)
+		}
+	}
+}
+
+func TestKVData(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	fields := map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"}
+	v2 := func(meta map[string]interface{}, data interface{}) *api.Secret {
+		return &api.Secret{Data: map[string]interface{}{"data": data, "metadata": meta}}
+	}
+
+	for _, c := range []struct {
+		name        string
+		mount       string
+		sec         *api.Secret
+		wantData    bool
+		wantDeleted bool
+	}{
+		{name: "missing secret", mount: "secret"},
+		{name: "KV v1", sec: &api.Secret{Data: fields}, wantData: true},
+		{name: "KV v2", mount: "secret", sec: v2(map[string]interface{}{"deletion_time": "", "destroyed": false}, fields), wantData: true},
+		{name: "soft deleted", mount: "secret", sec: v2(map[string]interface{}{"deletion_time": "2020-01-01T00:00:00Z"}, nil), wantDeleted: true},
+		{name: "destroyed", mount: "secret", sec: v2(map[string]interface{}{"destroyed": true}, nil), wantDeleted: true},
+	} {
+		cfg.Vault.KV2Mount = c.mount
+
+		data, deleted := kvData("secret/totp/mail", c.sec)
+		if (data != nil) != c.wantData || deleted != c.wantDeleted {
+			t.Errorf("%s: Expected data=%v deleted=%v, got %v / %v", c.name, c.wantData, c.wantDeleted, data, deleted)
+		}
+		if c.wantData && data["secret"] != "JBSWY3DPEHPK3PXP" {
+			t.Errorf("%s: Expected the fields of the secret, got %v", c.name, data)
+		}
+	}
+}
+
+func TestKVMalformed(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	v2 := func(data interface{}) *api.Secret {
+		return &api.Secret{Data: map[string]interface{}{"data": data, "metadata": map[string]interface{}{}}}
+	}
+
+	for _, c := range []struct {
+		name  string
+		mount string
+		sec   *api.Secret
+		want  bool
+	}{
+		{name: "missing secret", mount: "secret"},
+		{name: "fields", mount: "secret", sec: v2(map[string]interface{}{"secret": "JBSWY3DPEHPK3PXP"})},
+		{name: "deleted", mount: "secret", sec: v2(nil)},
+		{name: "list", mount: "secret", sec: v2([]interface{}{"JBSWY3DPEHPK3PXP"}), want: true},
+		{name: "string", mount: "secret", sec: v2("JBSWY3DPEHPK3PXP"), want: true},
+		// KV v1 data is always a map, a data field is just a field
+		{name: "KV v1", sec: &api.Secret{Data: map[string]interface{}{"data": []interface{}{"a"}}}},
+	} {
+		cfg.Vault.KV2Mount = c.mount
+		if got := kvMalformed("secret/totp/mail", c.sec); got != c.want {
+			t.Errorf("%s: Expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestKVCreatedTime(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	withMeta := func(meta interface{}) *api.Secret {
+		return &api.Secret{Data: map[string]interface{}{"data": map[string]interface{}{}, "metadata": meta}}
+	}
+
+	for _, c := range []struct {
+		name  string
+		mount string
+		sec   *api.Secret
+		want  time.Time
+	}{
+		{name: "KV v2", mount: "secret", sec: withMeta(map[string]interface{}{"created_time": "2020-01-02T03:04:05.123456Z"}),
+			want: time.Date(2020, 1, 2, 3, 4, 5, 123456000, time.UTC)},
+		{name: "KV v1", sec: withMeta(map[string]interface{}{"created_time": "2020-01-02T03:04:05Z"})},
+		{name: "missing secret", mount: "secret"},
+		{name: "missing metadata", mount: "secret", sec: withMeta(nil)},
+		{name: "malformed time", mount: "secret", sec: withMeta(map[string]interface{}{"created_time": "yesterday"})},
+	} {
+		cfg.Vault.KV2Mount = c.mount
+		if got := kvCreatedTime("secret/totp/mail", c.sec); !got.Equal(c.want) {
+			t.Errorf("%s: Expected %s, got %s", c.name, c.want, got)
+		}
+	}
+}
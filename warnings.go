@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// minDigits and maxDigits limit the length of the codes, digits
+	// outside are clamped
+	minDigits = 4
+	maxDigits = 10
+)
+
+// tokenWarning is a non-fatal problem of a token returned alongside the
+// tokens to be displayed to the user
+type tokenWarning struct {
+	Name    string `json:"name"`
+	Warning string `json:"warning"`
+}
+
+// warn logs the message and keeps it (including the error of the entry)
+// as a warning of the token
+func (t *token) warn(entry *log.Entry, msg string) {
+	entry.Warn(msg)
+
+	if err, ok := entry.Data[log.ErrorKey]; ok {
+		msg = fmt.Sprintf("%s: %v", msg, err)
+	}
+	t.Warnings = append(t.Warnings, msg)
+}
+
+// clampDigits limits the digits of the token to the supported range
+func (t *token) clampDigits(entry *log.Entry) {
+	if t.Digits == 0 || (t.Digits >= minDigits && t.Digits <= maxDigits) {
+		return
+	}
+
+	clamped := minDigits
+	if t.Digits > maxDigits {
+		clamped = maxDigits
+	}
+
+	t.warn(entry.WithFields(log.Fields{"digits": t.Digits, "clamped": clamped}),
+		fmt.Sprintf("Digits %d out of range (%d-%d), using %d", t.Digits, minDigits, maxDigits, clamped))
+	t.Digits = clamped
+}
+
+// skipWarning returns the warning for a key skipped during the scan. The
+// details of the error stay in the failures as they might contain
+// details of the Vault setup. Keys without OTP fields are expected in
+// shared prefixes and not worth a warning.
+func skipWarning(key string, err error) (tokenWarning, bool) {
+	var msg string
+	switch errors.Cause(err) {
+	case errNoOTPFields:
+		return tokenWarning{}, false
+	case errEmptySecret:
+		msg = "Skipped key with empty secret field"
+	case errMalformedSecret:
+		msg = "Skipped key with malformed data"
+	case errKeyUnreadable:
+		msg = "Skipped key which could not be read"
+	default:
+		msg = "Skipped key which was rejected"
+	}
+
+	return tokenWarning{Name: key, Warning: msg}, true
+}
+
+// sortWarnings orders the warnings by name as they are collected in the
+// order the keys are processed in
+func sortWarnings(w []tokenWarning) {
+	sort.SliceStable(w, func(i, j int) bool { return w[i].Name < w[j].Name })
+}
+
+// warningsFor combines the warnings of the scan with the ones of the
+// tokens returned from it
+func (s *scanResult) warningsFor(tokens tokenList) []tokenWarning {
+	return append(append([]tokenWarning{}, s.Warnings...), tokens.Warnings()...)
+}
+
+func (w tokenWarning) String() string {
+	if w.Name == "" {
+		return w.Warning
+	}
+	return fmt.Sprintf("%s: %s", w.Name, w.Warning)
+}
+
+// Warnings collects the warnings of all tokens
+func (t tokenList) Warnings() []tokenWarning {
+	out := []tokenWarning{}
+	for _, tok := range t {
+		for _, w := range tok.Warnings {
+			out = append(out, tokenWarning{Name: tok.Name, Warning: w})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type identity struct {
+	DisplayName string            `json:"display_name,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"` // i.e. org / username set by the Github login
+	Policies    []string          `json:"policies"`
+}
+
+// tokenIdentity looks up the identity the token was issued for
+func tokenIdentity(tok string) (*identity, error) {
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create client")
+	}
+
+	client.SetToken(tok)
+	s, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to lookup token")
+	}
+
+	id := &identity{Metadata: map[string]string{}}
+	if id.Policies, err = s.TokenPolicies(); err != nil {
+		return nil, errors.Wrap(err, "Unable to read token policies")
+	}
+	sort.Strings(id.Policies)
+
+	id.DisplayName, _ = s.Data["display_name"].(string)
+
+	meta, _ := s.Data["meta"].(map[string]interface{})
+	for k, v := range meta {
+		if v != nil {
+			id.Metadata[k] = fmt.Sprint(v)
+		}
+	}
+
+	return id, nil
+}
+
+func handleWhoami(res http.ResponseWriter, r *http.Request) {
+	_, tok, ok := getVaultToken(res, r)
+	if !ok {
+		return
+	}
+
+	id := &identity{Policies: []string{}}
+	if cfg.Source == sourceVault && tok != "" {
+		var err error
+		if id, err = tokenIdentity(tok); err != nil {
+			log.WithFields(log.Fields{"token": hashSecret(tok)}).Errorf("Unable to lookup identity: %s", err)
+			http.Error(res, `{"error":"Unexpected error while looking up identity"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(res).Encode(id)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestHandleWhoami(t *testing.T) {
+	var lookupFails bool
+
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/auth/token/create/r":
+			res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+		case "/v1/auth/token/lookup-self":
+			if lookupFails {
+				http.Error(res, `{"errors":["permission denied"]}`, http.StatusForbidden)
+				return
+			}
+			res.Write([]byte(`{"data":{
+				"display_name": "github-jdoe",
+				"meta": {"org": "binlabnet", "username": "jdoe", "team": null},
+				"policies": ["totp-read", "default"]
+			}}`))
+		default:
+			http.NotFound(res, r)
+		}
+	}))
+	defer vault.Close()
+
+	withArgs(t, []string{
+		"--vault-addr", vault.URL, "--vault-rate-limit-retries", "0",
+		"--auth-mode", "proxy", "--auth-proxy-vault-token", "x", "--auth-proxy-token-role", "r",
+	}, nil, func(err error) {
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %s", err)
+		}
+
+		oldStore := cookieStore
+		defer func() { cookieStore = oldStore }()
+		cookieStore = sessions.NewCookieStore(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+
+		for _, c := range []struct {
+			name        string
+			lookupFails bool
+			wantStatus  int
+			want        identity
+		}{
+			{
+				name:       "login metadata",
+				wantStatus: http.StatusOK,
+				want: identity{
+					DisplayName: "github-jdoe",
+					Metadata:    map[string]string{"org": "binlabnet", "username": "jdoe"},
+					Policies:    []string{"default", "totp-read"},
+				},
+			},
+			{name: "lookup failing", lookupFails: true, wantStatus: http.StatusInternalServerError},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				lookupFails = c.lookupFails
+
+				r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+				r.RemoteAddr = "127.0.0.1:42424"
+				r.Header.Set(cfg.Auth.ProxyHeader, "jdoe")
+				res := httptest.NewRecorder()
+
+				handleWhoami(res, r)
+
+				if res.Code != c.wantStatus {
+					t.Fatalf("Expected status %d, got %d: %s", c.wantStatus, res.Code, res.Body.String())
+				}
+				if c.wantStatus != http.StatusOK {
+					return
+				}
+
+				var got identity
+				if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+					t.Fatalf("Unable to decode response: %s", err)
+				}
+				if !reflect.DeepEqual(got, c.want) {
+					t.Errorf("Expected identity %+v, got %+v", c.want, got)
+				}
+			})
+		}
+	})
+}
+
+func TestLoginGithubAuthMount(t *testing.T) {
+	var logins []string
+	vault := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		logins = append(logins, r.URL.Path)
+		res.Write([]byte(`{"auth":{"client_token":"s.user"}}`))
+	}))
+	defer vault.Close()
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg.Vault.Address = vault.URL
+	cfg.Github.LoginTimeout = time.Second
+	cfg.Github.MaxTokenLength = 0
+
+	for _, c := range []struct {
+		mount string
+		want  string
+	}{
+		{mount: "github", want: "/v1/auth/github/login"},
+		{mount: "/github-corp/", want: "/v1/auth/github-corp/login"},
+		{mount: "teams/github", want: "/v1/auth/teams/github/login"},
+	} {
+		cfg.Github.AuthMount = c.mount
+		logins = nil
+
+		if tok, err := loginGithub("", "gh-token"); err != nil || tok != "s.user" {
+			t.Errorf("%s: Expected the token of the login, got %q / %v", c.mount, tok, err)
+		}
+		if !reflect.DeepEqual(logins, []string{c.want}) {
+			t.Errorf("%s: Expected login at %s, got %v", c.mount, c.want, logins)
+		}
+	}
+}